@@ -17,29 +17,325 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// FederatedIdentity identifies a user account at an external identity provider
+type FederatedIdentity struct {
+	// ProviderName is the name of the identity provider configured in the user pool
+	ProviderName string `json:"providerName"`
+
+	// ProviderUserID is the user's subject at the identity provider
+	ProviderUserID string `json:"providerUserId"`
+}
+
+// UserAddress is a postal address, stored in the address attribute in the
+// JSON shape defined by OpenID Connect
+type UserAddress struct {
+	// Formatted is the full address for display, lines separated by "\n"
+	// +optional
+	Formatted string `json:"formatted,omitempty"`
+
+	// StreetAddress is the street, house number and further address lines
+	// +optional
+	StreetAddress string `json:"streetAddress,omitempty"`
+
+	// Locality is the city or locality
+	// +optional
+	Locality string `json:"locality,omitempty"`
+
+	// Region is the state, province or region
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// PostalCode is the zip or postal code
+	// +optional
+	PostalCode string `json:"postalCode,omitempty"`
+
+	// Country is the country name or code
+	// +optional
+	Country string `json:"country,omitempty"`
+}
+
+// AttributeSource sets an attribute from a key of a Secret or ConfigMap in
+// the User's namespace. Exactly one of SecretKeyRef and ConfigMapKeyRef must
+// be set.
+type AttributeSource struct {
+	// Name is the logical attribute name, as used in spec.attributes
+	Name string `json:"name"`
+
+	// SecretKeyRef selects a key of a Secret. Values read from Secrets are
+	// never logged or written to the status.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
 // UserSpec defines the desired state of User.
 type UserSpec struct {
 	// Email is the user's email address
 	Email string `json:"email,omitempty"`
 
-	// Enabled indicates whether the user is enabled
-	Enabled bool `json:"enabled,omitempty"`
+	// EmailVerified marks the email as verified in the user pool. When unset,
+	// the controller's default applies.
+	// +optional
+	EmailVerified *bool `json:"emailVerified,omitempty"`
+
+	// SecondaryEmail is an additional contact email, e.g. a personal address,
+	// stored in the custom:secondaryEmail attribute. It is not used to sign
+	// in and is never verified. Unset leaves the attribute unchanged.
+	// +optional
+	SecondaryEmail string `json:"secondaryEmail,omitempty"`
+
+	// PreferredUsername is the name applications display, stored in the
+	// preferred_username attribute. It is separate from the username users
+	// sign in with. Unset leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	PreferredUsername string `json:"preferredUsername,omitempty"`
+
+	// Locale is the user's BCP 47 language tag, e.g. "en-US", stored in the
+	// locale attribute. Cognito messages can be localized with it. Unset
+	// leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Locale string `json:"locale,omitempty"`
+
+	// Address is the user's postal address, stored as JSON in the address
+	// attribute. Unset leaves the attribute unmanaged.
+	// +optional
+	Address *UserAddress `json:"address,omitempty"`
+
+	// Picture is the URL of the user's avatar, stored in the picture
+	// attribute. It must be an absolute http or https URL. Unset leaves the
+	// attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Picture string `json:"picture,omitempty"`
+
+	// Profile is the URL of the user's profile page, stored in the profile
+	// attribute. It must be an absolute http or https URL. Unset leaves the
+	// attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Profile string `json:"profile,omitempty"`
+
+	// Nickname is a casual name of the user, stored in the nickname
+	// attribute. Unset leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Nickname string `json:"nickname,omitempty"`
+
+	// Website is the URL of the user's web page or blog, stored in the
+	// website attribute. It must be an absolute http or https URL. Unset
+	// leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Website string `json:"website,omitempty"`
+
+	// ZoneInfo is the user's IANA time zone, e.g. "Europe/Paris", stored in
+	// the zoneinfo attribute. Unset leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	ZoneInfo string `json:"zoneInfo,omitempty"`
+
+	// Birthdate is the user's date of birth as YYYY-MM-DD, or 0000-MM-DD
+	// without the year, stored in the birthdate attribute. Unset leaves the
+	// attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`
+	Birthdate string `json:"birthdate,omitempty"`
+
+	// Gender is the user's gender, e.g. "female" or "male", stored in the
+	// gender attribute. Unset leaves the attribute unmanaged.
+	// +optional
+	// +kubebuilder:validation:MaxLength=2048
+	Gender string `json:"gender,omitempty"`
+
+	// Enabled indicates whether the user is enabled. An unset value is treated
+	// as disabled unless the defaulting webhook sets it.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// DisableReason records why the user is disabled, e.g. "offboarded" or
+	// "security-hold". It is written to the user pool while enabled is false
+	// and cleared when the user is enabled again.
+	// +optional
+	DisableReason string `json:"disableReason,omitempty"`
+
+	// ExpiresAt disables the user once it has passed, e.g. at the end of a
+	// contract, and signs the user out of all sessions. The user stays
+	// disabled until it is moved into the future or removed. The
+	// disableReason is written as for disabled users.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Confirmed requests that an unconfirmed user is confirmed by the controller
+	// instead of through a verification link
+	// +optional
+	Confirmed bool `json:"confirmed,omitempty"`
+
+	// Attributes holds additional user attributes keyed by their logical name.
+	// The controller maps logical names to the attribute names used by the
+	// user pool.
+	// +optional
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// AttributesFrom sets attributes from Secrets and ConfigMaps, keeping
+	// sensitive values out of the User. They take precedence over
+	// spec.attributes.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	AttributesFrom []AttributeSource `json:"attributesFrom,omitempty"`
+
+	// RemoveAttributes lists attributes, by logical name, that must be absent
+	// from the pool user. The controller deletes the ones present. They cannot
+	// also be set in spec.attributes or spec.attributesFrom.
+	// +optional
+	// +listType=set
+	RemoveAttributes []string `json:"removeAttributes,omitempty"`
+
+	// FederatedIdentities are external identity provider accounts linked to
+	// the user so the user can sign in through them
+	// +optional
+	FederatedIdentities []FederatedIdentity `json:"federatedIdentities,omitempty"`
+
+	// Groups are the user pool groups the user is a member of. Only
+	// memberships added through this field are removed again, memberships
+	// managed elsewhere are left alone.
+	// +optional
+	// +listType=set
+	Groups []string `json:"groups,omitempty"`
+
+	// GenerateUsername creates the pool user with a generated username
+	// instead of the object name. Requires email. The generated username is
+	// recorded in status.username.
+	// +optional
+	GenerateUsername bool `json:"generateUsername,omitempty"`
+
+	// Roles are high-level roles expanded into user pool group memberships by
+	// the controller's role mapping. The expanded groups are managed like
+	// spec.groups.
+	// +optional
+	// +listType=set
+	Roles []string `json:"roles,omitempty"`
+
+	// MFAMethod is the user's preferred MFA method. The user pool must have
+	// MFA turned on or optional. Unset leaves the preference unmanaged.
+	// +optional
+	// +kubebuilder:validation:Enum=SOFTWARE_TOKEN_MFA;SMS_MFA
+	MFAMethod string `json:"mfaMethod,omitempty"`
+
+	// Invitation decides whether the user pool sends the invitation message
+	// with the temporary password when the user is created: Suppress sends
+	// none, Send sends it once, and Resend also sends it again when the
+	// temporary password expires before the user signs in. Users created
+	// disabled are never sent an invitation. Unset means Suppress.
+	// +optional
+	// +kubebuilder:validation:Enum=Suppress;Send;Resend
+	Invitation string `json:"invitation,omitempty"`
+
+	// ForcePasswordChange makes a user with a permanent password choose a
+	// new one at the next sign-in. The password is replaced by a temporary
+	// one sent in the invitation message, once: set it to false and back to
+	// true to force another change.
+	// +optional
+	ForcePasswordChange bool `json:"forcePasswordChange,omitempty"`
 }
 
+// Invitation policies of spec.invitation
+const (
+	InvitationSuppress = "Suppress"
+	InvitationSend     = "Send"
+	InvitationResend   = "Resend"
+)
+
+// ConditionTypeReady indicates whether the User is in sync with the user pool
+const ConditionTypeReady = "Ready"
+
 // UserStatus defines the observed state of User.
 type UserStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Username is the username assigned in the user pool when
+	// spec.generateUsername is set
+	// +optional
+	Username string `json:"username,omitempty"`
+
+	// Groups are the group memberships added from spec.groups and spec.roles
+	// +optional
+	// +listType=set
+	Groups []string `json:"groups,omitempty"`
+
+	// ObservedGeneration is the generation last synced to the user pool
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// CognitoStatus is the account status reported by the user pool, e.g.
+	// CONFIRMED or FORCE_CHANGE_PASSWORD
+	// +optional
+	CognitoStatus string `json:"cognitoStatus,omitempty"`
+
+	// EmailVerified reports whether the user pool considers the email verified
+	// +optional
+	EmailVerified *bool `json:"emailVerified,omitempty"`
+
+	// PhoneVerified reports whether the user pool considers the phone number
+	// verified
+	// +optional
+	PhoneVerified *bool `json:"phoneVerified,omitempty"`
+
+	// MFAMethod is the user's preferred MFA method, empty when MFA is not set up
+	// +optional
+	MFAMethod string `json:"mfaMethod,omitempty"`
+
+	// TemporaryPasswordExpiresAt is when the temporary password of a user
+	// that never signed in expires. It is empty once the password was changed.
+	// +optional
+	TemporaryPasswordExpiresAt *metav1.Time `json:"temporaryPasswordExpiresAt,omitempty"`
+
+	// InvitationResentAt is when the invitation was last sent again because
+	// the temporary password had expired or a password change was forced
+	// +optional
+	InvitationResentAt *metav1.Time `json:"invitationResentAt,omitempty"`
+
+	// PasswordChangeForcedAt is when spec.forcePasswordChange was handled:
+	// when the password was replaced, or when the user already had to
+	// choose a new one. The user's change is pending while cognitoStatus is
+	// FORCE_CHANGE_PASSWORD.
+	// +optional
+	PasswordChangeForcedAt *metav1.Time `json:"passwordChangeForcedAt,omitempty"`
+
+	// CredentialsRotation is the value of the last completed
+	// kcp.cogniteo.io/rotate-credentials request
+	// +optional
+	CredentialsRotation string `json:"credentialsRotation,omitempty"`
+
+	// CredentialsRotatedAt is when the last credential rotation completed
+	// +optional
+	CredentialsRotatedAt *metav1.Time `json:"credentialsRotatedAt,omitempty"`
+
+	// Conditions represent the latest available observations of the User's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Email",type=string,JSONPath=`.spec.email`
+// +kubebuilder:printcolumn:name="Cognito Status",type=string,JSONPath=`.status.cognitoStatus`
+// +kubebuilder:printcolumn:name="Email Verified",type=boolean,JSONPath=`.status.emailVerified`
+// +kubebuilder:printcolumn:name="MFA",type=string,JSONPath=`.status.mfaMethod`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // User is the Schema for the users API.
 type User struct {
@@ -0,0 +1,109 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UserSetMember is a user managed through a UserSet
+type UserSetMember struct {
+	// Username is the username in the user pool
+	Username string `json:"username"`
+
+	// Email is the user's email address
+	// +optional
+	Email string `json:"email,omitempty"`
+
+	// Enabled indicates whether the user is enabled. Unset means enabled.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Attributes holds additional user attributes keyed by their logical name
+	// +optional
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// UserSetSpec defines the desired state of UserSet.
+type UserSetSpec struct {
+	// Users are the users managed by the set. Users removed from the list are
+	// deleted from the user pool.
+	// +optional
+	// +listType=map
+	// +listMapKey=username
+	Users []UserSetMember `json:"users,omitempty"`
+}
+
+// UserSetMemberStatus reports the sync state of a single user of a UserSet
+type UserSetMemberStatus struct {
+	// Username is the username in the user pool
+	Username string `json:"username"`
+
+	// Synced reports whether the user pool matches the spec for this user
+	Synced bool `json:"synced"`
+
+	// Message describes why the last sync of the user failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// UserSetStatus defines the observed state of UserSet.
+type UserSetStatus struct {
+	// ObservedGeneration is the generation last synced to the user pool
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Users reports the sync state of every user managed by the set
+	// +optional
+	// +listType=map
+	// +listMapKey=username
+	Users []UserSetMemberStatus `json:"users,omitempty"`
+
+	// Conditions represent the latest available observations of the UserSet's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// UserSet is the Schema for the usersets API. It manages many user pool users
+// from a single resource.
+type UserSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   UserSetSpec   `json:"spec,omitempty"`
+	Status UserSetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// UserSetList contains a list of UserSet.
+type UserSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []UserSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&UserSet{}, &UserSetList{})
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command backup-users writes every user of a user pool with its group
+// memberships to stdout as newline-delimited JSON, or restores such a backup
+// with --restore.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func main() {
+	var userPoolID string
+	var attributeMapping string
+	var customAttributePrefix string
+	var region string
+	var restore string
+	flag.StringVar(&userPoolID, "cognito-user-pool-id", "", "The AWS Cognito User Pool ID. Required.")
+	flag.StringVar(&attributeMapping, "cognito-attribute-mapping", "",
+		"Comma-separated logical=pool attribute name pairs, as passed to the controller.")
+	flag.StringVar(&customAttributePrefix, "cognito-custom-attribute-prefix", "",
+		"Prefix of the pool names of unmapped custom attributes, as passed to the controller.")
+	flag.StringVar(&region, "cognito-region", "",
+		"Region of the Cognito endpoint. Defaults to the AWS configuration, then to the user pool ID's region.")
+	flag.StringVar(&restore, "restore", "",
+		"Restore the users of this backup file instead of writing a backup.")
+	flag.Parse()
+
+	if err := run(context.Background(), userPoolID, attributeMapping, customAttributePrefix, region, restore); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, userPoolID, attributeMapping, customAttributePrefix, region, restore string) error {
+	if userPoolID == "" {
+		return fmt.Errorf("--cognito-user-pool-id is required")
+	}
+	mapping, err := cognito.ParseAttributeMapping(attributeMapping)
+	if err != nil {
+		return fmt.Errorf("invalid attribute mapping: %w", err)
+	}
+	pool, err := cognito.NewAWSClient(ctx, userPoolID,
+		cognito.WithAttributeMapping(mapping),
+		cognito.WithCustomAttributePrefix(customAttributePrefix),
+		cognito.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("failed to create Cognito client: %w", err)
+	}
+
+	if restore != "" {
+		f, err := os.Open(restore)
+		if err != nil {
+			return fmt.Errorf("failed to open backup: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		result, err := pool.ImportFromBackup(ctx, f)
+		fmt.Fprintf(os.Stderr, "Restored %d users\n", result.Count(userpool.BulkSucceeded))
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	count, err := pool.ExportUsers(ctx, w)
+	if flushErr := w.Flush(); err == nil && flushErr != nil {
+		err = fmt.Errorf("failed to write backup: %w", flushErr)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d users\n", count)
+	return err
+}
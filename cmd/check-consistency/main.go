@@ -0,0 +1,157 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command check-consistency compares the Users and UserSets in the workspace
+// of the current kubeconfig context with the user pool and prints the
+// differences as JSON. It changes neither side.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/internal/controller"
+	"piotrjanik.dev/users/pkg/cognito"
+)
+
+// keyValueFlag is a repeatable flag collecting key=value pairs
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[k] = v
+	return nil
+}
+
+func main() {
+	var userPoolID string
+	var attributeMapping string
+	var customAttributePrefix string
+	var region string
+	var emailVerifiedUnmanaged bool
+	var enrichmentURL string
+	attributeTemplates := keyValueFlag{}
+	flag.StringVar(&userPoolID, "cognito-user-pool-id", "", "The AWS Cognito User Pool ID. Required.")
+	flag.StringVar(&attributeMapping, "cognito-attribute-mapping", "",
+		"Comma-separated logical=pool attribute name pairs, as passed to the controller.")
+	flag.StringVar(&customAttributePrefix, "cognito-custom-attribute-prefix", "",
+		"Prefix of the pool names of unmapped custom attributes, as passed to the controller.")
+	flag.StringVar(&region, "cognito-region", "",
+		"Region of the Cognito endpoint. Defaults to the AWS configuration, then to the user pool ID's region.")
+	flag.Var(attributeTemplates, "attribute-template",
+		"Attribute template as passed to the controller, as name=template. Can be repeated.")
+	flag.BoolVar(&emailVerifiedUnmanaged, "cognito-email-verified-unmanaged", false,
+		"If set, email_verified is not compared, as passed to the controller.")
+	flag.StringVar(&enrichmentURL, "attribute-enrichment-url", "",
+		"URL of the attribute enrichment service, as passed to the controller.")
+	flag.Parse()
+
+	var enricher controller.AttributeEnricher
+	if enrichmentURL != "" {
+		enricher = &controller.HTTPEnricher{URL: enrichmentURL}
+	}
+	consistent, err := run(context.Background(), userPoolID, attributeMapping, customAttributePrefix, region,
+		attributeTemplates, emailVerifiedUnmanaged, enricher)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !consistent {
+		os.Exit(2)
+	}
+}
+
+func run(ctx context.Context, userPoolID, attributeMapping, customAttributePrefix, region string,
+	attributeTemplates map[string]string, emailVerifiedUnmanaged bool,
+	enricher controller.AttributeEnricher) (bool, error) {
+	if userPoolID == "" {
+		return false, fmt.Errorf("--cognito-user-pool-id is required")
+	}
+	mapping, err := cognito.ParseAttributeMapping(attributeMapping)
+	if err != nil {
+		return false, fmt.Errorf("invalid attribute mapping: %w", err)
+	}
+	templates, err := controller.ParseAttributeTemplates(attributeTemplates)
+	if err != nil {
+		return false, fmt.Errorf("invalid attribute template: %w", err)
+	}
+	pool, err := cognito.NewAWSClient(ctx, userPoolID,
+		cognito.WithAttributeMapping(mapping),
+		cognito.WithCustomAttributePrefix(customAttributePrefix),
+		cognito.WithRegion(region))
+	if err != nil {
+		return false, fmt.Errorf("failed to create Cognito client: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		return false, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return false, err
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return false, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return false, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	checker := &controller.ConsistencyChecker{
+		UserPoolClient:         pool,
+		Reader:                 c,
+		AttributeTemplates:     templates,
+		EmailVerifiedUnmanaged: emailVerifiedUnmanaged,
+		Enricher:               enricher,
+	}
+	report, err := checker.Check(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, warning := range report.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return false, fmt.Errorf("failed to write report: %w", err)
+	}
+	return report.Consistent(), nil
+}
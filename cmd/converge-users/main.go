@@ -0,0 +1,131 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command converge-users makes a user pool match the members of a UserSet
+// manifest exactly: missing users are created, differing users updated, and
+// pool users that are not members pruned according to --prune. It suits
+// small pools managed entirely as code, without running the controller.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/internal/controller"
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func main() {
+	var userPoolID string
+	var attributeMapping string
+	var customAttributePrefix string
+	var region string
+	var manifest string
+	var prune string
+	var opts userpool.ConvergeOptions
+	flag.StringVar(&userPoolID, "cognito-user-pool-id", "", "The AWS Cognito User Pool ID. Required.")
+	flag.StringVar(&attributeMapping, "cognito-attribute-mapping", "",
+		"Comma-separated logical=pool attribute name pairs, as passed to the controller.")
+	flag.StringVar(&customAttributePrefix, "cognito-custom-attribute-prefix", "",
+		"Prefix of the pool names of unmapped custom attributes, as passed to the controller.")
+	flag.StringVar(&region, "cognito-region", "",
+		"Region of the Cognito endpoint. Defaults to the AWS configuration, then to the user pool ID's region.")
+	flag.StringVar(&manifest, "manifest", "", "YAML or JSON file with the UserSet listing the desired users. Required.")
+	flag.StringVar(&prune, "prune", string(userpool.PruneNone),
+		"What happens to pool users that are not in the manifest: None, Disable or Delete.")
+	flag.IntVar(&opts.MaxPrune, "max-prune", 10,
+		"The most users a run prunes. A run that would prune more prunes none. 0 means no limit.")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "If set, the actions are reported but not performed.")
+	flag.Parse()
+
+	err := run(context.Background(), userPoolID, attributeMapping, customAttributePrefix, region, manifest, prune,
+		opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, userPoolID, attributeMapping, customAttributePrefix, region, manifest, prune string,
+	opts userpool.ConvergeOptions) error {
+	if userPoolID == "" {
+		return fmt.Errorf("--cognito-user-pool-id is required")
+	}
+	if manifest == "" {
+		return fmt.Errorf("--manifest is required")
+	}
+	policy, err := userpool.ParsePrunePolicy(prune)
+	if err != nil {
+		return err
+	}
+	opts.Prune = policy
+	mapping, err := cognito.ParseAttributeMapping(attributeMapping)
+	if err != nil {
+		return fmt.Errorf("invalid attribute mapping: %w", err)
+	}
+
+	f, err := os.Open(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	var set kcpv1alpha1.UserSet
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(&set); err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	pool, err := cognito.NewAWSClient(ctx, userPoolID,
+		cognito.WithAttributeMapping(mapping),
+		cognito.WithCustomAttributePrefix(customAttributePrefix),
+		cognito.WithRegion(region))
+	if err != nil {
+		return fmt.Errorf("failed to create Cognito client: %w", err)
+	}
+
+	summary, err := userpool.Converge(ctx, pool, controller.MemberUsers(&set), opts)
+	if summary != nil {
+		report(summary, opts.DryRun)
+	}
+	return err
+}
+
+// report prints the users per action and the summary line
+func report(summary *userpool.ConvergeSummary, dryRun bool) {
+	for _, action := range []struct {
+		name      string
+		usernames []string
+	}{
+		{"create", summary.Created},
+		{"update", summary.Updated},
+		{"prune", summary.Pruned},
+		{"unmanaged", summary.Unmanaged},
+	} {
+		for _, username := range action.usernames {
+			fmt.Printf("%s\t%s\n", action.name, username)
+		}
+	}
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %s\n", summary)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Converged: %s\n", summary)
+}
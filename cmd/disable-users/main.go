@@ -0,0 +1,74 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command disable-users disables every User matching a label selector in the
+// workspace of the current kubeconfig context, e.g. to lock down a tenant.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/internal/controller"
+)
+
+func main() {
+	var namespace string
+	var selector string
+	var reason string
+	flag.StringVar(&namespace, "namespace", "", "Namespace of the Users. If empty, all namespaces are searched.")
+	flag.StringVar(&selector, "selector", "", "Label selector of the Users to disable, e.g. tenant=acme. Required.")
+	flag.StringVar(&reason, "reason", "locked-down", "Value recorded in spec.disableReason of the disabled Users.")
+	flag.Parse()
+
+	if err := run(context.Background(), namespace, selector, reason); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, namespace, selector, reason string) error {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	disabled, err := controller.DisableUsers(ctx, c, namespace, parsed, reason)
+	fmt.Printf("Disabled %d users\n", disabled)
+	return err
+}
@@ -17,19 +17,26 @@ limitations under the License.
 package main
 
 import (
+	"cmp"
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	apisv1alpha1 "github.com/kcp-dev/kcp/sdk/apis/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
@@ -38,6 +45,7 @@ import (
 
 	"github.com/kcp-dev/multicluster-provider/apiexport"
 	"piotrjanik.dev/users/internal/controller"
+	webhookv1alpha1 "piotrjanik.dev/users/internal/webhook/v1alpha1"
 	"piotrjanik.dev/users/pkg/cognito"
 	"piotrjanik.dev/users/pkg/userpool"
 
@@ -50,6 +58,59 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// keyValueFlag is a repeatable flag collecting key=value pairs
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[k] = v
+	return nil
+}
+
+// endpointSliceURL returns the virtual workspace URL of the APIExport
+// published by an APIExportEndpointSlice in the workspace of cfg
+func endpointSliceURL(ctx context.Context, cfg *rest.Config, name string) (string, error) {
+	c, err := client.New(cfg, client.Options{Scheme: clientgoscheme.Scheme})
+	if err != nil {
+		return "", fmt.Errorf("failed to create client: %w", err)
+	}
+	var slice apisv1alpha1.APIExportEndpointSlice
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, &slice); err != nil {
+		return "", fmt.Errorf("failed to get APIExportEndpointSlice %s: %w", name, err)
+	}
+	endpoints := slice.Status.APIExportEndpoints
+	if len(endpoints) == 0 {
+		return "", fmt.Errorf("APIExportEndpointSlice %s has no endpoints yet", name)
+	}
+	if len(endpoints) > 1 {
+		setupLog.Info("APIExportEndpointSlice has more than one endpoint, only the first is served",
+			"name", name, "endpoints", len(endpoints))
+	}
+	return endpoints[0].URL, nil
+}
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(clientgoscheme.Scheme))
 	utilruntime.Must(kcpv1alpha1.AddToScheme(clientgoscheme.Scheme))
@@ -63,15 +124,65 @@ func main() {
 	var clientKeyPath string
 	var caCertPath string
 	var virtualWorkspaceUrl string
+	var apiExportEndpointSlice string
 	var metricsAddr string
 	var enableLeaderElection bool
+	var leaderElectionID string
 	var probeAddr string
 	var enableHTTP2 bool
 	var cognitoUserPoolID string
+	var cognitoAttributeMapping string
+	var cognitoCustomAttributePrefix string
+	var cognitoSchemaPolicy string
+	var cognitoLegacyMFA string
+	var cognitoRegion string
+	var cognitoClusterMetadataKey string
+	var cognitoEndpoint string
+	var cognitoUseFIPS bool
+	var cognitoMaxAttempts int
+	var cognitoMaxBackoff time.Duration
+	var cognitoMaxInFlight int
+	var cognitoQPS float64
+	var cognitoBurst int
+	var maxConcurrentReconciles int
+	var resyncPeriod time.Duration
+	attributeTemplates := keyValueFlag{}
+	roleMapping := keyValueFlag{}
+	defaultAttributes := keyValueFlag{}
+	userPoolFor := keyValueFlag{}
+	var emailVerifiedDefault bool
+	var emailVerifiedUnmanaged bool
+	var forceAliasCreation bool
+	var forgetDevicesOnDisable bool
+	var enableWebhooks bool
+	var webhookCertPath string
+	var managedAttributes string
+	var deleteManagedAttributes bool
+	var usernameStrategy string
+	var redactPII bool
+	var watchAttributeSources bool
+	var manageGroups bool
+	var groupPendingRequeue time.Duration
+	var uniquenessCheck bool
+	var uniquenessCheckPool bool
+	var referenceAttribute string
+	var temporaryPasswordValidity time.Duration
+	var resendExpiredInvitations bool
+	var orphanPolicy string
+	var orphanMaxDeletes int
+	var userPoolCapacity int
+	var userPoolCapacityThreshold float64
+	var userCountInterval time.Duration
+	var enrichmentURL string
+	var enrichmentTimeout time.Duration
+	var userSelector string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0",
 		"The address the metrics endpoint binds to. "+
 			"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "fe9d2d78.cogniteo.io",
+		"Name of the leader election lease. Instances reconciling different --user-selector shards "+
+			"need different IDs, otherwise only one of them runs.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
@@ -83,10 +194,133 @@ func main() {
 	flag.StringVar(&clientKeyPath, "client-key", "", "Path to the client key (PEM format) for TLS authentication.")
 	flag.StringVar(&caCertPath, "ca-cert", "", "Path to the CA certificate (PEM format) for TLS server verification.")
 	flag.StringVar(&virtualWorkspaceUrl, "virtual-workspace-url", "",
-		"The URL of the virtual workspace (e.g., https://kcp.example.com/clusters/org_myorg_workspace_myworkspace). "+
-			"This will override the host in the kubeconfig.")
+		"The URL of the APIExport virtual workspace (e.g., https://kcp.example.com/services/apiexport/root/users), "+
+			"without the /clusters/* suffix. This will override the host in the kubeconfig.")
+	flag.StringVar(&apiExportEndpointSlice, "apiexport-endpoint-slice", "",
+		"Name of the APIExportEndpointSlice in the workspace of the kubeconfig to read the virtual workspace URL "+
+			"from, instead of --virtual-workspace-url.")
 	flag.StringVar(&cognitoUserPoolID, "cognito-user-pool-id", "",
 		"AWS Cognito User Pool ID. If not provided, Cognito integration will be disabled.")
+	flag.Var(userPoolFor, "cognito-user-pool-for",
+		"Repeatable cluster=poolID pair mapping a kcp logical cluster to its own AWS Cognito User Pool ID, "+
+			"instead of --cognito-user-pool-id. The cluster * matches clusters without a pair of their own.")
+	flag.StringVar(&cognitoAttributeMapping, "cognito-attribute-mapping", "",
+		"Comma-separated logical=attribute pairs mapping User attribute names to user pool attribute names "+
+			"(e.g. org=custom:tenant).")
+	flag.StringVar(&cognitoCustomAttributePrefix, "cognito-custom-attribute-prefix", "",
+		"Prefix replacing custom: in the user pool names of unmapped custom attributes (e.g. custom:kcp_), "+
+			"so the controller reads and writes only the custom attributes under it.")
+	flag.StringVar(&cognitoSchemaPolicy, "cognito-schema-policy", string(cognito.SchemaPolicyFailClosed),
+		"How to handle User attributes missing from the user pool schema: FailClosed rejects the write, "+
+			"DropUnknown drops and logs them.")
+	flag.StringVar(&cognitoLegacyMFA, "cognito-legacy-mfa", string(cognito.LegacyMFAAuto),
+		"How SMS MFA preferences are set: Auto uses AdminSetUserMFAPreference and switches to the legacy "+
+			"AdminSetUserSettings once the pool rejects it, Always and Never use only one of them.")
+	flag.StringVar(&cognitoRegion, "cognito-region", "",
+		"Region of the Cognito endpoint. Defaults to the AWS configuration, then to the user pool ID's region.")
+	flag.StringVar(&cognitoClusterMetadataKey, "cognito-cluster-metadata-key", "",
+		"Client metadata key under which the kcp logical cluster of a User is passed to the user pool's Lambda "+
+			"triggers, e.g. kcpCluster. Empty disables it.")
+	flag.StringVar(&cognitoEndpoint, "cognito-endpoint", "",
+		"URL of the Cognito endpoint, overriding the one resolved from the region.")
+	flag.BoolVar(&cognitoUseFIPS, "cognito-use-fips", false, "If set, the region's FIPS endpoint is used.")
+	flag.IntVar(&cognitoMaxAttempts, "cognito-max-attempts", 0,
+		"Maximum number of attempts the AWS SDK makes for each Cognito call. 0 keeps the SDK default.")
+	flag.DurationVar(&cognitoMaxBackoff, "cognito-max-backoff", 0,
+		"Maximum delay between AWS SDK retries of a Cognito call. 0 keeps the SDK default.")
+	flag.IntVar(&cognitoMaxInFlight, "cognito-max-in-flight", 0,
+		"Maximum number of Cognito calls in flight at once for the user pool. 0 means no limit.")
+	flag.Float64Var(&cognitoQPS, "cognito-qps", 0,
+		"Sustained Cognito calls per second for the user pool, e.g. below its quota. 0 means no limit.")
+	flag.IntVar(&cognitoBurst, "cognito-burst", 1,
+		"Cognito calls that may be made at once on top of --cognito-qps after a quiet period.")
+	flag.BoolVar(&emailVerifiedDefault, "cognito-email-verified-default", true,
+		"email_verified value written for Users that don't set spec.emailVerified.")
+	flag.BoolVar(&emailVerifiedUnmanaged, "cognito-email-verified-unmanaged", false,
+		"If set, email_verified is never written and spec.emailVerified is ignored, for pools where an external "+
+			"verification flow owns the attribute.")
+	flag.BoolVar(&forceAliasCreation, "cognito-force-alias-creation", false,
+		"If set, creating a user takes over an email alias held by another pool user instead of failing.")
+	flag.BoolVar(&forgetDevicesOnDisable, "cognito-forget-devices-on-disable", false,
+		"If set, the remembered devices of a user are forgotten when the user is disabled, so they cannot "+
+			"skip MFA after the user is enabled again.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the validating and defaulting webhooks for User resources are served.")
+	flag.StringVar(&webhookCertPath, "webhook-cert-path", "",
+		"The directory that contains the webhook certificate (tls.crt and tls.key).")
+	flag.StringVar(&managedAttributes, "managed-attributes", "",
+		"Comma-separated list of attribute names Users may set in spec.attributes. If empty, all names are allowed.")
+	flag.BoolVar(&deleteManagedAttributes, "delete-managed-attributes", false,
+		"If set, attributes in --managed-attributes that a User or UserSet member no longer sets are deleted "+
+			"from its pool user.")
+	flag.StringVar(&usernameStrategy, "username-strategy", "uuid",
+		"How usernames are derived for Users with spec.generateUsername: uuid, literal (the email), "+
+			"email-local-part or email-hash.")
+	flag.BoolVar(&redactPII, "redact-pii", false,
+		"If set, usernames and emails in errors and log lines are replaced with a stable hash.")
+	flag.DurationVar(&temporaryPasswordValidity, "temporary-password-validity", 7*24*time.Hour,
+		"How long the user pool accepts temporary passwords, as configured in the pool. Reported in "+
+			"status.temporaryPasswordExpiresAt for users that never signed in. Set to 0 to disable.")
+	flag.BoolVar(&resendExpiredInvitations, "resend-expired-invitations", false,
+		"If set, users whose temporary password expired before they signed in are sent the invitation again "+
+			"with a new temporary password.")
+	flag.BoolVar(&watchAttributeSources, "watch-attribute-sources", false,
+		"If set, Users are reconciled again when a Secret or ConfigMap of their spec.attributesFrom changes. "+
+			"Otherwise changes are picked up at the next resync.")
+	flag.BoolVar(&manageGroups, "manage-groups", false,
+		"If set, Group resources are reconciled to user pool groups and Users waiting for a missing group are "+
+			"reconciled again once its Group changes. The APIExport must include groups.")
+	flag.DurationVar(&groupPendingRequeue, "group-pending-requeue", 5*time.Second,
+		"With --manage-groups, first requeue delay of a User waiting for a Group to create its group. "+
+			"It doubles with every retry up to 5m.")
+	flag.BoolVar(&uniquenessCheck, "uniqueness-check", false,
+		"If set, a pool user is only created when no older User in any workspace claims the same username or "+
+			"email. Otherwise the User reports DuplicateUser.")
+	flag.BoolVar(&uniquenessCheckPool, "uniqueness-check-pool", false,
+		"If set with --uniqueness-check, the email is also looked up in the user pool before creating a user, "+
+			"at the cost of one ListUsers call per create.")
+	flag.StringVar(&referenceAttribute, "cr-reference-attribute", "",
+		"If set, e.g. to custom:crRef, the attribute is set to the workspace, namespace and name of the User "+
+			"or UserSet managing each pool user. The attribute must be defined and writable in the user pool.")
+	flag.StringVar(&orphanPolicy, "orphan-policy", string(controller.OrphanPolicyIgnore),
+		"What to do with user pool users no User or UserSet manages: Ignore, Warn (log and report in a metric) "+
+			"or Delete. Orphans are checked every resync period.")
+	flag.IntVar(&orphanMaxDeletes, "orphan-max-deletes", 10,
+		"Maximum number of orphaned users deleted per check with --orphan-policy=Delete. A check finding more "+
+			"deletes none.")
+	flag.IntVar(&userPoolCapacity, "user-pool-capacity", controller.DefaultUserPoolCapacity,
+		"Most users the user pool can hold, Cognito's default quota unless it was raised.")
+	flag.Float64Var(&userPoolCapacityThreshold, "user-pool-capacity-threshold", controller.DefaultCapacityThreshold,
+		"Share of --user-pool-capacity from which the user pool is reported as near capacity.")
+	flag.DurationVar(&userCountInterval, "user-count-interval", 0,
+		"Interval at which the user pool's users are counted for the user count and capacity metrics. "+
+			"Defaults to the resync period.")
+	flag.StringVar(&enrichmentURL, "attribute-enrichment-url", "",
+		"URL of a service the User identity is posted to before every sync. The attributes it returns are "+
+			"merged into the desired attributes; if it fails, the User is retried without writing anything.")
+	flag.DurationVar(&enrichmentTimeout, "attribute-enrichment-timeout", 10*time.Second,
+		"Timeout of a call to --attribute-enrichment-url.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"Maximum number of User reconciles running in parallel. Each reconcile issues several Cognito API "+
+			"calls, so raising this increases throughput until the user pool's request quota is reached.")
+	flag.StringVar(&userSelector, "user-selector", "",
+		"Label selector restricting the Users this instance reconciles (e.g. 'shard=a'), so several instances "+
+			"can divide them. The selectors of all instances must not overlap and together cover every User. "+
+			"Empty reconciles all Users.")
+	flag.DurationVar(&resyncPeriod, "resync-period", time.Hour,
+		"Interval after which every User is reconciled again to correct changes made directly in Cognito. "+
+			"Set to 0 to disable periodic resync.")
+	flag.Var(attributeTemplates, "attribute-template",
+		"Attribute computed from the User with a Go template, as name=template "+
+			"(e.g. 'custom:displayName={{ .Spec.Attributes.givenName }} {{ .Spec.Attributes.familyName }}'). "+
+			"Can be repeated.")
+	flag.Var(defaultAttributes, "default-attribute",
+		"Attribute written for every user that doesn't set it, as name=value "+
+			"(e.g. 'custom:managedBy=kcp-users-controller'). Can be repeated.")
+	flag.Var(roleMapping, "role-mapping",
+		"Role used in spec.roles and the user pool groups it expands to, as role=groups "+
+			"(e.g. 'admin=admins,billing,role:viewer'). Entries prefixed with role: include another role. "+
+			"Can be repeated.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -111,6 +345,7 @@ func main() {
 	}
 
 	webhookServer := webhook.NewServer(webhook.Options{
+		CertDir: webhookCertPath,
 		TLSOpts: tlsOpts,
 	})
 
@@ -125,7 +360,17 @@ func main() {
 	cfg := ctrl.GetConfigOrDie()
 	cfg = rest.CopyConfig(cfg)
 
+	if apiExportEndpointSlice != "" && virtualWorkspaceUrl == "" {
+		url, err := endpointSliceURL(context.Background(), cfg, apiExportEndpointSlice)
+		if err != nil {
+			setupLog.Error(err, "unable to read virtual workspace URL")
+			os.Exit(1)
+		}
+		virtualWorkspaceUrl = url
+	}
 	if virtualWorkspaceUrl != "" {
+		// The provider adds /clusters/* itself
+		virtualWorkspaceUrl = strings.TrimSuffix(strings.TrimSuffix(virtualWorkspaceUrl, "/"), "/clusters/*")
 		cfg.Host = virtualWorkspaceUrl
 		setupLog.Info("using virtual workspace URL for REST client", "url", virtualWorkspaceUrl)
 	}
@@ -155,7 +400,7 @@ func main() {
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "fe9d2d78.cogniteo.io",
+		LeaderElectionID:       leaderElectionID,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -164,29 +409,250 @@ func main() {
 
 	// Initialize Cognito client if User Pool ID is provided
 	var userPoolClient userpool.Client
-	if cognitoUserPoolID != "" {
-		setupLog.Info("Initializing AWS Cognito client", "userPoolId", cognitoUserPoolID)
-		client, err := cognito.NewClient(context.Background(), cognitoUserPoolID)
+	if cognitoUserPoolID != "" && len(userPoolFor) > 0 {
+		setupLog.Error(errors.New("--cognito-user-pool-id and --cognito-user-pool-for are mutually exclusive"),
+			"invalid Cognito flags")
+		os.Exit(1)
+	}
+	if cognitoUserPoolID != "" || len(userPoolFor) > 0 {
+		attributeMapping, err := cognito.ParseAttributeMapping(cognitoAttributeMapping)
+		if err != nil {
+			setupLog.Error(err, "invalid Cognito attribute mapping")
+			os.Exit(1)
+		}
+		if err := cognito.ValidateCustomAttributePrefix(cognitoCustomAttributePrefix); err != nil {
+			setupLog.Error(err, "invalid Cognito custom attribute prefix")
+			os.Exit(1)
+		}
+		schemaPolicy, err := cognito.ParseSchemaPolicy(cognitoSchemaPolicy)
+		if err != nil {
+			setupLog.Error(err, "invalid Cognito schema policy")
+			os.Exit(1)
+		}
+		legacyMFA, err := cognito.ParseLegacyMFA(cognitoLegacyMFA)
 		if err != nil {
-			setupLog.Error(err, "unable to create Cognito client")
+			setupLog.Error(err, "invalid Cognito legacy MFA mode")
 			os.Exit(1)
 		}
-		userPoolClient = client
+		newClient := func(ctx context.Context, userPoolID string) (*cognito.AWSClient, error) {
+			client, err := cognito.NewAWSClient(ctx, userPoolID,
+				cognito.WithAttributeMapping(attributeMapping),
+				cognito.WithCustomAttributePrefix(cognitoCustomAttributePrefix),
+				cognito.WithEmailVerifiedDefault(emailVerifiedDefault),
+				cognito.WithEmailVerifiedUnmanaged(emailVerifiedUnmanaged),
+				cognito.WithForceAliasCreation(forceAliasCreation),
+				cognito.WithForgetDevicesOnDisable(forgetDevicesOnDisable),
+				cognito.WithDefaultAttributes(defaultAttributes),
+				cognito.WithOperationHook(controller.OperationMetrics{}),
+				cognito.WithSchemaPolicy(schemaPolicy),
+				cognito.WithLegacyMFA(legacyMFA),
+				cognito.WithMaxAttempts(cognitoMaxAttempts),
+				cognito.WithMaxBackoff(cognitoMaxBackoff),
+				cognito.WithRequestLimits(cognito.RequestLimits{
+					MaxInFlight: cognitoMaxInFlight,
+					QPS:         float32(cognitoQPS),
+					Burst:       cognitoBurst,
+					OnWait:      controller.LimitMetrics{UserPoolID: userPoolID}.OnWait,
+				}),
+				cognito.WithRedactPII(redactPII),
+				cognito.WithRegion(cognitoRegion),
+				cognito.WithClusterMetadataKey(cognitoClusterMetadataKey),
+				cognito.WithFIPSEndpoint(cognitoUseFIPS),
+				cognito.WithBaseEndpoint(cognitoEndpoint))
+			if err != nil {
+				return nil, err
+			}
+			if err := client.ValidateAttributeMapping(ctx); err != nil {
+				if !errors.Is(err, cognito.ErrSchemaUnavailable) {
+					return nil, fmt.Errorf("invalid Cognito attribute mapping: %w", err)
+				}
+				if errors.Is(err, cognito.ErrDescribeDenied) {
+					// Keep running under least-privilege IAM, Cognito rejects
+					// what the skipped checks would have caught
+					setupLog.Info("DescribeUserPool is not allowed, skipping user pool schema, username "+
+						"and MFA checks", "userPoolId", userPoolID)
+				} else {
+					setupLog.Info("Skipping user pool schema checks", "userPoolId", userPoolID, "reason", err.Error())
+				}
+			} else if mfa, err := client.MFAConfiguration(ctx); err == nil {
+				setupLog.Info("Detected user pool MFA configuration", "userPoolId", userPoolID, "mfa", mfa)
+			}
+			return client, nil
+		}
+
+		if cognitoUserPoolID != "" {
+			setupLog.Info("Initializing AWS Cognito client", "userPoolId", cognitoUserPoolID)
+			client, err := newClient(context.Background(), cognitoUserPoolID)
+			if err != nil {
+				setupLog.Error(err, "unable to create Cognito client")
+				os.Exit(1)
+			}
+			userPoolClient = client
+		} else {
+			setupLog.Info("Initializing AWS Cognito clients per logical cluster", "userPools", userPoolFor.String())
+			pools := &controller.WorkspacePools{
+				Pools: userPoolFor,
+				NewClient: func(ctx context.Context, userPoolID string) (userpool.Client, error) {
+					client, err := newClient(ctx, userPoolID)
+					if err != nil {
+						return nil, err
+					}
+					return client, nil
+				},
+			}
+			userPoolClient = userpool.NewRouter(pools.Resolve)
+		}
 	} else {
 		setupLog.Info("Cognito User Pool ID not provided, Cognito integration disabled")
 	}
 
+	templates, err := controller.ParseAttributeTemplates(attributeTemplates)
+	if err != nil {
+		setupLog.Error(err, "invalid attribute template")
+		os.Exit(1)
+	}
+	strategy, err := controller.ParseUsernameStrategy(usernameStrategy)
+	if err != nil {
+		setupLog.Error(err, "invalid username strategy")
+		os.Exit(1)
+	}
+	roles, err := controller.ParseRoleMapping(roleMapping)
+	if err != nil {
+		setupLog.Error(err, "invalid role mapping")
+		os.Exit(1)
+	}
+	orphans, err := controller.ParseOrphanPolicy(orphanPolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid orphan policy")
+		os.Exit(1)
+	}
+	var selector labels.Selector
+	if userSelector != "" {
+		selector, err = labels.Parse(userSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid user selector")
+			os.Exit(1)
+		}
+	}
+
+	var enricher controller.AttributeEnricher
+	if enrichmentURL != "" {
+		enricher = &controller.HTTPEnricher{URL: enrichmentURL, Timeout: enrichmentTimeout}
+	}
+	var capacity *controller.CapacityMonitor
+	if cognitoUserPoolID != "" {
+		capacity = &controller.CapacityMonitor{
+			UserPoolID: cognitoUserPoolID,
+			Capacity:   userPoolCapacity,
+			Threshold:  userPoolCapacityThreshold,
+		}
+	}
+	// Deleting attributes is destructive, so listing them for the webhook
+	// alone doesn't turn it on
+	var deletedAttributes []string
+	if deleteManagedAttributes {
+		deletedAttributes = splitList(managedAttributes)
+	}
+
 	if err := (&controller.UserReconciler{
 		Client:         mgr.GetLocalManager().GetClient(),
 		Scheme:         mgr.GetLocalManager().GetScheme(),
 		Manager:        mgr,
 		UserPoolClient: userPoolClient,
+		UserPoolID:     cognitoUserPoolID,
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		ResyncPeriod:            resyncPeriod,
+		Selector:                selector,
+		AttributeTemplates:      templates,
+		UsernameStrategy:        strategy,
+		RedactPII:               redactPII,
+		RoleMapping:             roles,
+		WatchAttributeSources:   watchAttributeSources,
+		WatchGroups:             manageGroups,
+		GroupPendingRequeue:     groupPendingRequeue,
+		UniquenessCheck:         uniquenessCheck,
+		UniquenessReader:        provider.GetWildcard(),
+		UniquenessCheckPool:     uniquenessCheckPool,
+		ReferenceAttribute:      referenceAttribute,
+		ManagedAttributes:       deletedAttributes,
+		EmailVerifiedUnmanaged:  emailVerifiedUnmanaged,
+		Capacity:                capacity,
+		Enricher:                enricher,
+
+		TemporaryPasswordValidity: temporaryPasswordValidity,
+		ResendExpiredInvitations:  resendExpiredInvitations,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "User")
 		os.Exit(1)
 	}
+	if err := (&controller.UserSetReconciler{
+		Scheme:         mgr.GetLocalManager().GetScheme(),
+		Manager:        mgr,
+		UserPoolClient: userPoolClient,
+		UserPoolID:     cognitoUserPoolID,
+		ResyncPeriod:   resyncPeriod,
+		RedactPII:      redactPII,
+
+		ReferenceAttribute: referenceAttribute,
+		ManagedAttributes:  deletedAttributes,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "UserSet")
+		os.Exit(1)
+	}
+	if groupClient, ok := userPoolClient.(userpool.GroupClient); ok && manageGroups {
+		if err := (&controller.GroupReconciler{
+			Scheme:       mgr.GetLocalManager().GetScheme(),
+			Manager:      mgr,
+			GroupClient:  groupClient,
+			ResyncPeriod: resyncPeriod,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Group")
+			os.Exit(1)
+		}
+	}
+	if enableWebhooks {
+		defaulter := &webhookv1alpha1.UserCustomDefaulter{EmailVerifiedDefault: &emailVerifiedDefault}
+		if emailVerifiedUnmanaged {
+			// spec.emailVerified is ignored, don't suggest otherwise
+			defaulter.EmailVerifiedDefault = nil
+		}
+		if err := webhookv1alpha1.SetupUserWebhookWithManager(mgr.GetLocalManager(),
+			&webhookv1alpha1.UserCustomValidator{AllowedAttributes: splitList(managedAttributes)},
+			defaulter); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "User")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	// The user count and orphan sweep cover a single pool, so they don't run
+	// with per-cluster pools
+	if cognitoUserPoolID != "" {
+		if err := mgr.GetLocalManager().Add(&controller.UserCountRefresher{
+			UserPoolClient: userPoolClient,
+			UserPoolID:     cognitoUserPoolID,
+			Interval:       cmp.Or(userCountInterval, resyncPeriod),
+			Capacity:       capacity,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up user count metric")
+			os.Exit(1)
+		}
+		if err := mgr.GetLocalManager().Add(&controller.OrphanSweeper{
+			UserPoolClient: userPoolClient,
+			UserPoolID:     cognitoUserPoolID,
+			Reader:         provider.GetWildcard(),
+			Policy:         orphans,
+			Interval:       resyncPeriod,
+			MinAge:         10 * time.Minute,
+			MaxDeletes:     orphanMaxDeletes,
+			RedactPII:      redactPII,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up orphan sweeper")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
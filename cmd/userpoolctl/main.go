@@ -0,0 +1,319 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command userpoolctl runs ad-hoc operations against the users of a Cognito
+// user pool with the same client and AWS credentials as the controller, e.g.
+//
+//	userpoolctl --cognito-user-pool-id=eu-west-1_AbC123 get jane
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// options are the flags shared by all subcommands
+type options struct {
+	userPoolID            string
+	attributeMapping      string
+	customAttributePrefix string
+	region                string
+	endpoint              string
+	useFIPS               bool
+	output                string
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+	root := &cobra.Command{
+		Use:          "userpoolctl",
+		Short:        "Run operations against the users of a Cognito user pool",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.output != outputTable && opts.output != outputJSON {
+				return fmt.Errorf("invalid output %q, expected %s or %s", opts.output, outputTable, outputJSON)
+			}
+			return nil
+		},
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.userPoolID, "cognito-user-pool-id", "", "The AWS Cognito User Pool ID. Required.")
+	flags.StringVar(&opts.attributeMapping, "cognito-attribute-mapping", "",
+		"Comma-separated logical=pool attribute name pairs, as passed to the controller.")
+	flags.StringVar(&opts.customAttributePrefix, "cognito-custom-attribute-prefix", "",
+		"Prefix of the pool names of unmapped custom attributes, as passed to the controller.")
+	flags.StringVar(&opts.region, "cognito-region", "",
+		"Region of the Cognito endpoint. Defaults to the AWS configuration, then to the user pool ID's region.")
+	flags.StringVar(&opts.endpoint, "cognito-endpoint", "",
+		"URL of the Cognito endpoint, overriding the one resolved from the region.")
+	flags.BoolVar(&opts.useFIPS, "cognito-use-fips", false, "If set, the region's FIPS endpoint is used.")
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "Output format of users: table or json.")
+
+	root.AddCommand(
+		newGetCommand(opts),
+		newListCommand(opts),
+		newCreateCommand(opts),
+		newDeleteCommand(opts),
+		newDisableCommand(opts),
+		newResetPasswordCommand(opts),
+	)
+	return root
+}
+
+// client creates the user pool client the flags describe
+func (o *options) client(ctx context.Context) (*cognito.AWSClient, error) {
+	if o.userPoolID == "" {
+		return nil, fmt.Errorf("--cognito-user-pool-id is required")
+	}
+	mapping, err := cognito.ParseAttributeMapping(o.attributeMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute mapping: %w", err)
+	}
+	pool, err := cognito.NewAWSClient(ctx, o.userPoolID,
+		cognito.WithAttributeMapping(mapping),
+		cognito.WithCustomAttributePrefix(o.customAttributePrefix),
+		cognito.WithRegion(o.region),
+		cognito.WithFIPSEndpoint(o.useFIPS),
+		cognito.WithBaseEndpoint(o.endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cognito client: %w", err)
+	}
+	return pool, nil
+}
+
+func newGetCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get USERNAME",
+		Short: "Show a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			user, err := pool.GetUser(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), opts.output, []*userpool.User{user})
+		},
+	}
+}
+
+func newListCommand(opts *options) *cobra.Command {
+	var filter string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the users of the pool",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			var users []*userpool.User
+			if filter != "" {
+				users, err = pool.ListUsersFiltered(cmd.Context(), filter)
+			} else {
+				users, err = pool.ListUsers(cmd.Context())
+			}
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), opts.output, users)
+		},
+	}
+	cmd.Flags().StringVar(&filter, "filter", "",
+		`Cognito filter expression selecting the users, e.g. 'email ^= "jane"'.`)
+	return cmd
+}
+
+func newCreateCommand(opts *options) *cobra.Command {
+	var user userpool.User
+	var disabled bool
+	cmd := &cobra.Command{
+		Use:   "create [USERNAME]",
+		Short: "Create a user",
+		Long:  "Create a user. Without a username, a UUID is generated and printed.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				user.Username = args[0]
+			}
+			user.Enabled = !disabled
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := pool.CreateUser(cmd.Context(), &user); err != nil {
+				return err
+			}
+			// AdminGetUser can lag briefly right after the create
+			created, err := userpool.WaitForUser(cmd.Context(), pool, user.Username, userpool.WaitOptions{})
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), opts.output, []*userpool.User{created})
+		},
+	}
+	cmd.Flags().StringVar(&user.Email, "email", "", "Email of the user.")
+	cmd.Flags().StringToStringVar(&user.Attributes, "attribute", nil,
+		"Attribute of the user as name=value, by logical name. Can be repeated.")
+	cmd.Flags().BoolVar(&user.SendInvitation, "send-invitation", false,
+		"If set, Cognito sends the invitation with a temporary password.")
+	cmd.Flags().BoolVar(&disabled, "disabled", false, "If set, the user is created disabled.")
+	return cmd
+}
+
+func newDeleteCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete USERNAME",
+		Short: "Delete a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := pool.DeleteUser(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Deleted user %s\n", args[0])
+			return err
+		},
+	}
+}
+
+func newDisableCommand(opts *options) *cobra.Command {
+	var reason string
+	cmd := &cobra.Command{
+		Use:   "disable USERNAME",
+		Short: "Disable a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			written, err := userpool.DisableUser(cmd.Context(), pool, args[0], reason)
+			if err != nil {
+				return err
+			}
+			message := "Disabled user %s\n"
+			if !written {
+				message = "User %s is already disabled\n"
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), message, args[0])
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "",
+		"Disable reason to record. The pool must define the custom:disableReason attribute.")
+	return cmd
+}
+
+func newResetPasswordCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reset-password USERNAME",
+		Short: "Reset a user's password",
+		Long: "Invalidate a user's password, so the user has to set a new one with a code sent to the verified " +
+			"email or phone number.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pool, err := opts.client(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := pool.ResetPassword(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Reset password of user %s\n", args[0])
+			return err
+		},
+	}
+}
+
+// userOutput is the JSON form of a user
+type userOutput struct {
+	Username      string            `json:"username"`
+	Email         string            `json:"email,omitempty"`
+	EmailVerified *bool             `json:"emailVerified,omitempty"`
+	Enabled       bool              `json:"enabled"`
+	Status        string            `json:"status"`
+	DisableReason string            `json:"disableReason,omitempty"`
+	PreferredMFA  string            `json:"preferredMfa,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt,omitzero"`
+	LastModified  time.Time         `json:"lastModified,omitzero"`
+}
+
+// printUsers writes users to w in format
+func printUsers(w io.Writer, format string, users []*userpool.User) error {
+	if format == outputJSON {
+		out := make([]userOutput, 0, len(users))
+		for _, user := range users {
+			out = append(out, userOutput{
+				Username:      user.Username,
+				Email:         user.Email,
+				EmailVerified: user.EmailVerified,
+				Enabled:       user.Enabled,
+				Status:        user.RawStatus,
+				DisableReason: user.DisableReason,
+				PreferredMFA:  user.PreferredMFA,
+				Attributes:    user.Attributes,
+				CreatedAt:     user.CreatedAt,
+				LastModified:  user.LastModified,
+			})
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tEMAIL\tSTATUS\tENABLED\tCREATED")
+	for _, user := range users {
+		created := ""
+		if !user.CreatedAt.IsZero() {
+			created = user.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\n", user.Username, user.Email, user.RawStatus,
+			user.Enabled, created)
+	}
+	return tw.Flush()
+}
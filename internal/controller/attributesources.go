@@ -0,0 +1,203 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// redactedValue replaces Secret values in error messages
+const redactedValue = "[redacted]"
+
+// resolveAttributeSources reads the attributes of spec.attributesFrom from
+// their Secrets and ConfigMaps. It also returns the values read from Secrets,
+// which must not appear in logs or the status. A missing object or key fails
+// unless the reference is optional.
+func resolveAttributeSources(ctx context.Context, c client.Reader,
+	user *kcpv1alpha1.User) (map[string]string, []string, error) {
+	if len(user.Spec.AttributesFrom) == 0 {
+		return nil, nil, nil
+	}
+
+	attributes := make(map[string]string, len(user.Spec.AttributesFrom))
+	var secrets []string
+	for _, source := range user.Spec.AttributesFrom {
+		switch {
+		case source.SecretKeyRef != nil:
+			ref := source.SecretKeyRef
+			var secret corev1.Secret
+			value, found, err := readKey(ctx, c, user.Namespace, ref.Name, ref.Key, &secret, func() ([]byte, bool) {
+				value, ok := secret.Data[ref.Key]
+				return value, ok
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("attribute %s: %w", source.Name, err)
+			}
+			if !found {
+				if ptr.Deref(ref.Optional, false) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("attribute %s: key %s not found in Secret %s", source.Name, ref.Key, ref.Name)
+			}
+			attributes[source.Name] = value
+			secrets = append(secrets, value)
+		case source.ConfigMapKeyRef != nil:
+			ref := source.ConfigMapKeyRef
+			var configMap corev1.ConfigMap
+			value, found, err := readKey(ctx, c, user.Namespace, ref.Name, ref.Key, &configMap, func() ([]byte, bool) {
+				if value, ok := configMap.Data[ref.Key]; ok {
+					return []byte(value), true
+				}
+				value, ok := configMap.BinaryData[ref.Key]
+				return value, ok
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("attribute %s: %w", source.Name, err)
+			}
+			if !found {
+				if ptr.Deref(ref.Optional, false) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("attribute %s: key %s not found in ConfigMap %s",
+					source.Name, ref.Key, ref.Name)
+			}
+			attributes[source.Name] = value
+		default:
+			return nil, nil, fmt.Errorf("attribute %s: secretKeyRef or configMapKeyRef must be set", source.Name)
+		}
+	}
+	return attributes, secrets, nil
+}
+
+// readKey gets obj and looks up key with lookup. A missing object is
+// reported like a missing key.
+func readKey(ctx context.Context, c client.Reader, namespace, name, key string, obj client.Object,
+	lookup func() ([]byte, bool)) (string, bool, error) {
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to read key %s of %s: %w", key, name, err)
+	}
+	value, ok := lookup()
+	return string(value), ok, nil
+}
+
+// redactedError hides secret values in the message of the wrapped error
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+func (e *redactedError) Error() string {
+	message := e.err.Error()
+	for _, secret := range e.secrets {
+		if secret != "" {
+			message = strings.ReplaceAll(message, secret, redactedValue)
+		}
+	}
+	return message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactSecrets wraps err so its message doesn't contain any of secrets
+func redactSecrets(err error, secrets []string) error {
+	if err == nil || len(secrets) == 0 {
+		return err
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+// enqueueReferencingUsers enqueues the Users of the object's namespace that
+// read attributes from it. isSecret tells whether the watched objects are
+// Secrets or ConfigMaps.
+func enqueueReferencingUsers(isSecret bool) func(string, cluster.Cluster) handler.TypedEventHandler[client.Object,
+	mcreconcile.Request] {
+	return func(clusterName string, cl cluster.Cluster) handler.TypedEventHandler[client.Object, mcreconcile.Request] {
+		return handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context,
+			obj client.Object) []mcreconcile.Request {
+			var users kcpv1alpha1.UserList
+			if err := cl.GetClient().List(ctx, &users, client.InNamespace(obj.GetNamespace())); err != nil {
+				return nil
+			}
+			var requests []mcreconcile.Request
+			for _, user := range users.Items {
+				if referencesObject(&user, isSecret, obj.GetName()) {
+					requests = append(requests, mcreconcile.Request{
+						ClusterName: clusterName,
+						Request:     reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&user)},
+					})
+				}
+			}
+			return requests
+		})
+	}
+}
+
+// referencesObject reports whether the User reads an attribute from the
+// Secret or ConfigMap with the given name
+func referencesObject(user *kcpv1alpha1.User, isSecret bool, name string) bool {
+	for _, source := range user.Spec.AttributesFrom {
+		if isSecret && source.SecretKeyRef != nil && source.SecretKeyRef.Name == name {
+			return true
+		}
+		if !isSecret && source.ConfigMapKeyRef != nil && source.ConfigMapKeyRef.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeAttributeSources returns attributes with the values read from
+// spec.attributesFrom added. Sources override spec.attributes, attribute
+// templates keep precedence over both.
+func mergeAttributeSources(attributes, fromSources map[string]string,
+	templates map[string]*template.Template) map[string]string {
+	if len(fromSources) == 0 {
+		return attributes
+	}
+	merged := maps.Clone(attributes)
+	if merged == nil {
+		merged = make(map[string]string, len(fromSources))
+	}
+	for name, value := range fromSources {
+		if _, ok := templates[name]; ok {
+			continue
+		}
+		merged[name] = value
+	}
+	return merged
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	err := redactSecrets(fmt.Errorf("invalid value s3cret: %w", userpool.ErrUserNotFound), []string{"s3cret"})
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("expected secret to be redacted, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), redactedValue) {
+		t.Errorf("expected %s in %q", redactedValue, err.Error())
+	}
+	if !stderrors.Is(err, userpool.ErrUserNotFound) {
+		t.Errorf("expected the wrapped error to be kept")
+	}
+	if redactSecrets(nil, []string{"s3cret"}) != nil {
+		t.Errorf("expected nil to stay nil")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+// specChangeRateLimiter is a workqueue rate limiter that forgets the failures
+// of a request once the generation of its object changes, so a corrected
+// spec is retried without the backoff the previous spec earned. Retries of
+// an unchanged spec keep backing off.
+type specChangeRateLimiter struct {
+	workqueue.TypedRateLimiter[mcreconcile.Request]
+
+	mu          sync.Mutex
+	generations map[mcreconcile.Request]int64
+}
+
+// newSpecChangeRateLimiter wraps the controller-runtime default rate limiter
+func newSpecChangeRateLimiter() *specChangeRateLimiter {
+	return &specChangeRateLimiter{
+		TypedRateLimiter: workqueue.DefaultTypedControllerRateLimiter[mcreconcile.Request](),
+		generations:      make(map[mcreconcile.Request]int64),
+	}
+}
+
+// observe records the generation of the object of req at the start of a
+// reconcile and forgets the failures of req if the generation changed since
+// the last one. It is a no-op on a nil limiter.
+func (l *specChangeRateLimiter) observe(req mcreconcile.Request, generation int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous, seen := l.generations[req]
+	l.generations[req] = generation
+	if seen && previous != generation {
+		l.TypedRateLimiter.Forget(req)
+	}
+}
+
+// Forget is called after a successful reconcile and drops the recorded
+// generation along with the failures
+func (l *specChangeRateLimiter) Forget(req mcreconcile.Request) {
+	l.mu.Lock()
+	delete(l.generations, req)
+	l.mu.Unlock()
+	l.TypedRateLimiter.Forget(req)
+}
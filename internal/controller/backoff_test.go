@@ -0,0 +1,58 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+func TestSpecChangeRateLimiter(t *testing.T) {
+	limiter := newSpecChangeRateLimiter()
+	req := mcreconcile.Request{ClusterName: "cluster1", Request: reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: "jane", Namespace: "default"},
+	}}
+
+	fail := func(generation int64) {
+		limiter.observe(req, generation)
+		limiter.When(req)
+	}
+
+	fail(1)
+	fail(1)
+	fail(1)
+	if got := limiter.NumRequeues(req); got != 3 {
+		t.Fatalf("expected retries of an unchanged spec to keep backing off, got %d requeues", got)
+	}
+
+	fail(2)
+	if got := limiter.NumRequeues(req); got != 1 {
+		t.Errorf("expected a spec change to reset the backoff, got %d requeues", got)
+	}
+
+	limiter.Forget(req)
+	limiter.observe(req, 3)
+	if got := limiter.NumRequeues(req); got != 0 {
+		t.Errorf("expected no requeues after success, got %d", got)
+	}
+
+	var unset *specChangeRateLimiter
+	unset.observe(req, 1)
+}
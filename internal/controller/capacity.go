@@ -0,0 +1,107 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DefaultUserPoolCapacity is Cognito's default quota of users per user
+	// pool
+	DefaultUserPoolCapacity = 40_000_000
+
+	// DefaultCapacityThreshold is the share of the capacity from which a
+	// user pool is near capacity
+	DefaultCapacityThreshold = 0.8
+)
+
+var (
+	// userPoolCapacityUsed tracks the share of the user capacity in use
+	userPoolCapacityUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kcp_users_user_pool_capacity_used_ratio",
+		Help: "Share of the user capacity of the user pool in use",
+	}, []string{"user_pool_id"})
+
+	// userPoolNearCapacity is 1 while the user pool is near capacity
+	userPoolNearCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kcp_users_user_pool_near_capacity",
+		Help: "1 while the user count of the user pool is at or above the capacity threshold, 0 otherwise",
+	}, []string{"user_pool_id"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(userPoolCapacityUsed, userPoolNearCapacity)
+}
+
+// CapacityMonitor compares the user count of a user pool with its capacity,
+// so onboarding doesn't run into the user quota unannounced. The
+// UserCountRefresher feeds it and the UserReconciler warns on Users created
+// while the pool is near capacity.
+type CapacityMonitor struct {
+	UserPoolID string
+
+	// Capacity is the most users the pool can hold, e.g. a raised quota.
+	// Zero uses DefaultUserPoolCapacity.
+	Capacity int
+
+	// Threshold is the share of Capacity from which the pool is near
+	// capacity. Zero uses DefaultCapacityThreshold.
+	Threshold float64
+
+	near atomic.Bool
+}
+
+// Observe records the user count of the pool and logs when the pool gets
+// near capacity or leaves it again
+func (m *CapacityMonitor) Observe(count int, log logr.Logger) {
+	capacity := m.Capacity
+	if capacity <= 0 {
+		capacity = DefaultUserPoolCapacity
+	}
+	threshold := m.Threshold
+	if threshold <= 0 {
+		threshold = DefaultCapacityThreshold
+	}
+
+	used := float64(count) / float64(capacity)
+	near := used >= threshold
+	userPoolCapacityUsed.WithLabelValues(m.UserPoolID).Set(used)
+	if near {
+		userPoolNearCapacity.WithLabelValues(m.UserPoolID).Set(1)
+	} else {
+		userPoolNearCapacity.WithLabelValues(m.UserPoolID).Set(0)
+	}
+
+	switch was := m.near.Swap(near); {
+	case near && !was:
+		log.Info("User pool is near capacity", "userPoolId", m.UserPoolID, "users", count, "capacity", capacity)
+	case !near && was:
+		log.Info("User pool is no longer near capacity", "userPoolId", m.UserPoolID, "users", count,
+			"capacity", capacity)
+	}
+}
+
+// NearCapacity reports whether the last observed user count reached the
+// threshold. It is false for a nil monitor.
+func (m *CapacityMonitor) NearCapacity() bool {
+	return m != nil && m.near.Load()
+}
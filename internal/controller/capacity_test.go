@@ -0,0 +1,64 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCapacityMonitor(t *testing.T) {
+	var unset *CapacityMonitor
+	if unset.NearCapacity() {
+		t.Errorf("expected a nil monitor not to be near capacity")
+	}
+
+	m := &CapacityMonitor{UserPoolID: "capacity-test", Capacity: 100, Threshold: 0.9}
+	steps := []struct {
+		count int
+		near  bool
+	}{
+		{count: 50, near: false},
+		{count: 90, near: true},
+		{count: 95, near: true},
+		{count: 89, near: false},
+	}
+	for _, step := range steps {
+		m.Observe(step.count, logr.Discard())
+		if m.NearCapacity() != step.near {
+			t.Errorf("count %d: expected near capacity %v", step.count, step.near)
+		}
+		if got := testutil.ToFloat64(userPoolCapacityUsed.WithLabelValues("capacity-test")); got != float64(step.count)/100 {
+			t.Errorf("count %d: expected used ratio %v, got %v", step.count, float64(step.count)/100, got)
+		}
+		want := 0.0
+		if step.near {
+			want = 1
+		}
+		if got := testutil.ToFloat64(userPoolNearCapacity.WithLabelValues("capacity-test")); got != want {
+			t.Errorf("count %d: expected near capacity gauge %v, got %v", step.count, want, got)
+		}
+	}
+
+	defaults := &CapacityMonitor{UserPoolID: "capacity-default"}
+	defaults.Observe(DefaultUserPoolCapacity*8/10, logr.Discard())
+	if !defaults.NearCapacity() {
+		t.Errorf("expected the default threshold to apply to the default capacity")
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+	"strings"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// ClientMetadataAnnotationPrefix marks User annotations that are passed to the
+// user pool's Lambda triggers as client metadata when the email changes, e.g.
+// client-metadata.kcp.cogniteo.io/tenant: acme
+const ClientMetadataAnnotationPrefix = "client-metadata.kcp.cogniteo.io/"
+
+// sensitiveMetadataKeys are substrings of metadata keys that are never sent,
+// client metadata is not encrypted and Lambdas commonly log it
+var sensitiveMetadataKeys = []string{"password", "secret", "token", "credential"}
+
+// clientMetadata collects the client metadata annotations of the User. Keys
+// that look like they carry secrets are dropped and returned separately.
+func clientMetadata(user *kcpv1alpha1.User) (metadata map[string]string, dropped []string) {
+	for name, value := range user.Annotations {
+		key, ok := strings.CutPrefix(name, ClientMetadataAnnotationPrefix)
+		if !ok || key == "" {
+			continue
+		}
+		lower := strings.ToLower(key)
+		if slices.ContainsFunc(sensitiveMetadataKeys, func(s string) bool { return strings.Contains(lower, s) }) {
+			dropped = append(dropped, key)
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = value
+	}
+	slices.Sort(dropped)
+	return metadata, dropped
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+// withClusterContext records the logical cluster of req in ctx, where the
+// user pool client can pass it on as client metadata, and in the logger of
+// ctx, which it also returns. Requests without a cluster, e.g. outside kcp,
+// leave ctx unchanged apart from the logger.
+func withClusterContext(ctx context.Context, req mcreconcile.Request) (context.Context, logr.Logger) {
+	log := logf.FromContext(ctx).WithValues("cluster", req.ClusterName)
+	if req.ClusterName != "" {
+		ctx = mccontext.WithCluster(ctx, req.ClusterName)
+	}
+	return logf.IntoContext(ctx, log), log
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+func TestWithClusterContext(t *testing.T) {
+	ctx, _ := withClusterContext(context.Background(), mcreconcile.Request{ClusterName: "root:org:team"})
+	if cluster, ok := mccontext.ClusterFrom(ctx); !ok || cluster != "root:org:team" {
+		t.Errorf("expected cluster root:org:team in the context, got %q", cluster)
+	}
+
+	ctx, _ = withClusterContext(context.Background(), mcreconcile.Request{})
+	if cluster, ok := mccontext.ClusterFrom(ctx); ok {
+		t.Errorf("expected no cluster outside kcp, got %q", cluster)
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"text/template"
+
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// ConsistencyReport lists the differences between the Users and UserSets
+// and the user pool
+type ConsistencyReport struct {
+	// Missing are resources whose pool user doesn't exist
+	Missing []ConsistencyEntry `json:"missing"`
+	// Extra are pool users no resource manages
+	Extra []string `json:"extra"`
+	// Drifted are resources whose pool user differs from their spec
+	Drifted []ConsistencyEntry `json:"drifted"`
+	// Unchecked are resources whose desired state could not be determined,
+	// e.g. because an attribute template failed
+	Unchecked []ConsistencyEntry `json:"unchecked"`
+	// Warnings explain why the report may be unreliable, e.g. because the
+	// user pool changed while it was listed
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Consistent reports whether no differences were found
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Drifted) == 0 && len(r.Unchecked) == 0
+}
+
+// ConsistencyEntry is a resource or UserSet member found by a consistency
+// check
+type ConsistencyEntry struct {
+	// Resource is the kind and namespaced name, e.g. "User default/jane"
+	Resource string `json:"resource"`
+	Username string `json:"username,omitempty"`
+	// Fields are the drifted fields as reported by userpool.DiffUser
+	Fields []string `json:"fields,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ConsistencyChecker compares the Users and UserSets with the user pool
+// without changing either, e.g. for audits before a migration
+type ConsistencyChecker struct {
+	UserPoolClient userpool.Client
+
+	// Reader lists Users and UserSets and reads their attribute sources
+	Reader client.Reader
+
+	// AttributeTemplates are applied like by the UserReconciler. They should
+	// match the controller's --attribute-template flags.
+	AttributeTemplates map[string]*template.Template
+
+	// ChurnThreshold is the relative change of the pool's user count during
+	// the listing above which the report carries a warning. Zero uses
+	// userpool.DefaultChurnThreshold.
+	ChurnThreshold float64
+
+	// EmailVerifiedUnmanaged skips comparing email_verified, like the
+	// UserReconciler's field of the same name
+	EmailVerifiedUnmanaged bool
+
+	// Enricher adds attributes like the UserReconciler's field of the same
+	// name. Users it fails for are reported as unchecked.
+	Enricher AttributeEnricher
+
+	// Clock decides whether Users have expired. Nil uses the system time.
+	Clock userpool.Clock
+}
+
+// Check lists the user pool and all resources and reports every difference
+func (c *ConsistencyChecker) Check(ctx context.Context) (*ConsistencyReport, error) {
+	snapshot, err := userpool.ListUsersSnapshot(ctx, c.UserPoolClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users in user pool: %w", err)
+	}
+	poolUsers := snapshot.Users
+	byName := make(map[string]*userpool.User, len(poolUsers))
+	for _, user := range poolUsers {
+		byName[user.Username] = user
+	}
+
+	report := &ConsistencyReport{
+		Missing:   []ConsistencyEntry{},
+		Extra:     []string{},
+		Drifted:   []ConsistencyEntry{},
+		Unchecked: []ConsistencyEntry{},
+	}
+	if snapshot.Churned(c.ChurnThreshold) {
+		report.Warnings = append(report.Warnings, fmt.Sprintf(
+			"user pool changed from %d to %d users while %d pages were listed, differences may be missing or stale",
+			snapshot.CountBefore, snapshot.CountAfter, snapshot.Pages))
+	}
+	managed := make(map[string]bool)
+	compare := func(resource string, desired *userpool.User) {
+		managed[desired.Username] = true
+		actual, ok := byName[desired.Username]
+		if desired.Username == "" || !ok {
+			report.Missing = append(report.Missing, ConsistencyEntry{Resource: resource, Username: desired.Username})
+			return
+		}
+		if fields := userpool.DiffUser(desired, actual); len(fields) > 0 {
+			report.Drifted = append(report.Drifted,
+				ConsistencyEntry{Resource: resource, Username: desired.Username, Fields: fields})
+		}
+	}
+
+	var users kcpv1alpha1.UserList
+	if err := c.Reader.List(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to list Users: %w", err)
+	}
+	for i := range users.Items {
+		user := &users.Items[i]
+		resource := "User " + client.ObjectKeyFromObject(user).String()
+		desired, err := c.desiredUser(ctx, user)
+		if err != nil {
+			username := poolUsername(user)
+			managed[username] = true
+			report.Unchecked = append(report.Unchecked,
+				ConsistencyEntry{Resource: resource, Username: username, Error: err.Error()})
+			continue
+		}
+		compare(resource, desired)
+	}
+
+	var sets kcpv1alpha1.UserSetList
+	if err := c.Reader.List(ctx, &sets); err != nil {
+		return nil, fmt.Errorf("failed to list UserSets: %w", err)
+	}
+	for _, set := range sets.Items {
+		resource := "UserSet " + client.ObjectKeyFromObject(&set).String()
+		for _, member := range set.Spec.Users {
+			compare(resource, &userpool.User{
+				Username:   member.Username,
+				Email:      member.Email,
+				Enabled:    ptr.Deref(member.Enabled, true),
+				Attributes: member.Attributes,
+			})
+		}
+	}
+
+	for _, user := range poolUsers {
+		if !managed[user.Username] {
+			report.Extra = append(report.Extra, user.Username)
+		}
+	}
+	slices.Sort(report.Extra)
+	return report, nil
+}
+
+// desiredUser returns the pool user the UserReconciler converges the User to
+func (c *ConsistencyChecker) desiredUser(ctx context.Context, user *kcpv1alpha1.User) (*userpool.User, error) {
+	attributes, err := renderAttributes(c.AttributeTemplates, user)
+	if err != nil {
+		return nil, err
+	}
+	fromSources, _, err := resolveAttributeSources(ctx, c.Reader, user)
+	if err != nil {
+		return nil, err
+	}
+	attributes = mergeAttributeSources(attributes, fromSources, c.AttributeTemplates)
+	if c.Enricher != nil {
+		enriched, err := c.Enricher.Enrich(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		attributes = mergeAttributeSources(attributes, enriched, c.AttributeTemplates)
+	}
+
+	desired := &userpool.User{
+		Username:          poolUsername(user),
+		Email:             user.Spec.Email,
+		EmailVerified:     user.Spec.EmailVerified,
+		SecondaryEmail:    user.Spec.SecondaryEmail,
+		PreferredUsername: user.Spec.PreferredUsername,
+		Locale:            user.Spec.Locale,
+		Address:           poolAddress(user.Spec.Address),
+		Picture:           user.Spec.Picture,
+		Profile:           user.Spec.Profile,
+		Nickname:          user.Spec.Nickname,
+		Website:           user.Spec.Website,
+		ZoneInfo:          user.Spec.ZoneInfo,
+		Birthdate:         user.Spec.Birthdate,
+		Gender:            user.Spec.Gender,
+		Enabled:           ptr.Deref(user.Spec.Enabled, false),
+		Attributes:        attributes,
+	}
+	if desired.Enabled && userExpired(user, c.Clock) {
+		desired.Enabled = false
+	}
+	if !desired.Enabled {
+		desired.DisableReason = user.Spec.DisableReason
+	}
+	if c.EmailVerifiedUnmanaged {
+		desired.EmailVerified = nil
+	}
+	return desired, nil
+}
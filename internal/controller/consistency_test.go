@@ -0,0 +1,87 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestConsistencyChecker(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add kcpv1alpha1 scheme: %v", err)
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "default"},
+			Spec:       kcpv1alpha1.UserSpec{Email: "jane@example.com", Enabled: ptr.To(true)},
+		},
+		&kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "joe", Namespace: "default"},
+			Spec:       kcpv1alpha1.UserSpec{Email: "joe@example.com", Enabled: ptr.To(true)},
+		},
+		&kcpv1alpha1.UserSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+			Spec: kcpv1alpha1.UserSetSpec{Users: []kcpv1alpha1.UserSetMember{
+				{Username: "john", Email: "john@example.com"},
+			}},
+		},
+	).Build()
+
+	mock := cognito.NewMockClient()
+	for _, user := range []*userpool.User{
+		{Username: "jane", Email: "jane@example.com", Enabled: true},
+		{Username: "john", Email: "old@example.com", Enabled: true},
+		{Username: "orphan", Enabled: true},
+	} {
+		if err := mock.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+
+	checker := &ConsistencyChecker{UserPoolClient: mock, Reader: reader}
+	report, err := checker.Check(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if report.Consistent() {
+		t.Fatalf("expected differences to be found")
+	}
+	if len(report.Missing) != 1 || report.Missing[0].Resource != "User default/joe" {
+		t.Errorf("expected joe to be missing, got %+v", report.Missing)
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != "orphan" {
+		t.Errorf("expected orphan to be extra, got %v", report.Extra)
+	}
+	if len(report.Drifted) != 1 || report.Drifted[0].Username != "john" ||
+		len(report.Drifted[0].Fields) != 1 || report.Drifted[0].Fields[0] != "email" {
+		t.Errorf("expected the email of john to have drifted, got %+v", report.Drifted)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("expected no warnings for a pool that didn't change, got %v", report.Warnings)
+	}
+}
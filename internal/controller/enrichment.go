@@ -0,0 +1,121 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// defaultEnrichmentTimeout bounds a call to the enrichment service when
+// HTTPEnricher.Timeout is unset
+const defaultEnrichmentTimeout = 10 * time.Second
+
+// maxEnrichmentResponse is the largest enrichment response read, larger ones
+// fail
+const maxEnrichmentResponse = 1 << 20
+
+// AttributeEnricher computes additional attributes of a User at reconcile
+// time, e.g. a risk score from an internal service. An error fails the
+// reconcile before anything is written to the user pool.
+type AttributeEnricher interface {
+	Enrich(ctx context.Context, user *kcpv1alpha1.User) (map[string]string, error)
+}
+
+// EnrichmentRequest is the body an HTTPEnricher posts for a User
+type EnrichmentRequest struct {
+	Cluster    string            `json:"cluster,omitempty"`
+	Namespace  string            `json:"namespace"`
+	Name       string            `json:"name"`
+	Username   string            `json:"username,omitempty"`
+	Email      string            `json:"email,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// EnrichmentResponse is the body an enrichment service answers with
+type EnrichmentResponse struct {
+	Attributes map[string]string `json:"attributes"`
+}
+
+// HTTPEnricher enriches Users by posting an EnrichmentRequest as JSON to URL.
+// The service answers 200 with an EnrichmentResponse; any other status, an
+// undecodable body or a timeout is an error.
+type HTTPEnricher struct {
+	URL string
+
+	// Client sends the requests. Nil uses http.DefaultClient.
+	Client *http.Client
+
+	// Timeout bounds each call. Zero uses ten seconds.
+	Timeout time.Duration
+}
+
+// Enrich calls the enrichment service for user
+func (e *HTTPEnricher) Enrich(ctx context.Context, user *kcpv1alpha1.User) (map[string]string, error) {
+	cluster, _ := mccontext.ClusterFrom(ctx)
+	body, err := json.Marshal(EnrichmentRequest{
+		Cluster:    cluster,
+		Namespace:  user.Namespace,
+		Name:       user.Name,
+		Username:   poolUsername(user),
+		Email:      user.Spec.Email,
+		Attributes: user.Spec.Attributes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode enrichment request: %w", err)
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultEnrichmentTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create enrichment request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call enrichment service: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enrichment service answered %s", resp.Status)
+	}
+	var decoded EnrichmentResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnrichmentResponse)).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode enrichment response: %w", err)
+	}
+	return decoded.Attributes, nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+func TestHTTPEnricher(t *testing.T) {
+	user := &kcpv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "default"},
+		Spec:       kcpv1alpha1.UserSpec{Email: "jane@example.com"},
+	}
+	ctx := mccontext.WithCluster(context.Background(), "root:team-a")
+
+	t.Run("returns attributes", func(t *testing.T) {
+		var got EnrichmentRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			_ = json.NewEncoder(w).Encode(EnrichmentResponse{Attributes: map[string]string{"custom:risk": "low"}})
+		}))
+		defer server.Close()
+
+		attributes, err := (&HTTPEnricher{URL: server.URL}).Enrich(ctx, user)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if attributes["custom:risk"] != "low" {
+			t.Errorf("expected enriched attribute, got %v", attributes)
+		}
+		want := EnrichmentRequest{Cluster: "root:team-a", Namespace: "default", Name: "jane", Username: "jane",
+			Email: "jane@example.com"}
+		if got.Cluster != want.Cluster || got.Namespace != want.Namespace || got.Name != want.Name ||
+			got.Username != want.Username || got.Email != want.Email {
+			t.Errorf("expected request %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("fails on error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		if _, err := (&HTTPEnricher{URL: server.URL}).Enrich(ctx, user); err == nil {
+			t.Errorf("expected an error for a failed call")
+		}
+	})
+
+	t.Run("fails on timeout", func(t *testing.T) {
+		done := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(done)
+
+		_, err := (&HTTPEnricher{URL: server.URL, Timeout: 10 * time.Millisecond}).Enrich(ctx, user)
+		if err == nil {
+			t.Errorf("expected an error when the service doesn't answer in time")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	stderrors "errors"
+	"time"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// ErrorAction is how a User reconcile responds to an error syncing with the
+// user pool
+type ErrorAction string
+
+const (
+	// ErrorActionRetry reconciles the User again after a short fixed delay,
+	// e.g. to recreate a pool user deleted while it was being updated
+	ErrorActionRetry ErrorAction = "Retry"
+	// ErrorActionRetryWithBackoff returns the error, so the workqueue retries
+	// the User with exponential backoff
+	ErrorActionRetryWithBackoff ErrorAction = "RetryWithBackoff"
+	// ErrorActionFail reports the User not ready and waits for the next
+	// resync, as retrying won't help until the User or the pool change
+	ErrorActionFail ErrorAction = "Fail"
+	// ErrorActionIgnore logs the error and finishes the reconcile as if the
+	// sync had succeeded
+	ErrorActionIgnore ErrorAction = "Ignore"
+)
+
+// errorRetryDelay is how long ErrorActionRetry waits before the next reconcile
+const errorRetryDelay = time.Second
+
+// ErrorPolicy decides how a User reconcile responds to an error syncing with
+// the user pool. Classify is only called with non-nil errors. The Ready
+// condition reason is derived from the error independently of the action.
+type ErrorPolicy interface {
+	Classify(err error) ErrorAction
+}
+
+// ErrorPolicyFunc adapts a function to an ErrorPolicy
+type ErrorPolicyFunc func(err error) ErrorAction
+
+// Classify calls f
+func (f ErrorPolicyFunc) Classify(err error) ErrorAction {
+	return f(err)
+}
+
+// DefaultErrorPolicy retries right away when the pool user was not found, so
+// the next reconcile recreates it, fails on errors that need the User or the
+// pool to change first, such as rejected values, and retries everything else,
+// including throttling, with backoff. Custom policies can fall back to it for
+// the errors they don't handle.
+type DefaultErrorPolicy struct{}
+
+// Classify implements ErrorPolicy
+func (DefaultErrorPolicy) Classify(err error) ErrorAction {
+	switch {
+	case stderrors.Is(err, userpool.ErrUserNotFound):
+		return ErrorActionRetry
+	case stderrors.Is(err, userpool.ErrThrottled):
+		return ErrorActionRetryWithBackoff
+	case stderrors.Is(err, userpool.ErrInvalidParameter),
+		stderrors.Is(err, userpool.ErrAliasExists),
+		stderrors.Is(err, userpool.ErrInvalidUsername),
+		stderrors.Is(err, userpool.ErrNoUserPool),
+		stderrors.Is(err, userpool.ErrMFADisabled),
+		stderrors.Is(err, errDuplicateUser):
+		return ErrorActionFail
+	}
+	return ErrorActionRetryWithBackoff
+}
+
+// syncFailure returns the Ready condition reason and the log message for an
+// error syncing a User with the user pool
+func syncFailure(err error) (reason, message string) {
+	var missingGroups *userpool.MissingGroupsError
+	switch {
+	case stderrors.Is(err, userpool.ErrAliasExists):
+		return ReasonAliasExists, "Email is already used by another user"
+	case stderrors.Is(err, errDuplicateUser):
+		return ReasonDuplicateUser, "Username or email is claimed by another User"
+	case stderrors.Is(err, userpool.ErrInvalidUsername):
+		return ReasonInvalidUsername, "Username doesn't fit how users sign in to the user pool"
+	case stderrors.Is(err, userpool.ErrInvalidParameter):
+		return ReasonInvalidParameter, "User pool rejected a value of the User"
+	case stderrors.Is(err, userpool.ErrNoUserPool):
+		return ReasonNoUserPool, "No user pool is configured for the workspace"
+	case stderrors.Is(err, userpool.ErrMFADisabled):
+		return ReasonMFADisabled, "MFA is turned off for the user pool"
+	case stderrors.As(err, &missingGroups):
+		return ReasonGroupsMissing, "Failed to sync user with user pool"
+	case stderrors.Is(err, userpool.ErrThrottled):
+		return ReasonThrottled, "Failed to sync user with user pool"
+	}
+	return ReasonSyncFailed, "Failed to sync user with user pool"
+}
+
+// errorPolicy returns the configured ErrorPolicy or DefaultErrorPolicy
+func (r *UserReconciler) errorPolicy() ErrorPolicy {
+	if r.ErrorPolicy == nil {
+		return DefaultErrorPolicy{}
+	}
+	return r.ErrorPolicy
+}
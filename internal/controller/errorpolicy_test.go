@@ -0,0 +1,45 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestDefaultErrorPolicy(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorAction
+	}{
+		{fmt.Errorf("failed to update user jane: %w", userpool.ErrUserNotFound), ErrorActionRetry},
+		{fmt.Errorf("failed to list groups: %w", userpool.ErrThrottled), ErrorActionRetryWithBackoff},
+		{&userpool.InvalidParameterError{Field: "locale", Message: "invalid"}, ErrorActionFail},
+		{fmt.Errorf("failed to create user jane: %w", userpool.ErrAliasExists), ErrorActionFail},
+		{fmt.Errorf("jane is %w", errDuplicateUser), ErrorActionFail},
+		{userpool.ErrNoUserPool, ErrorActionFail},
+		{errors.New("connection reset"), ErrorActionRetryWithBackoff},
+	}
+	for _, tt := range tests {
+		if got := (DefaultErrorPolicy{}).Classify(tt.err); got != tt.want {
+			t.Errorf("expected %v to be classified %s, got %s", tt.err, tt.want, got)
+		}
+	}
+}
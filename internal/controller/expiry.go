@@ -0,0 +1,44 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// userExpired reports whether the spec.expiresAt of user has passed
+func userExpired(user *kcpv1alpha1.User, clock userpool.Clock) bool {
+	return user.Spec.ExpiresAt != nil && !userpool.Now(clock).Before(user.Spec.ExpiresAt.Time)
+}
+
+// signOutExpired signs an expired user out of all sessions before it is
+// disabled, so a failure is retried while the pool user is still enabled
+func (r *UserReconciler) signOutExpired(ctx context.Context, username string, log logr.Logger) error {
+	log.Info("User expired, signing out of all sessions", "username", r.pii(username))
+	err := r.UserPoolClient.SignOutUser(ctx, username)
+	if err != nil && !stderrors.Is(err, userpool.ErrUserNotFound) {
+		return fmt.Errorf("failed to sign out expired user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+	mcbuilder "sigs.k8s.io/multicluster-runtime/pkg/builder"
+	mcmanager "sigs.k8s.io/multicluster-runtime/pkg/manager"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+// GroupReconciler reconciles a Group object. The user pool group is created
+// or updated from the spec and deleted with the Group.
+type GroupReconciler struct {
+	Scheme      *runtime.Scheme
+	Manager     mcmanager.Manager
+	GroupClient userpool.GroupClient
+
+	// ResyncPeriod is the interval after which a successfully reconciled
+	// Group is reconciled again. Zero disables periodic resync.
+	ResyncPeriod time.Duration
+}
+
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=groups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=groups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=groups/finalizers,verbs=update
+
+// Reconcile converges the user pool group to the Group spec
+func (r *GroupReconciler) Reconcile(ctx context.Context, req mcreconcile.Request) (ctrl.Result, error) {
+	ctx, log := withClusterContext(ctx, req)
+	log.Info("Reconciling Group")
+
+	cl, err := r.Manager.GetCluster(ctx, req.ClusterName)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get cluster: %w", err)
+	}
+	clusterClient := cl.GetClient()
+
+	var group kcpv1alpha1.Group
+	if err := clusterClient.Get(ctx, req.NamespacedName, &group); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if r.GroupClient == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if !group.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&group, UserPoolFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.finalizeGroup(ctx, clusterClient, &group, log)
+	}
+	if controllerutil.AddFinalizer(&group, UserPoolFinalizer) {
+		if err := clusterClient.Update(ctx, &group); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	persisted := group.Status.DeepCopy()
+	if err := r.syncGroup(ctx, &group, log); err != nil {
+		log.Error(err, "Failed to sync group with user pool")
+		if condErr := r.setReadyCondition(ctx, clusterClient, &group, persisted,
+			metav1.ConditionFalse, ReasonSyncFailed, err.Error()); condErr != nil {
+			log.Error(condErr, "Failed to update Group status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	group.Status.ObservedGeneration = group.Generation
+	if err := r.setReadyCondition(ctx, clusterClient, &group, persisted,
+		metav1.ConditionTrue, ReasonReconciled, "Group is in sync with the user pool"); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// syncGroup creates the pool group or updates it if it differs from the spec.
+// A group that already exists in the pool is adopted.
+func (r *GroupReconciler) syncGroup(ctx context.Context, group *kcpv1alpha1.Group, log logr.Logger) error {
+	desired := &userpool.Group{
+		Name:        poolGroupName(group),
+		Description: group.Spec.Description,
+		Precedence:  group.Spec.Precedence,
+		RoleARN:     group.Spec.RoleARN,
+	}
+
+	existing, err := r.GroupClient.GetGroup(ctx, desired.Name)
+	switch {
+	case stderrors.Is(err, userpool.ErrGroupNotFound):
+		log.Info("Creating group in user pool", "group", desired.Name)
+		if err := r.GroupClient.CreateGroup(ctx, desired); err != nil {
+			return fmt.Errorf("failed to create group in user pool: %w", err)
+		}
+		group.Status.GroupName = desired.Name
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to get group from user pool: %w", err)
+	}
+
+	group.Status.GroupName = desired.Name
+	if !groupChanged(desired, existing) {
+		return nil
+	}
+	log.Info("Updating group in user pool", "group", desired.Name)
+	if err := r.GroupClient.UpdateGroup(ctx, desired); err != nil {
+		return fmt.Errorf("failed to update group in user pool: %w", err)
+	}
+	return nil
+}
+
+// groupChanged reports whether the pool group differs from the desired one in
+// a field the spec sets. Unset fields are left to the pool.
+func groupChanged(desired, existing *userpool.Group) bool {
+	return (desired.Description != "" && desired.Description != existing.Description) ||
+		(desired.RoleARN != "" && desired.RoleARN != existing.RoleARN) ||
+		(desired.Precedence != nil && !equality.Semantic.DeepEqual(desired.Precedence, existing.Precedence))
+}
+
+// finalizeGroup deletes the pool group, unless the Group has the retain
+// annotation, and removes the finalizer
+func (r *GroupReconciler) finalizeGroup(ctx context.Context, c client.Client, group *kcpv1alpha1.Group,
+	log logr.Logger) error {
+	name := poolGroupName(group)
+	if group.Annotations[RetainAnnotation] == "true" {
+		log.Info("Retaining group in user pool", "group", name)
+	} else {
+		err := r.GroupClient.DeleteGroup(ctx, name)
+		if err != nil && !stderrors.Is(err, userpool.ErrGroupNotFound) {
+			return fmt.Errorf("failed to delete group from user pool: %w", err)
+		}
+		log.Info("Group deleted from user pool", "group", name)
+	}
+
+	controllerutil.RemoveFinalizer(group, UserPoolFinalizer)
+	if err := c.Update(ctx, group); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	return nil
+}
+
+// poolGroupName returns the name of the Group's pool group: the name it was
+// created with, else spec.groupName, else the object name
+func poolGroupName(group *kcpv1alpha1.Group) string {
+	switch {
+	case group.Status.GroupName != "":
+		return group.Status.GroupName
+	case group.Spec.GroupName != "":
+		return group.Spec.GroupName
+	default:
+		return group.Name
+	}
+}
+
+// setReadyCondition sets the Ready condition on the Group and updates its
+// status if it differs from the persisted status
+func (r *GroupReconciler) setReadyCondition(ctx context.Context, c client.Client, group *kcpv1alpha1.Group,
+	persisted *kcpv1alpha1.GroupStatus, status metav1.ConditionStatus, reason, message string) error {
+	meta.SetStatusCondition(&group.Status.Conditions, metav1.Condition{
+		Type:               kcpv1alpha1.ConditionTypeReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: group.Generation,
+	})
+	if equality.Semantic.DeepEqual(persisted, &group.Status) {
+		return nil
+	}
+	if err := c.Status().Update(ctx, group); err != nil {
+		return fmt.Errorf("failed to update Group status: %w", err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GroupReconciler) SetupWithManager(mgr mcmanager.Manager) error {
+	return mcbuilder.ControllerManagedBy(mgr).
+		For(&kcpv1alpha1.Group{}, mcbuilder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("group").
+		Complete(mcreconcile.Func(r.Reconcile))
+}
+
+// enqueueUsersMissingGroup enqueues the Users of the Group's cluster that wait
+// for its pool group, so they don't have to wait for the GroupsMissing retry
+func (r *UserReconciler) enqueueUsersMissingGroup(clusterName string,
+	cl cluster.Cluster) handler.TypedEventHandler[client.Object, mcreconcile.Request] {
+	return handler.TypedEnqueueRequestsFromMapFunc(func(ctx context.Context,
+		obj client.Object) []mcreconcile.Request {
+		group, ok := obj.(*kcpv1alpha1.Group)
+		if !ok {
+			return nil
+		}
+		name := poolGroupName(group)
+
+		var users kcpv1alpha1.UserList
+		if err := cl.GetClient().List(ctx, &users); err != nil {
+			return nil
+		}
+		var requests []mcreconcile.Request
+		for i := range users.Items {
+			user := &users.Items[i]
+			ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+			if ready == nil || (ready.Reason != ReasonGroupsMissing && ready.Reason != ReasonGroupsPending) {
+				continue
+			}
+			groups, err := r.desiredGroups(user)
+			if err != nil || !slices.Contains(groups, name) {
+				continue
+			}
+			requests = append(requests, mcreconcile.Request{
+				ClusterName: clusterName,
+				Request:     reconcile.Request{NamespacedName: client.ObjectKeyFromObject(user)},
+			})
+		}
+		return requests
+	})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestGroupReconciler_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add kcpv1alpha1 scheme: %v", err)
+	}
+
+	namespacedName := types.NamespacedName{Name: "admins", Namespace: "default"}
+	req := mcreconcile.Request{ClusterName: "cluster1", Request: reconcile.Request{NamespacedName: namespacedName}}
+
+	t.Run("creates, updates and deletes the pool group", func(t *testing.T) {
+		group := &kcpv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name, Namespace: namespacedName.Namespace},
+			Spec:       kcpv1alpha1.GroupSpec{Description: "Admins", Precedence: ptr.To[int32](1)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(group).
+			WithStatusSubresource(group).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}}
+		mock := cognito.NewMockClient()
+		r := &GroupReconciler{Scheme: scheme, Manager: mgr, GroupClient: mock}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		created, err := mock.GetGroup(context.Background(), "admins")
+		if err != nil || created.Description != "Admins" || ptr.Deref(created.Precedence, 0) != 1 {
+			t.Fatalf("expected group to be created, got %+v, %v", created, err)
+		}
+
+		var updated kcpv1alpha1.Group
+		if err := fakeClient.Get(context.Background(), namespacedName, &updated); err != nil {
+			t.Fatalf("failed to get Group: %v", err)
+		}
+		if updated.Status.GroupName != "admins" {
+			t.Errorf("expected status.groupName admins, got %q", updated.Status.GroupName)
+		}
+		if !meta.IsStatusConditionTrue(updated.Status.Conditions, kcpv1alpha1.ConditionTypeReady) {
+			t.Errorf("expected Ready condition to be true, got %+v", updated.Status.Conditions)
+		}
+
+		updated.Spec.Precedence = ptr.To[int32](5)
+		if err := fakeClient.Update(context.Background(), &updated); err != nil {
+			t.Fatalf("failed to update Group: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if changed, _ := mock.GetGroup(context.Background(), "admins"); ptr.Deref(changed.Precedence, 0) != 5 {
+			t.Errorf("expected precedence 5, got %+v", changed)
+		}
+
+		if err := fakeClient.Delete(context.Background(), &updated); err != nil {
+			t.Fatalf("failed to delete Group: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetGroup(context.Background(), "admins"); err == nil {
+			t.Errorf("expected group to be deleted from the user pool")
+		}
+	})
+
+	t.Run("adopts an existing pool group", func(t *testing.T) {
+		group := &kcpv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name, Namespace: namespacedName.Namespace},
+			Spec:       kcpv1alpha1.GroupSpec{GroupName: "tenant-admins", RoleARN: "arn:aws:iam::1:role/admins"},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(group).
+			WithStatusSubresource(group).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}}
+		mock := cognito.NewMockClient()
+		if err := mock.CreateGroup(context.Background(), &userpool.Group{
+			Name: "tenant-admins", Description: "Managed in the console",
+		}); err != nil {
+			t.Fatalf("failed to seed group: %v", err)
+		}
+		r := &GroupReconciler{Scheme: scheme, Manager: mgr, GroupClient: mock}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		adopted, err := mock.GetGroup(context.Background(), "tenant-admins")
+		if err != nil || adopted.RoleARN != "arn:aws:iam::1:role/admins" ||
+			adopted.Description != "Managed in the console" {
+			t.Errorf("expected group to be updated and keep its description, got %+v, %v", adopted, err)
+		}
+	})
+}
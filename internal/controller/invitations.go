@@ -0,0 +1,142 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// syncTemporaryPassword records when the temporary password of a pool user
+// that never signed in expires. With ResendExpiredInvitations, or the Resend
+// invitation policy, an expired password is replaced by sending the
+// invitation again.
+func (r *UserReconciler) syncTemporaryPassword(ctx context.Context, user *kcpv1alpha1.User,
+	poolUser *userpool.User, log logr.Logger) error {
+	if r.TemporaryPasswordValidity <= 0 || poolUser.Status != userpool.StatusForceChangePassword {
+		user.Status.TemporaryPasswordExpiresAt = nil
+		return nil
+	}
+
+	// The password was issued at creation or by the last resend
+	issued := poolUser.CreatedAt
+	if resent := user.Status.InvitationResentAt; resent != nil && resent.After(issued) {
+		issued = resent.Time
+	}
+	expiresAt := issued.Add(r.TemporaryPasswordValidity)
+	now := userpool.Now(r.Clock)
+	if now.Before(expiresAt) {
+		user.Status.TemporaryPasswordExpiresAt = &metav1.Time{Time: expiresAt}
+		return nil
+	}
+	if !r.resendsExpired(user) {
+		log.Info("Temporary password expired before the user signed in", "username", r.pii(poolUser.Username))
+		user.Status.TemporaryPasswordExpiresAt = &metav1.Time{Time: expiresAt}
+		return nil
+	}
+
+	log.Info("Resending invitation with an expired temporary password", "username", r.pii(poolUser.Username))
+	err := r.UserPoolClient.ResendInvitation(ctx, poolUser.Username)
+	if stderrors.Is(err, userpool.ErrUserAlreadyConfirmed) {
+		// The user signed in since the pool user was read
+		user.Status.TemporaryPasswordExpiresAt = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resend invitation: %w", err)
+	}
+	user.Status.InvitationResentAt = &metav1.Time{Time: now}
+	user.Status.TemporaryPasswordExpiresAt = &metav1.Time{Time: now.Add(r.TemporaryPasswordValidity)}
+	return nil
+}
+
+// syncPasswordChange handles spec.forcePasswordChange once: a user with a
+// permanent password gets a temporary one with the invitation message, a
+// user that already has to choose a new password is left alone. Clearing
+// the field allows the next change to be forced. It reports whether the
+// password was replaced.
+func (r *UserReconciler) syncPasswordChange(ctx context.Context, user *kcpv1alpha1.User,
+	poolUser *userpool.User, log logr.Logger) (bool, error) {
+	if !user.Spec.ForcePasswordChange {
+		user.Status.PasswordChangeForcedAt = nil
+		return false, nil
+	}
+	if user.Status.PasswordChangeForcedAt != nil {
+		return false, nil
+	}
+	now := &metav1.Time{Time: userpool.Now(r.Clock)}
+	switch poolUser.Status {
+	case userpool.StatusConfirmed:
+	case userpool.StatusForceChangePassword, userpool.StatusResetRequired:
+		// The user has to choose a new password anyway
+		user.Status.PasswordChangeForcedAt = now
+		return false, nil
+	default:
+		// E.g. unconfirmed or federated users, who have no password to change
+		return false, nil
+	}
+
+	log.Info("Forcing password change", "username", r.pii(poolUser.Username))
+	if err := r.UserPoolClient.ForcePasswordChange(ctx, poolUser.Username); err != nil {
+		return false, fmt.Errorf("failed to force password change: %w", err)
+	}
+	user.Status.PasswordChangeForcedAt = now
+	user.Status.InvitationResentAt = now
+	return true, nil
+}
+
+// resyncAfter returns the ResyncPeriod, shortened so that a temporary
+// password expiring before the next resync is resent when it expires, and a
+// User expiring before the next resync is disabled when it expires
+func (r *UserReconciler) resyncAfter(user *kcpv1alpha1.User) time.Duration {
+	after := r.ResyncPeriod
+	if r.resendsExpired(user) {
+		after = r.untilDeadline(after, user.Status.TemporaryPasswordExpiresAt)
+	}
+	return r.untilDeadline(after, user.Spec.ExpiresAt)
+}
+
+// untilDeadline shortens after to the time left until deadline if that is
+// sooner. Passed and nil deadlines leave after unchanged.
+func (r *UserReconciler) untilDeadline(after time.Duration, deadline *metav1.Time) time.Duration {
+	if deadline == nil {
+		return after
+	}
+	// Leave a second for timestamps being truncated to seconds
+	until := deadline.Sub(userpool.Now(r.Clock)) + time.Second
+	if until <= 0 {
+		return after
+	}
+	if after <= 0 || until < after {
+		return until
+	}
+	return after
+}
+
+// resendsExpired reports whether the invitation of user is sent again once
+// its temporary password expired
+func (r *UserReconciler) resendsExpired(user *kcpv1alpha1.User) bool {
+	return r.ResendExpiredInvitations || user.Spec.Invitation == kcpv1alpha1.InvitationResend
+}
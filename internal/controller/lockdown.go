@@ -0,0 +1,63 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// DisableUsers sets spec.enabled to false on every User in namespace that
+// matches selector and records reason as its spec.disableReason. The
+// UserReconciler then disables the pool users, which also revokes their
+// tokens. Users that are already disabled are left alone. An empty namespace
+// selects Users in all namespaces. It returns the number of Users changed,
+// also when it fails part way.
+func DisableUsers(ctx context.Context, c client.Client, namespace string, selector labels.Selector,
+	reason string) (int, error) {
+	if selector == nil || selector.Empty() {
+		return 0, fmt.Errorf("refusing to disable users without a label selector")
+	}
+
+	var users kcpv1alpha1.UserList
+	if err := c.List(ctx, &users, client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return 0, fmt.Errorf("failed to list Users: %w", err)
+	}
+
+	disabled := 0
+	for i := range users.Items {
+		user := &users.Items[i]
+		if user.Spec.Enabled != nil && !*user.Spec.Enabled {
+			continue
+		}
+		patch := client.MergeFrom(user.DeepCopy())
+		user.Spec.Enabled = ptr.To(false)
+		user.Spec.DisableReason = reason
+		if err := c.Patch(ctx, user, patch); err != nil {
+			return disabled, fmt.Errorf("failed to disable User %s/%s: %w", user.Namespace, user.Name, err)
+		}
+		disabled++
+	}
+	return disabled, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+func TestDisableUsers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add kcpv1alpha1 scheme: %v", err)
+	}
+	user := func(name, tenant string, enabled bool) *kcpv1alpha1.User {
+		return &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"tenant": tenant}},
+			Spec:       kcpv1alpha1.UserSpec{Enabled: ptr.To(enabled)},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		user("jane", "acme", true),
+		user("john", "acme", false),
+		user("joe", "other", true),
+	).Build()
+
+	if _, err := DisableUsers(context.Background(), c, "", labels.Everything(), "x"); err == nil {
+		t.Errorf("expected an empty selector to be refused")
+	}
+
+	disabled, err := DisableUsers(context.Background(), c, "",
+		labels.SelectorFromSet(labels.Set{"tenant": "acme"}), "compromised")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if disabled != 1 {
+		t.Errorf("expected 1 user disabled, got %d", disabled)
+	}
+
+	for name, want := range map[string]bool{"jane": false, "john": false, "joe": true} {
+		var got kcpv1alpha1.User
+		if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: name}, &got); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if *got.Spec.Enabled != want {
+			t.Errorf("user %s: expected enabled=%v, got %v", name, want, *got.Spec.Enabled)
+		}
+	}
+	var jane kcpv1alpha1.User
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "jane"}, &jane); err != nil {
+		t.Fatalf("failed to get user: %v", err)
+	}
+	if jane.Spec.DisableReason != "compromised" {
+		t.Errorf("expected disable reason to be recorded, got %q", jane.Spec.DisableReason)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+var (
+	// managedUsers tracks the number of users in each user pool
+	managedUsers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kcp_users_managed_users",
+		Help: "Number of users in the user pool managed by the controller",
+	}, []string{"user_pool_id"})
+
+	// reconcileResults counts reconciles by what happened to the pool user
+	reconcileResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kcp_users_reconcile_results_total",
+		Help: "Number of User reconciles by outcome",
+	}, []string{"outcome"})
+
+	// cognitoOperations observes the duration of Cognito API calls
+	cognitoOperations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kcp_users_cognito_operation_duration_seconds",
+		Help:    "Duration of Cognito API calls including retries, by operation and result",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"operation", "result"})
+
+	// cognitoLimitWaits observes how long Cognito calls waited for the
+	// request limits of their user pool
+	cognitoLimitWaits = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kcp_users_cognito_limit_wait_seconds",
+		Help:    "Time Cognito API calls waited for the request limits of their user pool",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"user_pool_id"})
+)
+
+// OperationMetrics records Cognito API calls in the
+// kcp_users_cognito_operation_duration_seconds histogram. It implements
+// cognito.OperationHook.
+type OperationMetrics struct{}
+
+// OnOperation observes one Cognito API call
+func (OperationMetrics) OnOperation(op string, dur time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	cognitoOperations.WithLabelValues(op, result).Observe(dur.Seconds())
+}
+
+// LimitMetrics records the waits for the request limits of one user pool in
+// the kcp_users_cognito_limit_wait_seconds histogram
+type LimitMetrics struct {
+	UserPoolID string
+}
+
+// OnWait observes the wait of one Cognito API call. It is meant as
+// cognito.RequestLimits.OnWait.
+func (m LimitMetrics) OnWait(op string, wait time.Duration) {
+	cognitoLimitWaits.WithLabelValues(m.UserPoolID).Observe(wait.Seconds())
+}
+
+// reconcileOutcome is the reconcileResults label describing what a reconcile
+// did to the pool user
+type reconcileOutcome string
+
+const (
+	outcomeCreated   reconcileOutcome = "created"
+	outcomeUpdated   reconcileOutcome = "updated"
+	outcomeUnchanged reconcileOutcome = "unchanged"
+	outcomeDeleted   reconcileOutcome = "deleted"
+	outcomeError     reconcileOutcome = "error"
+)
+
+func init() {
+	metrics.Registry.MustRegister(managedUsers, reconcileResults, cognitoOperations, cognitoLimitWaits)
+}
+
+// UserCountRefresher periodically sets the managed users gauge from the user
+// count of the user pool. Between refreshes the reconciler adjusts the gauge
+// on every create and delete.
+type UserCountRefresher struct {
+	UserPoolClient userpool.Client
+	UserPoolID     string
+	Interval       time.Duration
+
+	// Capacity, if set, is given every count to compare it with the
+	// capacity of the pool
+	Capacity *CapacityMonitor
+}
+
+// Start refreshes the gauge immediately and then every Interval until ctx is
+// done. It implements manager.Runnable.
+func (r *UserCountRefresher) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("user-count")
+
+	refresh := func() {
+		count, err := r.UserPoolClient.CountUsers(ctx)
+		if err != nil {
+			log.Error(err, "Failed to count users")
+			return
+		}
+		managedUsers.WithLabelValues(r.UserPoolID).Set(float64(count))
+		if r.Capacity != nil {
+			r.Capacity.Observe(count, log)
+		}
+	}
+
+	refresh()
+	if r.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// failingCountClient fails every CountUsers
+type failingCountClient struct {
+	userpool.Client
+}
+
+func (c failingCountClient) CountUsers(ctx context.Context) (int, error) {
+	return 0, fmt.Errorf("failed to count users: %w", userpool.ErrThrottled)
+}
+
+func TestUserCountRefresher(t *testing.T) {
+	mock := cognito.NewMockClient()
+	for _, username := range []string{"jane", "john", "joan"} {
+		if err := mock.CreateUser(context.Background(), &userpool.User{Username: username, Enabled: true}); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	gauge := managedUsers.WithLabelValues("pool-refresh")
+	gauge.Set(42)
+
+	refresher := &UserCountRefresher{UserPoolClient: mock, UserPoolID: "pool-refresh"}
+	if err := refresher.Start(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := testutil.ToFloat64(gauge); got != 3 {
+		t.Errorf("expected the gauge to be refreshed to 3, got %v", got)
+	}
+
+	failing := &UserCountRefresher{UserPoolClient: failingCountClient{Client: mock}, UserPoolID: "pool-refresh"}
+	if err := failing.Start(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := testutil.ToFloat64(gauge); got != 3 {
+		t.Errorf("expected a failed count to keep the gauge at 3, got %v", got)
+	}
+}
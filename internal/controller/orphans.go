@@ -0,0 +1,219 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// OrphanPolicy decides what happens to pool users that no User or UserSet
+// manages
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyIgnore leaves orphans alone
+	OrphanPolicyIgnore OrphanPolicy = "Ignore"
+	// OrphanPolicyWarn logs orphans and reports them in the
+	// kcp_users_orphaned_users metric
+	OrphanPolicyWarn OrphanPolicy = "Warn"
+	// OrphanPolicyDelete deletes orphans from the user pool
+	OrphanPolicyDelete OrphanPolicy = "Delete"
+)
+
+// ParseOrphanPolicy parses an OrphanPolicy name
+func ParseOrphanPolicy(s string) (OrphanPolicy, error) {
+	switch policy := OrphanPolicy(s); policy {
+	case OrphanPolicyIgnore, OrphanPolicyWarn, OrphanPolicyDelete:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid orphan policy %q, expected %s, %s or %s", s,
+			OrphanPolicyIgnore, OrphanPolicyWarn, OrphanPolicyDelete)
+	}
+}
+
+// orphanedUsers tracks the pool users found by the last sweep that no
+// resource manages
+var orphanedUsers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "kcp_users_orphaned_users",
+	Help: "Number of users in the user pool not managed by any User or UserSet",
+}, []string{"user_pool_id"})
+
+func init() {
+	metrics.Registry.MustRegister(orphanedUsers)
+}
+
+// OrphanSweeper periodically compares the user pool with the Users and
+// UserSets of all clusters and applies its Policy to pool users no resource
+// manages.
+type OrphanSweeper struct {
+	UserPoolClient userpool.Client
+	UserPoolID     string
+
+	// Reader lists Users and UserSets across all clusters, e.g. the
+	// provider's wildcard cache
+	Reader client.Reader
+
+	Policy   OrphanPolicy
+	Interval time.Duration
+
+	// MinAge skips pool users modified more recently than this, so users
+	// whose resource hasn't recorded them yet are not taken for orphans
+	MinAge time.Duration
+
+	// MaxDeletes is the most orphans a sweep deletes under
+	// OrphanPolicyDelete. A sweep finding more deletes none of them, since
+	// that usually means the resources could not be listed completely.
+	MaxDeletes int
+
+	// RedactPII logs pool usernames as a stable hash instead of in clear text
+	RedactPII bool
+
+	// Clock is compared with MinAge. Nil uses the system time.
+	Clock userpool.Clock
+}
+
+// Start sweeps immediately and then every Interval until ctx is done. It
+// implements manager.Runnable.
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	if s.Policy == OrphanPolicyIgnore || s.Policy == "" {
+		return nil
+	}
+	log := logf.FromContext(ctx).WithName("orphans")
+
+	sweep := func() {
+		if err := s.Sweep(ctx, log); err != nil {
+			log.Error(err, "Failed to sweep orphaned users")
+		}
+	}
+
+	sweep()
+	if s.Interval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
+
+// Sweep finds the current orphans, reports them and deletes them if the
+// policy says so
+func (s *OrphanSweeper) Sweep(ctx context.Context, log logr.Logger) error {
+	// List the pool first: a user created after this is not considered, and
+	// a resource created after it is listed below. Only the username and
+	// modification time are compared.
+	poolUsers, err := s.UserPoolClient.ListUsersProjected(ctx, userpool.Projection{Timestamps: true})
+	if err != nil {
+		return fmt.Errorf("failed to list users in user pool: %w", err)
+	}
+	managed, err := s.managedUsernames(ctx)
+	if err != nil {
+		return err
+	}
+
+	cutoff := userpool.Now(s.Clock).Add(-s.MinAge)
+	var orphans []string
+	for _, user := range poolUsers {
+		if managed[user.Username] || user.LastModified.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, user.Username)
+	}
+	orphanedUsers.WithLabelValues(s.UserPoolID).Set(float64(len(orphans)))
+
+	for _, username := range orphans {
+		log.Info("Found user not managed by any User or UserSet", "username", s.pii(username))
+	}
+	if s.Policy != OrphanPolicyDelete || len(orphans) == 0 {
+		return nil
+	}
+	if len(orphans) > s.MaxDeletes {
+		return fmt.Errorf("refusing to delete %d orphaned users, more than the limit of %d",
+			len(orphans), s.MaxDeletes)
+	}
+
+	for _, username := range orphans {
+		err := s.UserPoolClient.DeleteUser(ctx, username)
+		switch {
+		case stderrors.Is(err, userpool.ErrUserNotFound):
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to delete orphaned user: %w", err)
+		}
+		managedUsers.WithLabelValues(s.UserPoolID).Dec()
+		log.Info("Orphaned user deleted from user pool", "username", s.pii(username))
+	}
+	orphanedUsers.WithLabelValues(s.UserPoolID).Set(0)
+	return nil
+}
+
+// managedUsernames returns the pool usernames of all Users and UserSet
+// members, including members still being removed
+func (s *OrphanSweeper) managedUsernames(ctx context.Context) (map[string]bool, error) {
+	managed := make(map[string]bool)
+
+	var users kcpv1alpha1.UserList
+	if err := s.Reader.List(ctx, &users); err != nil {
+		return nil, fmt.Errorf("failed to list Users: %w", err)
+	}
+	for i := range users.Items {
+		if username := poolUsername(&users.Items[i]); username != "" {
+			managed[username] = true
+		}
+	}
+
+	var sets kcpv1alpha1.UserSetList
+	if err := s.Reader.List(ctx, &sets); err != nil {
+		return nil, fmt.Errorf("failed to list UserSets: %w", err)
+	}
+	for _, set := range sets.Items {
+		for _, member := range set.Spec.Users {
+			managed[member.Username] = true
+		}
+		for _, member := range set.Status.Users {
+			managed[member.Username] = true
+		}
+	}
+	return managed, nil
+}
+
+// pii returns value, or its hash when PII redaction is enabled
+func (s *OrphanSweeper) pii(value string) string {
+	if s.RedactPII {
+		return userpool.Redact(value)
+	}
+	return value
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestOrphanSweeper(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add kcpv1alpha1 scheme: %v", err)
+	}
+
+	setup := func(t *testing.T) (*cognito.MockClient, *OrphanSweeper) {
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&kcpv1alpha1.User{ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "default"}},
+			&kcpv1alpha1.UserSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "default"},
+				Spec:       kcpv1alpha1.UserSetSpec{Users: []kcpv1alpha1.UserSetMember{{Username: "john"}}},
+			},
+		).Build()
+		mock := cognito.NewMockClient()
+		for _, username := range []string{"jane", "john", "orphan"} {
+			if err := mock.CreateUser(context.Background(), &userpool.User{Username: username}); err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+		}
+		return mock, &OrphanSweeper{UserPoolClient: mock, Reader: reader, MaxDeletes: 1}
+	}
+
+	t.Run("warn keeps orphans", func(t *testing.T) {
+		mock, sweeper := setup(t)
+		sweeper.Policy = OrphanPolicyWarn
+		if err := sweeper.Sweep(context.Background(), logr.Discard()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "orphan"); err != nil {
+			t.Errorf("expected orphan to be kept, got %v", err)
+		}
+	})
+
+	t.Run("delete removes only orphans", func(t *testing.T) {
+		mock, sweeper := setup(t)
+		sweeper.Policy = OrphanPolicyDelete
+		if err := sweeper.Sweep(context.Background(), logr.Discard()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "orphan"); err == nil {
+			t.Errorf("expected orphan to be deleted")
+		}
+		for _, username := range []string{"jane", "john"} {
+			if _, err := mock.GetUser(context.Background(), username); err != nil {
+				t.Errorf("expected managed user %s to be kept, got %v", username, err)
+			}
+		}
+	})
+
+	t.Run("delete refuses more than the limit", func(t *testing.T) {
+		mock, sweeper := setup(t)
+		sweeper.Policy = OrphanPolicyDelete
+		sweeper.MaxDeletes = 0
+		if err := sweeper.Sweep(context.Background(), logr.Discard()); err == nil {
+			t.Fatalf("expected an error when the limit is exceeded")
+		}
+		if _, err := mock.GetUser(context.Background(), "orphan"); err != nil {
+			t.Errorf("expected orphan to be kept, got %v", err)
+		}
+	})
+
+	t.Run("recently modified users are skipped", func(t *testing.T) {
+		mock, sweeper := setup(t)
+		sweeper.Policy = OrphanPolicyDelete
+		sweeper.MinAge = time.Hour
+		if err := sweeper.Sweep(context.Background(), logr.Discard()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "orphan"); err != nil {
+			t.Errorf("expected recent orphan to be kept, got %v", err)
+		}
+	})
+
+	t.Run("orphans older than the minimum age are deleted", func(t *testing.T) {
+		mock, sweeper := setup(t)
+		sweeper.Policy = OrphanPolicyDelete
+		sweeper.MinAge = time.Hour
+		sweeper.Clock = fixedClock(time.Now().Add(2 * time.Hour))
+		if err := sweeper.Sweep(context.Background(), logr.Discard()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "orphan"); err == nil {
+			t.Errorf("expected old orphan to be deleted")
+		}
+	})
+}
+
+// fixedClock is a userpool.Clock that always returns the same time
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+const (
+	// defaultGroupPendingRequeue is the first delay of a User waiting for a
+	// Group resource to create its pool group
+	defaultGroupPendingRequeue = 5 * time.Second
+	// maxGroupPendingRequeue caps the doubled delay at the retry interval of
+	// Users whose groups are missing
+	maxGroupPendingRequeue = 5 * time.Minute
+)
+
+// pendingBackoff doubles the requeue delay of a request every time in a row
+// it waits for something to appear, e.g. a group. The zero value is ready to
+// use.
+type pendingBackoff struct {
+	mu       sync.Mutex
+	attempts map[mcreconcile.Request]int
+}
+
+// next counts a wait of req and returns its delay: base, doubled for every
+// earlier wait since the last reset, up to limit
+func (b *pendingBackoff) next(req mcreconcile.Request, base, limit time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.attempts == nil {
+		b.attempts = make(map[mcreconcile.Request]int)
+	}
+	delay := base
+	for range b.attempts[req] {
+		if delay *= 2; delay >= limit {
+			delay = limit
+			break
+		}
+	}
+	b.attempts[req]++
+	return delay
+}
+
+// reset forgets the waits of req
+func (b *pendingBackoff) reset(req mcreconcile.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.attempts, req)
+}
+
+// groupsPending reports whether every missing group is the pool group of a
+// Group resource in the cluster that isn't being deleted, so it will exist
+// once that Group is reconciled. Groups without a resource are
+// misconfigured, and so are all groups if Groups can't be listed.
+func groupsPending(ctx context.Context, c client.Reader, missing []string) bool {
+	var groups kcpv1alpha1.GroupList
+	if err := c.List(ctx, &groups); err != nil {
+		return false
+	}
+	var pending []string
+	for i := range groups.Items {
+		if groups.Items[i].DeletionTimestamp.IsZero() {
+			pending = append(pending, poolGroupName(&groups.Items[i]))
+		}
+	}
+	for _, name := range missing {
+		if !slices.Contains(pending, name) {
+			return false
+		}
+	}
+	return len(missing) > 0
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+func TestPendingBackoff(t *testing.T) {
+	var b pendingBackoff
+	jane := mcreconcile.Request{ClusterName: "a", Request: reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "jane"},
+	}}
+	john := mcreconcile.Request{ClusterName: "a", Request: reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "john"},
+	}}
+
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if got := b.next(jane, time.Second, 5*time.Second); got != want {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+	if got := b.next(jane, time.Second, 5*time.Second); got != 5*time.Second {
+		t.Errorf("expected the delay to stay at the limit, got %v", got)
+	}
+	if got := b.next(john, time.Second, 5*time.Second); got != time.Second {
+		t.Errorf("expected other requests to start over, got %v", got)
+	}
+
+	b.reset(jane)
+	if got := b.next(jane, time.Second, 5*time.Second); got != time.Second {
+		t.Errorf("expected the delay to start over after a reset, got %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"maps"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// crReference returns the reference written to the pool user of obj, in the
+// form "<workspace>|<namespace>/<name>", or "<namespace>/<name>" if the
+// workspace is unknown
+func crReference(obj client.Object) string {
+	reference := obj.GetNamespace() + "/" + obj.GetName()
+	if cluster := obj.GetAnnotations()[clusterAnnotation]; cluster != "" {
+		reference = cluster + "|" + reference
+	}
+	return reference
+}
+
+// withReference returns a copy of attributes that also sets the attribute
+// name to the reference of obj. Attributes are returned as is if name is
+// empty.
+func withReference(attributes map[string]string, name string, obj client.Object) map[string]string {
+	if name == "" {
+		return attributes
+	}
+	withRef := maps.Clone(attributes)
+	if withRef == nil {
+		withRef = make(map[string]string, 1)
+	}
+	withRef[name] = crReference(obj)
+	return withRef
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// roleReferencePrefix marks a role mapping entry that includes another role
+// instead of naming a group
+const roleReferencePrefix = "role:"
+
+// RoleMapping maps role names to their user pool groups. An entry of the form
+// "role:<name>" includes all groups of another role, so roles can build on
+// each other, e.g. admin includes editor which includes viewer.
+type RoleMapping map[string][]string
+
+// ParseRoleMapping parses role definitions keyed by role name. Each value is a
+// comma-separated list of groups and role references, e.g.
+// "admins,billing,role:viewer". References must name defined roles and must
+// not form a cycle.
+func ParseRoleMapping(defs map[string]string) (RoleMapping, error) {
+	mapping := make(RoleMapping, len(defs))
+	for role, value := range defs {
+		var entries []string
+		for _, entry := range strings.Split(value, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("role %s maps to no groups", role)
+		}
+		mapping[role] = entries
+	}
+
+	for role := range mapping {
+		if _, err := mapping.Expand([]string{role}); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// Expand returns the sorted, deduplicated groups of the given roles,
+// following role references. It fails on undefined roles and cycles.
+func (m RoleMapping) Expand(roles []string) ([]string, error) {
+	var groups []string
+	for _, role := range roles {
+		if err := m.expand(role, nil, &groups); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(groups)
+	return slices.Compact(groups), nil
+}
+
+// expand appends the groups of role to groups. path holds the roles being
+// expanded to detect cycles.
+func (m RoleMapping) expand(role string, path []string, groups *[]string) error {
+	if slices.Contains(path, role) {
+		return fmt.Errorf("role %s includes itself through %s", role, strings.Join(append(path, role), " -> "))
+	}
+	entries, ok := m[role]
+	if !ok {
+		if len(path) > 0 {
+			return fmt.Errorf("role %s referenced by %s is not defined", role, path[len(path)-1])
+		}
+		return fmt.Errorf("role %s is not defined", role)
+	}
+
+	path = append(path, role)
+	for _, entry := range entries {
+		if ref, ok := strings.CutPrefix(entry, roleReferencePrefix); ok {
+			if err := m.expand(ref, path, groups); err != nil {
+				return err
+			}
+			continue
+		}
+		*groups = append(*groups, entry)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := ParseRoleMapping(map[string]string{
+		"admin":  "admins, billing, role:editor",
+		"editor": "editors,role:viewer",
+		"viewer": "readers",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	groups, err := mapping.Expand([]string{"admin", "viewer"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := []string{"admins", "billing", "editors", "readers"}; !slices.Equal(groups, want) {
+		t.Errorf("expected %v, got %v", want, groups)
+	}
+
+	if _, err := mapping.Expand([]string{"owner"}); err == nil || !strings.Contains(err.Error(), "owner") {
+		t.Errorf("expected error for undefined role, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		defs    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "cycle",
+			defs:    map[string]string{"a": "role:b", "b": "role:a"},
+			wantErr: "includes itself",
+		},
+		{
+			name:    "undefined reference",
+			defs:    map[string]string{"a": "role:b"},
+			wantErr: "referenced by a",
+		},
+		{
+			name:    "no groups",
+			defs:    map[string]string{"a": " , "},
+			wantErr: "maps to no groups",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRoleMapping(tt.defs); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
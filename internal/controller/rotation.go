@@ -0,0 +1,175 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// RotateCredentialsAnnotation set to a value identifying the request, e.g.
+// the time it was made, rotates the credentials of a compromised pool user
+// once. The annotation is removed afterwards and its value is recorded in
+// status.credentialsRotation.
+const RotateCredentialsAnnotation = "kcp.cogniteo.io/rotate-credentials"
+
+// RotateCredentialsStepsAnnotation selects the steps of a credential rotation
+// as a comma-separated list. Without it the password is reset and the user is
+// signed out.
+const RotateCredentialsStepsAnnotation = "kcp.cogniteo.io/rotate-credentials-steps"
+
+// Steps of a credential rotation, in the order they run
+const (
+	// RotationStepResetPassword invalidates the password, so it must be set
+	// again with a code sent to the user
+	RotationStepResetPassword = "reset-password"
+	// RotationStepSignOut revokes the refresh tokens of all sessions
+	RotationStepSignOut = "sign-out"
+	// RotationStepForgetDevices forgets remembered devices, so none of them
+	// can skip MFA
+	RotationStepForgetDevices = "forget-devices"
+)
+
+// rotationStepOrder is the order rotation steps run in: the password is reset
+// first so no new session can start with it while the existing ones are
+// revoked
+var rotationStepOrder = []string{RotationStepResetPassword, RotationStepSignOut, RotationStepForgetDevices}
+
+// rotateCredentialsRequested passes updates that add or change the
+// rotate-credentials annotation, which don't change the generation
+var rotateCredentialsRequested = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		requested, ok := e.ObjectNew.GetAnnotations()[RotateCredentialsAnnotation]
+		return ok && requested != e.ObjectOld.GetAnnotations()[RotateCredentialsAnnotation]
+	},
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// rotationSteps parses the steps annotation and returns the selected steps in
+// the order they run
+func rotationSteps(value string, set bool) ([]string, error) {
+	if !set {
+		return []string{RotationStepResetPassword, RotationStepSignOut}, nil
+	}
+	var selected []string
+	for _, step := range strings.Split(value, ",") {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			continue
+		}
+		if !slices.Contains(rotationStepOrder, step) {
+			return nil, fmt.Errorf("unknown credential rotation step %q, expected %s", step,
+				strings.Join(rotationStepOrder, ", "))
+		}
+		selected = append(selected, step)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no credential rotation steps selected")
+	}
+
+	var steps []string
+	for _, step := range rotationStepOrder {
+		if slices.Contains(selected, step) {
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// rotateCredentialsIfRequested runs the selected rotation steps if the User
+// has the rotate-credentials annotation, records the request in the status
+// and removes the annotations from user. The caller persists the removal. A
+// failed step fails the rotation and all steps run again on the retry.
+func (r *UserReconciler) rotateCredentialsIfRequested(ctx context.Context, recorder record.EventRecorder,
+	user *kcpv1alpha1.User, log logr.Logger) error {
+	requested, ok := user.Annotations[RotateCredentialsAnnotation]
+	if !ok {
+		return nil
+	}
+	done := func() {
+		delete(user.Annotations, RotateCredentialsAnnotation)
+		delete(user.Annotations, RotateCredentialsStepsAnnotation)
+	}
+	if user.Status.CredentialsRotation == requested {
+		// Completed before, only the removal of the annotation failed
+		done()
+		return nil
+	}
+
+	stepsValue, stepsSet := user.Annotations[RotateCredentialsStepsAnnotation]
+	steps, err := rotationSteps(stepsValue, stepsSet)
+	if err != nil {
+		// Retrying won't help, the request has to be made again
+		log.Error(err, "Dropping invalid credential rotation request")
+		recordEvent(recorder, user, corev1.EventTypeWarning, "CredentialRotationFailed", err.Error())
+		done()
+		return nil
+	}
+
+	username := poolUsername(user)
+	for _, step := range steps {
+		err := r.rotationStep(ctx, step, username)
+		switch {
+		case stderrors.Is(err, userpool.ErrUserNotFound):
+			log.Info("Dropping credential rotation request of missing pool user", "username", r.pii(username))
+			done()
+			return nil
+		case err != nil:
+			recordEvent(recorder, user, corev1.EventTypeWarning, "CredentialRotationFailed",
+				fmt.Sprintf("Step %s failed: %s", step, err))
+			return fmt.Errorf("failed to rotate credentials: %w", err)
+		}
+	}
+
+	now := metav1.NewTime(userpool.Now(r.Clock))
+	user.Status.CredentialsRotation = requested
+	user.Status.CredentialsRotatedAt = &now
+	log.Info("User credentials rotated", "username", r.pii(username), "requested", requested, "steps", steps)
+	recordEvent(recorder, user, corev1.EventTypeNormal, "CredentialsRotated",
+		fmt.Sprintf("Ran %s as requested at %s", strings.Join(steps, ", "), requested))
+	done()
+	return nil
+}
+
+// rotationStep runs a single credential rotation step
+func (r *UserReconciler) rotationStep(ctx context.Context, step, username string) error {
+	switch step {
+	case RotationStepResetPassword:
+		return r.UserPoolClient.ResetPassword(ctx, username)
+	case RotationStepSignOut:
+		return r.UserPoolClient.SignOutUser(ctx, username)
+	case RotationStepForgetDevices:
+		return r.UserPoolClient.ForgetDevices(ctx, username)
+	default:
+		return fmt.Errorf("unknown credential rotation step %q", step)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// SignOutAnnotation set to any value, e.g. the time of the request, signs the
+// pool user out of all sessions once. The annotation is removed afterwards;
+// the enabled state is not changed.
+const SignOutAnnotation = "kcp.cogniteo.io/sign-out"
+
+// signOutRequested passes updates that add or change the sign-out annotation,
+// which don't change the generation
+var signOutRequested = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		requested, ok := e.ObjectNew.GetAnnotations()[SignOutAnnotation]
+		return ok && requested != e.ObjectOld.GetAnnotations()[SignOutAnnotation]
+	},
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// signOutIfRequested signs the pool user out if the User has the sign-out
+// annotation and removes the annotation from user. The caller persists the
+// removal; if that fails, the user is signed out again, which is harmless.
+func (r *UserReconciler) signOutIfRequested(ctx context.Context, recorder record.EventRecorder,
+	user *kcpv1alpha1.User, log logr.Logger) error {
+	requested, ok := user.Annotations[SignOutAnnotation]
+	if !ok {
+		return nil
+	}
+	username := poolUsername(user)
+
+	err := r.UserPoolClient.SignOutUser(ctx, username)
+	switch {
+	case stderrors.Is(err, userpool.ErrUserNotFound):
+		log.Info("Dropping sign-out request of missing pool user", "username", r.pii(username))
+	case err != nil:
+		recordEvent(recorder, user, corev1.EventTypeWarning, "SignOutFailed", err.Error())
+		return fmt.Errorf("failed to sign out user: %w", err)
+	default:
+		log.Info("User signed out of all sessions", "username", r.pii(username), "requested", requested)
+		recordEvent(recorder, user, corev1.EventTypeNormal, "SignedOut",
+			fmt.Sprintf("Signed out of all sessions as requested at %s", requested))
+	}
+	delete(user.Annotations, SignOutAnnotation)
+	return nil
+}
+
+// recordEvent records an event on user if recorder is not nil
+func recordEvent(recorder record.EventRecorder, user *kcpv1alpha1.User, eventType, reason, message string) {
+	if recorder != nil {
+		recorder.Event(user, eventType, reason, message)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// ParseAttributeTemplates parses attribute templates keyed by attribute name.
+// Templates use Go text/template syntax and are executed against the User,
+// e.g. `{{ .Spec.Attributes.givenName }} {{ .Spec.Attributes.familyName }}`.
+// Referencing a missing map key is an execution error.
+func ParseAttributeTemplates(defs map[string]string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(defs))
+	for name, text := range defs {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for attribute %s: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// renderAttributes returns the User's attributes merged with the values
+// computed from templates. Templated attributes take precedence so derived
+// values stay consistent with their sources.
+func renderAttributes(templates map[string]*template.Template, user *kcpv1alpha1.User) (map[string]string, error) {
+	if len(templates) == 0 {
+		return user.Spec.Attributes, nil
+	}
+
+	attributes := make(map[string]string, len(user.Spec.Attributes)+len(templates))
+	maps.Copy(attributes, user.Spec.Attributes)
+	for name, tmpl := range templates {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, user); err != nil {
+			return nil, fmt.Errorf("failed to render attribute %s: %w", name, err)
+		}
+		attributes[name] = b.String()
+	}
+	return attributes, nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// throttledReconciles counts reconciles that failed because the user pool
+// kept throttling the controller
+var throttledReconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kcp_users_throttled_reconciles_total",
+	Help: "Number of reconciles that failed because Cognito throttled a call after all retries",
+}, []string{"controller", "user_pool_id"})
+
+func init() {
+	metrics.Registry.MustRegister(throttledReconciles)
+}
+
+// throttledGuidance tells operators how to get out of sustained throttling
+const throttledGuidance = "reduce --max-concurrent-reconciles or set --cognito-qps, " +
+	"or request a higher Cognito quota for the pool"
+
+// reportThrottled counts a reconcile of obj that failed with
+// userpool.ErrThrottled and records a Throttled event on obj
+func reportThrottled(recorder record.EventRecorder, obj client.Object, controller, userPoolID string, err error) {
+	throttledReconciles.WithLabelValues(controller, userPoolID).Inc()
+	if recorder != nil {
+		recorder.Event(obj, corev1.EventTypeWarning, "Throttled",
+			fmt.Sprintf("Cognito throttled the reconcile, %s: %s", throttledGuidance, err))
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// clusterAnnotation names the kcp workspace of objects read from the
+// provider's wildcard cache
+const clusterAnnotation = "kcp.io/cluster"
+
+// errDuplicateUser is wrapped by the error checkUnique returns when another
+// User claims the username or email
+var errDuplicateUser = stderrors.New("claimed by another User")
+
+// checkUnique returns an error wrapping errDuplicateUser if another User
+// claims the pool username or email of user, and, with UniquenessCheckPool,
+// an error wrapping userpool.ErrAliasExists if another pool user has the
+// email. It is called before creating a pool user. Of two Users claiming the
+// same value the older one keeps it, so only the newer one fails.
+func (r *UserReconciler) checkUnique(ctx context.Context, c client.Reader, user *kcpv1alpha1.User,
+	poolUser *userpool.User) error {
+	if !r.UniquenessCheck {
+		return nil
+	}
+	if r.UniquenessReader != nil {
+		c = r.UniquenessReader
+	}
+
+	var users kcpv1alpha1.UserList
+	if err := c.List(ctx, &users); err != nil {
+		return fmt.Errorf("failed to list Users: %w", err)
+	}
+	for i := range users.Items {
+		other := &users.Items[i]
+		if other.UID == user.UID || !claimedFirst(other, user) {
+			continue
+		}
+		if poolUser.Username != "" && poolUsername(other) == poolUser.Username {
+			return fmt.Errorf("username %s is %w %s", r.pii(poolUser.Username), errDuplicateUser, describeUser(other))
+		}
+		if poolUser.Email != "" && strings.EqualFold(other.Spec.Email, poolUser.Email) {
+			return fmt.Errorf("email %s is %w %s", r.pii(poolUser.Email), errDuplicateUser, describeUser(other))
+		}
+	}
+
+	if !r.UniquenessCheckPool || poolUser.Email == "" {
+		return nil
+	}
+	existing, err := r.UserPoolClient.GetUserByEmail(ctx, poolUser.Email)
+	switch {
+	case stderrors.Is(err, userpool.ErrUserNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to look up email in user pool: %w", err)
+	case existing.Username != poolUser.Username:
+		return fmt.Errorf("email %s is used by pool user %s: %w", r.pii(poolUser.Email), r.pii(existing.Username),
+			userpool.ErrAliasExists)
+	}
+	return nil
+}
+
+// claimedFirst reports whether other was created before user, ordering
+// Users created in the same second by UID
+func claimedFirst(other, user *kcpv1alpha1.User) bool {
+	if !other.CreationTimestamp.Equal(&user.CreationTimestamp) {
+		return other.CreationTimestamp.Before(&user.CreationTimestamp)
+	}
+	return other.UID < user.UID
+}
+
+// describeUser names a User for condition messages, including its workspace
+// if known
+func describeUser(user *kcpv1alpha1.User) string {
+	name := "User " + user.Namespace + "/" + user.Name
+	if cluster := user.Annotations[clusterAnnotation]; cluster != "" {
+		name += " in workspace " + cluster
+	}
+	return name
+}
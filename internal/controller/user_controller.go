@@ -17,35 +17,186 @@ limitations under the License.
 package controller
 
 import (
+	"cmp"
 	"context"
+	stderrors "errors"
 	"fmt"
+	"slices"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
 	"piotrjanik.dev/users/pkg/userpool"
 	mcbuilder "sigs.k8s.io/multicluster-runtime/pkg/builder"
+	mccontroller "sigs.k8s.io/multicluster-runtime/pkg/controller"
 	mcmanager "sigs.k8s.io/multicluster-runtime/pkg/manager"
 	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
 )
 
+// Reasons used for the Ready condition
+const (
+	ReasonReconciled              = "Reconciled"
+	ReasonSyncFailed              = "SyncFailed"
+	ReasonAttributeTemplateFailed = "AttributeTemplateFailed"
+	ReasonGroupsMissing           = "GroupsMissing"
+	ReasonGroupsPending           = "GroupsPending"
+	ReasonAliasExists             = "AliasExists"
+	ReasonUnknownRole             = "UnknownRole"
+	ReasonMFADisabled             = "MFADisabled"
+	ReasonAttributeSourceFailed   = "AttributeSourceFailed"
+	ReasonInvalidParameter        = "InvalidParameter"
+	ReasonDuplicateUser           = "DuplicateUser"
+	ReasonThrottled               = "Throttled"
+	ReasonNoUserPool              = "NoUserPool"
+	ReasonEnrichmentFailed        = "EnrichmentFailed"
+	ReasonInvalidUsername         = "InvalidUsername"
+)
+
+// UserPoolFinalizer is set on every User. The pool user is deleted, or
+// retained, before the finalizer is removed, while the User and its
+// annotations and status can still be read.
+const UserPoolFinalizer = "kcp.cogniteo.io/user-pool"
+
+// RetainAnnotation set to "true" keeps the pool user when its User is deleted,
+// e.g. to hand the user over to another controller
+const RetainAnnotation = "kcp.cogniteo.io/retain"
+
 // UserReconciler reconciles a User object
 type UserReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
 	Manager        mcmanager.Manager
 	UserPoolClient userpool.Client
+
+	// UserPoolID identifies the user pool in metrics
+	UserPoolID string
+
+	// MaxConcurrentReconciles is the maximum number of User reconciles that
+	// run in parallel. Zero uses the controller-runtime default of 1.
+	MaxConcurrentReconciles int
+
+	// ResyncPeriod is the interval after which a successfully reconciled User is
+	// reconciled again, correcting changes made directly in the user pool. Zero
+	// disables periodic resync.
+	ResyncPeriod time.Duration
+
+	// Selector restricts the Users this reconciler handles to those whose
+	// labels match, so several controller instances can divide the Users
+	// between them. Nil handles all Users.
+	Selector labels.Selector
+
+	// AttributeTemplates compute attribute values from the User, keyed by
+	// attribute name. See ParseAttributeTemplates.
+	AttributeTemplates map[string]*template.Template
+
+	// UsernameStrategy derives usernames for Users with spec.generateUsername.
+	// Nil lets the user pool client generate a UUID.
+	UsernameStrategy UsernameStrategy
+
+	// RedactPII logs pool usernames as a stable hash instead of in clear text
+	RedactPII bool
+
+	// RoleMapping expands spec.roles into group memberships. See
+	// ParseRoleMapping.
+	RoleMapping RoleMapping
+
+	// Clock stamps the lastReconciledAt annotation. Nil uses the system time.
+	Clock userpool.Clock
+
+	// Enricher, if set, adds attributes computed outside the User. They take
+	// precedence over spec.attributes and spec.attributesFrom, but not over
+	// AttributeTemplates.
+	Enricher AttributeEnricher
+
+	// Capacity, if set, records a warning event on Users created while the
+	// user pool is near capacity
+	Capacity *CapacityMonitor
+
+	// TemporaryPasswordValidity is how long the user pool accepts a temporary
+	// password, as configured in the pool. Zero disables tracking temporary
+	// passwords.
+	TemporaryPasswordValidity time.Duration
+
+	// ResendExpiredInvitations sends the invitation again, with a fresh
+	// temporary password, to users whose temporary password expired before
+	// they signed in
+	ResendExpiredInvitations bool
+
+	// WatchAttributeSources reconciles Users again when a Secret or ConfigMap
+	// of their spec.attributesFrom changes. Otherwise changes are picked up
+	// at the next resync.
+	WatchAttributeSources bool
+
+	// UniquenessCheck makes sure no other User claims the username or email
+	// before a pool user is created. Users are listed from UniquenessReader,
+	// e.g. the provider's wildcard cache to cover all workspaces, or from the
+	// User's workspace if it is nil.
+	UniquenessCheck  bool
+	UniquenessReader client.Reader
+
+	// UniquenessCheckPool additionally looks the email up in the user pool
+	// before a create, so an email held by another pool user is reported
+	// without attempting the create. It costs one ListUsers call per create.
+	UniquenessCheckPool bool
+
+	// WatchGroups reconciles Users waiting for a missing group again when a
+	// Group resource for it changes. Otherwise they retry after five minutes.
+	// It also tells missing groups that a Group resource will create from
+	// misconfigured ones: Users waiting only for the former report
+	// GroupsPending and are requeued with backoff instead of failing.
+	WatchGroups bool
+
+	// GroupPendingRequeue is the first requeue delay of a User waiting for a
+	// Group resource to create its groups. It doubles with every retry up to
+	// five minutes. Zero means five seconds.
+	GroupPendingRequeue time.Duration
+
+	// ReferenceAttribute names an attribute, e.g. custom:crRef, that is set
+	// to the workspace, namespace and name of the User managing a pool user.
+	// It is written on create and update but a differing value alone does
+	// not cause an update. Empty disables the reference.
+	ReferenceAttribute string
+
+	// ManagedAttributes lists the attributes owned by Users. One of them
+	// that a User no longer sets is deleted from its pool user. Attributes
+	// not in the list are never deleted, so empty deletes nothing.
+	ManagedAttributes []string
+
+	// EmailVerifiedUnmanaged ignores spec.emailVerified, for pools where an
+	// external verification flow owns email_verified. The user pool client
+	// must be configured to leave the attribute out of its writes as well.
+	EmailVerifiedUnmanaged bool
+
+	// ErrorPolicy decides how the reconcile responds to errors syncing with
+	// the user pool. Nil uses DefaultErrorPolicy.
+	ErrorPolicy ErrorPolicy
+
+	// groupsPending backs off Users waiting for Group resources
+	groupsPending pendingBackoff
+
+	// backoff resets the retry backoff of a User when its spec changes
+	backoff *specChangeRateLimiter
 }
 
 // +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=users,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=users/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=users/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,10 +207,18 @@ type UserReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
-func (r *UserReconciler) Reconcile(ctx context.Context, req mcreconcile.Request) (ctrl.Result, error) {
-	log := logf.FromContext(ctx).WithValues("cluster", req.ClusterName)
+func (r *UserReconciler) Reconcile(ctx context.Context, req mcreconcile.Request) (_ ctrl.Result, err error) {
+	ctx, log := withClusterContext(ctx, req)
 	log.Info("Reconciling User")
 
+	outcome := outcomeUnchanged
+	defer func() {
+		if err != nil {
+			outcome = outcomeError
+		}
+		reconcileResults.WithLabelValues(string(outcome)).Inc()
+	}()
+
 	// Fetch the User instance
 	var user kcpv1alpha1.User
 	cl, err := r.Manager.GetCluster(ctx, req.ClusterName)
@@ -68,78 +227,628 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req mcreconcile.Request)
 	}
 	clusterClient := cl.GetClient()
 	if err := clusterClient.Get(ctx, req.NamespacedName, &user); err != nil {
-		if errors.IsNotFound(err) {
-			// User was deleted, remove from user pool
-			if r.UserPoolClient != nil {
-				if err := r.UserPoolClient.DeleteUser(ctx, req.Name); err != nil {
-					log.Error(err, "Failed to delete user from user pool", "username", req.Name)
-					// Continue with reconciliation even if user pool deletion fails
-				} else {
-					log.Info("User deleted from user pool", "username", req.Name)
-				}
-			}
-			return ctrl.Result{}, nil
-		}
-		return ctrl.Result{}, err
+		// The pool user of a deleted User was handled through the finalizer
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.selects(&user) {
+		// Enqueued by a watch, but handled by another instance
+		return ctrl.Result{}, nil
 	}
+	r.backoff.observe(req, user.Generation)
+
+	persisted := user.Status.DeepCopy()
 
 	// Sync user with user pool
 	if r.UserPoolClient != nil {
-		if err := r.syncUserWithUserPool(ctx, &user, log); err != nil {
-			log.Error(err, "Failed to sync user with user pool")
-			return ctrl.Result{RequeueAfter: time.Minute * 5}, err
+		if !user.DeletionTimestamp.IsZero() {
+			if !controllerutil.ContainsFinalizer(&user, UserPoolFinalizer) {
+				return ctrl.Result{}, nil
+			}
+			deleted, err := r.finalizeUser(ctx, clusterClient, &user, log)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if deleted {
+				outcome = outcomeDeleted
+			}
+			return ctrl.Result{}, nil
+		}
+		if controllerutil.AddFinalizer(&user, UserPoolFinalizer) {
+			if err := clusterClient.Update(ctx, &user); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+			}
+		}
+
+		attributes, err := renderAttributes(r.AttributeTemplates, &user)
+		if err != nil {
+			// Retrying won't help until the User or the templates change
+			log.Error(err, "Failed to render attribute templates")
+			outcome = outcomeError
+			return ctrl.Result{}, r.setReadyCondition(ctx, clusterClient, &user, persisted,
+				metav1.ConditionFalse, ReasonAttributeTemplateFailed, err.Error())
+		}
+
+		fromSources, secrets, err := resolveAttributeSources(ctx, clusterClient, &user)
+		if err != nil {
+			// The referenced object may be created later, check again at the
+			// next resync
+			log.Error(err, "Failed to resolve attribute sources")
+			outcome = outcomeError
+			return ctrl.Result{RequeueAfter: r.ResyncPeriod}, r.setReadyCondition(ctx, clusterClient, &user,
+				persisted, metav1.ConditionFalse, ReasonAttributeSourceFailed, err.Error())
+		}
+		attributes = mergeAttributeSources(attributes, fromSources, r.AttributeTemplates)
+
+		if r.Enricher != nil {
+			enriched, err := r.Enricher.Enrich(ctx, &user)
+			if err != nil {
+				// Provisioning without the enriched attributes would write
+				// partial data, retry with backoff instead
+				log.Error(err, "Failed to enrich attributes")
+				if condErr := r.setReadyCondition(ctx, clusterClient, &user, persisted,
+					metav1.ConditionFalse, ReasonEnrichmentFailed, err.Error()); condErr != nil {
+					log.Error(condErr, "Failed to update User status")
+				}
+				return ctrl.Result{}, err
+			}
+			attributes = mergeAttributeSources(attributes, enriched, r.AttributeTemplates)
+		}
+
+		groups, err := r.desiredGroups(&user)
+		if err != nil {
+			// Retrying won't help until the User or the role mapping change
+			log.Error(err, "Failed to expand roles")
+			outcome = outcomeError
+			return ctrl.Result{}, r.setReadyCondition(ctx, clusterClient, &user, persisted,
+				metav1.ConditionFalse, ReasonUnknownRole, err.Error())
+		}
+
+		generated := user.Status.Username
+		outcome, err = r.syncUserWithUserPool(ctx, clusterClient, &user, attributes, groups, log)
+		err = redactSecrets(err, secrets)
+		if user.Status.Username != generated {
+			// Persist the generated username right away, later updates of the
+			// object would drop it
+			if err := clusterClient.Status().Update(ctx, &user); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to record generated username: %w", err)
+			}
+			persisted = user.Status.DeepCopy()
+		}
+		var missingGroups *userpool.MissingGroupsError
+		if r.WatchGroups && stderrors.As(err, &missingGroups) &&
+			groupsPending(ctx, clusterClient, missingGroups.Groups) {
+			// The Groups haven't created their pool groups yet, wait for them
+			// rather than failing
+			log.Info("Waiting for Groups to create missing groups", "groups", missingGroups.Groups)
+			outcome = outcomeError
+			base := cmp.Or(r.GroupPendingRequeue, defaultGroupPendingRequeue)
+			delay := r.groupsPending.next(req, base, maxGroupPendingRequeue)
+			return ctrl.Result{RequeueAfter: delay}, r.setReadyCondition(ctx, clusterClient, &user, persisted,
+				metav1.ConditionFalse, ReasonGroupsPending, "Waiting for Groups to create "+
+					strings.Join(missingGroups.Groups, ", "))
+		}
+		r.groupsPending.reset(req)
+		if err != nil {
+			reason, message := syncFailure(err)
+			if reason == ReasonThrottled {
+				reportThrottled(cl.GetEventRecorderFor("user"), &user, "user", r.UserPoolID, err)
+			}
+			action := r.errorPolicy().Classify(err)
+			if action == ErrorActionIgnore {
+				log.Info("Ignoring error syncing user with user pool", "error", err.Error())
+			} else {
+				log.Error(err, message)
+				outcome = outcomeError
+				condErr := r.setReadyCondition(ctx, clusterClient, &user, persisted,
+					metav1.ConditionFalse, reason, err.Error())
+				switch action {
+				case ErrorActionFail:
+					// Retrying won't help until the User or the pool change,
+					// check again at the next resync
+					return ctrl.Result{RequeueAfter: r.ResyncPeriod}, condErr
+				case ErrorActionRetry:
+					return ctrl.Result{RequeueAfter: errorRetryDelay}, condErr
+				}
+				if condErr != nil {
+					log.Error(condErr, "Failed to update User status")
+				}
+				return ctrl.Result{RequeueAfter: time.Minute * 5}, err
+			}
+		}
+
+		if outcome == outcomeCreated && r.Capacity.NearCapacity() {
+			recordEvent(cl.GetEventRecorderFor("user"), &user, corev1.EventTypeWarning, "UserPoolNearCapacity",
+				"The user pool is near its user capacity, new users may soon be rejected")
+		}
+
+		if err := r.signOutIfRequested(ctx, cl.GetEventRecorderFor("user"), &user, log); err != nil {
+			log.Error(err, "Failed to sign out user")
+			return ctrl.Result{}, err
+		}
+
+		if err := r.rotateCredentialsIfRequested(ctx, cl.GetEventRecorderFor("user"), &user, log); err != nil {
+			log.Error(err, "Failed to rotate user credentials")
+			return ctrl.Result{}, err
 		}
 	}
 
+	user.Status.ObservedGeneration = user.Generation
+	observed := user.Status.DeepCopy()
+
 	// Add or update annotation
 	if user.Annotations == nil {
 		user.Annotations = make(map[string]string)
 	}
-	user.Annotations["kcp.cogniteo.io/lastReconciledAt"] = time.Now().Format(time.RFC3339)
+	user.Annotations["kcp.cogniteo.io/lastReconciledAt"] = userpool.Now(r.Clock).Format(time.RFC3339)
 
 	if err := clusterClient.Update(ctx, &user); err != nil {
 		log.Error(err, "Failed to update User annotation")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// The update returns the stored status, put back what was observed
+	user.Status = *observed
+	if err := r.setReadyCondition(ctx, clusterClient, &user, persisted,
+		metav1.ConditionTrue, ReasonReconciled, "User is in sync with the user pool"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: r.resyncAfter(&user)}, nil
+}
+
+// setReadyCondition sets the Ready condition on the User and updates its status
+// if it differs from the persisted status
+func (r *UserReconciler) setReadyCondition(ctx context.Context, c client.Client, user *kcpv1alpha1.User,
+	persisted *kcpv1alpha1.UserStatus, status metav1.ConditionStatus, reason, message string) error {
+	meta.SetStatusCondition(&user.Status.Conditions, metav1.Condition{
+		Type:               kcpv1alpha1.ConditionTypeReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: user.Generation,
+	})
+	if equality.Semantic.DeepEqual(persisted, &user.Status) {
+		return nil
+	}
+	if err := c.Status().Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to update User status: %w", err)
+	}
+	return nil
 }
 
-// syncUserWithUserPool synchronizes a Kubernetes User with User Pool
-func (r *UserReconciler) syncUserWithUserPool(ctx context.Context, user *kcpv1alpha1.User, log logr.Logger) error {
+// syncUserWithUserPool synchronizes a Kubernetes User with User Pool and
+// reports whether the pool user was created, updated or left unchanged
+func (r *UserReconciler) syncUserWithUserPool(ctx context.Context, c client.Reader, user *kcpv1alpha1.User,
+	attributes map[string]string, groups []string, log logr.Logger) (reconcileOutcome, error) {
 	poolUser := &userpool.User{
-		Username: user.Name,
-		Email:    user.Spec.Email,
-		Enabled:  user.Spec.Enabled,
+		Username:          poolUsername(user),
+		Email:             user.Spec.Email,
+		EmailVerified:     user.Spec.EmailVerified,
+		SecondaryEmail:    user.Spec.SecondaryEmail,
+		PreferredUsername: user.Spec.PreferredUsername,
+		Locale:            user.Spec.Locale,
+		Address:           poolAddress(user.Spec.Address),
+		Picture:           user.Spec.Picture,
+		Profile:           user.Spec.Profile,
+		Nickname:          user.Spec.Nickname,
+		Website:           user.Spec.Website,
+		ZoneInfo:          user.Spec.ZoneInfo,
+		Birthdate:         user.Spec.Birthdate,
+		Gender:            user.Spec.Gender,
+		Enabled:           ptr.Deref(user.Spec.Enabled, false),
+		Attributes:        attributes,
+	}
+	expired := poolUser.Enabled && userExpired(user, r.Clock)
+	if expired {
+		poolUser.Enabled = false
+	}
+	if !poolUser.Enabled {
+		poolUser.DisableReason = user.Spec.DisableReason
+	}
+	if r.EmailVerifiedUnmanaged {
+		poolUser.EmailVerified = nil
+	}
+	switch user.Spec.Invitation {
+	case kcpv1alpha1.InvitationSend, kcpv1alpha1.InvitationResend:
+		poolUser.SendInvitation = true
 	}
 
-	// Check if user exists in user pool
-	existingUser, err := r.UserPoolClient.GetUser(ctx, user.Name)
-	if err != nil {
+	// Check if user exists in user pool. A user waiting for a generated
+	// username has not been created yet.
+	var existingUser *userpool.User
+	if user.Spec.GenerateUsername && poolUser.Username == "" && r.UsernameStrategy != nil {
+		username, adopted, err := r.assignUsername(ctx, user)
+		if err != nil {
+			return outcomeError, err
+		}
+		poolUser.Username = username
+		if adopted != nil {
+			log.Info("Adopting existing user in user pool", "username", r.pii(username))
+			user.Status.Username = username
+			existingUser = adopted
+		}
+	} else if poolUser.Username != "" {
+		found, err := r.UserPoolClient.GetUser(ctx, poolUser.Username)
+		switch {
+		case err == nil:
+			existingUser = found
+		case !stderrors.Is(err, userpool.ErrUserNotFound):
+			// Only a missing user is created, other errors go to the error
+			// policy
+			return outcomeError, fmt.Errorf("failed to get user from user pool: %w", err)
+		}
+	}
+	if existingUser == nil {
 		// User doesn't exist, create it
-		log.Info("Creating user in user pool", "username", user.Name)
+		if err := r.checkUnique(ctx, c, user, poolUser); err != nil {
+			return outcomeError, err
+		}
+		poolUser.Attributes = withReference(poolUser.Attributes, r.ReferenceAttribute, user)
+		log.Info("Creating user in user pool", "username", r.pii(poolUser.Username))
 		if err := r.UserPoolClient.CreateUser(ctx, poolUser); err != nil {
-			return fmt.Errorf("failed to create user in user pool: %w", err)
+			return outcomeError, fmt.Errorf("failed to create user in user pool: %w", err)
 		}
-		log.Info("User created in user pool", "username", user.Name)
-	} else {
-		// User exists, update if needed
-		if existingUser.Email != poolUser.Email || existingUser.Enabled != poolUser.Enabled {
-			log.Info("Updating user in user pool", "username", user.Name)
-			if err := r.UserPoolClient.UpdateUser(ctx, poolUser); err != nil {
-				return fmt.Errorf("failed to update user in user pool: %w", err)
+		if user.Spec.GenerateUsername {
+			user.Status.Username = poolUser.Username
+		}
+		managedUsers.WithLabelValues(r.UserPoolID).Inc()
+		log.Info("User created in user pool", "username", r.pii(poolUser.Username))
+		if err := r.syncGroups(ctx, user, poolUser.Username, groups, log); err != nil {
+			return outcomeError, err
+		}
+		if _, err := r.syncMFA(ctx, user, poolUser.Username, "", log); err != nil {
+			return outcomeError, err
+		}
+		if refreshed := r.refreshPoolStatus(ctx, user, poolUser.Username, log); refreshed != nil {
+			if err := r.syncTemporaryPassword(ctx, user, refreshed, log); err != nil {
+				return outcomeError, err
 			}
-			log.Info("User updated in user pool", "username", user.Name)
 		}
+		return outcomeCreated, nil
 	}
 
+	// User exists, update if needed
+	outcome := outcomeUnchanged
+	if expired && existingUser.Enabled {
+		if err := r.signOutExpired(ctx, poolUser.Username, log); err != nil {
+			return outcomeError, err
+		}
+	}
+	poolUser.DeleteAttributes = removedAttributes(withRemoved(r.ManagedAttributes, user.Spec.RemoveAttributes),
+		poolUser.Attributes, existingUser.Attributes, r.ReferenceAttribute)
+	changed := existingUser.Email != poolUser.Email || existingUser.Enabled != poolUser.Enabled ||
+		existingUser.DisableReason != poolUser.DisableReason ||
+		(poolUser.SecondaryEmail != "" && existingUser.SecondaryEmail != poolUser.SecondaryEmail) ||
+		(poolUser.PreferredUsername != "" && existingUser.PreferredUsername != poolUser.PreferredUsername) ||
+		(poolUser.Locale != "" && existingUser.Locale != poolUser.Locale) ||
+		(poolUser.Address != nil && (existingUser.Address == nil || *existingUser.Address != *poolUser.Address)) ||
+		(poolUser.Picture != "" && existingUser.Picture != poolUser.Picture) ||
+		(poolUser.Profile != "" && existingUser.Profile != poolUser.Profile) ||
+		(poolUser.Nickname != "" && existingUser.Nickname != poolUser.Nickname) ||
+		(poolUser.Website != "" && existingUser.Website != poolUser.Website) ||
+		(poolUser.ZoneInfo != "" && existingUser.ZoneInfo != poolUser.ZoneInfo) ||
+		(poolUser.Birthdate != "" && existingUser.Birthdate != poolUser.Birthdate) ||
+		(poolUser.Gender != "" && existingUser.Gender != poolUser.Gender) ||
+		attributesChanged(poolUser.Attributes, existingUser.Attributes) || len(poolUser.DeleteAttributes) > 0
+	verifiedChanged := emailVerifiedChanged(poolUser.EmailVerified, existingUser.EmailVerified)
+	if !changed && verifiedChanged && *poolUser.EmailVerified {
+		// Only the verified flag flipped on, there is no need to rewrite the
+		// other attributes
+		log.Info("Verifying email in user pool", "username", r.pii(poolUser.Username))
+		if err := r.UserPoolClient.VerifyAttribute(ctx, poolUser.Username, "email"); err != nil {
+			return outcomeError, fmt.Errorf("failed to verify email in user pool: %w", err)
+		}
+		outcome = outcomeUpdated
+	} else if changed || verifiedChanged {
+		if existingUser.Email != poolUser.Email {
+			// The email change triggers a verification message, give the
+			// custom message trigger the context it needs
+			metadata, dropped := clientMetadata(user)
+			if len(dropped) > 0 {
+				log.Info("Dropping sensitive client metadata", "keys", dropped)
+			}
+			poolUser.ClientMetadata = metadata
+		}
+		poolUser.Attributes = withReference(poolUser.Attributes, r.ReferenceAttribute, user)
+		log.Info("Updating user in user pool", "username", r.pii(poolUser.Username))
+		written, err := r.UserPoolClient.UpdateUserIfChanged(ctx, existingUser, poolUser)
+		if err != nil {
+			return outcomeError, fmt.Errorf("failed to update user in user pool: %w", err)
+		}
+		if written {
+			log.Info("User updated in user pool", "username", r.pii(poolUser.Username))
+			outcome = outcomeUpdated
+		}
+	}
+
+	if err := r.linkFederatedIdentities(ctx, user, existingUser, log); err != nil {
+		return outcomeError, err
+	}
+
+	confirmed := false
+	if user.Spec.Confirmed && existingUser.Status == userpool.StatusUnconfirmed {
+		log.Info("Confirming user in user pool", "username", r.pii(poolUser.Username))
+		if err := r.UserPoolClient.ConfirmUser(ctx, poolUser.Username); err != nil &&
+			!stderrors.Is(err, userpool.ErrUserAlreadyConfirmed) {
+			return outcomeError, fmt.Errorf("failed to confirm user in user pool: %w", err)
+		}
+		confirmed = true
+	}
+
+	passwordForced, err := r.syncPasswordChange(ctx, user, existingUser, log)
+	if err != nil {
+		return outcomeError, err
+	}
+
+	if err := r.syncGroups(ctx, user, poolUser.Username, groups, log); err != nil {
+		return outcomeError, err
+	}
+
+	mfaChanged, err := r.syncMFA(ctx, user, poolUser.Username, existingUser.PreferredMFA, log)
+	if err != nil {
+		return outcomeError, err
+	}
+
+	current := existingUser
+	if outcome == outcomeUpdated || confirmed || mfaChanged || passwordForced {
+		current = r.refreshPoolStatus(ctx, user, poolUser.Username, log)
+	} else {
+		setPoolStatus(user, existingUser)
+	}
+	if current != nil {
+		if err := r.syncTemporaryPassword(ctx, user, current, log); err != nil {
+			return outcomeError, err
+		}
+	}
+
+	return outcome, nil
+}
+
+// syncMFA sets the preferred MFA method from spec.mfaMethod if it differs
+// from current and reports whether it was changed
+func (r *UserReconciler) syncMFA(ctx context.Context, user *kcpv1alpha1.User, username, current string,
+	log logr.Logger) (bool, error) {
+	if user.Spec.MFAMethod == "" || user.Spec.MFAMethod == current {
+		return false, nil
+	}
+	log.Info("Setting preferred MFA method", "username", r.pii(username), "method", user.Spec.MFAMethod)
+	if err := r.UserPoolClient.SetPreferredMFA(ctx, username, user.Spec.MFAMethod); err != nil {
+		return false, fmt.Errorf("failed to set preferred MFA method: %w", err)
+	}
+	return true, nil
+}
+
+// desiredGroups returns the groups of spec.groups and the groups the
+// User's roles expand to
+func (r *UserReconciler) desiredGroups(user *kcpv1alpha1.User) ([]string, error) {
+	if len(user.Spec.Roles) == 0 {
+		return user.Spec.Groups, nil
+	}
+	expanded, err := r.RoleMapping.Expand(user.Spec.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand roles: %w", err)
+	}
+	return append(slices.Clone(user.Spec.Groups), expanded...), nil
+}
+
+// syncGroups applies the changes to the desired groups since the last sync.
+// Only groups recorded in status.groups are removed, so memberships managed
+// elsewhere are kept.
+func (r *UserReconciler) syncGroups(ctx context.Context, user *kcpv1alpha1.User, username string,
+	groups []string, log logr.Logger) error {
+	add := missingFrom(groups, user.Status.Groups)
+	remove := missingFrom(user.Status.Groups, groups)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	log.Info("Updating group memberships", "username", r.pii(username), "add", add, "remove", remove)
+	if err := r.UserPoolClient.UpdateGroups(ctx, username, add, remove); err != nil {
+		return fmt.Errorf("failed to update group memberships: %w", err)
+	}
+	user.Status.Groups = missingFrom(groups, nil)
+	return nil
+}
+
+// missingFrom returns the sorted, deduplicated values that are not in from
+func missingFrom(values, from []string) []string {
+	var missing []string
+	for _, value := range values {
+		if !slices.Contains(from, value) {
+			missing = append(missing, value)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing)
+}
+
+// refreshPoolStatus reads the pool user after a write so the User status
+// reflects what the user pool stored. It returns the pool user, or nil if it
+// could not be read.
+func (r *UserReconciler) refreshPoolStatus(ctx context.Context, user *kcpv1alpha1.User, username string,
+	log logr.Logger) *userpool.User {
+	poolUser, err := r.UserPoolClient.GetUser(ctx, username)
+	if err != nil {
+		log.Error(err, "Failed to read user after write", "username", r.pii(username))
+		return nil
+	}
+	setPoolStatus(user, poolUser)
+	return poolUser
+}
+
+// setPoolStatus copies the identity state reported by the user pool into the
+// User status
+func setPoolStatus(user *kcpv1alpha1.User, poolUser *userpool.User) {
+	user.Status.CognitoStatus = poolUser.RawStatus
+	user.Status.EmailVerified = ptr.To(ptr.Deref(poolUser.EmailVerified, false))
+	user.Status.PhoneVerified = ptr.To(ptr.Deref(poolUser.PhoneNumberVerified, false))
+	user.Status.MFAMethod = poolUser.PreferredMFA
+}
+
+// linkFederatedIdentities links every identity from the spec that isn't linked
+// to the pool user yet
+func (r *UserReconciler) linkFederatedIdentities(ctx context.Context, user *kcpv1alpha1.User,
+	existingUser *userpool.User, log logr.Logger) error {
+	for _, identity := range user.Spec.FederatedIdentities {
+		linked := slices.Contains(existingUser.Identities, userpool.Identity{
+			ProviderName: identity.ProviderName,
+			UserID:       identity.ProviderUserID,
+		})
+		if linked {
+			continue
+		}
+		log.Info("Linking federated identity", "username", r.pii(existingUser.Username), "provider", identity.ProviderName)
+		if err := r.UserPoolClient.LinkProvider(ctx, existingUser.Username, identity.ProviderName,
+			identity.ProviderUserID); err != nil {
+			return fmt.Errorf("failed to link federated identity: %w", err)
+		}
+	}
 	return nil
 }
 
+// pii returns value, or its hash when PII redaction is enabled
+func (r *UserReconciler) pii(value string) string {
+	if r.RedactPII {
+		return userpool.Redact(value)
+	}
+	return value
+}
+
+// poolUsername returns the username of the User in the user pool, or an empty
+// string while a generated username hasn't been assigned yet
+func poolUsername(user *kcpv1alpha1.User) string {
+	if user.Spec.GenerateUsername {
+		return user.Status.Username
+	}
+	return user.Name
+}
+
+// poolAddress converts the address of a User spec, nil if it is unset
+func poolAddress(address *kcpv1alpha1.UserAddress) *userpool.Address {
+	if address == nil {
+		return nil
+	}
+	return &userpool.Address{
+		Formatted:     address.Formatted,
+		StreetAddress: address.StreetAddress,
+		Locality:      address.Locality,
+		Region:        address.Region,
+		PostalCode:    address.PostalCode,
+		Country:       address.Country,
+	}
+}
+
+// finalizeUser deletes the pool user of a deleted User, unless the User has
+// the retain annotation, and removes the finalizer. It reports whether a pool
+// user was deleted.
+func (r *UserReconciler) finalizeUser(ctx context.Context, c client.Client, user *kcpv1alpha1.User,
+	log logr.Logger) (bool, error) {
+	deleted := false
+	username := poolUsername(user)
+	switch {
+	case user.Annotations[RetainAnnotation] == "true":
+		log.Info("Retaining user in user pool", "username", r.pii(username))
+	case username != "":
+		err := r.UserPoolClient.DeleteUser(ctx, username)
+		switch {
+		case err == nil:
+			deleted = true
+			managedUsers.WithLabelValues(r.UserPoolID).Dec()
+			log.Info("User deleted from user pool", "username", r.pii(username))
+		case !stderrors.Is(err, userpool.ErrUserNotFound):
+			return false, fmt.Errorf("failed to delete user from user pool: %w", err)
+		}
+	}
+	controllerutil.RemoveFinalizer(user, UserPoolFinalizer)
+	if err := c.Update(ctx, user); err != nil {
+		return false, fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	return deleted, nil
+}
+
+// attributesChanged reports whether any desired attribute differs from the
+// current value. Attributes not present in desired are ignored.
+func attributesChanged(desired, current map[string]string) bool {
+	for k, v := range desired {
+		if cv, ok := current[k]; !ok || cv != v {
+			return true
+		}
+	}
+	return false
+}
+
+// removedAttributes returns the managed attributes set in current but not in
+// desired, except keep
+func removedAttributes(managed []string, desired, current map[string]string, keep string) []string {
+	var removed []string
+	for _, name := range managed {
+		if name == keep {
+			continue
+		}
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if _, ok := current[name]; ok {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// withRemoved returns the managed attributes followed by the ones a User
+// explicitly removes that are not managed anyway
+func withRemoved(managed, removed []string) []string {
+	if len(removed) == 0 {
+		return managed
+	}
+	all := slices.Clone(managed)
+	for _, name := range removed {
+		if !slices.Contains(all, name) {
+			all = append(all, name)
+		}
+	}
+	return all
+}
+
+// emailVerifiedChanged reports whether an explicitly desired email_verified
+// value differs from the current one
+func emailVerifiedChanged(desired, current *bool) bool {
+	return desired != nil && (current == nil || *desired != *current)
+}
+
+// selects reports whether obj is handled by this reconciler's Selector
+func (r *UserReconciler) selects(obj client.Object) bool {
+	return r.Selector == nil || r.Selector.Matches(labels.Set(obj.GetLabels()))
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *UserReconciler) SetupWithManager(mgr mcmanager.Manager) error {
-	return mcbuilder.ControllerManagedBy(mgr).
-		For(&kcpv1alpha1.User{}).
+	r.backoff = newSpecChangeRateLimiter()
+	// Only spec changes and sign-out requests need a sync. Status and
+	// annotation updates made by the reconciler itself would otherwise
+	// trigger another reconcile; drift in the user pool is picked up by the
+	// periodic resync.
+	triggers := []predicate.Predicate{predicate.GenerationChangedPredicate{}, signOutRequested,
+		rotateCredentialsRequested}
+	if r.Selector != nil {
+		// A User relabeled into this instance's selector is picked up right
+		// away
+		triggers = append(triggers, predicate.LabelChangedPredicate{})
+	}
+	b := mcbuilder.ControllerManagedBy(mgr).
+		For(&kcpv1alpha1.User{}, mcbuilder.WithPredicates(
+			predicate.NewPredicateFuncs(r.selects), predicate.Or(triggers...))).
 		Named("user").
-		Complete(mcreconcile.Func(r.Reconcile))
+		WithOptions(mccontroller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             r.backoff,
+		})
+	if r.WatchAttributeSources {
+		b = b.Watches(&corev1.Secret{}, enqueueReferencingUsers(true)).
+			Watches(&corev1.ConfigMap{}, enqueueReferencingUsers(false))
+	}
+	if r.WatchGroups {
+		b = b.Watches(&kcpv1alpha1.Group{}, r.enqueueUsersMissingGroup)
+	}
+	return b.Complete(mcreconcile.Func(r.Reconcile))
 }
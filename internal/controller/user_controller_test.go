@@ -18,21 +18,29 @@ package controller
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	logr "github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -51,11 +59,13 @@ import (
 
 	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
 	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
 )
 
 // Test helper types
 type fakeCluster struct {
-	client client.Client
+	client   client.Client
+	recorder record.EventRecorder
 }
 
 func (f *fakeCluster) GetClient() client.Client                             { return f.client }
@@ -65,10 +75,30 @@ func (f *fakeCluster) GetConfig() *rest.Config                              { re
 func (f *fakeCluster) GetCache() cache.Cache                                { return nil }
 func (f *fakeCluster) GetScheme() *runtime.Scheme                           { return nil }
 func (f *fakeCluster) GetFieldIndexer() client.FieldIndexer                 { return nil }
-func (f *fakeCluster) GetEventRecorderFor(name string) record.EventRecorder { return nil }
+func (f *fakeCluster) GetEventRecorderFor(name string) record.EventRecorder { return f.recorder }
 func (f *fakeCluster) GetRESTMapper() meta.RESTMapper                       { return nil }
 func (f *fakeCluster) Start(ctx context.Context) error                      { return nil }
 
+// throttledClient fails every CreateUser like a user pool that keeps
+// throttling
+type throttledClient struct {
+	userpool.Client
+}
+
+func (c throttledClient) CreateUser(ctx context.Context, user *userpool.User) error {
+	return fmt.Errorf("failed to create user %s: %w", user.Username, userpool.ErrThrottled)
+}
+
+// throttledReadClient fails every GetUser like a user pool that throttles
+// reads
+type throttledReadClient struct {
+	userpool.Client
+}
+
+func (c throttledReadClient) GetUser(ctx context.Context, username string) (*userpool.User, error) {
+	return nil, fmt.Errorf("failed to get user %s: %w", username, userpool.ErrThrottled)
+}
+
 type fakeManager struct {
 	cluster cluster.Cluster
 	err     error
@@ -128,7 +158,7 @@ var _ = Describe("User Controller", func() {
 					},
 					Spec: kcpv1alpha1.UserSpec{
 						Email:   "test@example.com",
-						Enabled: true,
+						Enabled: ptr.To(true),
 					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
@@ -227,10 +257,11 @@ func TestUserReconciler_Reconcile(t *testing.T) {
 			},
 			Spec: kcpv1alpha1.UserSpec{
 				Email:   "test@example.com",
-				Enabled: true,
+				Enabled: ptr.To(true),
 			},
 		}
-		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).Build()
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
 		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
 		mockCognitoClient := cognito.NewMockClient()
 		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
@@ -270,4 +301,2158 @@ func TestUserReconciler_Reconcile(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("attribute update", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:      "test@example.com",
+				Enabled:    ptr.To(true),
+				Attributes: map[string]string{"org": "new-org"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username:   userName,
+			Email:      "test@example.com",
+			Enabled:    true,
+			Attributes: map[string]string{"org": "old-org"},
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		_, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		cognitoUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil {
+			t.Fatalf("expected user in Cognito, got error: %v", err)
+		}
+		if cognitoUser.Attributes["org"] != "new-org" {
+			t.Errorf("expected attribute org=new-org, got %v", cognitoUser.Attributes)
+		}
+	})
+
+	t.Run("attribute template failure", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:      "test@example.com",
+				Enabled:    ptr.To(true),
+				Attributes: map[string]string{"givenName": "Jane"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		templates, err := ParseAttributeTemplates(map[string]string{
+			"custom:displayName": "{{ .Spec.Attributes.givenName }} {{ .Spec.Attributes.familyName }}",
+		})
+		if err != nil {
+			t.Fatalf("failed to parse templates: %v", err)
+		}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{
+			Scheme:             scheme,
+			Manager:            mgr,
+			UserPoolClient:     mockCognitoClient,
+			AttributeTemplates: templates,
+		}
+		_, err = r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		updatedUser := &kcpv1alpha1.User{}
+		if err := fakeClient.Get(context.Background(), namespacedName, updatedUser); err != nil {
+			t.Fatalf("failed to get updated user: %v", err)
+		}
+		cond := meta.FindStatusCondition(updatedUser.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonAttributeTemplateFailed {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonAttributeTemplateFailed, cond)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err == nil {
+			t.Errorf("expected user not to be created in Cognito")
+		}
+	})
+	t.Run("reconcile outcomes", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: cognito.NewMockClient()}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		expectOutcome := func(outcome reconcileOutcome) {
+			t.Helper()
+			counter := reconcileResults.WithLabelValues(string(outcome))
+			before := testutil.ToFloat64(counter)
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("expected one %s reconcile, got %v", outcome, got)
+			}
+		}
+
+		expectOutcome(outcomeCreated)
+		expectOutcome(outcomeUnchanged)
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Email = "changed@example.com"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		expectOutcome(outcomeUpdated)
+
+		if err := fakeClient.Delete(context.Background(), &user); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		expectOutcome(outcomeDeleted)
+	})
+	t.Run("managed users gauge", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: cognito.NewMockClient(),
+			UserPoolID: "pool-gauge"}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		gauge := managedUsers.WithLabelValues("pool-gauge")
+		gauge.Set(5)
+
+		expectGauge := func(want float64) {
+			t.Helper()
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := testutil.ToFloat64(gauge); got != want {
+				t.Errorf("expected %v managed users, got %v", want, got)
+			}
+		}
+
+		expectGauge(6)
+		expectGauge(6)
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if err := fakeClient.Delete(context.Background(), &user); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		expectGauge(5)
+	})
+	t.Run("generated username", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:            "test@example.com",
+				Enabled:          ptr.To(true),
+				GenerateUsername: true,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		username := user.Status.Username
+		if username == "" || username == userName {
+			t.Fatalf("expected a generated username in status, got %q", username)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), username); err != nil {
+			t.Fatalf("expected user %s in Cognito, got error: %v", username, err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err == nil {
+			t.Errorf("expected no Cognito user named after the object")
+		}
+
+		// A second reconcile must find the user by its generated username
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		users, err := mockCognitoClient.ListUsers(context.Background())
+		if err != nil {
+			t.Fatalf("failed to list users: %v", err)
+		}
+		if len(users) != 1 {
+			t.Errorf("expected one Cognito user, got %d", len(users))
+		}
+
+		if err := fakeClient.Delete(context.Background(), &user); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), username); err == nil {
+			t.Errorf("expected user %s to be deleted from Cognito", username)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); !errors.IsNotFound(err) {
+			t.Errorf("expected User to be gone after finalization, got %v", err)
+		}
+	})
+	t.Run("pool status", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       userName,
+				Namespace:  userNamespace,
+				Generation: 3,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:         "test@example.com",
+				EmailVerified: ptr.To(true),
+				Enabled:       ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: cognito.NewMockClient()}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if user.Status.CognitoStatus != "FORCE_CHANGE_PASSWORD" {
+			t.Errorf("expected cognitoStatus FORCE_CHANGE_PASSWORD, got %q", user.Status.CognitoStatus)
+		}
+		if !ptr.Deref(user.Status.EmailVerified, false) {
+			t.Errorf("expected emailVerified to be true, got %v", user.Status.EmailVerified)
+		}
+		if user.Status.ObservedGeneration != user.Generation {
+			t.Errorf("expected observedGeneration %d, got %d", user.Generation, user.Status.ObservedGeneration)
+		}
+	})
+	t.Run("client metadata on email change", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+				Annotations: map[string]string{
+					ClientMetadataAnnotationPrefix + "tenant":    "acme",
+					ClientMetadataAnnotationPrefix + "api-token": "hunter2",
+				},
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "new@example.com",
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName,
+			Email:    "old@example.com",
+			Enabled:  true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var updated *userpool.User
+		for _, op := range recorder.Operations() {
+			if op.Name == "UpdateUserIfChanged" {
+				updated = op.Args[1].(*userpool.User)
+			}
+		}
+		if updated == nil {
+			t.Fatalf("expected UpdateUserIfChanged to be called, got %v", recorder.Operations())
+		}
+		want := map[string]string{"tenant": "acme"}
+		if !maps.Equal(updated.ClientMetadata, want) {
+			t.Errorf("expected client metadata %v, got %v", want, updated.ClientMetadata)
+		}
+	})
+	t.Run("unmanaged email_verified", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email: "test@example.com", EmailVerified: ptr.To(true), Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username:      userName,
+			Email:         "test@example.com",
+			EmailVerified: ptr.To(false),
+			Enabled:       true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder, EmailVerifiedUnmanaged: true}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		for _, op := range recorder.Operations() {
+			if op.Name == "VerifyAttribute" || op.Name == "UpdateUserIfChanged" {
+				t.Errorf("expected spec.emailVerified to be ignored, got %s", op.Name)
+			}
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if user.Status.EmailVerified == nil || *user.Status.EmailVerified {
+			t.Errorf("expected status to report the pool's email_verified, got %v", user.Status.EmailVerified)
+		}
+	})
+	t.Run("expired temporary password is resent", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName,
+			Email:    "test@example.com",
+			Enabled:  true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		now := time.Now().Add(8 * 24 * time.Hour)
+		r := &UserReconciler{
+			Scheme:                    scheme,
+			Manager:                   mgr,
+			UserPoolClient:            recorder,
+			ResyncPeriod:              time.Hour,
+			Clock:                     fixedClock(now),
+			TemporaryPasswordValidity: 7 * 24 * time.Hour,
+			ResendExpiredInvitations:  true,
+		}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		resent := slices.ContainsFunc(recorder.Operations(), func(op userpool.Operation) bool {
+			return op.Name == "ResendInvitation"
+		})
+		if !resent {
+			t.Errorf("expected ResendInvitation to be called, got %v", recorder.Operations())
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if user.Status.InvitationResentAt == nil || user.Status.TemporaryPasswordExpiresAt == nil ||
+			!user.Status.TemporaryPasswordExpiresAt.After(now) {
+			t.Errorf("expected a fresh temporary password expiry, got %v", user.Status.TemporaryPasswordExpiresAt)
+		}
+	})
+	t.Run("invitation policy", func(t *testing.T) {
+		for policy, want := range map[string]bool{
+			"":                             false,
+			kcpv1alpha1.InvitationSuppress: false,
+			kcpv1alpha1.InvitationSend:     true,
+			kcpv1alpha1.InvitationResend:   true,
+		} {
+			initialUser := &kcpv1alpha1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+				Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true),
+					Invitation: policy},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+				WithStatusSubresource(initialUser).Build()
+			mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+			recorder := userpool.NewRecordingClient(cognito.NewMockClient())
+			r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+			if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+				ClusterName: "cluster1",
+				Request:     reconcile.Request{NamespacedName: namespacedName},
+			}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			i := slices.IndexFunc(recorder.Operations(), func(op userpool.Operation) bool {
+				return op.Name == "CreateUser"
+			})
+			if i < 0 {
+				t.Fatalf("expected CreateUser to be called, got %v", recorder.Operations())
+			}
+			if got := recorder.Operations()[i].Args[0].(*userpool.User).SendInvitation; got != want {
+				t.Errorf("policy %q: expected SendInvitation %v, got %v", policy, want, got)
+			}
+		}
+
+		r := &UserReconciler{}
+		if !r.resendsExpired(&kcpv1alpha1.User{Spec: kcpv1alpha1.UserSpec{
+			Invitation: kcpv1alpha1.InvitationResend}}) {
+			t.Errorf("expected the Resend policy to resend expired invitations")
+		}
+	})
+	t.Run("secondary email", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:          "test@example.com",
+				SecondaryEmail: "personal@example.com",
+				Enabled:        ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.SecondaryEmail = "other@example.com"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil {
+			t.Fatalf("failed to get pool user: %v", err)
+		}
+		if poolUser.SecondaryEmail != "other@example.com" || poolUser.Email != "test@example.com" {
+			t.Errorf("expected secondary email to be updated independently, got %q and %q",
+				poolUser.Email, poolUser.SecondaryEmail)
+		}
+	})
+	t.Run("preferred username", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:             "test@example.com",
+				PreferredUsername: "jane.doe",
+				Enabled:           ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		mockCognitoClient.SetPreferredUsernameAlias(true)
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: "john", Email: "john@example.com", PreferredUsername: "johnny",
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.PreferredUsername != "jane.doe" {
+			t.Fatalf("expected preferred username jane.doe, got %+v, %v", poolUser, err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.PreferredUsername = "johnny"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonAliasExists {
+			t.Errorf("expected reason %s for a taken preferred username, got %+v", ReasonAliasExists, ready)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if poolUser.PreferredUsername != "jane.doe" {
+			t.Errorf("expected preferred username to be kept, got %q", poolUser.PreferredUsername)
+		}
+	})
+
+	t.Run("locale", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Locale: "de-AT", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.Locale != "de-AT" {
+			t.Fatalf("expected locale de-AT, got %+v, %v", poolUser, err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Locale = "german"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonInvalidParameter {
+			t.Errorf("expected reason %s for an invalid locale, got %+v", ReasonInvalidParameter, ready)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if poolUser.Locale != "de-AT" {
+			t.Errorf("expected locale to be kept, got %q", poolUser.Locale)
+		}
+	})
+	t.Run("custom error policy", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Locale: "german", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		var classified []error
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: cognito.NewMockClient(),
+			ErrorPolicy: ErrorPolicyFunc(func(err error) ErrorAction {
+				classified = append(classified, err)
+				return ErrorActionRetryWithBackoff
+			})}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); !stderrors.Is(err, userpool.ErrInvalidParameter) {
+			t.Fatalf("expected the invalid parameter to be retried with backoff, got %v", err)
+		}
+		if len(classified) != 1 {
+			t.Errorf("expected the policy to classify one error, got %v", classified)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonInvalidParameter {
+			t.Errorf("expected reason %s regardless of the action, got %+v", ReasonInvalidParameter, ready)
+		}
+
+		r.ErrorPolicy = ErrorPolicyFunc(func(error) ErrorAction { return ErrorActionIgnore })
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected the ignored error to finish the reconcile, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready = meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Status != metav1.ConditionTrue {
+			t.Errorf("expected the User to be ready once the error is ignored, got %+v", ready)
+		}
+	})
+
+	t.Run("address", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Address: &kcpv1alpha1.UserAddress{StreetAddress: "Ringstraße 1", Locality: "Wien", Country: "AT"},
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := userpool.Address{StreetAddress: "Ringstraße 1", Locality: "Wien", Country: "AT"}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.Address == nil || *poolUser.Address != want {
+			t.Fatalf("expected address %+v, got %+v, %v", want, poolUser, err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Address.Locality = "Graz"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if poolUser.Address == nil || poolUser.Address.Locality != "Graz" {
+			t.Errorf("expected the locality to be updated, got %+v", poolUser.Address)
+		}
+	})
+
+	t.Run("sign-out annotation", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Annotations[SignOutAnnotation] = "2025-01-01T00:00:00Z"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if got := mockCognitoClient.SignOuts(userName); got != 1 {
+			t.Errorf("expected the user to be signed out once, got %d", got)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if _, ok := user.Annotations[SignOutAnnotation]; ok {
+			t.Errorf("expected the sign-out annotation to be removed")
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || !poolUser.Enabled {
+			t.Errorf("expected the user to stay enabled, got %+v, %v", poolUser, err)
+		}
+	})
+	t.Run("rotate-credentials annotation", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Annotations[RotateCredentialsAnnotation] = "incident-42"
+		user.Annotations[RotateCredentialsStepsAnnotation] = "forget-devices, sign-out, reset-password"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		recorder.Reset()
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var steps []string
+		for _, op := range recorder.Operations() {
+			switch op.Name {
+			case "ResetPassword", "SignOutUser", "ForgetDevices":
+				steps = append(steps, op.Name)
+			}
+		}
+		if want := []string{"ResetPassword", "SignOutUser", "ForgetDevices"}; !slices.Equal(steps, want) {
+			t.Errorf("expected the steps %v to run once in order, got %v", want, steps)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if _, ok := user.Annotations[RotateCredentialsAnnotation]; ok {
+			t.Errorf("expected the rotate-credentials annotation to be removed")
+		}
+		if _, ok := user.Annotations[RotateCredentialsStepsAnnotation]; ok {
+			t.Errorf("expected the steps annotation to be removed")
+		}
+		if user.Status.CredentialsRotation != "incident-42" || user.Status.CredentialsRotatedAt == nil {
+			t.Errorf("expected the rotation to be recorded, got %q at %v", user.Status.CredentialsRotation,
+				user.Status.CredentialsRotatedAt)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.Status != userpool.StatusResetRequired {
+			t.Errorf("expected the password to be reset, got %+v, %v", poolUser, err)
+		}
+	})
+	t.Run("throttled", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		events := record.NewFakeRecorder(10)
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient, recorder: events}, err: nil}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr,
+			UserPoolClient: throttledClient{Client: cognito.NewMockClient()}, UserPoolID: "pool-throttled"}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); !stderrors.Is(err, userpool.ErrThrottled) {
+			t.Fatalf("expected ErrThrottled, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		condition := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if condition == nil || condition.Reason != ReasonThrottled {
+			t.Errorf("expected Ready reason %s, got %+v", ReasonThrottled, condition)
+		}
+		select {
+		case event := <-events.Events:
+			if !strings.HasPrefix(event, "Warning Throttled") {
+				t.Errorf("expected a Throttled warning event, got %q", event)
+			}
+		default:
+			t.Errorf("expected a Throttled warning event")
+		}
+		if got := testutil.ToFloat64(throttledReconciles.WithLabelValues("user", "pool-throttled")); got != 1 {
+			t.Errorf("expected one throttled reconcile, got %v", got)
+		}
+	})
+	t.Run("throttled read", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		recorder := userpool.NewRecordingClient(throttledReadClient{Client: cognito.NewMockClient()})
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); !stderrors.Is(err, userpool.ErrThrottled) {
+			t.Fatalf("expected ErrThrottled, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		condition := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if condition == nil || condition.Reason != ReasonThrottled {
+			t.Errorf("expected Ready reason %s, got %+v", ReasonThrottled, condition)
+		}
+		for _, op := range recorder.Operations() {
+			if op.Name == "CreateUser" {
+				t.Errorf("expected no CreateUser after a throttled read, got %+v", op)
+			}
+		}
+	})
+	t.Run("cr reference attribute", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace,
+				Annotations: map[string]string{clusterAnnotation: "root:team"}},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient,
+			ReferenceAttribute: "custom:crRef"}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := "root:team|" + userNamespace + "/" + userName
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil {
+			t.Fatalf("failed to get pool user: %v", err)
+		}
+		if got := poolUser.Attributes["custom:crRef"]; got != want {
+			t.Errorf("expected reference %q, got %q", want, got)
+		}
+
+		// A differing reference alone is not drift
+		poolUser.Attributes["custom:crRef"] = "other"
+		if err := mockCognitoClient.UpdateUser(context.Background(), poolUser); err != nil {
+			t.Fatalf("failed to update pool user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if got := poolUser.Attributes["custom:crRef"]; got != "other" {
+			t.Errorf("expected the reference to be left alone, got %q", got)
+		}
+
+		// but it is written again with the next update
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Email = "new@example.com"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if got := poolUser.Attributes["custom:crRef"]; got != want {
+			t.Errorf("expected reference %q after update, got %q", want, got)
+		}
+	})
+	t.Run("removed managed attributes", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true),
+				Attributes: map[string]string{"team": "a"}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName, Email: "test@example.com", Enabled: true,
+			Attributes: map[string]string{"team": "a", "department": "sales", "custom:external": "x"},
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder,
+			ManagedAttributes: []string{"team", "department"}}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var deleted []string
+		for _, op := range recorder.Operations() {
+			if op.Name == "UpdateUserIfChanged" {
+				deleted = op.Args[1].(*userpool.User).DeleteAttributes
+			}
+		}
+		if !slices.Equal(deleted, []string{"department"}) {
+			t.Errorf("expected only department to be deleted, got %v", deleted)
+		}
+	})
+	t.Run("explicitly removed attributes", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true),
+				Attributes:       map[string]string{"team": "a"},
+				RemoveAttributes: []string{"custom:legacy", "department", "custom:absent"}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName, Email: "test@example.com", Enabled: true,
+			Attributes: map[string]string{"team": "a", "department": "sales", "custom:legacy": "x"},
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		// Explicit removals don't need --delete-managed-attributes
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder,
+			ManagedAttributes: []string{"department"}}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var deleted []string
+		for _, op := range recorder.Operations() {
+			if op.Name == "UpdateUserIfChanged" {
+				deleted = op.Args[1].(*userpool.User).DeleteAttributes
+			}
+		}
+		if !slices.Equal(deleted, []string{"department", "custom:legacy"}) {
+			t.Errorf("expected the present attributes to be deleted once, got %v", deleted)
+		}
+	})
+	t.Run("uniqueness check", func(t *testing.T) {
+		created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		older := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "other", UID: "b", CreationTimestamp: created},
+			Spec:       kcpv1alpha1.UserSpec{Email: "Test@example.com"},
+		}
+		newer := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace, UID: "a",
+				CreationTimestamp: metav1.NewTime(created.Add(time.Minute))},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(older, newer).
+			WithStatusSubresource(older, newer).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient,
+			UniquenessCheck: true, UniquenessCheckPool: true}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected the duplicate not to be retried right away, got %v", err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err == nil {
+			t.Errorf("expected no pool user for the newer User")
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonDuplicateUser || !strings.Contains(ready.Message, "other/jane") {
+			t.Errorf("expected DuplicateUser naming the older User, got %+v", ready)
+		}
+
+		// Once the older User gives up the email, the pool still holds it
+		if err := fakeClient.Delete(context.Background(), older); err != nil {
+			t.Fatalf("failed to delete User: %v", err)
+		}
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: "jane", Email: "test@example.com",
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected the alias conflict not to be retried right away, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready = meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonAliasExists {
+			t.Errorf("expected AliasExists from the pool check, got %+v", ready)
+		}
+	})
+	t.Run("invalid attribute value", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:      "test@example.com",
+				Enabled:    ptr.To(true),
+				Attributes: map[string]string{"custom:bio": strings.Repeat("x", 4096)},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: cognito.NewMockClient(),
+			ResyncPeriod: time.Hour}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		result, err := r.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("expected the invalid value not to be retried right away, got %v", err)
+		}
+		if result.RequeueAfter != time.Hour {
+			t.Errorf("expected requeue after the resync period, got %v", result.RequeueAfter)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonInvalidParameter || !strings.Contains(ready.Message, "custom:bio") {
+			t.Errorf("expected InvalidParameter naming custom:bio, got %+v", ready)
+		}
+	})
+	t.Run("email verification only", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:         "test@example.com",
+				Enabled:       ptr.To(true),
+				EmailVerified: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username:      userName,
+			Email:         "test@example.com",
+			Enabled:       true,
+			EmailVerified: ptr.To(false),
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var names []string
+		for _, op := range recorder.Operations() {
+			names = append(names, op.Name)
+		}
+		if !slices.Contains(names, "VerifyAttribute") || slices.Contains(names, "UpdateUserIfChanged") {
+			t.Errorf("expected VerifyAttribute instead of UpdateUser, got %v", names)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || !ptr.Deref(poolUser.EmailVerified, false) {
+			t.Errorf("expected email to be verified, got %+v, %v", poolUser, err)
+		}
+	})
+	t.Run("attributes from Secrets and ConfigMaps", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:      "test@example.com",
+				Enabled:    ptr.To(true),
+				Attributes: map[string]string{"custom:team": "spec"},
+				AttributesFrom: []kcpv1alpha1.AttributeSource{
+					{Name: "custom:team", ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "hr"}, Key: "team"}},
+					{Name: "custom:apiKey", SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "apiKey"}},
+					{Name: "custom:optional", SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}, Key: "value",
+						Optional: ptr.To(true)}},
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser,
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "hr", Namespace: userNamespace},
+				Data: map[string]string{"team": "platform"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: userNamespace},
+				Data: map[string][]byte{"apiKey": []byte("s3cret")}},
+		).WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil {
+			t.Fatalf("failed to get pool user: %v", err)
+		}
+		if got := poolUser.Attributes["custom:team"]; got != "platform" {
+			t.Errorf("expected custom:team from the ConfigMap, got %q", got)
+		}
+		if got := poolUser.Attributes["custom:apiKey"]; got != "s3cret" {
+			t.Errorf("expected custom:apiKey from the Secret, got %q", got)
+		}
+		if _, ok := poolUser.Attributes["custom:optional"]; ok {
+			t.Errorf("expected missing optional source to be skipped")
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.AttributesFrom = append(user.Spec.AttributesFrom, kcpv1alpha1.AttributeSource{
+			Name: "custom:other", ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "absent"}, Key: "value"}})
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonAttributeSourceFailed {
+			t.Errorf("expected Ready reason %s, got %v", ReasonAttributeSourceFailed, cond)
+		}
+	})
+
+	t.Run("retain annotation", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        userName,
+				Namespace:   userNamespace,
+				Annotations: map[string]string{RetainAnnotation: "true"},
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if err := fakeClient.Delete(context.Background(), &user); err != nil {
+			t.Fatalf("failed to delete user: %v", err)
+		}
+		// The second reconcile sees the User gone and must not touch the pool
+		for range 2 {
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); !errors.IsNotFound(err) {
+			t.Errorf("expected User to be gone after finalization, got %v", err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err != nil {
+			t.Errorf("expected retained user in Cognito, got %v", err)
+		}
+	})
+	t.Run("preferred MFA method", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:     "test@example.com",
+				Enabled:   ptr.To(true),
+				MFAMethod: userpool.MFASoftwareToken,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		mockCognitoClient.SetMFADisabled(true)
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName,
+			Email:    "test@example.com",
+			Enabled:  true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error while MFA is off, got %v", err)
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonMFADisabled {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonMFADisabled, cond)
+		}
+
+		mockCognitoClient.SetMFADisabled(false)
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if user.Status.MFAMethod != userpool.MFASoftwareToken {
+			t.Errorf("expected status.mfaMethod %s, got %q", userpool.MFASoftwareToken, user.Status.MFAMethod)
+		}
+	})
+	t.Run("group memberships", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+				Groups:  []string{"admins", "ghosts"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		mockCognitoClient.AddGroup("admins")
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		_, err := r.Reconcile(context.Background(), req)
+		var missing *userpool.MissingGroupsError
+		if !stderrors.As(err, &missing) || !slices.Equal(missing.Groups, []string{"ghosts"}) {
+			t.Fatalf("expected missing group ghosts, got %v", err)
+		}
+		if members := mockCognitoClient.GroupMembers("admins"); len(members) != 0 {
+			t.Errorf("expected no memberships while a group is missing, got %v", members)
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonGroupsMissing {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonGroupsMissing, cond)
+		}
+
+		user.Spec.Groups = []string{"admins"}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if members := mockCognitoClient.GroupMembers("admins"); !slices.Equal(members, []string{userName}) {
+			t.Errorf("expected %s to be a member of admins, got %v", userName, members)
+		}
+	})
+	t.Run("pending group memberships", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+				Groups:  []string{"admins"},
+			},
+		}
+		group := &kcpv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "admins", Namespace: userNamespace},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser, group).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient, WatchGroups: true,
+			GroupPendingRequeue: time.Second}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+			result, err := r.Reconcile(context.Background(), req)
+			if err != nil {
+				t.Fatalf("expected no error while the Group is pending, got %v", err)
+			}
+			if result.RequeueAfter != want {
+				t.Errorf("expected requeue after %v, got %v", want, result.RequeueAfter)
+			}
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonGroupsPending {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonGroupsPending, cond)
+		}
+
+		// A group no Group creates is misconfigured
+		user.Spec.Groups = []string{"admins", "ghosts"}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		var missing *userpool.MissingGroupsError
+		if _, err := r.Reconcile(context.Background(), req); !stderrors.As(err, &missing) {
+			t.Fatalf("expected missing groups, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond = meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonGroupsMissing {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonGroupsMissing, cond)
+		}
+
+		// Once the Group created its group, the membership is added
+		mockCognitoClient.AddGroup("admins")
+		user.Spec.Groups = []string{"admins"}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if members := mockCognitoClient.GroupMembers("admins"); !slices.Equal(members, []string{userName}) {
+			t.Errorf("expected %s to be a member of admins, got %v", userName, members)
+		}
+	})
+	t.Run("role memberships", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+				Groups:  []string{"staff"},
+				Roles:   []string{"admin"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		for _, group := range []string{"staff", "admins", "readers"} {
+			mockCognitoClient.AddGroup(group)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient,
+			RoleMapping: RoleMapping{"admin": {"admins", "role:viewer"}, "viewer": {"readers"}}}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, group := range []string{"staff", "admins", "readers"} {
+			if members := mockCognitoClient.GroupMembers(group); !slices.Equal(members, []string{userName}) {
+				t.Errorf("expected %s to be a member of %s, got %v", userName, group, members)
+			}
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Roles = []string{"viewer"}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if members := mockCognitoClient.GroupMembers("admins"); len(members) != 0 {
+			t.Errorf("expected %s to be removed from admins, got %v", userName, members)
+		}
+
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Roles = []string{"owner"}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error for an unknown role, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonUnknownRole {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonUnknownRole, cond)
+		}
+	})
+	t.Run("disable reason", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:         "test@example.com",
+				Enabled:       ptr.To(true),
+				DisableReason: "offboarded",
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		setEnabled := func(enabled bool) {
+			t.Helper()
+			var user kcpv1alpha1.User
+			if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+				t.Fatalf("failed to get user: %v", err)
+			}
+			user.Spec.Enabled = ptr.To(enabled)
+			if err := fakeClient.Update(context.Background(), &user); err != nil {
+				t.Fatalf("failed to update user: %v", err)
+			}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		disableReason := func() string {
+			t.Helper()
+			cognitoUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+			if err != nil {
+				t.Fatalf("expected user in Cognito, got error: %v", err)
+			}
+			return cognitoUser.DisableReason
+		}
+
+		setEnabled(true)
+		if reason := disableReason(); reason != "" {
+			t.Errorf("expected no disable reason for an enabled user, got %q", reason)
+		}
+		setEnabled(false)
+		if reason := disableReason(); reason != "offboarded" {
+			t.Errorf("expected disable reason offboarded, got %q", reason)
+		}
+		setEnabled(true)
+		if reason := disableReason(); reason != "" {
+			t.Errorf("expected disable reason to be cleared, got %q", reason)
+		}
+	})
+	t.Run("email used by another user", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "shared@example.com",
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		mockCognitoClient.SetEmailAlias(true)
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: "other-user",
+			Email:    "shared@example.com",
+			Enabled:  true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error so the User isn't retried blindly, got %v", err)
+		}
+
+		var createErr error
+		for _, op := range recorder.Operations() {
+			if op.Name == "CreateUser" {
+				createErr = op.Err
+			}
+		}
+		if !stderrors.Is(createErr, userpool.ErrAliasExists) {
+			t.Errorf("expected CreateUser to fail with ErrAliasExists, got %v", createErr)
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonAliasExists {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonAliasExists, cond)
+		}
+	})
+	t.Run("email username in a pool using email as alias", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:            "jane@example.com",
+				Enabled:          ptr.To(true),
+				GenerateUsername: true,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		mockCognitoClient.SetEmailAlias(true)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient,
+			UsernameStrategy: UsernameLiteral}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error so the User isn't retried blindly, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonInvalidUsername {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonInvalidUsername, cond)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), "jane@example.com"); err == nil {
+			t.Errorf("expected no user with an email username in the pool")
+		}
+	})
+	t.Run("username strategy collision", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:            "Jane@example.com",
+				Enabled:          ptr.To(true),
+				GenerateUsername: true,
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: "jane",
+			Email:    "jane@other.example.com",
+			Enabled:  true,
+		}); err != nil {
+			t.Fatalf("failed to seed mock user: %v", err)
+		}
+		r := &UserReconciler{
+			Scheme:           scheme,
+			Manager:          mgr,
+			UserPoolClient:   mockCognitoClient,
+			UsernameStrategy: UsernameEmailLocalPart,
+		}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if user.Status.Username != "jane-2" {
+			t.Errorf("expected username jane-2, got %q", user.Status.Username)
+		}
+	})
+
+	t.Run("workspace without user pool", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:   "test@example.com",
+				Enabled: ptr.To(true),
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		pools := &WorkspacePools{
+			Pools: map[string]string{"cluster2": "pool-b"},
+			NewClient: func(context.Context, string) (userpool.Client, error) {
+				return mockCognitoClient, nil
+			},
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: userpool.NewRouter(pools.Resolve)}
+		if _, err := r.Reconcile(context.Background(), mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonNoUserPool {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonNoUserPool, cond)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err == nil {
+			t.Errorf("expected no user in the pool of another workspace")
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:     "test@example.com",
+				Enabled:   ptr.To(true),
+				ExpiresAt: &metav1.Time{Time: now.Add(time.Hour)},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{
+			Scheme:         scheme,
+			Manager:        mgr,
+			UserPoolClient: mockCognitoClient,
+			ResyncPeriod:   10 * time.Hour,
+			Clock:          fixedClock(now),
+		}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		result, err := r.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour+time.Second {
+			t.Errorf("expected requeue at the expiry, got %v", result.RequeueAfter)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || !poolUser.Enabled {
+			t.Fatalf("expected enabled user before the expiry, got %+v, %v", poolUser, err)
+		}
+
+		r.Clock = fixedClock(now.Add(2 * time.Hour))
+		for range 2 {
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		poolUser, err = mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.Enabled {
+			t.Fatalf("expected user disabled after the expiry, got %+v, %v", poolUser, err)
+		}
+		if signOuts := mockCognitoClient.SignOuts(userName); signOuts != 1 {
+			t.Errorf("expected expired user to be signed out once, got %d", signOuts)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.ExpiresAt = &metav1.Time{Time: now.Add(3 * time.Hour)}
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to extend expiry: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err = mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || !poolUser.Enabled {
+			t.Errorf("expected user enabled again after extending the expiry, got %+v, %v", poolUser, err)
+		}
+	})
+
+	t.Run("attribute enrichment", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      userName,
+				Namespace: userNamespace,
+			},
+			Spec: kcpv1alpha1.UserSpec{
+				Email:      "test@example.com",
+				Enabled:    ptr.To(true),
+				Attributes: map[string]string{"custom:region": "eu"},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		enricher := &stubEnricher{err: stderrors.New("service unavailable")}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient, Enricher: enricher}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+
+		if _, err := r.Reconcile(context.Background(), req); err == nil {
+			t.Fatalf("expected the enrichment error to be returned")
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err == nil {
+			t.Errorf("expected no user to be created without its enriched attributes")
+		}
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		cond := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Reason != ReasonEnrichmentFailed {
+			t.Errorf("expected Ready condition with reason %s, got %v", ReasonEnrichmentFailed, cond)
+		}
+
+		enricher.err = nil
+		enricher.attributes = map[string]string{"custom:region": "us", "custom:risk": "low"}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil {
+			t.Fatalf("expected user to be created, got %v", err)
+		}
+		if poolUser.Attributes["custom:region"] != "us" || poolUser.Attributes["custom:risk"] != "low" {
+			t.Errorf("expected enriched attributes to override spec.attributes, got %v", poolUser.Attributes)
+		}
+	})
+	t.Run("profile claims", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{
+				Email: "test@example.com", Enabled: ptr.To(true),
+				Nickname: "Janie", Website: "https://jane.example.com", ZoneInfo: "Europe/Vienna",
+				Birthdate: "1990-04-23", Gender: "female",
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, err := mockCognitoClient.GetUser(context.Background(), userName)
+		if err != nil || poolUser.Nickname != "Janie" || poolUser.ZoneInfo != "Europe/Vienna" ||
+			poolUser.Birthdate != "1990-04-23" {
+			t.Fatalf("expected the profile claims to be written, got %+v, %v", poolUser, err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Nickname = ""
+		user.Spec.Website = "jane.example.com"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Reason != ReasonInvalidParameter {
+			t.Errorf("expected reason %s for an invalid website, got %+v", ReasonInvalidParameter, ready)
+		}
+
+		user.Spec.Website = "https://jane.example.org"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		poolUser, _ = mockCognitoClient.GetUser(context.Background(), userName)
+		if poolUser.Website != "https://jane.example.org" || poolUser.Nickname != "Janie" {
+			t.Errorf("expected the website to be updated and the nickname kept, got %+v", poolUser)
+		}
+	})
+
+	t.Run("confirm unconfirmed user", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec:       kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName, Email: "test@example.com", Enabled: true, Status: userpool.StatusUnconfirmed,
+		}); err != nil {
+			t.Fatalf("failed to create pool user: %v", err)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if status, _, _ := mockCognitoClient.GetUserStatus(context.Background(), userName); status !=
+			userpool.StatusUnconfirmed {
+			t.Fatalf("expected the user to stay unconfirmed without spec.confirmed, got %s", status)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		user.Spec.Confirmed = true
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		for range 2 {
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if status, _, _ := mockCognitoClient.GetUserStatus(context.Background(), userName); status !=
+			userpool.StatusConfirmed {
+			t.Errorf("expected the user to be confirmed, got %s", status)
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		ready := meta.FindStatusCondition(user.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if ready == nil || ready.Status != metav1.ConditionTrue {
+			t.Errorf("expected the confirmed user to be ready, got %+v", ready)
+		}
+	})
+
+	t.Run("force password change", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true),
+				ForcePasswordChange: true},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		// The user signed in and chose a permanent password
+		confirm := func() {
+			_ = mockCognitoClient.DeleteUser(context.Background(), userName)
+			if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+				Username: userName, Email: "test@example.com", Enabled: true,
+				Status: userpool.StatusConfirmed, RawStatus: "CONFIRMED",
+			}); err != nil {
+				t.Fatalf("failed to create pool user: %v", err)
+			}
+		}
+		confirm()
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		forced := func() int {
+			n := 0
+			for _, op := range recorder.Operations() {
+				if op.Name == "ForcePasswordChange" {
+					n++
+				}
+			}
+			return n
+		}
+		reconcileUser := func(update func(*kcpv1alpha1.User)) *kcpv1alpha1.User {
+			t.Helper()
+			var user kcpv1alpha1.User
+			if update != nil {
+				if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+					t.Fatalf("failed to get user: %v", err)
+				}
+				update(&user)
+				if err := fakeClient.Update(context.Background(), &user); err != nil {
+					t.Fatalf("failed to update user: %v", err)
+				}
+			}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+				t.Fatalf("failed to get user: %v", err)
+			}
+			return &user
+		}
+
+		user := reconcileUser(nil)
+		if forced() != 1 || user.Status.PasswordChangeForcedAt == nil ||
+			user.Status.CognitoStatus != "FORCE_CHANGE_PASSWORD" {
+			t.Fatalf("expected the password change to be forced and pending, got %d calls and %+v", forced(),
+				user.Status)
+		}
+
+		confirm()
+		user = reconcileUser(nil)
+		if forced() != 1 || user.Status.CognitoStatus != "CONFIRMED" {
+			t.Errorf("expected the change not to be forced again, got %d calls and %+v", forced(), user.Status)
+		}
+
+		user = reconcileUser(func(user *kcpv1alpha1.User) { user.Spec.ForcePasswordChange = false })
+		if user.Status.PasswordChangeForcedAt != nil {
+			t.Errorf("expected clearing the field to clear the status, got %v", user.Status.PasswordChangeForcedAt)
+		}
+		reconcileUser(func(user *kcpv1alpha1.User) { user.Spec.ForcePasswordChange = true })
+		if forced() != 2 {
+			t.Errorf("expected setting the field again to force another change, got %d calls", forced())
+		}
+	})
+
+	t.Run("federated identities", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true),
+				FederatedIdentities: []kcpv1alpha1.FederatedIdentity{
+					{ProviderName: "Google", ProviderUserID: "1234"},
+					{ProviderName: "SAML", ProviderUserID: "jane@corp"},
+				}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		if err := mockCognitoClient.CreateUser(context.Background(), &userpool.User{
+			Username: userName, Email: "test@example.com", Enabled: true,
+			Identities: []userpool.Identity{{ProviderName: "Google", UserID: "1234"}},
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		recorder := userpool.NewRecordingClient(mockCognitoClient)
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: recorder}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		for range 2 {
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		var links []string
+		for _, op := range recorder.Operations() {
+			if op.Name == "LinkProvider" {
+				links = append(links, fmt.Sprintf("%s:%v", op.Username, op.Args))
+			}
+		}
+		if want := []string{userName + ":[SAML jane@corp]"}; !slices.Equal(links, want) {
+			t.Errorf("expected only the unlinked identity to be linked once, got %v", links)
+		}
+	})
+
+	t.Run("user outside the selector", func(t *testing.T) {
+		initialUser := &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: userName, Namespace: userNamespace,
+				Labels: map[string]string{"shard": "b"}},
+			Spec: kcpv1alpha1.UserSpec{Email: "test@example.com", Enabled: ptr.To(true)},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(initialUser).
+			WithStatusSubresource(initialUser).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}, err: nil}
+		mockCognitoClient := cognito.NewMockClient()
+		selector, err := labels.Parse("shard=a")
+		if err != nil {
+			t.Fatalf("failed to parse selector: %v", err)
+		}
+		r := &UserReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mockCognitoClient, Selector: selector}
+		req := mcreconcile.Request{
+			ClusterName: "cluster1",
+			Request:     reconcile.Request{NamespacedName: namespacedName},
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); !stderrors.Is(err,
+			userpool.ErrUserNotFound) {
+			t.Fatalf("expected a User of another shard to be left alone, got %v", err)
+		}
+
+		var user kcpv1alpha1.User
+		if err := fakeClient.Get(context.Background(), namespacedName, &user); err != nil {
+			t.Fatalf("failed to get user: %v", err)
+		}
+		if len(user.Finalizers) != 0 || len(user.Status.Conditions) != 0 {
+			t.Errorf("expected no finalizer or status on a User of another shard, got %+v", user)
+		}
+		user.Labels["shard"] = "a"
+		if err := fakeClient.Update(context.Background(), &user); err != nil {
+			t.Fatalf("failed to update user: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mockCognitoClient.GetUser(context.Background(), userName); err != nil {
+			t.Errorf("expected the relabeled User to be created, got %v", err)
+		}
+	})
+}
+
+// stubEnricher is an AttributeEnricher returning fixed attributes or an error
+type stubEnricher struct {
+	attributes map[string]string
+	err        error
+}
+
+func (e *stubEnricher) Enrich(context.Context, *kcpv1alpha1.User) (map[string]string, error) {
+	return e.attributes, e.err
 }
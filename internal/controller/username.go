@@ -0,0 +1,120 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// maxUsernameAttempts is the number of candidates tried when a generated
+// username is taken, e.g. jane, jane-2, ..., jane-5
+const maxUsernameAttempts = 5
+
+// UsernameStrategy derives the pool username for a User with
+// spec.generateUsername set. Collisions with other pool users are resolved by
+// the reconciler, so a strategy may return the same name for different Users.
+type UsernameStrategy func(user *kcpv1alpha1.User) (string, error)
+
+// UsernameUUID generates a random UUID username
+func UsernameUUID(user *kcpv1alpha1.User) (string, error) {
+	return uuid.NewString(), nil
+}
+
+// UsernameLiteral uses the lowercased email as username
+func UsernameLiteral(user *kcpv1alpha1.User) (string, error) {
+	if user.Spec.Email == "" {
+		return "", fmt.Errorf("email is required to derive a username")
+	}
+	return strings.ToLower(user.Spec.Email), nil
+}
+
+// UsernameEmailLocalPart uses the lowercased part of the email before the @
+func UsernameEmailLocalPart(user *kcpv1alpha1.User) (string, error) {
+	local, _, ok := strings.Cut(user.Spec.Email, "@")
+	if !ok || local == "" {
+		return "", fmt.Errorf("email %q has no local part to derive a username from", user.Spec.Email)
+	}
+	return strings.ToLower(local), nil
+}
+
+// UsernameEmailHash uses the hex SHA-256 of the lowercased email, which is
+// stable but doesn't reveal the email
+func UsernameEmailHash(user *kcpv1alpha1.User) (string, error) {
+	if user.Spec.Email == "" {
+		return "", fmt.Errorf("email is required to derive a username")
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(user.Spec.Email)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ParseUsernameStrategy returns the built-in strategy with the given name:
+// uuid, literal, email-local-part or email-hash
+func ParseUsernameStrategy(name string) (UsernameStrategy, error) {
+	switch name {
+	case "uuid":
+		return UsernameUUID, nil
+	case "literal":
+		return UsernameLiteral, nil
+	case "email-local-part":
+		return UsernameEmailLocalPart, nil
+	case "email-hash":
+		return UsernameEmailHash, nil
+	default:
+		return nil, fmt.Errorf("unknown username strategy %q, expected uuid, literal, email-local-part or email-hash",
+			name)
+	}
+}
+
+// assignUsername picks a free pool username for the User using the
+// reconciler's strategy. A pool user with the candidate name and the same
+// email is treated as created by an earlier reconcile and returned, so it is
+// adopted instead of created twice.
+func (r *UserReconciler) assignUsername(ctx context.Context, user *kcpv1alpha1.User) (string, *userpool.User, error) {
+	base, err := r.UsernameStrategy(user)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate username: %w", err)
+	}
+
+	for attempt := 1; attempt <= maxUsernameAttempts; attempt++ {
+		candidate := base
+		if attempt > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		existing, err := r.UserPoolClient.GetUser(ctx, candidate)
+		if errors.Is(err, userpool.ErrUserNotFound) {
+			return candidate, nil, nil
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to check username %s: %w", r.pii(candidate), err)
+		}
+		if existing.Email == user.Spec.Email {
+			return candidate, existing, nil
+		}
+	}
+	return "", nil, fmt.Errorf("failed to generate username: %s and %d alternatives are taken",
+		base, maxUsernameAttempts-1)
+}
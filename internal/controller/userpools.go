@@ -0,0 +1,77 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// AnyCluster is the WorkspacePools key matching logical clusters without an
+// entry of their own
+const AnyCluster = "*"
+
+// WorkspacePools resolves the user pool client for the logical cluster a call
+// is made for, as recorded in its context by the reconcilers, so every kcp
+// workspace can have its own user pool. It is meant as the resolver of a
+// userpool.Router. Clients are created on first use and reused for all
+// clusters mapped to the same pool.
+type WorkspacePools struct {
+	// Pools maps logical cluster names to user pool IDs. AnyCluster matches
+	// clusters without an entry of their own; without it, calls for
+	// unmapped clusters fail with userpool.ErrNoUserPool.
+	Pools map[string]string
+
+	// NewClient creates the client of a user pool
+	NewClient func(ctx context.Context, userPoolID string) (userpool.Client, error)
+
+	mu      sync.Mutex
+	clients map[string]userpool.Client
+}
+
+// Resolve returns the client of the user pool mapped to the logical cluster
+// of ctx
+func (p *WorkspacePools) Resolve(ctx context.Context) (userpool.Client, error) {
+	cluster, _ := mccontext.ClusterFrom(ctx)
+	poolID, ok := p.Pools[cluster]
+	if !ok {
+		poolID, ok = p.Pools[AnyCluster]
+	}
+	if !ok {
+		return nil, fmt.Errorf("logical cluster %q: %w", cluster, userpool.ErrNoUserPool)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[poolID]; ok {
+		return client, nil
+	}
+	client, err := p.NewClient(ctx, poolID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for user pool %s: %w", poolID, err)
+	}
+	if p.clients == nil {
+		p.clients = make(map[string]userpool.Client)
+	}
+	p.clients[poolID] = client
+	return client, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestWorkspacePools(t *testing.T) {
+	var created []string
+	pools := &WorkspacePools{
+		Pools: map[string]string{"root:a": "pool-a", "root:b": "pool-a", AnyCluster: "pool-default"},
+		NewClient: func(_ context.Context, userPoolID string) (userpool.Client, error) {
+			created = append(created, userPoolID)
+			return cognito.NewMockClient(), nil
+		},
+	}
+	resolve := func(cluster string) userpool.Client {
+		t.Helper()
+		client, err := pools.Resolve(mccontext.WithCluster(context.Background(), cluster))
+		if err != nil {
+			t.Fatalf("failed to resolve cluster %s: %v", cluster, err)
+		}
+		return client
+	}
+
+	if resolve("root:a") != resolve("root:b") {
+		t.Errorf("expected clusters mapped to the same pool to share a client")
+	}
+	if resolve("root:c") == resolve("root:a") {
+		t.Errorf("expected unmapped cluster to use the default pool")
+	}
+	if len(created) != 2 || created[0] != "pool-a" || created[1] != "pool-default" {
+		t.Errorf("expected one client per pool, got %v", created)
+	}
+
+	delete(pools.Pools, AnyCluster)
+	_, err := pools.Resolve(mccontext.WithCluster(context.Background(), "root:c"))
+	if !errors.Is(err, userpool.ErrNoUserPool) {
+		t.Errorf("expected ErrNoUserPool for an unmapped cluster, got %v", err)
+	}
+}
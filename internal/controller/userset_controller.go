@@ -0,0 +1,351 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/userpool"
+	mcbuilder "sigs.k8s.io/multicluster-runtime/pkg/builder"
+	mcmanager "sigs.k8s.io/multicluster-runtime/pkg/manager"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+)
+
+// UserSetReconciler reconciles a UserSet object. Every member of the set is
+// converged to a pool user with the member's username; members removed from
+// the set are deleted from the user pool.
+type UserSetReconciler struct {
+	Scheme         *runtime.Scheme
+	Manager        mcmanager.Manager
+	UserPoolClient userpool.Client
+
+	// UserPoolID identifies the user pool in metrics
+	UserPoolID string
+
+	// ResyncPeriod is the interval after which a successfully reconciled
+	// UserSet is reconciled again. Zero disables periodic resync.
+	ResyncPeriod time.Duration
+
+	// RedactPII logs pool usernames as a stable hash instead of in clear text
+	RedactPII bool
+
+	// ReferenceAttribute names an attribute that is set to the reference of
+	// the UserSet managing a pool user, like UserReconciler.ReferenceAttribute
+	ReferenceAttribute string
+
+	// ManagedAttributes lists the attributes owned by UserSet members, like
+	// UserReconciler.ManagedAttributes
+	ManagedAttributes []string
+}
+
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=usersets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=usersets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=kcp.cogniteo.io,resources=usersets/finalizers,verbs=update
+
+// Reconcile converges the user pool to the members of a UserSet. The pool is
+// listed once per reconcile and the members are diffed against it, so a large
+// set costs one list plus one call per changed user.
+func (r *UserSetReconciler) Reconcile(ctx context.Context, req mcreconcile.Request) (ctrl.Result, error) {
+	ctx, log := withClusterContext(ctx, req)
+	log.Info("Reconciling UserSet")
+
+	cl, err := r.Manager.GetCluster(ctx, req.ClusterName)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get cluster: %w", err)
+	}
+	clusterClient := cl.GetClient()
+
+	var set kcpv1alpha1.UserSet
+	if err := clusterClient.Get(ctx, req.NamespacedName, &set); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if r.UserPoolClient == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if !set.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&set, UserPoolFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.deleteMembers(ctx, clusterClient, &set, log)
+	}
+	if controllerutil.AddFinalizer(&set, UserPoolFinalizer) {
+		if err := clusterClient.Update(ctx, &set); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	persisted := set.Status.DeepCopy()
+
+	existing, err := r.UserPoolClient.ListUsers(ctx)
+	if err != nil {
+		log.Error(err, "Failed to list users in user pool")
+		reason := ReasonSyncFailed
+		switch {
+		case stderrors.Is(err, userpool.ErrThrottled):
+			reason = ReasonThrottled
+			reportThrottled(cl.GetEventRecorderFor("userset"), &set, "userset", r.UserPoolID, err)
+		case stderrors.Is(err, userpool.ErrNoUserPool):
+			reason = ReasonNoUserPool
+		}
+		if condErr := r.setReadyCondition(ctx, clusterClient, &set, persisted,
+			metav1.ConditionFalse, reason, err.Error()); condErr != nil {
+			log.Error(condErr, "Failed to update UserSet status")
+		}
+		return ctrl.Result{RequeueAfter: time.Minute * 5}, fmt.Errorf("failed to list users in user pool: %w", err)
+	}
+	byName := make(map[string]*userpool.User, len(existing))
+	for _, user := range existing {
+		byName[user.Username] = user
+	}
+
+	desired := make(map[string]bool, len(set.Spec.Users))
+	statuses := make([]kcpv1alpha1.UserSetMemberStatus, 0, len(set.Spec.Users))
+	failed := 0
+	var throttled error
+	for _, member := range set.Spec.Users {
+		desired[member.Username] = true
+		status := kcpv1alpha1.UserSetMemberStatus{Username: member.Username, Synced: true}
+		if err := r.syncMember(ctx, &set, member, byName[member.Username], log); err != nil {
+			log.Error(err, "Failed to sync user with user pool", "username", r.pii(member.Username))
+			status.Synced = false
+			status.Message = err.Error()
+			failed++
+			if stderrors.Is(err, userpool.ErrThrottled) {
+				throttled = err
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Members removed from the spec are still listed in the status until
+	// their pool user is deleted
+	for _, previous := range set.Status.Users {
+		if desired[previous.Username] {
+			continue
+		}
+		if err := r.deleteMember(ctx, &set, previous.Username, byName[previous.Username], log); err != nil {
+			log.Error(err, "Failed to delete user from user pool", "username", r.pii(previous.Username))
+			if stderrors.Is(err, userpool.ErrThrottled) {
+				throttled = err
+			}
+			statuses = append(statuses, kcpv1alpha1.UserSetMemberStatus{
+				Username: previous.Username,
+				Message:  err.Error(),
+			})
+			failed++
+		}
+	}
+
+	set.Status.Users = statuses
+	set.Status.ObservedGeneration = set.Generation
+	if failed > 0 {
+		message := fmt.Sprintf("%d of %d users failed to sync", failed, len(statuses))
+		reason := ReasonSyncFailed
+		if throttled != nil {
+			// One event per reconcile, however many members were throttled
+			reason = ReasonThrottled
+			reportThrottled(cl.GetEventRecorderFor("userset"), &set, "userset", r.UserPoolID, throttled)
+		}
+		if err := r.setReadyCondition(ctx, clusterClient, &set, persisted,
+			metav1.ConditionFalse, reason, message); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	}
+
+	if err := r.setReadyCondition(ctx, clusterClient, &set, persisted,
+		metav1.ConditionTrue, ReasonReconciled, "All users are in sync with the user pool"); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// errMemberConflict is wrapped by the error syncMember returns when the pool
+// user of a member is managed by another resource
+var errMemberConflict = stderrors.New("managed by another resource")
+
+// syncMember creates the pool user of a member or updates it if it differs
+// from the member. An existing pool user is only adopted if it carries no
+// reference or the reference of set.
+func (r *UserSetReconciler) syncMember(ctx context.Context, set *kcpv1alpha1.UserSet,
+	member kcpv1alpha1.UserSetMember, existing *userpool.User, log logr.Logger) error {
+	poolUser := memberUser(member)
+
+	if existing == nil {
+		poolUser.Attributes = withReference(poolUser.Attributes, r.ReferenceAttribute, set)
+		log.Info("Creating user in user pool", "username", r.pii(poolUser.Username))
+		if err := r.UserPoolClient.CreateUser(ctx, poolUser); err != nil {
+			return fmt.Errorf("failed to create user in user pool: %w", err)
+		}
+		managedUsers.WithLabelValues(r.UserPoolID).Inc()
+		return nil
+	}
+
+	owner, owned := r.owner(set, existing)
+	if !owned {
+		return fmt.Errorf("user %s is %w %s", r.pii(poolUser.Username), errMemberConflict, owner)
+	}
+	poolUser.DeleteAttributes = removedAttributes(r.ManagedAttributes, poolUser.Attributes, existing.Attributes,
+		r.ReferenceAttribute)
+	// An adopted user without a reference gets one, so it is recognized as
+	// this set's when it is deleted
+	if existing.Email == poolUser.Email && existing.Enabled == poolUser.Enabled &&
+		(owner != "" || r.ReferenceAttribute == "") &&
+		!attributesChanged(poolUser.Attributes, existing.Attributes) && len(poolUser.DeleteAttributes) == 0 {
+		return nil
+	}
+	poolUser.Attributes = withReference(poolUser.Attributes, r.ReferenceAttribute, set)
+	log.Info("Updating user in user pool", "username", r.pii(poolUser.Username))
+	if err := r.UserPoolClient.UpdateUserDelta(ctx, existing, poolUser); err != nil {
+		return fmt.Errorf("failed to update user in user pool: %w", err)
+	}
+	return nil
+}
+
+// memberUser returns the pool user a member converges to
+func memberUser(member kcpv1alpha1.UserSetMember) *userpool.User {
+	return &userpool.User{
+		Username:   member.Username,
+		Email:      member.Email,
+		Enabled:    ptr.Deref(member.Enabled, true),
+		Attributes: member.Attributes,
+	}
+}
+
+// MemberUsers returns the pool users the members of set converge to, e.g. to
+// converge a pool to a UserSet manifest with userpool.Converge
+func MemberUsers(set *kcpv1alpha1.UserSet) []*userpool.User {
+	users := make([]*userpool.User, 0, len(set.Spec.Users))
+	for _, member := range set.Spec.Users {
+		users = append(users, memberUser(member))
+	}
+	return users
+}
+
+// owner returns the reference of the resource managing the pool user, empty
+// if it has none or references are disabled, and whether set may manage it
+func (r *UserSetReconciler) owner(set *kcpv1alpha1.UserSet, user *userpool.User) (string, bool) {
+	if r.ReferenceAttribute == "" {
+		return "", true
+	}
+	owner := user.Attributes[r.ReferenceAttribute]
+	return owner, owner == "" || owner == crReference(set)
+}
+
+// deleteMember deletes the pool user of a former member of set. A user that
+// is already gone counts as deleted, and so does one managed by another
+// resource, which is left alone. existing is the pool user if it was listed
+// already; otherwise it is read when references are enabled.
+func (r *UserSetReconciler) deleteMember(ctx context.Context, set *kcpv1alpha1.UserSet, username string,
+	existing *userpool.User, log logr.Logger) error {
+	if r.ReferenceAttribute != "" && existing == nil {
+		user, err := r.UserPoolClient.GetUser(ctx, username)
+		switch {
+		case stderrors.Is(err, userpool.ErrUserNotFound):
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to read user from user pool: %w", err)
+		}
+		existing = user
+	}
+	if existing != nil {
+		if owner, owned := r.owner(set, existing); !owned {
+			log.Info("Not deleting user managed by another resource", "username", r.pii(username), "owner", owner)
+			return nil
+		}
+	}
+
+	err := r.UserPoolClient.DeleteUser(ctx, username)
+	switch {
+	case stderrors.Is(err, userpool.ErrUserNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to delete user from user pool: %w", err)
+	}
+	managedUsers.WithLabelValues(r.UserPoolID).Dec()
+	log.Info("User deleted from user pool", "username", r.pii(username))
+	return nil
+}
+
+// deleteMembers deletes the pool users of every member recorded in the status
+// and removes the finalizer once all of them are gone
+func (r *UserSetReconciler) deleteMembers(ctx context.Context, c client.Client, set *kcpv1alpha1.UserSet,
+	log logr.Logger) error {
+	for _, member := range set.Status.Users {
+		if err := r.deleteMember(ctx, set, member.Username, nil, log); err != nil {
+			return err
+		}
+	}
+	controllerutil.RemoveFinalizer(set, UserPoolFinalizer)
+	if err := c.Update(ctx, set); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove finalizer: %w", err)
+	}
+	return nil
+}
+
+// pii returns value, or its hash when PII redaction is enabled
+func (r *UserSetReconciler) pii(value string) string {
+	if r.RedactPII {
+		return userpool.Redact(value)
+	}
+	return value
+}
+
+// setReadyCondition sets the Ready condition on the UserSet and updates its
+// status if it differs from the persisted status
+func (r *UserSetReconciler) setReadyCondition(ctx context.Context, c client.Client, set *kcpv1alpha1.UserSet,
+	persisted *kcpv1alpha1.UserSetStatus, status metav1.ConditionStatus, reason, message string) error {
+	meta.SetStatusCondition(&set.Status.Conditions, metav1.Condition{
+		Type:               kcpv1alpha1.ConditionTypeReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: set.Generation,
+	})
+	if equality.Semantic.DeepEqual(persisted, &set.Status) {
+		return nil
+	}
+	if err := c.Status().Update(ctx, set); err != nil {
+		return fmt.Errorf("failed to update UserSet status: %w", err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UserSetReconciler) SetupWithManager(mgr mcmanager.Manager) error {
+	return mcbuilder.ControllerManagedBy(mgr).
+		For(&kcpv1alpha1.UserSet{}, mcbuilder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("userset").
+		Complete(mcreconcile.Func(r.Reconcile))
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	mcreconcile "sigs.k8s.io/multicluster-runtime/pkg/reconcile"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestUserSetReconciler_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add clientgoscheme: %v", err)
+	}
+	if err := kcpv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add kcpv1alpha1 scheme: %v", err)
+	}
+
+	namespacedName := types.NamespacedName{Name: "team", Namespace: "default"}
+	req := mcreconcile.Request{ClusterName: "cluster1", Request: reconcile.Request{NamespacedName: namespacedName}}
+
+	t.Run("converges the user pool to the members", func(t *testing.T) {
+		set := &kcpv1alpha1.UserSet{
+			ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name, Namespace: namespacedName.Namespace},
+			Spec: kcpv1alpha1.UserSetSpec{Users: []kcpv1alpha1.UserSetMember{
+				{Username: "jane", Email: "jane@example.com"},
+				{Username: "john", Email: "john@example.com", Enabled: ptr.To(false)},
+			}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(set).
+			WithStatusSubresource(set).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}}
+		mock := cognito.NewMockClient()
+		if err := mock.CreateUser(context.Background(), &userpool.User{
+			Username: "john", Email: "old@example.com", Enabled: true,
+		}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+		r := &UserSetReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mock}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		jane, err := mock.GetUser(context.Background(), "jane")
+		if err != nil || !jane.Enabled || jane.Email != "jane@example.com" {
+			t.Errorf("expected jane to be created enabled, got %+v, %v", jane, err)
+		}
+		john, err := mock.GetUser(context.Background(), "john")
+		if err != nil || john.Enabled || john.Email != "john@example.com" {
+			t.Errorf("expected john to be updated and disabled, got %+v, %v", john, err)
+		}
+
+		var updated kcpv1alpha1.UserSet
+		if err := fakeClient.Get(context.Background(), namespacedName, &updated); err != nil {
+			t.Fatalf("failed to get UserSet: %v", err)
+		}
+		if len(updated.Status.Users) != 2 || !updated.Status.Users[0].Synced || !updated.Status.Users[1].Synced {
+			t.Errorf("expected both users to be synced, got %+v", updated.Status.Users)
+		}
+		if !meta.IsStatusConditionTrue(updated.Status.Conditions, kcpv1alpha1.ConditionTypeReady) {
+			t.Errorf("expected Ready condition to be true, got %+v", updated.Status.Conditions)
+		}
+
+		// Removing a member deletes its pool user
+		updated.Spec.Users = updated.Spec.Users[:1]
+		if err := fakeClient.Update(context.Background(), &updated); err != nil {
+			t.Fatalf("failed to update UserSet: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "john"); err == nil {
+			t.Errorf("expected john to be deleted from the user pool")
+		}
+		if err := fakeClient.Get(context.Background(), namespacedName, &updated); err != nil {
+			t.Fatalf("failed to get UserSet: %v", err)
+		}
+		if len(updated.Status.Users) != 1 || updated.Status.Users[0].Username != "jane" {
+			t.Errorf("expected only jane in status, got %+v", updated.Status.Users)
+		}
+
+		// Deleting the set deletes the remaining pool users
+		if err := fakeClient.Delete(context.Background(), &updated); err != nil {
+			t.Fatalf("failed to delete UserSet: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "jane"); err == nil {
+			t.Errorf("expected jane to be deleted from the user pool")
+		}
+	})
+
+	t.Run("reports per-user failures", func(t *testing.T) {
+		set := &kcpv1alpha1.UserSet{
+			ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name, Namespace: namespacedName.Namespace},
+			Spec: kcpv1alpha1.UserSetSpec{Users: []kcpv1alpha1.UserSetMember{
+				{Username: "jane", Email: "jane@example.com"},
+				{Username: "john", Email: "jane@example.com"},
+			}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(set).
+			WithStatusSubresource(set).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}}
+		mock := cognito.NewMockClient()
+		mock.SetEmailAlias(true)
+		r := &UserSetReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mock}
+
+		result, err := r.Reconcile(context.Background(), req)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result.RequeueAfter == 0 {
+			t.Errorf("expected a requeue after a failed user")
+		}
+
+		var updated kcpv1alpha1.UserSet
+		if err := fakeClient.Get(context.Background(), namespacedName, &updated); err != nil {
+			t.Fatalf("failed to get UserSet: %v", err)
+		}
+		if len(updated.Status.Users) != 2 || !updated.Status.Users[0].Synced ||
+			updated.Status.Users[1].Synced || updated.Status.Users[1].Message == "" {
+			t.Errorf("expected only john to fail, got %+v", updated.Status.Users)
+		}
+		cond := meta.FindStatusCondition(updated.Status.Conditions, kcpv1alpha1.ConditionTypeReady)
+		if cond == nil || cond.Status != metav1.ConditionFalse || cond.Message != "1 of 2 users failed to sync" {
+			t.Errorf("expected Ready condition to report the failure, got %+v", cond)
+		}
+	})
+	t.Run("leaves users managed by other resources", func(t *testing.T) {
+		set := &kcpv1alpha1.UserSet{
+			ObjectMeta: metav1.ObjectMeta{Name: namespacedName.Name, Namespace: namespacedName.Namespace},
+			Spec: kcpv1alpha1.UserSetSpec{Users: []kcpv1alpha1.UserSetMember{
+				{Username: "jane", Email: "jane@example.com"},
+				{Username: "john", Email: "john@example.com"},
+			}},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(set).
+			WithStatusSubresource(set).Build()
+		mgr := &fakeManager{cluster: &fakeCluster{client: fakeClient}}
+		mock := cognito.NewMockClient()
+		for _, user := range []*userpool.User{
+			{Username: "jane", Email: "jane@example.com", Enabled: true,
+				Attributes: map[string]string{"custom:crRef": "default/jane"}},
+			{Username: "john", Email: "john@example.com", Enabled: true},
+		} {
+			if err := mock.CreateUser(context.Background(), user); err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+		}
+		r := &UserSetReconciler{Scheme: scheme, Manager: mgr, UserPoolClient: mock,
+			ReferenceAttribute: "custom:crRef"}
+
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		var updated kcpv1alpha1.UserSet
+		if err := fakeClient.Get(context.Background(), namespacedName, &updated); err != nil {
+			t.Fatalf("failed to get UserSet: %v", err)
+		}
+		if len(updated.Status.Users) != 2 || updated.Status.Users[0].Synced ||
+			!strings.Contains(updated.Status.Users[0].Message, "default/jane") || !updated.Status.Users[1].Synced {
+			t.Errorf("expected a conflict for jane only, got %+v", updated.Status.Users)
+		}
+		jane, _ := mock.GetUser(context.Background(), "jane")
+		if ref := jane.Attributes["custom:crRef"]; ref != "default/jane" {
+			t.Errorf("expected the reference of jane to be kept, got %q", ref)
+		}
+		john, _ := mock.GetUser(context.Background(), "john")
+		if ref := john.Attributes["custom:crRef"]; ref != "default/team" {
+			t.Errorf("expected john to be adopted with the set's reference, got %q", ref)
+		}
+
+		if err := fakeClient.Delete(context.Background(), &updated); err != nil {
+			t.Fatalf("failed to delete UserSet: %v", err)
+		}
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "jane"); err != nil {
+			t.Errorf("expected jane to be kept, got %v", err)
+		}
+		if _, err := mock.GetUser(context.Background(), "john"); err == nil {
+			t.Errorf("expected john to be deleted from the user pool")
+		}
+	})
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+// maxUsernameLength is the maximum length of a Cognito username
+const maxUsernameLength = 128
+
+// phoneNumberAttribute is the standard attribute holding the user's phone number
+const phoneNumberAttribute = "phone_number"
+
+// phoneNumberPattern is the E.164 format Cognito expects phone numbers in
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// nolint:unused
+// log is for logging in this package.
+var userlog = logf.Log.WithName("user-resource")
+
+// SetupUserWebhookWithManager registers the webhook for User in the manager.
+func SetupUserWebhookWithManager(mgr ctrl.Manager, validator *UserCustomValidator,
+	defaulter *UserCustomDefaulter) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&kcpv1alpha1.User{}).
+		WithValidator(validator).
+		WithDefaulter(defaulter).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-kcp-cogniteo-io-v1alpha1-user,mutating=true,failurePolicy=fail,sideEffects=None,groups=kcp.cogniteo.io,resources=users,verbs=create;update,versions=v1alpha1,name=muser-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// UserCustomDefaulter sets default values on User resources. Defaults are only
+// applied to unset fields and never override explicit values.
+type UserCustomDefaulter struct {
+	// EmailVerifiedDefault is used for spec.emailVerified when the User has an
+	// email but doesn't set emailVerified. Nil leaves the field unset.
+	EmailVerifiedDefault *bool
+}
+
+var _ webhook.CustomDefaulter = &UserCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type User.
+func (d *UserCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	user, ok := obj.(*kcpv1alpha1.User)
+	if !ok {
+		return fmt.Errorf("expected a User object but got %T", obj)
+	}
+	userlog.Info("Defaulting for User", "name", user.GetName())
+
+	if user.Spec.Enabled == nil {
+		user.Spec.Enabled = ptr.To(true)
+	}
+	if user.Spec.EmailVerified == nil && user.Spec.Email != "" && d.EmailVerifiedDefault != nil {
+		user.Spec.EmailVerified = ptr.To(*d.EmailVerifiedDefault)
+	}
+	// The username is the object name, which Kubernetes already restricts to
+	// lowercase, so no case normalization is needed.
+
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-kcp-cogniteo-io-v1alpha1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=kcp.cogniteo.io,resources=users,verbs=create;update,versions=v1alpha1,name=vuser-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// UserCustomValidator validates User resources on create and update.
+type UserCustomValidator struct {
+	// AllowedAttributes restricts the attribute names a User may set. An empty
+	// list allows all attributes.
+	AllowedAttributes []string
+}
+
+var _ webhook.CustomValidator = &UserCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	user, ok := obj.(*kcpv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object but got %T", obj)
+	}
+	userlog.Info("Validation for User upon creation", "name", user.GetName())
+
+	return nil, v.validate(user)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	user, ok := newObj.(*kcpv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object for the newObj but got %T", newObj)
+	}
+	oldUser, ok := oldObj.(*kcpv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object for the oldObj but got %T", oldObj)
+	}
+	userlog.Info("Validation for User upon update", "name", user.GetName())
+
+	allErrs := ValidateUser(user, v.AllowedAttributes)
+	if user.Spec.GenerateUsername != oldUser.Spec.GenerateUsername {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "generateUsername"),
+			"cannot be changed after creation"))
+	}
+	return nil, toInvalid(user, allErrs)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type User.
+func (v *UserCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate returns an Invalid error listing every problem with the User
+func (v *UserCustomValidator) validate(user *kcpv1alpha1.User) error {
+	return toInvalid(user, ValidateUser(user, v.AllowedAttributes))
+}
+
+// toInvalid converts allErrs into an Invalid error, or nil if it is empty
+func toInvalid(user *kcpv1alpha1.User, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(kcpv1alpha1.GroupVersion.WithKind("User").GroupKind(), user.Name, allErrs)
+}
+
+// ValidateUser checks a User for problems the user pool would reject.
+// allowedAttributes restricts spec.attributes keys unless it is empty.
+func ValidateUser(user *kcpv1alpha1.User, allowedAttributes []string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	namePath := field.NewPath("metadata", "name")
+	switch {
+	case user.Name == "":
+		allErrs = append(allErrs, field.Required(namePath, "username cannot be empty"))
+	case len(user.Name) > maxUsernameLength:
+		allErrs = append(allErrs, field.TooLong(namePath, user.Name, maxUsernameLength))
+	case strings.IndexFunc(user.Name, unicode.IsSpace) >= 0:
+		allErrs = append(allErrs, field.Invalid(namePath, user.Name, "username cannot contain whitespace"))
+	}
+
+	specPath := field.NewPath("spec")
+	if user.Spec.Email != "" {
+		if addr, err := mail.ParseAddress(user.Spec.Email); err != nil || addr.Address != user.Spec.Email {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("email"), user.Spec.Email,
+				"must be a valid email address"))
+		}
+	}
+	if user.Spec.SecondaryEmail != "" {
+		if addr, err := mail.ParseAddress(user.Spec.SecondaryEmail); err != nil ||
+			addr.Address != user.Spec.SecondaryEmail {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("secondaryEmail"), user.Spec.SecondaryEmail,
+				"must be a valid email address"))
+		}
+	}
+	if user.Spec.GenerateUsername && user.Spec.Email == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("email"),
+			"email is required when generateUsername is set"))
+	}
+	if user.Spec.EmailVerified != nil && *user.Spec.EmailVerified && user.Spec.Email == "" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("emailVerified"), true,
+			"cannot mark an empty email as verified"))
+	}
+
+	allErrs = append(allErrs, validatePhoneNumber(user, specPath)...)
+
+	for i, group := range user.Spec.Groups {
+		if strings.TrimSpace(group) == "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("groups").Index(i), group,
+				"group name cannot be empty"))
+		}
+	}
+
+	attrPath := specPath.Child("attributes")
+	for name := range user.Spec.Attributes {
+		if name == "" {
+			allErrs = append(allErrs, field.Invalid(attrPath, name, "attribute name cannot be empty"))
+			continue
+		}
+		if len(allowedAttributes) > 0 && !slices.Contains(allowedAttributes, name) {
+			allErrs = append(allErrs, field.NotSupported(attrPath.Key(name), name, allowedAttributes))
+		}
+	}
+
+	fromPath := specPath.Child("attributesFrom")
+	for i, source := range user.Spec.AttributesFrom {
+		if len(allowedAttributes) > 0 && !slices.Contains(allowedAttributes, source.Name) {
+			allErrs = append(allErrs, field.NotSupported(fromPath.Index(i).Child("name"), source.Name,
+				allowedAttributes))
+		}
+		if (source.SecretKeyRef == nil) == (source.ConfigMapKeyRef == nil) {
+			allErrs = append(allErrs, field.Invalid(fromPath.Index(i), source.Name,
+				"exactly one of secretKeyRef or configMapKeyRef must be set"))
+		}
+	}
+
+	removePath := specPath.Child("removeAttributes")
+	for i, name := range user.Spec.RemoveAttributes {
+		switch {
+		case name == "":
+			allErrs = append(allErrs, field.Invalid(removePath.Index(i), name, "attribute name cannot be empty"))
+		case len(allowedAttributes) > 0 && !slices.Contains(allowedAttributes, name):
+			allErrs = append(allErrs, field.NotSupported(removePath.Index(i), name, allowedAttributes))
+		case setsAttribute(user, name):
+			allErrs = append(allErrs, field.Invalid(removePath.Index(i), name,
+				"attribute is also set in spec.attributes or spec.attributesFrom"))
+		}
+	}
+
+	return allErrs
+}
+
+// setsAttribute reports whether user sets the attribute name
+func setsAttribute(user *kcpv1alpha1.User, name string) bool {
+	if _, ok := user.Spec.Attributes[name]; ok {
+		return true
+	}
+	return slices.ContainsFunc(user.Spec.AttributesFrom, func(source kcpv1alpha1.AttributeSource) bool {
+		return source.Name == name
+	})
+}
+
+// validatePhoneNumber checks spec.attributes.phone_number and that SMS MFA is
+// only requested for users with a phone number. A phone number read from
+// spec.attributesFrom is only known at reconcile time and is accepted.
+func validatePhoneNumber(user *kcpv1alpha1.User, specPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	phonePath := specPath.Child("attributes").Key(phoneNumberAttribute)
+	phone := user.Spec.Attributes[phoneNumberAttribute]
+	if phone != "" && !phoneNumberPattern.MatchString(phone) {
+		allErrs = append(allErrs, field.Invalid(phonePath, phone,
+			"must be in E.164 format, e.g. +14155550100"))
+	}
+	hasPhone := phone != "" || slices.ContainsFunc(user.Spec.AttributesFrom,
+		func(source kcpv1alpha1.AttributeSource) bool { return source.Name == phoneNumberAttribute })
+
+	if user.Spec.MFAMethod == "SMS_MFA" && !hasPhone {
+		allErrs = append(allErrs, field.Required(phonePath, "a phone number is required for SMS_MFA"))
+	}
+
+	return allErrs
+}
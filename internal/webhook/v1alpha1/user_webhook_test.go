@@ -0,0 +1,221 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	kcpv1alpha1 "piotrjanik.dev/users/api/v1alpha1"
+)
+
+func TestUserCustomValidator(t *testing.T) {
+	newUser := func(name string, spec kcpv1alpha1.UserSpec) *kcpv1alpha1.User {
+		return &kcpv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       spec,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		user    *kcpv1alpha1.User
+		wantErr string
+	}{
+		{
+			name: "valid user",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				Email:      "jane@example.com",
+				Attributes: map[string]string{"org": "acme"},
+			}),
+		},
+		{
+			name:    "username too long",
+			user:    newUser(strings.Repeat("a", maxUsernameLength+1), kcpv1alpha1.UserSpec{}),
+			wantErr: "metadata.name",
+		},
+		{
+			name:    "invalid email",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{Email: "Jane <jane@example.com>"}),
+			wantErr: "spec.email",
+		},
+		{
+			name:    "invalid secondary email",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{SecondaryEmail: "not an email"}),
+			wantErr: "spec.secondaryEmail",
+		},
+		{
+			name:    "verified without email",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{EmailVerified: ptr.To(true)}),
+			wantErr: "spec.emailVerified",
+		},
+		{
+			name: "attribute not allowed",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				Attributes: map[string]string{"custom:secret": "x"},
+			}),
+			wantErr: "spec.attributes[custom:secret]",
+		},
+		{
+			name: "attribute source not allowed",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				AttributesFrom: []kcpv1alpha1.AttributeSource{{Name: "custom:secret", SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "secret"}}},
+			}),
+			wantErr: "spec.attributesFrom[0].name",
+		},
+		{
+			name: "attribute source without reference",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				AttributesFrom: []kcpv1alpha1.AttributeSource{{Name: "org"}},
+			}),
+			wantErr: "spec.attributesFrom[0]",
+		},
+		{
+			name:    "generated username without email",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{GenerateUsername: true}),
+			wantErr: "spec.email",
+		},
+		{
+			name: "sms mfa with phone number",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				MFAMethod:  "SMS_MFA",
+				Attributes: map[string]string{"phone_number": "+14155550100"},
+			}),
+		},
+		{
+			name: "sms mfa with phone number from a secret",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				MFAMethod: "SMS_MFA",
+				AttributesFrom: []kcpv1alpha1.AttributeSource{{Name: "phone_number",
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "phone"}}},
+			}),
+		},
+		{
+			name:    "sms mfa without phone number",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{MFAMethod: "SMS_MFA"}),
+			wantErr: "spec.attributes[phone_number]: Required value",
+		},
+		{
+			name: "sms mfa with empty phone number",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				MFAMethod:  "SMS_MFA",
+				Attributes: map[string]string{"phone_number": ""},
+			}),
+			wantErr: "spec.attributes[phone_number]: Required value",
+		},
+		{
+			name: "software token mfa without phone number",
+			user: newUser("jane", kcpv1alpha1.UserSpec{MFAMethod: "SOFTWARE_TOKEN_MFA"}),
+		},
+		{
+			name: "removed attribute",
+			user: newUser("jane", kcpv1alpha1.UserSpec{RemoveAttributes: []string{"org"}}),
+		},
+		{
+			name:    "removed attribute not allowed",
+			user:    newUser("jane", kcpv1alpha1.UserSpec{RemoveAttributes: []string{"custom:secret"}}),
+			wantErr: "spec.removeAttributes[0]: Unsupported value",
+		},
+		{
+			name: "removed attribute also set",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				Attributes:       map[string]string{"org": "acme"},
+				RemoveAttributes: []string{"org"},
+			}),
+			wantErr: "spec.removeAttributes[0]: Invalid value",
+		},
+		{
+			name: "malformed phone number",
+			user: newUser("jane", kcpv1alpha1.UserSpec{
+				Attributes: map[string]string{"phone_number": "(415) 555-0100"},
+			}),
+			wantErr: "spec.attributes[phone_number]: Invalid value",
+		},
+	}
+
+	v := &UserCustomValidator{AllowedAttributes: []string{"org", "phone_number"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCreate(context.Background(), tt.user)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestUserCustomValidatorUpdate(t *testing.T) {
+	v := &UserCustomValidator{}
+	oldUser := &kcpv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane", Namespace: "default"},
+		Spec:       kcpv1alpha1.UserSpec{Email: "jane@example.com"},
+	}
+	newUser := oldUser.DeepCopy()
+	newUser.Spec.GenerateUsername = true
+
+	_, err := v.ValidateUpdate(context.Background(), oldUser, newUser)
+	if err == nil || !strings.Contains(err.Error(), "spec.generateUsername") {
+		t.Errorf("expected error mentioning spec.generateUsername, got %v", err)
+	}
+}
+
+func TestUserCustomDefaulter(t *testing.T) {
+	d := &UserCustomDefaulter{EmailVerifiedDefault: ptr.To(false)}
+
+	t.Run("unset fields are defaulted", func(t *testing.T) {
+		user := &kcpv1alpha1.User{Spec: kcpv1alpha1.UserSpec{Email: "jane@example.com"}}
+		if err := d.Default(context.Background(), user); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if user.Spec.Enabled == nil || !*user.Spec.Enabled {
+			t.Errorf("expected enabled to default to true, got %v", user.Spec.Enabled)
+		}
+		if user.Spec.EmailVerified == nil || *user.Spec.EmailVerified {
+			t.Errorf("expected emailVerified to default to false, got %v", user.Spec.EmailVerified)
+		}
+	})
+
+	t.Run("explicit values are kept", func(t *testing.T) {
+		user := &kcpv1alpha1.User{Spec: kcpv1alpha1.UserSpec{
+			Email:         "jane@example.com",
+			Enabled:       ptr.To(false),
+			EmailVerified: ptr.To(true),
+		}}
+		if err := d.Default(context.Background(), user); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if *user.Spec.Enabled {
+			t.Errorf("expected enabled to stay false")
+		}
+		if !*user.Spec.EmailVerified {
+			t.Errorf("expected emailVerified to stay true")
+		}
+	})
+}
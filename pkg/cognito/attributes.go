@@ -0,0 +1,154 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// Names of the standard Cognito attributes the client reads or writes
+const (
+	AttrEmail               = "email"
+	AttrEmailVerified       = "email_verified"
+	AttrPhoneNumber         = "phone_number"
+	AttrPhoneNumberVerified = "phone_number_verified"
+	AttrPreferredUsername   = "preferred_username"
+	AttrLocale              = "locale"
+	AttrAddress             = "address"
+	AttrPicture             = "picture"
+	AttrProfile             = "profile"
+	AttrNickname            = "nickname"
+	AttrWebsite             = "website"
+	AttrZoneInfo            = "zoneinfo"
+	AttrBirthdate           = "birthdate"
+	AttrGender              = "gender"
+	AttrSub                 = "sub"
+	AttrIdentities          = "identities"
+)
+
+// CustomAttributePrefix starts the names of custom attributes, e.g.
+// "custom:tenant"
+const CustomAttributePrefix = "custom:"
+
+// maxCustomAttributeNameLength is the longest custom attribute name Cognito
+// allows, without CustomAttributePrefix
+const maxCustomAttributeNameLength = 20
+
+// SecondaryEmailAttribute is the custom attribute holding
+// User.SecondaryEmail. It must be defined in the user pool to store
+// secondary emails.
+const SecondaryEmailAttribute = CustomAttributePrefix + "secondaryEmail"
+
+// DisableReasonAttribute is the custom attribute holding User.DisableReason.
+// It must be defined in the user pool to record disable reasons.
+const DisableReasonAttribute = CustomAttributePrefix + "disableReason"
+
+// stringAttributes lists the standard attributes held as plain strings in
+// userpool.User, in the order they are written
+var stringAttributes = []string{
+	AttrPreferredUsername, AttrLocale, AttrPicture, AttrProfile,
+	AttrNickname, AttrWebsite, AttrZoneInfo, AttrBirthdate, AttrGender,
+}
+
+// stringAttributeField returns the field of user holding the standard
+// attribute name, or nil if it isn't one of stringAttributes. Writes and reads
+// both go through it, so every standard attribute round-trips the same way.
+func stringAttributeField(user *userpool.User, name string) *string {
+	switch name {
+	case AttrPreferredUsername:
+		return &user.PreferredUsername
+	case AttrLocale:
+		return &user.Locale
+	case AttrPicture:
+		return &user.Picture
+	case AttrProfile:
+		return &user.Profile
+	case AttrNickname:
+		return &user.Nickname
+	case AttrWebsite:
+		return &user.Website
+	case AttrZoneInfo:
+		return &user.ZoneInfo
+	case AttrBirthdate:
+		return &user.Birthdate
+	case AttrGender:
+		return &user.Gender
+	}
+	return nil
+}
+
+// ValidateCustomAttributePrefix checks that prefix can start the names of
+// custom attributes, e.g. "custom:kcp_". An empty prefix is valid and
+// disables prefixing.
+func ValidateCustomAttributePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	namespace, ok := strings.CutPrefix(prefix, CustomAttributePrefix)
+	if !ok || namespace == "" {
+		return fmt.Errorf("invalid custom attribute prefix %q, expected %s followed by a namespace", prefix,
+			CustomAttributePrefix)
+	}
+	if len(namespace) >= maxCustomAttributeNameLength {
+		return fmt.Errorf("invalid custom attribute prefix %q, the namespace must be shorter than %d characters",
+			prefix, maxCustomAttributeNameLength)
+	}
+	if strings.ContainsFunc(namespace, func(r rune) bool { return r == ':' || unicode.IsSpace(r) }) {
+		return fmt.Errorf("invalid custom attribute prefix %q, the namespace must not contain colons or spaces",
+			prefix)
+	}
+	return nil
+}
+
+// poolAttributeName returns the user pool name of a logical attribute: its
+// mapping if it has one, otherwise the name with custom attributes moved
+// under the custom attribute prefix
+func (c *AWSClient) poolAttributeName(logical string) string {
+	if mapped, ok := c.attributeMapping[logical]; ok {
+		return mapped
+	}
+	if c.customAttributePrefix != "" {
+		if short, ok := strings.CutPrefix(logical, CustomAttributePrefix); ok {
+			return c.customAttributePrefix + short
+		}
+	}
+	return logical
+}
+
+// logicalAttributeName reverses poolAttributeName. It reports false for
+// standard attributes and for custom attributes outside the custom attribute
+// prefix, which belong to other writers of the pool.
+func (c *AWSClient) logicalAttributeName(name string) (string, bool) {
+	if logical, ok := c.reverseAttributeMapping[name]; ok {
+		return logical, true
+	}
+	if !strings.HasPrefix(name, CustomAttributePrefix) {
+		return "", false
+	}
+	if c.customAttributePrefix == "" {
+		return name, true
+	}
+	short, ok := strings.CutPrefix(name, c.customAttributePrefix)
+	if !ok || short == "" {
+		return "", false
+	}
+	return CustomAttributePrefix + short, true
+}
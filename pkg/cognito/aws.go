@@ -18,12 +18,23 @@ package cognito
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/smithy-go"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"piotrjanik.dev/users/pkg/userpool"
 )
 
@@ -31,10 +42,102 @@ import (
 type AWSClient struct {
 	cognito    *cognitoidentityprovider.Client
 	userPoolID string
+
+	// emailVerifiedDefault is written as email_verified for users that don't
+	// set User.EmailVerified
+	emailVerifiedDefault bool
+
+	// emailVerifiedUnmanaged leaves email_verified out of all writes
+	emailVerifiedUnmanaged bool
+
+	// clusterMetadataKey is the client metadata key the logical cluster of a
+	// call is passed under, see WithClusterMetadataKey
+	clusterMetadataKey string
+
+	// attributeMapping translates logical attribute names to pool attribute
+	// names, reverseAttributeMapping translates them back
+	attributeMapping        map[string]string
+	reverseAttributeMapping map[string]string
+
+	// customAttributePrefix replaces CustomAttributePrefix in the pool names
+	// of unmapped custom attributes, see WithCustomAttributePrefix
+	customAttributePrefix string
+
+	// schema holds the attribute names defined in the user pool once loaded
+	// by ValidateAttributeMapping, schemaPolicy decides what happens to
+	// attributes missing from it
+	schema       map[string]bool
+	schemaPolicy SchemaPolicy
+
+	// preferredUsernameAlias is set by ValidateAttributeMapping when the
+	// pool signs in with preferred_username, which must then be unique
+	preferredUsernameAlias bool
+
+	// usernames is set by ValidateAttributeMapping from the pool's username
+	// and alias attributes, CreateUser checks new usernames against it
+	usernames userpool.UsernameConfiguration
+
+	// describeDenied records that DescribeUserPool was denied, see
+	// describeUserPool
+	describeDenied atomic.Bool
+
+	// clientOptions customize the Cognito SDK client when it is created
+	clientOptions []func(*cognitoidentityprovider.Options)
+
+	// groups caches the group names of the user pool for UpdateGroups
+	groups groupCache
+
+	// appClientID and appClientSecret identify the app client used by the
+	// non-admin APIs, e.g. ResendConfirmationCode
+	appClientID     string
+	appClientSecret string
+
+	// redactPII replaces usernames and emails in errors and log lines with a
+	// stable hash
+	redactPII bool
+
+	// mfa caches the MFA configuration of the user pool for SetPreferredMFA
+	mfa mfaCache
+
+	// legacyMFA selects how SMS MFA is set, legacyMFADetected records that
+	// LegacyMFAAuto found the pool to need AdminSetUserSettings
+	legacyMFA         LegacyMFA
+	legacyMFADetected atomic.Bool
+
+	// forceAliasCreation moves an email alias held by another user to the
+	// created user instead of failing with ErrAliasExists
+	forceAliasCreation bool
+
+	// clock is used for cache expiry, see WithClock
+	clock userpool.Clock
+
+	// defaultAttributes are written for every user that doesn't set them,
+	// keyed by logical attribute name
+	defaultAttributes map[string]string
+
+	// hooks are told about every Cognito call, see WithOperationHook
+	hooks []OperationHook
+
+	// forgetDevicesOnDisable forgets the remembered devices of users when
+	// they are disabled
+	forgetDevicesOnDisable bool
+
+	// limits bound the calls to the user pool, see WithRequestLimits
+	limits *RequestLimits
 }
 
+var (
+	// ErrSchemaUnavailable is returned when the user pool schema cannot be read,
+	// e.g. because the caller lacks the cognito-idp:DescribeUserPool permission
+	ErrSchemaUnavailable = errors.New("user pool schema unavailable")
+
+	// ErrUnknownAttribute is returned by CreateUser and UpdateUser under
+	// SchemaPolicyFailClosed when an attribute is not in the user pool schema
+	ErrUnknownAttribute = errors.New("attribute not in user pool schema")
+)
+
 // NewAWSClient creates a new AWS Cognito client with Pod Identity authentication
-func NewAWSClient(ctx context.Context, userPoolID string) (*AWSClient, error) {
+func NewAWSClient(ctx context.Context, userPoolID string, opts ...Option) (*AWSClient, error) {
 	if userPoolID == "" {
 		return nil, fmt.Errorf("userPoolID cannot be empty")
 	}
@@ -44,48 +147,120 @@ func NewAWSClient(ctx context.Context, userPoolID string) (*AWSClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	if cfg.Region == "" {
+		cfg.Region = regionFromUserPoolID(userPoolID)
+	}
 
-	return &AWSClient{
-		cognito:    cognitoidentityprovider.NewFromConfig(cfg),
-		userPoolID: userPoolID,
-	}, nil
+	c := &AWSClient{
+		userPoolID:           userPoolID,
+		emailVerifiedDefault: true,
+		schemaPolicy:         SchemaPolicyFailClosed,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := ValidateCustomAttributePrefix(c.customAttributePrefix); err != nil {
+		return nil, err
+	}
+	c.clientOptions = append(c.clientOptions, withThrottlingErrors())
+	if len(c.hooks) > 0 {
+		c.clientOptions = append(c.clientOptions, withOperationHooks(c.hooks))
+	}
+	if c.limits != nil {
+		c.clientOptions = append(c.clientOptions, withRequestLimits(*c.limits))
+	}
+	c.cognito = cognitoidentityprovider.NewFromConfig(cfg, c.clientOptions...)
+
+	return c, nil
 }
 
-// CreateUser creates a new user in the Cognito user pool
+// regionFromUserPoolID returns the region a user pool ID starts with, e.g.
+// "us-gov-west-1" for "us-gov-west-1_AbC123", or "" if it has none
+func regionFromUserPoolID(userPoolID string) string {
+	region, _, ok := strings.Cut(userPoolID, "_")
+	if !ok {
+		return ""
+	}
+	return region
+}
+
+// CreateUser creates a new user in the Cognito user pool. Cognito creates
+// users enabled, so a user with Enabled false is disabled right afterwards.
 func (c *AWSClient) CreateUser(ctx context.Context, user *userpool.User) error {
 	if user == nil {
 		return fmt.Errorf("user cannot be nil")
 	}
 	if user.Username == "" {
-		return fmt.Errorf("username cannot be empty")
+		if user.Email == "" {
+			return fmt.Errorf("username or email must be set")
+		}
+		user.Username = uuid.NewString()
+	}
+	if err := c.usernames.ValidateUsername(user.Username, user.Email); err != nil {
+		return fmt.Errorf("failed to create user %s: %w", c.pii(user.Username), err)
+	}
+	if err := validateStandardAttributes(user); err != nil {
+		return fmt.Errorf("failed to create user %s: %w", c.pii(user.Username), err)
 	}
 
-	attributes := []types.AttributeType{
-		{
-			Name:  aws.String("email"),
-			Value: aws.String(user.Email),
-		},
-		{
-			Name:  aws.String("email_verified"),
-			Value: aws.String("true"),
-		},
+	attributes := c.emailAttributes(user)
+	custom, err := c.checkSchema(ctx, user.Username, c.desiredAttributes(user))
+	if err != nil {
+		return err
+	}
+	attributes = append(attributes, custom...)
+	if err := c.checkPreferredUsername(ctx, user); err != nil {
+		return err
 	}
 
 	input := &cognitoidentityprovider.AdminCreateUserInput{
 		UserPoolId:     aws.String(c.userPoolID),
 		Username:       aws.String(user.Username),
 		UserAttributes: attributes,
-		MessageAction:  types.MessageActionTypeSuppress, // Don't send welcome email
+		ClientMetadata: c.clientMetadata(ctx, nil),
 	}
-
-	// User will be enabled by default, we'll handle disabling separately if needed
-	if !user.Enabled {
-		input.TemporaryPassword = aws.String("TempPass123!")
+	switch {
+	case !user.SendInvitation || !user.Enabled:
+		// A disabled user couldn't use the invitation anyway
+		input.MessageAction = types.MessageActionTypeSuppress
+	case user.Email != "":
+		// Cognito delivers by SMS unless asked otherwise
+		input.DesiredDeliveryMediums = []types.DeliveryMediumType{types.DeliveryMediumTypeEmail}
+	}
+	if c.forceAliasCreation {
+		input.ForceAliasCreation = true
 	}
 
-	_, err := c.cognito.AdminCreateUser(ctx, input)
+	output, err := c.cognito.AdminCreateUser(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to create user %s: %w", user.Username, err)
+		if isAliasExists(err) {
+			return fmt.Errorf("failed to create user %s with email %s: %w", c.pii(user.Username), c.pii(user.Email),
+				userpool.ErrAliasExists)
+		}
+		var usernameExists *types.UsernameExistsException
+		if errors.As(err, &usernameExists) {
+			return fmt.Errorf("failed to create user %s: %w", c.pii(user.Username), userpool.ErrUserExists)
+		}
+		if invalid := c.invalidParameter(err, attributes); invalid != nil {
+			return fmt.Errorf("failed to create user %s: %w", c.pii(user.Username), invalid)
+		}
+		return fmt.Errorf("failed to create user %s: %w", c.pii(user.Username), err)
+	}
+	// Pools that sign in with email assign their own username
+	if output.User != nil && output.User.Username != nil {
+		user.Username = *output.User.Username
+	}
+
+	// Cognito creates users enabled. A new user has no devices to forget, so
+	// it is disabled directly instead of through setEnabled.
+	if !user.Enabled {
+		_, err := c.cognito.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(user.Username),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to disable created user %s: %w", c.pii(user.Username), err)
+		}
 	}
 
 	return nil
@@ -104,23 +279,75 @@ func (c *AWSClient) GetUser(ctx context.Context, username string) (*userpool.Use
 
 	output, err := c.cognito.AdminGetUser(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user %s: %w", username, err)
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to get user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user %s: %w", c.pii(username), err)
 	}
 
-	user := &userpool.User{
-		Username: username,
-		Enabled:  output.Enabled,
+	user := c.UserFromAdminGetUser(output)
+	// The user keeps the name it was requested by
+	user.Username = username
+	return user, nil
+}
+
+// GetUserStatus returns the account status and enabled state of a user
+// without converting its attributes. Cognito still returns the full user, so
+// this saves allocations, not Cognito calls.
+func (c *AWSClient) GetUserStatus(ctx context.Context, username string) (userpool.Status, bool, error) {
+	if username == "" {
+		return "", false, fmt.Errorf("username cannot be empty")
 	}
 
-	// Extract email from user attributes
-	for _, attr := range output.UserAttributes {
-		if attr.Name != nil && *attr.Name == "email" && attr.Value != nil {
-			user.Email = *attr.Value
-			break
+	output, err := c.cognito.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return "", false, fmt.Errorf("failed to get user %s: %w", c.pii(username), userpool.ErrUserNotFound)
 		}
+		return "", false, fmt.Errorf("failed to get user %s: %w", c.pii(username), err)
 	}
 
-	return user, nil
+	return mapUserStatus(output.UserStatus), output.Enabled, nil
+}
+
+// EnsureUser creates the user if it doesn't exist and updates it otherwise,
+// writing only the attributes that differ. A user created concurrently
+// between the lookup and the create is updated instead. It returns the user
+// as read back from the pool.
+func (c *AWSClient) EnsureUser(ctx context.Context, user *userpool.User) (*userpool.User, error) {
+	if user == nil {
+		return nil, fmt.Errorf("user cannot be nil")
+	}
+	if user.Username == "" {
+		// CreateUser generates the username
+		if err := c.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		return c.GetUser(ctx, user.Username)
+	}
+
+	current, err := c.GetUser(ctx, user.Username)
+	switch {
+	case errors.Is(err, userpool.ErrUserNotFound):
+		err = c.CreateUser(ctx, user)
+		if errors.Is(err, userpool.ErrUserExists) {
+			err = c.UpdateUser(ctx, user)
+		}
+	case err != nil:
+		return nil, err
+	default:
+		err = c.UpdateUserDelta(ctx, current, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.GetUser(ctx, user.Username)
 }
 
 // UpdateUser updates an existing user in the Cognito user pool
@@ -131,47 +358,230 @@ func (c *AWSClient) UpdateUser(ctx context.Context, user *userpool.User) error {
 	if user.Username == "" {
 		return fmt.Errorf("username cannot be empty")
 	}
+	if err := validateStandardAttributes(user); err != nil {
+		return fmt.Errorf("failed to update user %s: %w", c.pii(user.Username), err)
+	}
 
-	// Update user attributes
-	attributes := []types.AttributeType{
-		{
-			Name:  aws.String("email"),
-			Value: aws.String(user.Email),
-		},
+	// Update user attributes. email_verified is always written, unless it is
+	// unmanaged, because changing the email resets it in Cognito.
+	attributes := c.emailAttributes(user)
+	custom, err := c.checkSchema(ctx, user.Username, c.desiredAttributes(user))
+	if err != nil {
+		return err
+	}
+	attributes = append(attributes, custom...)
+	if err := c.checkPreferredUsername(ctx, user); err != nil {
+		return err
+	}
+
+	if err := c.updateAttributes(ctx, user, attributes); err != nil {
+		return err
+	}
+	if _, err := c.deleteAttributes(ctx, user, nil); err != nil {
+		return err
+	}
+	if _, err := c.setEnabled(ctx, user, nil); err != nil {
+		return err
+	}
+
+	if user.Enabled || user.DisableReason == "" {
+		return c.clearDisableReason(ctx, user.Username)
+	}
+	return nil
+}
+
+// UpdateUserDelta updates an existing user like UpdateUser, but only writes
+// the attributes that differ from current, the user as last read with
+// GetUser. email_verified is written when it is set explicitly and differs,
+// or when the email changes, since Cognito resets it then. A nil current
+// falls back to UpdateUser.
+func (c *AWSClient) UpdateUserDelta(ctx context.Context, current, user *userpool.User) error {
+	_, err := c.UpdateUserIfChanged(ctx, current, user)
+	return err
+}
+
+// UpdateUserIfChanged updates the user like UpdateUserDelta and reports
+// whether any Cognito write was made. A nil current always writes.
+func (c *AWSClient) UpdateUserIfChanged(ctx context.Context, current, user *userpool.User) (bool, error) {
+	if current == nil {
+		if err := c.UpdateUser(ctx, user); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if user == nil {
+		return false, fmt.Errorf("user cannot be nil")
+	}
+	if user.Username == "" {
+		return false, fmt.Errorf("username cannot be empty")
+	}
+	if err := validateStandardAttributes(user); err != nil {
+		return false, fmt.Errorf("failed to update user %s: %w", c.pii(user.Username), err)
+	}
+
+	var attributes []types.AttributeType
+	if user.Email != current.Email {
+		attributes = append(attributes, types.AttributeType{Name: aws.String(AttrEmail), Value: aws.String(user.Email)})
+	}
+	if !c.emailVerifiedUnmanaged && (user.Email != current.Email || (user.EmailVerified != nil &&
+		(current.EmailVerified == nil || *user.EmailVerified != *current.EmailVerified))) {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(AttrEmailVerified),
+			Value: aws.String(c.emailVerified(user)),
+		})
+	}
+
+	existing := make(map[string]string)
+	for _, attr := range c.customAttributes(current) {
+		existing[aws.ToString(attr.Name)] = aws.ToString(attr.Value)
+	}
+	var changed []types.AttributeType
+	for _, attr := range c.desiredAttributes(user) {
+		if value, ok := existing[aws.ToString(attr.Name)]; !ok || value != aws.ToString(attr.Value) {
+			changed = append(changed, attr)
+		}
+	}
+	custom, err := c.checkSchema(ctx, user.Username, changed)
+	if err != nil {
+		return false, err
+	}
+	attributes = append(attributes, custom...)
+	if user.PreferredUsername != current.PreferredUsername {
+		if err := c.checkPreferredUsername(ctx, user); err != nil {
+			return false, err
+		}
 	}
 
-	updateInput := &cognitoidentityprovider.AdminUpdateUserAttributesInput{
+	written := len(attributes) > 0
+	if written {
+		if err := c.updateAttributes(ctx, user, attributes); err != nil {
+			return false, err
+		}
+	}
+	deleted, err := c.deleteAttributes(ctx, user, current)
+	if err != nil {
+		return written, err
+	}
+	toggled, err := c.setEnabled(ctx, user, current)
+	if err != nil {
+		return written || deleted, err
+	}
+	written = written || deleted || toggled
+
+	if current.DisableReason != "" && (user.Enabled || user.DisableReason == "") {
+		return true, c.clearDisableReason(ctx, user.Username)
+	}
+	return written, nil
+}
+
+// updateAttributes writes attributes of user with AdminUpdateUserAttributes
+func (c *AWSClient) updateAttributes(ctx context.Context, user *userpool.User,
+	attributes []types.AttributeType) error {
+	_, err := c.cognito.AdminUpdateUserAttributes(ctx, &cognitoidentityprovider.AdminUpdateUserAttributesInput{
 		UserPoolId:     aws.String(c.userPoolID),
 		Username:       aws.String(user.Username),
 		UserAttributes: attributes,
+		ClientMetadata: c.clientMetadata(ctx, user.ClientMetadata),
+	})
+	if err != nil {
+		if isAliasExists(err) {
+			return fmt.Errorf("failed to update user %s to email %s: %w", c.pii(user.Username), c.pii(user.Email),
+				userpool.ErrAliasExists)
+		}
+		if invalid := c.invalidParameter(err, attributes); invalid != nil {
+			return fmt.Errorf("failed to update user attributes for %s: %w", c.pii(user.Username), invalid)
+		}
+		return fmt.Errorf("failed to update user attributes for %s: %w", c.pii(user.Username), err)
 	}
+	return nil
+}
 
-	_, err := c.cognito.AdminUpdateUserAttributes(ctx, updateInput)
+// deleteAttributes removes the attributes in user.DeleteAttributes with
+// AdminDeleteUserAttributes. Attributes the user still sets, default
+// attributes, and attributes current doesn't have are skipped; a nil current
+// deletes all of them. It reports whether any attribute was deleted.
+func (c *AWSClient) deleteAttributes(ctx context.Context, user, current *userpool.User) (bool, error) {
+	var names []string
+	for _, logical := range user.DeleteAttributes {
+		if _, ok := user.Attributes[logical]; ok {
+			continue
+		}
+		if _, ok := c.defaultAttributes[logical]; ok {
+			continue
+		}
+		if current != nil {
+			if _, ok := current.Attributes[logical]; !ok {
+				continue
+			}
+		}
+		names = append(names, c.poolAttributeName(logical))
+	}
+	if len(names) == 0 {
+		return false, nil
+	}
+
+	_, err := c.cognito.AdminDeleteUserAttributes(ctx, &cognitoidentityprovider.AdminDeleteUserAttributesInput{
+		UserPoolId:         aws.String(c.userPoolID),
+		Username:           aws.String(user.Username),
+		UserAttributeNames: names,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update user attributes for %s: %w", user.Username, err)
+		return false, fmt.Errorf("failed to delete attributes %s of user %s: %w", strings.Join(names, ", "),
+			c.pii(user.Username), err)
 	}
+	return true, nil
+}
 
-	// Update user status if needed
+// setEnabled enables or disables the user according to user.Enabled. Nothing
+// is called if current, the user as last read, is already in that state, so
+// steady-state updates don't touch the user's enabled state or devices. It
+// reports whether the state was changed.
+func (c *AWSClient) setEnabled(ctx context.Context, user, current *userpool.User) (bool, error) {
+	if current != nil && current.Enabled == user.Enabled {
+		return false, nil
+	}
 	if user.Enabled {
 		enableInput := &cognitoidentityprovider.AdminEnableUserInput{
 			UserPoolId: aws.String(c.userPoolID),
 			Username:   aws.String(user.Username),
 		}
-		_, err = c.cognito.AdminEnableUser(ctx, enableInput)
-		if err != nil {
-			return fmt.Errorf("failed to enable user %s: %w", user.Username, err)
+		if _, err := c.cognito.AdminEnableUser(ctx, enableInput); err != nil {
+			return false, fmt.Errorf("failed to enable user %s: %w", c.pii(user.Username), err)
 		}
-	} else {
-		disableInput := &cognitoidentityprovider.AdminDisableUserInput{
-			UserPoolId: aws.String(c.userPoolID),
-			Username:   aws.String(user.Username),
-		}
-		_, err = c.cognito.AdminDisableUser(ctx, disableInput)
-		if err != nil {
-			return fmt.Errorf("failed to disable user %s: %w", user.Username, err)
+		return true, nil
+	}
+
+	disableInput := &cognitoidentityprovider.AdminDisableUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(user.Username),
+	}
+	if _, err := c.cognito.AdminDisableUser(ctx, disableInput); err != nil {
+		return false, fmt.Errorf("failed to disable user %s: %w", c.pii(user.Username), err)
+	}
+	if c.forgetDevicesOnDisable {
+		if err := c.forgetDevices(ctx, user.Username); err != nil {
+			return true, err
 		}
 	}
+	return true, nil
+}
+
+// clearDisableReason removes the disable reason attribute of a user. Pools
+// without the attribute are skipped.
+func (c *AWSClient) clearDisableReason(ctx context.Context, username string) error {
+	if c.schema != nil && !c.schema[DisableReasonAttribute] {
+		return nil
+	}
 
+	_, err := c.cognito.AdminDeleteUserAttributes(ctx, &cognitoidentityprovider.AdminDeleteUserAttributesInput{
+		UserPoolId:         aws.String(c.userPoolID),
+		Username:           aws.String(username),
+		UserAttributeNames: []string{DisableReasonAttribute},
+	})
+	var invalid *types.InvalidParameterException
+	if err != nil && !errors.As(err, &invalid) {
+		return fmt.Errorf("failed to clear disable reason of user %s: %w", c.pii(username), err)
+	}
 	return nil
 }
 
@@ -188,7 +598,217 @@ func (c *AWSClient) DeleteUser(ctx context.Context, username string) error {
 
 	_, err := c.cognito.AdminDeleteUser(ctx, input)
 	if err != nil {
-		return fmt.Errorf("failed to delete user %s: %w", username, err)
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to delete user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to delete user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// VerifyAttribute sets email_verified or phone_number_verified to true
+// without touching any other attribute
+func (c *AWSClient) VerifyAttribute(ctx context.Context, username, attribute string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if !userpool.IsVerifiable(attribute) {
+		return fmt.Errorf("failed to verify %s of user %s: %w", attribute, c.pii(username),
+			userpool.ErrAttributeNotVerifiable)
+	}
+	if attribute == AttrEmail && c.emailVerifiedUnmanaged {
+		return fmt.Errorf("failed to verify email of user %s: email_verified is not managed by this client",
+			c.pii(username))
+	}
+
+	_, err := c.cognito.AdminUpdateUserAttributes(ctx, &cognitoidentityprovider.AdminUpdateUserAttributesInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		UserAttributes: []types.AttributeType{
+			{Name: aws.String(attribute + "_verified"), Value: aws.String("true")},
+		},
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to verify %s of user %s: %w", attribute, c.pii(username),
+				userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to verify %s of user %s: %w", attribute, c.pii(username), err)
+	}
+
+	return nil
+}
+
+// ConfirmUser confirms an unconfirmed user without requiring the user to follow
+// a verification link. The status is read first because Cognito only reports
+// an already confirmed user in the message of a generic NotAuthorizedException.
+func (c *AWSClient) ConfirmUser(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	if err := c.checkUnconfirmed(ctx, username); err != nil {
+		return err
+	}
+
+	input := &cognitoidentityprovider.AdminConfirmSignUpInput{
+		UserPoolId:     aws.String(c.userPoolID),
+		Username:       aws.String(username),
+		ClientMetadata: c.clientMetadata(ctx, nil),
+	}
+
+	_, err := c.cognito.AdminConfirmSignUp(ctx, input)
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to confirm user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		var notAuthorized *types.NotAuthorizedException
+		if errors.As(err, &notAuthorized) {
+			// The user may have been confirmed since the status was read
+			if checkErr := c.checkUnconfirmed(ctx, username); checkErr != nil {
+				return checkErr
+			}
+		}
+		return fmt.Errorf("failed to confirm user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// checkUnconfirmed returns ErrUserAlreadyConfirmed unless the user is still
+// waiting for confirmation
+func (c *AWSClient) checkUnconfirmed(ctx context.Context, username string) error {
+	status, _, err := c.GetUserStatus(ctx, username)
+	if err != nil {
+		return fmt.Errorf("failed to confirm user %s: %w", c.pii(username), err)
+	}
+	if status != userpool.StatusUnconfirmed {
+		return fmt.Errorf("failed to confirm user %s with status %s: %w", c.pii(username), status,
+			userpool.ErrUserAlreadyConfirmed)
+	}
+	return nil
+}
+
+// SignOutUser revokes all refresh tokens of a user with AdminUserGlobalSignOut
+func (c *AWSClient) SignOutUser(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	_, err := c.cognito.AdminUserGlobalSignOut(ctx, &cognitoidentityprovider.AdminUserGlobalSignOutInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to sign out user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to sign out user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// ResetPassword invalidates the password of a user with
+// AdminResetUserPassword, which moves the user to RESET_REQUIRED and sends a
+// confirmation code to the verified email or phone number
+func (c *AWSClient) ResetPassword(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	_, err := c.cognito.AdminResetUserPassword(ctx, &cognitoidentityprovider.AdminResetUserPasswordInput{
+		UserPoolId:     aws.String(c.userPoolID),
+		Username:       aws.String(username),
+		ClientMetadata: c.clientMetadata(ctx, nil),
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to reset password of user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to reset password of user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// ResendInvitation sends the invitation message to a user in
+// FORCE_CHANGE_PASSWORD with AdminCreateUser's RESEND action. Cognito
+// generates a new temporary password with a fresh validity.
+func (c *AWSClient) ResendInvitation(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	input := &cognitoidentityprovider.AdminCreateUserInput{
+		UserPoolId:    aws.String(c.userPoolID),
+		Username:      aws.String(username),
+		MessageAction: types.MessageActionTypeResend,
+	}
+
+	_, err := c.cognito.AdminCreateUser(ctx, input)
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to resend invitation of user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		var unsupported *types.UnsupportedUserStateException
+		if errors.As(err, &unsupported) {
+			return fmt.Errorf("failed to resend invitation of user %s: %w", c.pii(username),
+				userpool.ErrUserAlreadyConfirmed)
+		}
+		return fmt.Errorf("failed to resend invitation of user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// LinkProvider links a federated identity to the native Cognito user so the
+// user can sign in through the external identity provider. Identities that are
+// already linked are left unchanged: Cognito rejects linking them again, so
+// the user is read first. The reconciler filters against the pool user it
+// already read, but callers outside it, such as scripts linking identities
+// in bulk, rely on the check.
+func (c *AWSClient) LinkProvider(ctx context.Context, username, providerName, providerAttributeValue string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if providerName == "" || providerAttributeValue == "" {
+		return fmt.Errorf("provider name and attribute value cannot be empty")
+	}
+
+	user, err := c.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	for _, identity := range user.Identities {
+		if identity.ProviderName == providerName && identity.UserID == providerAttributeValue {
+			return nil
+		}
+	}
+
+	input := &cognitoidentityprovider.AdminLinkProviderForUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		DestinationUser: &types.ProviderUserIdentifierType{
+			ProviderName:           aws.String("Cognito"),
+			ProviderAttributeValue: aws.String(username),
+		},
+		SourceUser: &types.ProviderUserIdentifierType{
+			ProviderName:           aws.String(providerName),
+			ProviderAttributeName:  aws.String("Cognito_Subject"),
+			ProviderAttributeValue: aws.String(providerAttributeValue),
+		},
+	}
+
+	_, err = c.cognito.AdminLinkProviderForUser(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to link provider %s to user %s: %w", providerName, c.pii(username), err)
 	}
 
 	return nil
@@ -196,46 +816,520 @@ func (c *AWSClient) DeleteUser(ctx context.Context, username string) error {
 
 // ListUsers lists all users in the Cognito user pool
 func (c *AWSClient) ListUsers(ctx context.Context) ([]*userpool.User, error) {
+	return c.listUsers(ctx, "", nil, nil)
+}
+
+// CountUsers returns the EstimatedNumberOfUsers reported by DescribeUserPool.
+// Cognito updates the estimate periodically, so it can lag behind recent
+// creates and deletes by several minutes. If the pool can't be described,
+// e.g. because cognito-idp:DescribeUserPool is not allowed, the users are
+// counted by listing them all.
+func (c *AWSClient) CountUsers(ctx context.Context) (int, error) {
+	pool, err := c.describeUserPool(ctx)
+	if err == nil {
+		return int(pool.EstimatedNumberOfUsers), nil
+	}
+
+	users, listErr := c.listUsers(ctx, "", nil, nil)
+	if listErr != nil {
+		return 0, fmt.Errorf("failed to count users: %w", errors.Join(err, listErr))
+	}
+	return len(users), nil
+}
+
+// BulkUpdateAttribute applies fn to every user of the pool and writes the
+// attribute changes it returns, see userpool.BulkUpdateAttribute. Updates run
+// userpool.DefaultBulkConcurrency at a time within the client's request
+// limits.
+func (c *AWSClient) BulkUpdateAttribute(ctx context.Context,
+	fn func(*userpool.User) (map[string]string, bool)) (*userpool.BulkResult, error) {
+	return userpool.BulkUpdateAttribute(ctx, c, fn, userpool.DefaultBulkConcurrency)
+}
+
+// ListUsersModifiedSince lists users whose last modification is after since.
+// Cognito cannot filter on the modification date, so this still scans the
+// whole pool; it only reduces the number of users callers have to process.
+func (c *AWSClient) ListUsersModifiedSince(ctx context.Context, since time.Time) ([]*userpool.User, error) {
+	return c.listUsers(ctx, "", nil, func(cognitoUser types.UserType) bool {
+		return cognitoUser.UserLastModifiedDate != nil && cognitoUser.UserLastModifiedDate.After(since)
+	})
+}
+
+// ListUsersProjected lists all users in the Cognito user pool, populating
+// only the fields of projection. Cognito is asked for the selected
+// attributes only, and the response isn't converted beyond them.
+func (c *AWSClient) ListUsersProjected(ctx context.Context, projection userpool.Projection) ([]*userpool.User,
+	error) {
+	return c.listUsers(ctx, "", &projection, nil)
+}
+
+// attributesToGet returns the pool attribute names projection needs. Cognito
+// returns all attributes for an empty list, so sub stands in when none is
+// needed.
+func (c *AWSClient) attributesToGet(projection *userpool.Projection) []string {
+	var names []string
+	if projection.Email {
+		names = append(names, AttrEmail, AttrEmailVerified)
+	}
+	for _, logical := range projection.Attributes {
+		names = append(names, c.poolAttributeName(logical))
+	}
+	if len(names) == 0 {
+		return []string{AttrSub}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// projectUser converts a listed user into user, populating only the fields of
+// projection
+func (c *AWSClient) projectUser(user *userpool.User, cognitoUser types.UserType, projection *userpool.Projection) {
+	user.Username = *cognitoUser.Username
+	if projection.Enabled {
+		user.Enabled = cognitoUser.Enabled
+	}
+	if projection.Status {
+		user.Status = mapUserStatus(cognitoUser.UserStatus)
+		user.RawStatus = string(cognitoUser.UserStatus)
+	}
+	if projection.Timestamps {
+		user.LastModified = aws.ToTime(cognitoUser.UserLastModifiedDate)
+		user.CreatedAt = aws.ToTime(cognitoUser.UserCreateDate)
+	}
+	if projection.Email || len(projection.Attributes) > 0 {
+		c.fromCognitoAttributes(user, cognitoUser.Attributes)
+	}
+}
+
+// ListUsersFiltered lists the users matching a Cognito filter expression,
+// e.g. `email ^= "jane"`. Values must be quoted with
+// userpool.QuoteFilterValue.
+func (c *AWSClient) ListUsersFiltered(ctx context.Context, filter string) ([]*userpool.User, error) {
+	return c.listUsers(ctx, filter, nil, nil)
+}
+
+// GetUserByEmail returns the user with the given email. It fails with
+// userpool.ErrUserNotFound if there is none and with an error if several
+// users share the email.
+func (c *AWSClient) GetUserByEmail(ctx context.Context, email string) (*userpool.User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("email cannot be empty")
+	}
+	users, err := c.listUsers(ctx, AttrEmail+" = "+userpool.QuoteFilterValue(email), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user with email %s: %w", c.pii(email), err)
+	}
+	switch len(users) {
+	case 0:
+		return nil, fmt.Errorf("user with email %s: %w", c.pii(email), userpool.ErrUserNotFound)
+	case 1:
+		return users[0], nil
+	default:
+		return nil, fmt.Errorf("failed to get user with email %s: %d users share it", c.pii(email), len(users))
+	}
+}
+
+// listUsers pages through the users matching filter, all users if it is
+// empty, and converts every user accepted by keep. A nil keep accepts all
+// users.
+func (c *AWSClient) listUsers(ctx context.Context, filter string, projection *userpool.Projection,
+	keep func(types.UserType) bool) ([]*userpool.User, error) {
 	var users []*userpool.User
 	var nextToken *string
 
 	for {
-		input := &cognitoidentityprovider.ListUsersInput{
-			UserPoolId:      aws.String(c.userPoolID),
-			PaginationToken: nextToken,
+		page, token, err := c.listUsersPage(ctx, filter, nextToken, projection, keep)
+		if err != nil {
+			return nil, err
 		}
+		users = append(users, page...)
 
-		output, err := c.cognito.ListUsers(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list users: %w", err)
+		nextToken = token
+		if nextToken == nil {
+			break
 		}
+	}
 
-		for _, cognitoUser := range output.Users {
-			if cognitoUser.Username == nil {
-				continue
-			}
+	return users, nil
+}
 
-			user := &userpool.User{
-				Username: *cognitoUser.Username,
-				Enabled:  cognitoUser.Enabled,
-			}
+// ListUsersPage lists one page of up to 60 users. The cursor is Cognito's
+// pagination token and expires after a while, so it is meant for resuming a
+// listing soon, not for storing.
+func (c *AWSClient) ListUsersPage(ctx context.Context, cursor string) ([]*userpool.User, string, error) {
+	var token *string
+	if cursor != "" {
+		token = aws.String(cursor)
+	}
+	users, next, err := c.listUsersPage(ctx, "", token, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return users, aws.ToString(next), nil
+}
 
-			// Extract email from user attributes
-			for _, attr := range cognitoUser.Attributes {
-				if attr.Name != nil && *attr.Name == "email" && attr.Value != nil {
-					user.Email = *attr.Value
-					break
-				}
-			}
+// listUsersPage fetches the page at token of the users matching filter and
+// returns the users accepted by keep, all users if keep is nil, and the token
+// of the next page. A non-nil projection limits the fields read.
+func (c *AWSClient) listUsersPage(ctx context.Context, filter string, token *string,
+	projection *userpool.Projection, keep func(types.UserType) bool) ([]*userpool.User, *string, error) {
+	input := &cognitoidentityprovider.ListUsersInput{
+		UserPoolId:      aws.String(c.userPoolID),
+		PaginationToken: token,
+	}
+	if filter != "" {
+		input.Filter = aws.String(filter)
+	}
+	if projection != nil {
+		input.AttributesToGet = c.attributesToGet(projection)
+	}
+	output, err := c.cognito.ListUsers(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return c.convertUsers(output.Users, projection, keep), output.PaginationToken, nil
+}
 
-			users = append(users, user)
+// convertUsers converts the listed users accepted by keep, populating only the
+// fields of projection if it is non-nil. Full pool listings run through here
+// for every user, so the users of a page share one allocation.
+func (c *AWSClient) convertUsers(cognitoUsers []types.UserType, projection *userpool.Projection,
+	keep func(types.UserType) bool) []*userpool.User {
+	converted := make([]userpool.User, 0, len(cognitoUsers))
+	for _, cognitoUser := range cognitoUsers {
+		if cognitoUser.Username == nil {
+			continue
+		}
+		if keep != nil && !keep(cognitoUser) {
+			continue
 		}
 
-		nextToken = output.PaginationToken
-		if nextToken == nil {
-			break
+		converted = append(converted, userpool.User{})
+		user := &converted[len(converted)-1]
+		if projection != nil {
+			c.projectUser(user, cognitoUser, projection)
+			continue
 		}
+
+		c.convertUser(user, recordOf(cognitoUser))
 	}
 
-	return users, nil
+	users := make([]*userpool.User, len(converted))
+	for i := range converted {
+		users[i] = &converted[i]
+	}
+	return users
+}
+
+// ValidateAttributeMapping checks that every mapped attribute exists in the
+// user pool schema. It returns an error wrapping ErrSchemaUnavailable when the
+// schema cannot be read. The schema is kept so CreateUser and UpdateUser can
+// apply the SchemaPolicy; until it is loaded all attributes are passed through.
+func (c *AWSClient) ValidateAttributeMapping(ctx context.Context) error {
+	pool, err := c.describeUserPool(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSchemaUnavailable, err)
+	}
+
+	schema := make(map[string]bool, len(pool.SchemaAttributes))
+	for _, attr := range pool.SchemaAttributes {
+		if attr.Name != nil {
+			schema[*attr.Name] = true
+		}
+	}
+
+	c.schema = schema
+	c.preferredUsernameAlias = slices.Contains(pool.AliasAttributes,
+		types.AliasAttributeTypePreferredUsername)
+	c.usernames = userpool.UsernameConfiguration{
+		EmailUsername: slices.Contains(pool.UsernameAttributes, types.UsernameAttributeTypeEmail),
+		EmailAlias:    slices.Contains(pool.AliasAttributes, types.AliasAttributeTypeEmail),
+	}
+	c.mfa.set(pool.MfaConfiguration, userpool.Now(c.clock))
+
+	var missing []string
+	for logical, name := range c.attributeMapping {
+		if !schema[name] {
+			missing = append(missing, fmt.Sprintf("%s=%s", logical, name))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("attribute mapping references attributes not in user pool %s: %s",
+			c.userPoolID, strings.Join(missing, ", "))
+	}
+
+	for _, attr := range c.toCognitoAttributes(c.defaultAttributes) {
+		if !schema[aws.ToString(attr.Name)] {
+			missing = append(missing, aws.ToString(attr.Name))
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return fmt.Errorf("default attributes are not in user pool %s: %s",
+			c.userPoolID, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// parseIdentities parses the JSON identities attribute Cognito sets on users
+// with linked identity providers. Malformed values yield no identities.
+func parseIdentities(value string) []userpool.Identity {
+	var raw []struct {
+		UserID       string `json:"userId"`
+		ProviderName string `json:"providerName"`
+	}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil
+	}
+
+	identities := make([]userpool.Identity, 0, len(raw))
+	for _, r := range raw {
+		identities = append(identities, userpool.Identity{ProviderName: r.ProviderName, UserID: r.UserID})
+	}
+	return identities
+}
+
+// mapUserStatus maps a Cognito user status to a userpool.Status. Values not
+// known to this client map to userpool.StatusUnknown.
+func mapUserStatus(status types.UserStatusType) userpool.Status {
+	switch status {
+	case types.UserStatusTypeUnconfirmed:
+		return userpool.StatusUnconfirmed
+	case types.UserStatusTypeConfirmed:
+		return userpool.StatusConfirmed
+	case types.UserStatusTypeForceChangePassword:
+		return userpool.StatusForceChangePassword
+	case types.UserStatusTypeResetRequired:
+		return userpool.StatusResetRequired
+	case types.UserStatusTypeExternalProvider:
+		return userpool.StatusExternalProvider
+	case types.UserStatusTypeArchived:
+		return userpool.StatusArchived
+	case types.UserStatusTypeCompromised:
+		return userpool.StatusCompromised
+	default:
+		return userpool.StatusUnknown
+	}
+}
+
+// isAliasExists reports whether err is Cognito's AliasExistsException. The SDK
+// doesn't model it for AdminCreateUser, which returns it as a generic API error.
+func isAliasExists(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AliasExistsException"
+}
+
+// pii returns value, or its hash when PII redaction is enabled
+func (c *AWSClient) pii(value string) string {
+	if c.redactPII {
+		return userpool.Redact(value)
+	}
+	return value
+}
+
+// emailAttributes returns the email and email_verified attributes written for
+// user, without email_verified if it is unmanaged
+func (c *AWSClient) emailAttributes(user *userpool.User) []types.AttributeType {
+	attributes := []types.AttributeType{{Name: aws.String(AttrEmail), Value: aws.String(user.Email)}}
+	if !c.emailVerifiedUnmanaged {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(AttrEmailVerified),
+			Value: aws.String(c.emailVerified(user)),
+		})
+	}
+	return attributes
+}
+
+// emailVerified returns the email_verified value to write for user. An
+// explicitly set User.EmailVerified takes precedence over the client default.
+func (c *AWSClient) emailVerified(user *userpool.User) string {
+	verified := c.emailVerifiedDefault
+	if user.EmailVerified != nil {
+		verified = *user.EmailVerified
+	}
+	return strconv.FormatBool(verified)
+}
+
+// validateStandardAttributes rejects the standard attribute values of user
+// Cognito would store but applications could not use
+func validateStandardAttributes(user *userpool.User) error {
+	if err := userpool.ValidateLocale(user.Locale); err != nil {
+		return err
+	}
+	if err := userpool.ValidateAddress(user.Address); err != nil {
+		return err
+	}
+	if err := userpool.ValidateURL(AttrPicture, user.Picture); err != nil {
+		return err
+	}
+	if err := userpool.ValidateURL(AttrProfile, user.Profile); err != nil {
+		return err
+	}
+	if err := userpool.ValidateURL(AttrWebsite, user.Website); err != nil {
+		return err
+	}
+	if err := userpool.ValidateZoneInfo(user.ZoneInfo); err != nil {
+		return err
+	}
+	return userpool.ValidateBirthdate(user.Birthdate)
+}
+
+// customAttributes returns the Cognito attributes written for the user next to
+// email and email_verified
+func (c *AWSClient) customAttributes(user *userpool.User) []types.AttributeType {
+	attributes := c.toCognitoAttributes(user.Attributes)
+	if user.SecondaryEmail != "" {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(SecondaryEmailAttribute),
+			Value: aws.String(user.SecondaryEmail),
+		})
+	}
+	for _, name := range stringAttributes {
+		if value := *stringAttributeField(user, name); value != "" {
+			attributes = append(attributes, types.AttributeType{
+				Name:  aws.String(name),
+				Value: aws.String(value),
+			})
+		}
+	}
+	if user.Address != nil {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(AttrAddress),
+			Value: aws.String(userpool.EncodeAddress(user.Address)),
+		})
+	}
+	if !user.Enabled && user.DisableReason != "" {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(DisableReasonAttribute),
+			Value: aws.String(user.DisableReason),
+		})
+	}
+	return attributes
+}
+
+// desiredAttributes returns the custom attributes written for the user,
+// including the default attributes it doesn't set itself
+func (c *AWSClient) desiredAttributes(user *userpool.User) []types.AttributeType {
+	if len(c.defaultAttributes) == 0 {
+		return c.customAttributes(user)
+	}
+	withDefaults := *user
+	withDefaults.Attributes = maps.Clone(c.defaultAttributes)
+	maps.Copy(withDefaults.Attributes, user.Attributes)
+	return c.customAttributes(&withDefaults)
+}
+
+// toCognitoAttributes converts logical attributes to Cognito attributes
+func (c *AWSClient) toCognitoAttributes(attrs map[string]string) []types.AttributeType {
+	attributes := make([]types.AttributeType, 0, len(attrs))
+	for logical, value := range attrs {
+		attributes = append(attributes, types.AttributeType{
+			Name:  aws.String(c.poolAttributeName(logical)),
+			Value: aws.String(value),
+		})
+	}
+	return attributes
+}
+
+// checkPreferredUsername fails with userpool.ErrAliasExists if the pool signs
+// in with preferred_username and another user already has the one of user.
+// Cognito would reject the write as well, but without saying which alias
+// collided.
+func (c *AWSClient) checkPreferredUsername(ctx context.Context, user *userpool.User) error {
+	if !c.preferredUsernameAlias || user.PreferredUsername == "" {
+		return nil
+	}
+	holders, err := c.listUsers(ctx, AttrPreferredUsername+" = "+userpool.QuoteFilterValue(user.PreferredUsername),
+		&userpool.Projection{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check preferred username of user %s: %w", c.pii(user.Username), err)
+	}
+	for _, holder := range holders {
+		if holder.Username != user.Username {
+			return fmt.Errorf("failed to write user %s with preferred username %s: %w", c.pii(user.Username),
+				c.pii(user.PreferredUsername), userpool.ErrAliasExists)
+		}
+	}
+	return nil
+}
+
+// checkSchema applies the schema policy to attributes missing from the user
+// pool schema
+func (c *AWSClient) checkSchema(ctx context.Context, username string,
+	attrs []types.AttributeType) ([]types.AttributeType, error) {
+	if c.schema == nil {
+		return attrs, nil
+	}
+
+	known := make([]types.AttributeType, 0, len(attrs))
+	var unknown []string
+	for _, attr := range attrs {
+		if c.schema[aws.ToString(attr.Name)] {
+			known = append(known, attr)
+		} else {
+			unknown = append(unknown, aws.ToString(attr.Name))
+		}
+	}
+	if len(unknown) == 0 {
+		return attrs, nil
+	}
+
+	slices.Sort(unknown)
+	if c.schemaPolicy == SchemaPolicyDropUnknown {
+		logr.FromContextOrDiscard(ctx).Info("Dropping attributes not in user pool schema",
+			"username", c.pii(username), "attributes", unknown)
+		return known, nil
+	}
+	return nil, fmt.Errorf("failed to write user %s: %w: %s", c.pii(username), ErrUnknownAttribute,
+		strings.Join(unknown, ", "))
+}
+
+// fromCognitoAttributes populates user fields from Cognito attributes. Mapped
+// and custom attributes are stored in user.Attributes under their logical name.
+func (c *AWSClient) fromCognitoAttributes(user *userpool.User, attrs []types.AttributeType) {
+	for i, attr := range attrs {
+		if attr.Name == nil || attr.Value == nil {
+			continue
+		}
+		name := *attr.Name
+		switch name {
+		case AttrEmail:
+			user.Email = *attr.Value
+			continue
+		case AttrEmailVerified:
+			user.EmailVerified = aws.Bool(*attr.Value == "true")
+			continue
+		case AttrPhoneNumberVerified:
+			user.PhoneNumberVerified = aws.Bool(*attr.Value == "true")
+			continue
+		case AttrIdentities:
+			user.Identities = parseIdentities(*attr.Value)
+			continue
+		case DisableReasonAttribute:
+			user.DisableReason = *attr.Value
+			continue
+		case SecondaryEmailAttribute:
+			user.SecondaryEmail = *attr.Value
+			continue
+		case AttrAddress:
+			user.Address = userpool.DecodeAddress(*attr.Value)
+			continue
+		}
+		if field := stringAttributeField(user, name); field != nil {
+			*field = *attr.Value
+			continue
+		}
+
+		logical, ok := c.logicalAttributeName(name)
+		if !ok {
+			continue
+		}
+		if user.Attributes == nil {
+			// Sized for the remaining attributes so the map never grows
+			user.Attributes = make(map[string]string, len(attrs)-i)
+		}
+		user.Attributes[logical] = *attr.Value
+	}
 }
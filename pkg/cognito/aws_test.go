@@ -0,0 +1,1788 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// testResponder returns the status code and JSON body the test server answers
+// an operation with
+type testResponder func(op string) (int, string)
+
+// testRequest is a Cognito call received by the test server
+type testRequest struct {
+	op   string
+	body map[string]any
+}
+
+// newTestAWSClient returns an AWSClient talking to a server that answers
+// Cognito calls with respond, or with an empty result if respond is nil, and
+// the operations the server received. respond may be called concurrently.
+func newTestAWSClient(t *testing.T, respond testResponder, opts ...Option) (*AWSClient, func() []string) {
+	t.Helper()
+	c, requests := newRecordingAWSClient(t, respond, opts...)
+	return c, func() []string {
+		return requestOps(requests())
+	}
+}
+
+// newRecordingAWSClient is like newTestAWSClient but returns the full requests
+// the server received so tests can check the payloads sent to Cognito
+func newRecordingAWSClient(t *testing.T, respond testResponder, opts ...Option) (*AWSClient, func() []testRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []testRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Amz-Target")
+		op := target[strings.LastIndex(target, ".")+1:]
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, testRequest{op: op, body: body})
+		mu.Unlock()
+		status, response := http.StatusOK, "{}"
+		if respond != nil {
+			status, response = respond(op)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	c, err := NewAWSClient(context.Background(), "us-east-1_test",
+		append([]Option{WithBaseEndpoint(server.URL)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c, func() []testRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+// requestOps returns the operations of requests in order
+func requestOps(requests []testRequest) []string {
+	var ops []string
+	for _, req := range requests {
+		ops = append(ops, req.op)
+	}
+	return ops
+}
+
+// requestsFor returns the bodies of the requests for op
+func requestsFor(requests []testRequest, op string) []map[string]any {
+	var bodies []map[string]any
+	for _, req := range requests {
+		if req.op == op {
+			bodies = append(bodies, req.body)
+		}
+	}
+	return bodies
+}
+
+// requestAttributes returns the name/value pairs of the attribute list under
+// key in a request body
+func requestAttributes(body map[string]any, key string) map[string]string {
+	attributes := map[string]string{}
+	list, _ := body[key].([]any)
+	for _, item := range list {
+		attr, _ := item.(map[string]any)
+		name, _ := attr["Name"].(string)
+		value, _ := attr["Value"].(string)
+		attributes[name] = value
+	}
+	return attributes
+}
+
+func TestAWSClient_CreateUser_EnabledState(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    []string
+	}{
+		{name: "enabled", enabled: true, want: []string{"AdminCreateUser"}},
+		{name: "disabled", enabled: false, want: []string{"AdminCreateUser", "AdminDisableUser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			err := c.CreateUser(context.Background(), &userpool.User{
+				Username: "jane", Email: "jane@example.com", Enabled: tt.enabled,
+			})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("disable fails", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			if op == "AdminDisableUser" {
+				return http.StatusBadRequest, `{"__type":"NotAuthorizedException","message":"Not allowed."}`
+			}
+			return http.StatusOK, "{}"
+		})
+		err := c.CreateUser(context.Background(), &userpool.User{Username: "jane", Email: "jane@example.com"})
+		if err == nil {
+			t.Fatalf("expected an error when the created user cannot be disabled")
+		}
+	})
+}
+
+func TestAWSClient_UpdateUserDelta_EnabledState(t *testing.T) {
+	tests := []struct {
+		name             string
+		current, desired bool
+		want             []string
+	}{
+		{name: "enabling an enabled user", current: true, desired: true},
+		{name: "disabling a disabled user", current: false, desired: false},
+		{name: "disabling an enabled user", current: true, desired: false, want: []string{"AdminDisableUser"}},
+		{name: "enabling a disabled user", current: false, desired: true, want: []string{"AdminEnableUser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			current := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: tt.current}
+			desired := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: tt.desired}
+
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected operations %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_UpdateUserDelta_DeleteAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete []string
+		want   []string
+	}{
+		{name: "set attribute", delete: []string{"tenant"}, want: []string{"AdminDeleteUserAttributes"}},
+		{name: "absent attribute", delete: []string{"department"}},
+		{name: "default attribute", delete: []string{"region"}},
+		{name: "attribute still desired", delete: []string{"team"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil,
+				WithAttributeMapping(map[string]string{"tenant": "custom:tenant"}),
+				WithDefaultAttributes(map[string]string{"region": "eu"}))
+			current := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true,
+				Attributes: map[string]string{"tenant": "acme", "region": "eu", "team": "a"}}
+			desired := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true,
+				Attributes: map[string]string{"team": "a"}, DeleteAttributes: tt.delete}
+
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected operations %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_PreferredUsernameAlias(t *testing.T) {
+	c, operations := newTestAWSClient(t, func(op string) (int, string) {
+		if op == "ListUsers" {
+			return http.StatusOK, `{"Users":[{"Username":"john"}]}`
+		}
+		return http.StatusOK, "{}"
+	})
+	c.preferredUsernameAlias = true
+
+	err := c.CreateUser(context.Background(), &userpool.User{
+		Username: "jane", Email: "jane@example.com", Enabled: true, PreferredUsername: "johnny",
+	})
+	if !errors.Is(err, userpool.ErrAliasExists) {
+		t.Fatalf("expected ErrAliasExists, got %v", err)
+	}
+	if got := operations(); !slices.Equal(got, []string{"ListUsers"}) {
+		t.Errorf("expected the create not to be attempted, got %v", got)
+	}
+}
+
+func TestAWSClient_UsernameConfiguration(t *testing.T) {
+	c, operations := newTestAWSClient(t, func(op string) (int, string) {
+		if op == "DescribeUserPool" {
+			return http.StatusOK, `{"UserPool":{"AliasAttributes":["email"]}}`
+		}
+		return http.StatusOK, "{}"
+	})
+	if err := c.ValidateAttributeMapping(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := c.CreateUser(context.Background(), &userpool.User{
+		Username: "jane@example.com", Email: "jane@example.com", Enabled: true,
+	})
+	if !errors.Is(err, userpool.ErrInvalidUsername) {
+		t.Fatalf("expected ErrInvalidUsername, got %v", err)
+	}
+	if got := operations(); !slices.Equal(got, []string{"DescribeUserPool"}) {
+		t.Errorf("expected the create not to be attempted, got %v", got)
+	}
+}
+
+func TestAWSClient_ListGroupsForUser(t *testing.T) {
+	t.Run("pages", func(t *testing.T) {
+		pages := []string{
+			`{"Groups":[{"GroupName":"viewers"},{"GroupName":"admins"}],"NextToken":"next"}`,
+			`{"Groups":[{"GroupName":"billing"}]}`,
+		}
+		var page atomic.Int32
+		c, operations := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusOK, pages[page.Add(1)-1]
+		})
+
+		groups, err := c.ListGroupsForUser(context.Background(), "jane")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if want := []string{"admins", "billing", "viewers"}; !slices.Equal(groups, want) {
+			t.Errorf("expected groups %v, got %v", want, groups)
+		}
+		if got := operations(); len(got) != 2 {
+			t.Errorf("expected two AdminListGroupsForUser calls, got %v", got)
+		}
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusBadRequest, `{"__type":"UserNotFoundException","message":"User does not exist."}`
+		})
+
+		if _, err := c.ListGroupsForUser(context.Background(), "jane"); !errors.Is(err, userpool.ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound, got %v", err)
+		}
+	})
+}
+
+func TestAWSClient_Throttling(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantThrottled bool
+	}{
+		{name: "too many requests", body: `{"__type":"TooManyRequestsException","message":"Too many requests"}`,
+			wantThrottled: true},
+		{name: "other error", body: `{"__type":"NotAuthorizedException","message":"Not allowed."}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, func(op string) (int, string) {
+				return http.StatusBadRequest, tt.body
+			}, WithMaxAttempts(2), WithMaxBackoff(time.Millisecond))
+
+			_, err := c.GetUser(context.Background(), "jane")
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if got := errors.Is(err, userpool.ErrThrottled); got != tt.wantThrottled {
+				t.Errorf("expected throttled %v, got %v for %v", tt.wantThrottled, got, err)
+			}
+			if tt.wantThrottled && len(operations()) != 2 {
+				t.Errorf("expected the call to be retried before it is reported, got %v", operations())
+			}
+		})
+	}
+}
+
+func TestAWSClient_EmailVerifiedDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		verified *bool
+		want     string
+	}{
+		{name: "default true", want: "true"},
+		{name: "default false", opts: []Option{WithEmailVerifiedDefault(false)}, want: "false"},
+		{name: "user overrides default true", verified: aws.Bool(false), want: "false"},
+		{name: "user overrides default false", opts: []Option{WithEmailVerifiedDefault(false)},
+			verified: aws.Bool(true), want: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newRecordingAWSClient(t, nil, tt.opts...)
+			err := c.CreateUser(context.Background(), &userpool.User{Username: "jane", Email: "jane@example.com",
+				EmailVerified: tt.verified, Enabled: true})
+			if err != nil {
+				t.Fatalf("CreateUser failed: %v", err)
+			}
+			creates := requestsFor(requests(), "AdminCreateUser")
+			if len(creates) != 1 {
+				t.Fatalf("expected one AdminCreateUser request, got %d", len(creates))
+			}
+			if got := requestAttributes(creates[0], "UserAttributes")[AttrEmailVerified]; got != tt.want {
+				t.Errorf("expected email_verified %s, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_EmailVerifiedUnmanaged(t *testing.T) {
+	c, operations := newTestAWSClient(t, nil, WithEmailVerifiedUnmanaged(true))
+	user := &userpool.User{Username: "jane", Email: "jane@example.com", EmailVerified: aws.Bool(true), Enabled: true}
+
+	for _, attr := range c.emailAttributes(user) {
+		if aws.ToString(attr.Name) == AttrEmailVerified {
+			t.Errorf("expected email_verified to be left out of writes")
+		}
+	}
+
+	current := &userpool.User{Username: "jane", Email: "jane@example.com", EmailVerified: aws.Bool(false), Enabled: true}
+	if err := c.UpdateUserDelta(context.Background(), current, user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := c.VerifyAttribute(context.Background(), "jane", "email"); err == nil {
+		t.Errorf("expected verifying the email to fail")
+	}
+	if got := operations(); len(got) != 0 {
+		t.Errorf("expected no Cognito calls, got %v", got)
+	}
+}
+
+func TestAWSClient_Locale(t *testing.T) {
+	t.Run("invalid locale is rejected before the call", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, nil)
+		err := c.CreateUser(context.Background(), &userpool.User{
+			Username: "jane", Email: "jane@example.com", Enabled: true, Locale: "en_US",
+		})
+		if !errors.Is(err, userpool.ErrInvalidParameter) {
+			t.Fatalf("expected ErrInvalidParameter, got %v", err)
+		}
+		if got := operations(); len(got) != 0 {
+			t.Errorf("expected no Cognito calls, got %v", got)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		current string
+		desired string
+		want    []string
+	}{
+		{name: "unchanged", current: "de-AT", desired: "de-AT"},
+		{name: "unset keeps the stored value", current: "de-AT"},
+		{name: "changed", current: "de-AT", desired: "fr", want: []string{"AdminUpdateUserAttributes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			current := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true, Locale: tt.current}
+			desired := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true, Locale: tt.desired}
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_Address(t *testing.T) {
+	t.Run("empty address is rejected before the call", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, nil)
+		err := c.CreateUser(context.Background(), &userpool.User{
+			Username: "jane", Email: "jane@example.com", Enabled: true, Address: &userpool.Address{},
+		})
+		if !errors.Is(err, userpool.ErrInvalidParameter) {
+			t.Fatalf("expected ErrInvalidParameter, got %v", err)
+		}
+		if got := operations(); len(got) != 0 {
+			t.Errorf("expected no Cognito calls, got %v", got)
+		}
+	})
+
+	stored := &userpool.Address{Locality: "Wien", Country: "AT"}
+	tests := []struct {
+		name    string
+		desired *userpool.Address
+		want    []string
+	}{
+		{name: "unchanged", desired: &userpool.Address{Locality: "Wien", Country: "AT"}},
+		{name: "unset keeps the stored value"},
+		{name: "changed", desired: &userpool.Address{Locality: "Graz", Country: "AT"},
+			want: []string{"AdminUpdateUserAttributes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			current := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true, Address: stored}
+			desired := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true, Address: tt.desired}
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_PictureAndProfile(t *testing.T) {
+	t.Run("invalid URL is rejected before the call", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, nil)
+		err := c.UpdateUser(context.Background(), &userpool.User{
+			Username: "jane", Email: "jane@example.com", Enabled: true, Picture: "avatars/jane.png",
+		})
+		var invalid *userpool.InvalidParameterError
+		if !errors.As(err, &invalid) || invalid.Field != AttrPicture {
+			t.Fatalf("expected an invalid picture, got %v", err)
+		}
+		if got := operations(); len(got) != 0 {
+			t.Errorf("expected no Cognito calls, got %v", got)
+		}
+	})
+
+	current := &userpool.User{
+		Username: "jane", Email: "jane@example.com", Enabled: true,
+		Picture: "https://example.com/jane.png", Profile: "https://example.com/jane",
+	}
+	tests := []struct {
+		name             string
+		picture, profile string
+		want             []string
+	}{
+		{name: "unchanged", picture: current.Picture, profile: current.Profile},
+		{name: "unset keeps the stored values"},
+		{name: "picture changed", picture: "https://example.com/jane-2.png", want: []string{"AdminUpdateUserAttributes"}},
+		{name: "profile changed", profile: "https://example.org/~jane", want: []string{"AdminUpdateUserAttributes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			desired := &userpool.User{
+				Username: "jane", Email: "jane@example.com", Enabled: true, Picture: tt.picture, Profile: tt.profile,
+			}
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_ProfileClaims(t *testing.T) {
+	invalid := []struct {
+		field string
+		user  userpool.User
+	}{
+		{field: AttrWebsite, user: userpool.User{Website: "jane.example.com"}},
+		{field: AttrZoneInfo, user: userpool.User{ZoneInfo: "CET+1"}},
+		{field: AttrBirthdate, user: userpool.User{Birthdate: "23/04/1990"}},
+	}
+	for _, tt := range invalid {
+		t.Run("invalid "+tt.field, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			user := tt.user
+			user.Username, user.Email, user.Enabled = "jane", "jane@example.com", true
+			var invalid *userpool.InvalidParameterError
+			if err := c.CreateUser(context.Background(), &user); !errors.As(err, &invalid) || invalid.Field != tt.field {
+				t.Fatalf("expected an invalid %s, got %v", tt.field, err)
+			}
+			if got := operations(); len(got) != 0 {
+				t.Errorf("expected no Cognito calls, got %v", got)
+			}
+		})
+	}
+
+	current := &userpool.User{
+		Username: "jane", Email: "jane@example.com", Enabled: true,
+		Nickname: "Janie", Website: "https://jane.example.com", ZoneInfo: "Europe/Vienna",
+		Birthdate: "1990-04-23", Gender: "female",
+	}
+	tests := []struct {
+		name    string
+		desired userpool.User
+		want    []string
+	}{
+		{name: "unchanged", desired: *current},
+		{name: "unset keeps the stored values"},
+		{name: "nickname changed", desired: userpool.User{Nickname: "JD"}, want: []string{"AdminUpdateUserAttributes"}},
+		{name: "zoneinfo changed", desired: userpool.User{ZoneInfo: "UTC"}, want: []string{"AdminUpdateUserAttributes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			desired := tt.desired
+			desired.Username, desired.Email, desired.Enabled = "jane", "jane@example.com", true
+			if err := c.UpdateUserDelta(context.Background(), current, &desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if got := operations(); !slices.Equal(got, tt.want) {
+				t.Errorf("expected calls %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_InvalidParameter(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		wantField string
+	}{
+		{
+			name: "attribute position",
+			message: "1 validation error detected: Value at 'userAttributes.3.member.value' failed to satisfy " +
+				"constraint: Member must have length less than or equal to 2048",
+			wantField: "tenant",
+		},
+		{
+			name:      "attribute name",
+			message:   "Attributes did not conform to the schema: custom:tenant: Attribute does not exist in the schema.",
+			wantField: "tenant",
+		},
+		{name: "attribute description", message: "Invalid email address format.", wantField: AttrEmail},
+		{name: "unknown field", message: "Something is wrong."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"__type":"InvalidParameterException","message":"` + tt.message + `"}`
+			c, _ := newTestAWSClient(t, func(op string) (int, string) {
+				return http.StatusBadRequest, body
+			}, WithAttributeMapping(map[string]string{"tenant": "custom:tenant"}))
+
+			err := c.CreateUser(context.Background(), &userpool.User{
+				Username: "jane", Email: "jane", Enabled: true, Attributes: map[string]string{"tenant": "acme"},
+			})
+			var invalid *userpool.InvalidParameterError
+			if !errors.As(err, &invalid) || !errors.Is(err, userpool.ErrInvalidParameter) {
+				t.Fatalf("expected an InvalidParameterError, got %v", err)
+			}
+			if invalid.Field != tt.wantField || invalid.Message != tt.message {
+				t.Errorf("expected field %q and the AWS message, got %q and %q", tt.wantField, invalid.Field,
+					invalid.Message)
+			}
+		})
+	}
+}
+
+func TestAWSClient_ListUsersProjected(t *testing.T) {
+	const page = `{"Users":[{"Username":"jane","Enabled":true,"UserStatus":"CONFIRMED",` +
+		`"Attributes":[{"Name":"custom:tenant","Value":"acme"}]}]}`
+	c, operations := newTestAWSClient(t, func(op string) (int, string) {
+		return http.StatusOK, page
+	}, WithAttributeMapping(map[string]string{"tenant": "custom:tenant"}))
+
+	users, err := c.ListUsersProjected(context.Background(), userpool.Projection{
+		Enabled: true, Attributes: []string{"tenant"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+	user := users[0]
+	if user.Username != "jane" || !user.Enabled || user.Attributes["tenant"] != "acme" {
+		t.Errorf("expected the projected fields to be set, got %+v", user)
+	}
+	if user.Status != "" || user.RawStatus != "" {
+		t.Errorf("expected the status not to be populated, got %q", user.Status)
+	}
+	if got := operations(); !slices.Equal(got, []string{"ListUsers"}) {
+		t.Errorf("expected one ListUsers call, got %v", got)
+	}
+
+	if got := c.attributesToGet(&userpool.Projection{Email: true, Attributes: []string{"tenant"}}); !slices.Equal(got,
+		[]string{"custom:tenant", AttrEmail, AttrEmailVerified}) {
+		t.Errorf("expected the pool names of the projected attributes, got %v", got)
+	}
+	if got := c.attributesToGet(&userpool.Projection{Enabled: true}); !slices.Equal(got, []string{AttrSub}) {
+		t.Errorf("expected only sub without projected attributes, got %v", got)
+	}
+}
+
+// benchmarkPage returns a ListUsers page of n users with the attributes
+// Cognito typically returns for a managed user
+func benchmarkPage(n int) []types.UserType {
+	now := time.Now()
+	users := make([]types.UserType, n)
+	for i := range users {
+		username := fmt.Sprintf("user-%d", i)
+		users[i] = types.UserType{
+			Username:             aws.String(username),
+			Enabled:              true,
+			UserStatus:           types.UserStatusTypeConfirmed,
+			UserCreateDate:       aws.Time(now),
+			UserLastModifiedDate: aws.Time(now),
+			Attributes: []types.AttributeType{
+				{Name: aws.String(AttrSub), Value: aws.String("4f6c1a2e-" + username)},
+				{Name: aws.String(AttrEmail), Value: aws.String(username + "@example.com")},
+				{Name: aws.String(AttrEmailVerified), Value: aws.String("true")},
+				{Name: aws.String("given_name"), Value: aws.String("Jane")},
+				{Name: aws.String("custom:tenant"), Value: aws.String("acme")},
+				{Name: aws.String("custom:department"), Value: aws.String("sales")},
+				{Name: aws.String("custom:cost_center"), Value: aws.String("4711")},
+				{Name: aws.String(SecondaryEmailAttribute), Value: aws.String(username + "@example.org")},
+			},
+		}
+	}
+	return users
+}
+
+// newBenchmarkClient returns an AWSClient for benchmarks that don't call
+// Cognito
+func newBenchmarkClient() *AWSClient {
+	c := &AWSClient{}
+	WithAttributeMapping(map[string]string{"tenant": "custom:tenant", "department": "custom:department"})(c)
+	return c
+}
+
+func BenchmarkAWSClient_convertUsers(b *testing.B) {
+	c := newBenchmarkClient()
+	page := benchmarkPage(60)
+	b.ReportAllocs()
+	for b.Loop() {
+		c.convertUsers(page, nil, nil)
+	}
+}
+
+func BenchmarkAWSClient_convertUsersProjected(b *testing.B) {
+	c := newBenchmarkClient()
+	page := benchmarkPage(60)
+	projection := &userpool.Projection{Timestamps: true}
+	b.ReportAllocs()
+	for b.Loop() {
+		c.convertUsers(page, projection, nil)
+	}
+}
+
+func TestAWSClient_RequestLimits(t *testing.T) {
+	t.Run("max in flight", func(t *testing.T) {
+		var mu sync.Mutex
+		inFlight, maxInFlight := 0, 0
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return http.StatusOK, `{"Username":"jane"}`
+		}, WithRequestLimits(RequestLimits{MaxInFlight: 1}))
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.GetUser(context.Background(), "jane"); err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if maxInFlight != 1 {
+			t.Errorf("expected at most one call in flight, got %d", maxInFlight)
+		}
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		var mu sync.Mutex
+		var waits []time.Duration
+		c, operations := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusOK, `{"Username":"jane"}`
+		}, WithRequestLimits(RequestLimits{QPS: 20, Burst: 1, OnWait: func(op string, wait time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			waits = append(waits, wait)
+		}}))
+
+		start := time.Now()
+		for range 3 {
+			if _, err := c.GetUser(context.Background(), "jane"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("expected 3 calls at 20 per second to take at least 100ms, took %v", elapsed)
+		}
+		if len(waits) != 3 || len(operations()) != 3 {
+			t.Errorf("expected 3 waits and calls, got %v and %v", waits, operations())
+		}
+	})
+
+	t.Run("canceled while waiting", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, nil, WithRequestLimits(RequestLimits{QPS: 0.001, Burst: 1}))
+		if err := c.DeleteUser(context.Background(), "jane"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := c.DeleteUser(ctx, "jane"); err == nil {
+			t.Fatalf("expected an error once the context is done")
+		}
+		if got := len(operations()); got != 1 {
+			t.Errorf("expected 1 call to reach Cognito, got %d", got)
+		}
+	})
+}
+
+func TestAWSClient_LegacySMSMFA(t *testing.T) {
+	respond := func(rejectPreference bool) testResponder {
+		return func(op string) (int, string) {
+			switch {
+			case op == "DescribeUserPool":
+				return http.StatusOK, `{"UserPool":{"MfaConfiguration":"OPTIONAL"}}`
+			case op == "AdminSetUserMFAPreference" && rejectPreference:
+				return http.StatusBadRequest, `{"__type":"InvalidParameterException","message":"SMS MFA is not enabled"}`
+			}
+			return http.StatusOK, "{}"
+		}
+	}
+
+	t.Run("auto falls back once", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, respond(true))
+		for range 2 {
+			if err := c.SetPreferredMFA(context.Background(), "jane", userpool.MFASMS); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		want := []string{"DescribeUserPool", "AdminSetUserMFAPreference", "AdminSetUserSettings", "AdminSetUserSettings"}
+		if got := operations(); !slices.Equal(got, want) {
+			t.Errorf("expected operations %v, got %v", want, got)
+		}
+	})
+
+	t.Run("always", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, respond(false), WithLegacyMFA(LegacyMFAAlways))
+		if err := c.SetPreferredMFA(context.Background(), "jane", userpool.MFASMS); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := c.SetPreferredMFA(context.Background(), "jane", userpool.MFASoftwareToken); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		want := []string{"DescribeUserPool", "AdminSetUserSettings", "AdminSetUserMFAPreference"}
+		if got := operations(); !slices.Equal(got, want) {
+			t.Errorf("expected operations %v, got %v", want, got)
+		}
+	})
+
+	t.Run("never", func(t *testing.T) {
+		c, operations := newTestAWSClient(t, respond(true), WithLegacyMFA(LegacyMFANever))
+		if err := c.SetPreferredMFA(context.Background(), "jane", userpool.MFASMS); err == nil {
+			t.Fatalf("expected the rejected preference to fail")
+		}
+		if slices.Contains(operations(), "AdminSetUserSettings") {
+			t.Errorf("expected no legacy call, got %v", operations())
+		}
+	})
+}
+
+func TestAWSClient_DescribeUserPoolDenied(t *testing.T) {
+	c, operations := newTestAWSClient(t, func(op string) (int, string) {
+		switch op {
+		case "DescribeUserPool":
+			return http.StatusBadRequest, `{"__type":"AccessDeniedException","message":"not authorized"}`
+		case "ListUsers":
+			return http.StatusOK, `{"Users":[{"Username":"jane"}]}`
+		}
+		return http.StatusOK, "{}"
+	})
+
+	err := c.ValidateAttributeMapping(context.Background())
+	if !errors.Is(err, ErrSchemaUnavailable) || !errors.Is(err, ErrDescribeDenied) {
+		t.Fatalf("expected ErrSchemaUnavailable and ErrDescribeDenied, got %v", err)
+	}
+	if !c.DescribeDenied() {
+		t.Errorf("expected the denied call to be recorded")
+	}
+	if err := c.SetPreferredMFA(context.Background(), "jane", userpool.MFASoftwareToken); err != nil {
+		t.Errorf("expected the MFA preference to be set without the MFA configuration, got %v", err)
+	}
+	if count, err := c.CountUsers(context.Background()); err != nil || count != 1 {
+		t.Errorf("expected one user counted by listing, got %d, %v", count, err)
+	}
+
+	want := []string{"DescribeUserPool", "AdminSetUserMFAPreference", "ListUsers"}
+	if got := operations(); !slices.Equal(got, want) {
+		t.Errorf("expected operations %v, got %v", want, got)
+	}
+}
+
+func TestAWSClient_MigrateUser(t *testing.T) {
+	const oldUser = `{"Username":"old","Enabled":true,"UserAttributes":[` +
+		`{"Name":"sub","Value":"1234"},{"Name":"email","Value":"jane@example.com"},` +
+		`{"Name":"email_verified","Value":"true"}]}`
+	internalError := `{"__type":"InternalErrorException","message":"Internal error."}`
+
+	tests := []struct {
+		name       string
+		failOp     string
+		wantErr    bool
+		wantDelete []string
+		wantAlias  bool
+	}{
+		{name: "migrated", wantDelete: []string{"old"}},
+		{name: "group copy fails", failOp: "AdminAddUserToGroup", wantErr: true,
+			wantDelete: []string{"new"}, wantAlias: true},
+		{name: "old user delete fails", failOp: "AdminDeleteUser", wantErr: true,
+			wantDelete: []string{"old", "new"}, wantAlias: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failed atomic.Bool
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				if op == tt.failOp && failed.CompareAndSwap(false, true) {
+					return http.StatusBadRequest, internalError
+				}
+				switch op {
+				case "AdminGetUser":
+					return http.StatusOK, oldUser
+				case "AdminListGroupsForUser":
+					return http.StatusOK, `{"Groups":[{"GroupName":"admins"}]}`
+				}
+				return http.StatusOK, "{}"
+			})
+
+			err := c.MigrateUser(context.Background(), "old", "new", WithGroupMemberships())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			created := requestsFor(requests(), "AdminCreateUser")
+			if len(created) != 1 {
+				t.Fatalf("expected one create, got %d", len(created))
+			}
+			if created[0]["Username"] != "new" || created[0]["ForceAliasCreation"] != true {
+				t.Errorf("expected new user created with the alias forced, got %v", created[0])
+			}
+			attributes := requestAttributes(created[0], "UserAttributes")
+			if _, ok := attributes[AttrSub]; ok || attributes[AttrEmail] != "jane@example.com" {
+				t.Errorf("expected attributes copied without sub, got %v", attributes)
+			}
+
+			var deleted []string
+			for _, body := range requestsFor(requests(), "AdminDeleteUser") {
+				deleted = append(deleted, body["Username"].(string))
+			}
+			if !slices.Equal(deleted, tt.wantDelete) {
+				t.Errorf("expected deleted users %v, got %v", tt.wantDelete, deleted)
+			}
+
+			restored := requestsFor(requests(), "AdminUpdateUserAttributes")
+			if !tt.wantAlias {
+				if len(restored) != 0 {
+					t.Errorf("expected no alias restore, got %v", restored)
+				}
+				return
+			}
+			if len(restored) != 1 || restored[0]["Username"] != "old" ||
+				requestAttributes(restored[0], "UserAttributes")[AttrEmailVerified] != "true" {
+				t.Errorf("expected the old user's verified email restored, got %v", restored)
+			}
+		})
+	}
+}
+
+func TestAWSClient_ConfirmUser(t *testing.T) {
+	user := func(status string) string {
+		return `{"Username":"jane","Enabled":true,"UserStatus":"` + status + `"}`
+	}
+	notAuthorized := `{"__type":"NotAuthorizedException","message":"User cannot be confirmed."}`
+
+	tests := []struct {
+		name     string
+		statuses []string
+		confirm  string
+		wantErr  error
+		wantOps  []string
+	}{
+		{name: "unconfirmed", statuses: []string{"UNCONFIRMED"},
+			wantOps: []string{"AdminGetUser", "AdminConfirmSignUp"}},
+		{name: "already confirmed", statuses: []string{"CONFIRMED"}, wantErr: userpool.ErrUserAlreadyConfirmed,
+			wantOps: []string{"AdminGetUser"}},
+		{name: "created by an administrator", statuses: []string{"FORCE_CHANGE_PASSWORD"},
+			wantErr: userpool.ErrUserAlreadyConfirmed, wantOps: []string{"AdminGetUser"}},
+		{name: "confirmed concurrently", statuses: []string{"UNCONFIRMED", "CONFIRMED"}, confirm: notAuthorized,
+			wantErr: userpool.ErrUserAlreadyConfirmed,
+			wantOps: []string{"AdminGetUser", "AdminConfirmSignUp", "AdminGetUser"}},
+		{name: "rejected", statuses: []string{"UNCONFIRMED", "UNCONFIRMED"}, confirm: notAuthorized,
+			wantOps: []string{"AdminGetUser", "AdminConfirmSignUp", "AdminGetUser"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gets atomic.Int32
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				switch op {
+				case "AdminGetUser":
+					i := min(int(gets.Add(1)), len(tt.statuses)) - 1
+					return http.StatusOK, user(tt.statuses[i])
+				case "AdminConfirmSignUp":
+					if tt.confirm != "" {
+						return http.StatusBadRequest, tt.confirm
+					}
+				}
+				return http.StatusOK, "{}"
+			})
+
+			err := c.ConfirmUser(context.Background(), "jane")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.confirm != "":
+				if err == nil || errors.Is(err, userpool.ErrUserAlreadyConfirmed) {
+					t.Errorf("expected the rejection to be returned, got %v", err)
+				}
+			case err != nil:
+				t.Errorf("expected no error, got %v", err)
+			}
+
+			ops := requestOps(requests())
+			if !slices.Equal(ops, tt.wantOps) {
+				t.Errorf("expected operations %v, got %v", tt.wantOps, ops)
+			}
+			for _, body := range requestsFor(requests(), "AdminConfirmSignUp") {
+				if body["Username"] != "jane" || body["UserPoolId"] != "us-east-1_test" {
+					t.Errorf("expected jane confirmed in the test pool, got %v", body)
+				}
+			}
+		})
+	}
+}
+
+func TestAWSClient_EnsureUser_CreatedConcurrently(t *testing.T) {
+	var gets atomic.Int32
+	c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+		switch op {
+		case "AdminGetUser":
+			if gets.Add(1) == 1 {
+				return http.StatusBadRequest, `{"__type":"UserNotFoundException","message":"User does not exist."}`
+			}
+			return http.StatusOK, `{"Username":"jane","Enabled":true,"UserStatus":"CONFIRMED",` +
+				`"UserAttributes":[{"Name":"email","Value":"jane@example.com"}]}`
+		case "AdminCreateUser":
+			return http.StatusBadRequest, `{"__type":"UsernameExistsException","message":"User account already exists"}`
+		}
+		return http.StatusOK, "{}"
+	})
+
+	user, err := c.EnsureUser(context.Background(), &userpool.User{
+		Username: "jane", Email: "jane@example.com", Enabled: true,
+	})
+	if err != nil {
+		t.Fatalf("expected the existing user to be updated, got %v", err)
+	}
+	if user.Username != "jane" || user.Email != "jane@example.com" {
+		t.Errorf("expected the user read back from the pool, got %+v", user)
+	}
+
+	ops := requestOps(requests())
+	create := slices.Index(ops, "AdminCreateUser")
+	update := slices.Index(ops, "AdminUpdateUserAttributes")
+	if create < 0 || update < create || ops[len(ops)-1] != "AdminGetUser" {
+		t.Fatalf("expected create, then update, then a read back, got %v", ops)
+	}
+	updates := requestsFor(requests(), "AdminUpdateUserAttributes")
+	if got := requestAttributes(updates[0], "UserAttributes")[AttrEmail]; updates[0]["Username"] != "jane" ||
+		got != "jane@example.com" {
+		t.Errorf("expected jane updated with the desired email, got %v", updates[0])
+	}
+}
+
+func TestAWSClient_ForgetDevicesOnDisable(t *testing.T) {
+	tests := []struct {
+		name        string
+		forget      bool
+		wasEnabled  bool
+		wantForgets []string
+	}{
+		{name: "disabled", forget: true, wasEnabled: true, wantForgets: []string{"device-1", "device-2"}},
+		{name: "already disabled", forget: true},
+		{name: "option off", wasEnabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages atomic.Int32
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				if op != "AdminListDevices" {
+					return http.StatusOK, "{}"
+				}
+				if pages.Add(1) == 1 {
+					return http.StatusOK, `{"Devices":[{"DeviceKey":"device-1"}],"PaginationToken":"next"}`
+				}
+				return http.StatusOK, `{"Devices":[{"DeviceKey":"device-2"}]}`
+			}, WithForgetDevicesOnDisable(tt.forget))
+
+			current := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: tt.wasEnabled}
+			desired := &userpool.User{Username: "jane", Email: "jane@example.com"}
+			if err := c.UpdateUserDelta(context.Background(), current, desired); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			listed := requestsFor(requests(), "AdminListDevices")
+			var forgotten []string
+			for _, body := range requestsFor(requests(), "AdminForgetDevice") {
+				if body["Username"] != "jane" {
+					t.Errorf("expected devices of jane forgotten, got %v", body)
+				}
+				forgotten = append(forgotten, body["DeviceKey"].(string))
+			}
+			if !slices.Equal(forgotten, tt.wantForgets) {
+				t.Errorf("expected forgotten devices %v, got %v", tt.wantForgets, forgotten)
+			}
+			if tt.wantForgets == nil {
+				if len(listed) != 0 {
+					t.Errorf("expected no devices listed, got %v", listed)
+				}
+				return
+			}
+			if len(listed) != 2 || listed[1]["PaginationToken"] != "next" {
+				t.Errorf("expected both device pages listed, got %v", listed)
+			}
+			if ops := requestOps(requests()); slices.Index(ops, "AdminDisableUser") > slices.Index(ops, "AdminListDevices") {
+				t.Errorf("expected the user disabled before the devices are forgotten, got %v", ops)
+			}
+		})
+	}
+}
+
+func TestAWSClient_CountUsers(t *testing.T) {
+	internalError := `{"__type":"InternalErrorException","message":"Internal error."}`
+	tests := []struct {
+		name      string
+		describe  string
+		list      string
+		wantCount int
+		wantErr   bool
+		wantOps   []string
+	}{
+		{name: "estimate", describe: `{"UserPool":{"EstimatedNumberOfUsers":42}}`, wantCount: 42,
+			wantOps: []string{"DescribeUserPool"}},
+		{name: "listing fallback", list: `{"Users":[{"Username":"jane"},{"Username":"john"}]}`, wantCount: 2,
+			wantOps: []string{"DescribeUserPool", "ListUsers"}},
+		{name: "both fail", wantErr: true, wantOps: []string{"DescribeUserPool", "ListUsers"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				body := tt.describe
+				if op == "ListUsers" {
+					body = tt.list
+				}
+				if body == "" {
+					return http.StatusBadRequest, internalError
+				}
+				return http.StatusOK, body
+			}, WithMaxAttempts(1))
+
+			count, err := c.CountUsers(context.Background())
+			if (err != nil) != tt.wantErr || count != tt.wantCount {
+				t.Errorf("expected %d users and error %v, got %d, %v", tt.wantCount, tt.wantErr, count, err)
+			}
+			if ops := requestOps(requests()); !slices.Equal(ops, tt.wantOps) {
+				t.Errorf("expected operations %v, got %v", tt.wantOps, ops)
+			}
+			for _, req := range requests() {
+				if req.body["UserPoolId"] != "us-east-1_test" {
+					t.Errorf("expected %s on the test pool, got %v", req.op, req.body)
+				}
+			}
+		})
+	}
+}
+
+func TestAWSClient_GetUserStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  userpool.Status
+		wantEnabled bool
+		wantErr     error
+	}{
+		{name: "confirmed", status: http.StatusOK,
+			body:       `{"Username":"jane","Enabled":true,"UserStatus":"CONFIRMED"}`,
+			wantStatus: userpool.StatusConfirmed, wantEnabled: true},
+		{name: "disabled with a temporary password", status: http.StatusOK,
+			body:       `{"Username":"jane","Enabled":false,"UserStatus":"FORCE_CHANGE_PASSWORD"}`,
+			wantStatus: userpool.StatusForceChangePassword},
+		{name: "unknown status", status: http.StatusOK,
+			body:       `{"Username":"jane","Enabled":true,"UserStatus":"SOMETHING_NEW"}`,
+			wantStatus: userpool.StatusUnknown, wantEnabled: true},
+		{name: "not found", status: http.StatusBadRequest,
+			body:    `{"__type":"UserNotFoundException","message":"User does not exist."}`,
+			wantErr: userpool.ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				return tt.status, tt.body
+			})
+
+			status, enabled, err := c.GetUserStatus(context.Background(), "jane")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil || status != tt.wantStatus || enabled != tt.wantEnabled {
+				t.Errorf("expected %s and enabled %v, got %s, %v, %v", tt.wantStatus, tt.wantEnabled,
+					status, enabled, err)
+			}
+
+			gets := requestsFor(requests(), "AdminGetUser")
+			if len(requests()) != 1 || len(gets) != 1 || gets[0]["Username"] != "jane" ||
+				gets[0]["UserPoolId"] != "us-east-1_test" {
+				t.Errorf("expected a single AdminGetUser for jane, got %v", requests())
+			}
+		})
+	}
+
+	c, operations := newTestAWSClient(t, nil)
+	if _, _, err := c.GetUserStatus(context.Background(), ""); err == nil || len(operations()) != 0 {
+		t.Errorf("expected an empty username to fail without calling Cognito, got %v, %v", err, operations())
+	}
+}
+
+func TestAWSClient_CreateUser_ForceAliasCreation(t *testing.T) {
+	aliasExists := `{"__type":"AliasExistsException","message":"An account with the email already exists."}`
+	tests := []struct {
+		name      string
+		force     bool
+		wantForce any
+		wantErr   error
+	}{
+		{name: "default", wantErr: userpool.ErrAliasExists},
+		{name: "forced", force: true, wantForce: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+				if op == "AdminCreateUser" && !tt.force {
+					return http.StatusBadRequest, aliasExists
+				}
+				return http.StatusOK, "{}"
+			}, WithForceAliasCreation(tt.force))
+
+			err := c.CreateUser(context.Background(), &userpool.User{
+				Username: "jane", Email: "jane@example.com", Enabled: true,
+			})
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) || tt.wantErr == nil && err != nil {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+
+			created := requestsFor(requests(), "AdminCreateUser")
+			if len(created) != 1 {
+				t.Fatalf("expected one create, got %d", len(created))
+			}
+			// The SDK leaves false out of the request
+			if got := created[0]["ForceAliasCreation"]; got != tt.wantForce {
+				t.Errorf("expected ForceAliasCreation %v, got %v", tt.wantForce, got)
+			}
+		})
+	}
+}
+
+func TestAWSClient_DefaultAttributes(t *testing.T) {
+	defaults := map[string]string{"custom:managedBy": "kcp-users-controller", "custom:tier": "free"}
+
+	t.Run("written on create and update", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, nil, WithDefaultAttributes(defaults),
+			WithAttributeMapping(map[string]string{"custom:managedBy": "custom:owner"}))
+		user := &userpool.User{
+			Username: "jane", Email: "jane@example.com", Enabled: true,
+			Attributes: map[string]string{"custom:tier": "gold"},
+		}
+		if err := c.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := c.UpdateUser(context.Background(), user); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		for _, op := range []string{"AdminCreateUser", "AdminUpdateUserAttributes"} {
+			bodies := requestsFor(requests(), op)
+			if len(bodies) != 1 {
+				t.Fatalf("expected one %s, got %d", op, len(bodies))
+			}
+			attributes := requestAttributes(bodies[0], "UserAttributes")
+			if attributes["custom:owner"] != "kcp-users-controller" || attributes["custom:tier"] != "gold" {
+				t.Errorf("expected %s to write the mapped default and keep the user's value, got %v",
+					op, attributes)
+			}
+			if _, ok := attributes["custom:managedBy"]; ok {
+				t.Errorf("expected %s to use the mapped name only, got %v", op, attributes)
+			}
+		}
+	})
+
+	t.Run("missing from the pool", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusOK, `{"UserPool":{"SchemaAttributes":[{"Name":"email"},{"Name":"custom:tier"}]}}`
+		}, WithDefaultAttributes(defaults))
+		err := c.ValidateAttributeMapping(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "custom:managedBy") ||
+			strings.Contains(err.Error(), "custom:tier") {
+			t.Errorf("expected custom:managedBy reported missing, got %v", err)
+		}
+	})
+}
+
+func TestAWSClient_CustomAttributePrefix(t *testing.T) {
+	c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+		if op == "AdminGetUser" {
+			return http.StatusOK, `{"Username":"jane","UserStatus":"CONFIRMED","UserAttributes":[` +
+				`{"Name":"custom:kcp_team","Value":"a"},{"Name":"custom:team","Value":"other"},` +
+				`{"Name":"custom:tenant","Value":"acme"},{"Name":"custom:kcp_","Value":"empty"}]}`
+		}
+		return http.StatusOK, "{}"
+	}, WithCustomAttributePrefix("custom:kcp_"), WithAttributeMapping(map[string]string{"org": "custom:tenant"}))
+
+	user := &userpool.User{
+		Username: "jane", Email: "jane@example.com", Enabled: true,
+		Attributes:       map[string]string{"custom:team": "a", "org": "acme", "locale": "de"},
+		DeleteAttributes: []string{"custom:legacy"},
+	}
+	if err := c.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	attributes := requestAttributes(requestsFor(requests(), "AdminCreateUser")[0], "UserAttributes")
+	want := map[string]string{"custom:kcp_team": "a", "custom:tenant": "acme", "locale": "de"}
+	for name, value := range want {
+		if attributes[name] != value {
+			t.Errorf("expected %s=%s to be written, got %v", name, value, attributes)
+		}
+	}
+	if _, ok := attributes["custom:team"]; ok {
+		t.Errorf("expected custom:team to be written under the prefix only, got %v", attributes)
+	}
+
+	if err := c.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	deletes := requestsFor(requests(), "AdminDeleteUserAttributes")
+	if len(deletes) == 0 || !reflect.DeepEqual(deletes[0]["UserAttributeNames"], []any{"custom:kcp_legacy"}) {
+		t.Errorf("expected custom:kcp_legacy to be deleted, got %v", deletes)
+	}
+
+	got, err := c.GetUser(context.Background(), "jane")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if want := map[string]string{"custom:team": "a", "org": "acme"}; !reflect.DeepEqual(got.Attributes, want) {
+		t.Errorf("expected attributes %v read without the prefix, got %v", want, got.Attributes)
+	}
+
+	for _, prefix := range []string{"kcp_", "custom:", "custom:kcp:", "custom:kcp ", "custom:abcdefghijklmnopqrst"} {
+		if _, err := NewAWSClient(context.Background(), "us-east-1_test", WithCustomAttributePrefix(prefix)); err == nil {
+			t.Errorf("expected prefix %q to be rejected", prefix)
+		}
+	}
+}
+
+func TestAWSClient_ForcePasswordChange(t *testing.T) {
+	t.Run("temporary password", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, nil)
+		if err := c.ForcePasswordChange(context.Background(), "jane"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if ops := requestOps(requests()); !slices.Equal(ops, []string{"AdminSetUserPassword", "AdminCreateUser"}) {
+			t.Fatalf("expected the password to be set and the invitation resent, got %v", ops)
+		}
+		set := requestsFor(requests(), "AdminSetUserPassword")[0]
+		if permanent, _ := set["Permanent"].(bool); permanent {
+			t.Errorf("expected a temporary password, got %v", set)
+		}
+		password, _ := set["Password"].(string)
+		if len(password) != temporaryPasswordLength {
+			t.Errorf("expected a password of %d characters, got %d", temporaryPasswordLength, len(password))
+		}
+		for _, class := range passwordCharacterClasses {
+			if !strings.ContainsAny(password, class) {
+				t.Errorf("expected the password to contain one of %q", class)
+			}
+		}
+		resend := requestsFor(requests(), "AdminCreateUser")[0]
+		if resend["MessageAction"] != "RESEND" {
+			t.Errorf("expected the invitation to be resent, got %v", resend)
+		}
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, func(op string) (int, string) {
+			return http.StatusBadRequest, `{"__type":"UserNotFoundException","message":"User does not exist."}`
+		})
+		err := c.ForcePasswordChange(context.Background(), "jane")
+		if !errors.Is(err, userpool.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound, got %v", err)
+		}
+		if ops := requestOps(requests()); !slices.Equal(ops, []string{"AdminSetUserPassword"}) {
+			t.Errorf("expected no invitation for a missing user, got %v", ops)
+		}
+	})
+}
+
+func TestAWSClient_LinkProvider(t *testing.T) {
+	linked := func(op string) (int, string) {
+		if op == "AdminGetUser" {
+			return http.StatusOK, `{"Username":"jane","UserAttributes":[{"Name":"identities",` +
+				`"Value":"[{\"userId\":\"1234\",\"providerName\":\"Google\"}]"}]}`
+		}
+		return http.StatusOK, "{}"
+	}
+
+	t.Run("links", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, linked)
+		if err := c.LinkProvider(context.Background(), "jane", "SAML", "jane@corp"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		links := requestsFor(requests(), "AdminLinkProviderForUser")
+		if len(links) != 1 {
+			t.Fatalf("expected one AdminLinkProviderForUser, got %v", requestOps(requests()))
+		}
+		wantDestination := map[string]any{"ProviderName": "Cognito", "ProviderAttributeValue": "jane"}
+		if !reflect.DeepEqual(links[0]["DestinationUser"], wantDestination) {
+			t.Errorf("expected destination %v, got %v", wantDestination, links[0]["DestinationUser"])
+		}
+		wantSource := map[string]any{"ProviderName": "SAML", "ProviderAttributeName": "Cognito_Subject",
+			"ProviderAttributeValue": "jane@corp"}
+		if !reflect.DeepEqual(links[0]["SourceUser"], wantSource) {
+			t.Errorf("expected source %v, got %v", wantSource, links[0]["SourceUser"])
+		}
+	})
+
+	t.Run("already linked", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, linked)
+		if err := c.LinkProvider(context.Background(), "jane", "Google", "1234"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if ops := requestOps(requests()); !slices.Equal(ops, []string{"AdminGetUser"}) {
+			t.Errorf("expected only the user to be read, got %v", ops)
+		}
+	})
+
+	t.Run("empty arguments", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, linked)
+		for _, args := range [][3]string{{"", "Google", "1234"}, {"jane", "", "1234"}, {"jane", "Google", ""}} {
+			if err := c.LinkProvider(context.Background(), args[0], args[1], args[2]); err == nil {
+				t.Errorf("expected an error for %q", args)
+			}
+		}
+		if ops := requestOps(requests()); len(ops) != 0 {
+			t.Errorf("expected no requests, got %v", ops)
+		}
+	})
+}
+
+func TestAWSClient_SchemaPolicy(t *testing.T) {
+	schema := func(op string) (int, string) {
+		if op == "DescribeUserPool" {
+			return http.StatusOK, `{"UserPool":{"SchemaAttributes":[{"Name":"email"},{"Name":"email_verified"},` +
+				`{"Name":"custom:team"}]}}`
+		}
+		return http.StatusOK, "{}"
+	}
+	newClient := func(t *testing.T, opts ...Option) (*AWSClient, func() []testRequest) {
+		c, requests := newRecordingAWSClient(t, schema, opts...)
+		if err := c.ValidateAttributeMapping(context.Background()); err != nil {
+			t.Fatalf("failed to load schema: %v", err)
+		}
+		return c, requests
+	}
+	newUser := func() *userpool.User {
+		return &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true,
+			Attributes: map[string]string{"custom:team": "a", "custom:unknown": "x"}}
+	}
+
+	t.Run("fail closed", func(t *testing.T) {
+		c, requests := newClient(t)
+		if err := c.CreateUser(context.Background(), newUser()); !errors.Is(err, ErrUnknownAttribute) {
+			t.Errorf("expected ErrUnknownAttribute from CreateUser, got %v", err)
+		}
+		if err := c.UpdateUser(context.Background(), newUser()); !errors.Is(err, ErrUnknownAttribute) {
+			t.Errorf("expected ErrUnknownAttribute from UpdateUser, got %v", err)
+		}
+		current := newUser()
+		delete(current.Attributes, "custom:unknown")
+		if _, err := c.UpdateUserIfChanged(context.Background(), current, newUser()); !errors.Is(err,
+			ErrUnknownAttribute) {
+			t.Errorf("expected ErrUnknownAttribute from UpdateUserIfChanged, got %v", err)
+		}
+		if ops := requestOps(requests()); !slices.Equal(ops, []string{"DescribeUserPool"}) {
+			t.Errorf("expected no writes, got %v", ops)
+		}
+	})
+
+	t.Run("drop unknown", func(t *testing.T) {
+		c, requests := newClient(t, WithSchemaPolicy(SchemaPolicyDropUnknown))
+		if err := c.CreateUser(context.Background(), newUser()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		attributes := requestAttributes(requestsFor(requests(), "AdminCreateUser")[0], "UserAttributes")
+		if _, ok := attributes["custom:unknown"]; ok || attributes["custom:team"] != "a" {
+			t.Errorf("expected only the known attributes to be written, got %v", attributes)
+		}
+	})
+
+	t.Run("every change dropped", func(t *testing.T) {
+		c, requests := newClient(t, WithSchemaPolicy(SchemaPolicyDropUnknown))
+		current := newUser()
+		current.Attributes["custom:unknown"] = "y"
+		written, err := c.UpdateUserIfChanged(context.Background(), current, newUser())
+		if err != nil || written {
+			t.Errorf("expected nothing to be written, got %v, %v", written, err)
+		}
+		if ops := requestOps(requests()); !slices.Equal(ops, []string{"DescribeUserPool"}) {
+			t.Errorf("expected no writes, got %v", ops)
+		}
+	})
+
+	t.Run("no schema", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, nil)
+		if err := c.CreateUser(context.Background(), newUser()); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		attributes := requestAttributes(requestsFor(requests(), "AdminCreateUser")[0], "UserAttributes")
+		if attributes["custom:unknown"] != "x" || attributes["custom:team"] != "a" {
+			t.Errorf("expected every attribute to pass through, got %v", attributes)
+		}
+	})
+}
+
+func TestAWSClient_SignUp(t *testing.T) {
+	t.Run("app client required", func(t *testing.T) {
+		c, requests := newTestAWSClient(t, nil)
+		if err := c.ResendConfirmationCode(context.Background(), "jane"); !errors.Is(err, ErrAppClientIDRequired) {
+			t.Errorf("expected ErrAppClientIDRequired from ResendConfirmationCode, got %v", err)
+		}
+		if err := c.ConfirmSignUp(context.Background(), "jane", "123456"); !errors.Is(err, ErrAppClientIDRequired) {
+			t.Errorf("expected ErrAppClientIDRequired from ConfirmSignUp, got %v", err)
+		}
+		if ops := requests(); len(ops) != 0 {
+			t.Errorf("expected no requests, got %v", ops)
+		}
+	})
+	t.Run("secret hash", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, nil, WithAppClientID("client-id", "client-secret"))
+		if err := c.ResendConfirmationCode(context.Background(), "jane"); err != nil {
+			t.Fatalf("ResendConfirmationCode failed: %v", err)
+		}
+		if err := c.ConfirmSignUp(context.Background(), "jane", "123456"); err != nil {
+			t.Fatalf("ConfirmSignUp failed: %v", err)
+		}
+		// Base64 of HMAC-SHA256 over username + client ID, keyed with the
+		// client secret
+		const want = "cjgPnmwcoTV+6qfLUyjb+K84I2eH2Qnjiebb4fcPrT8="
+		for _, op := range []string{"ResendConfirmationCode", "ConfirmSignUp"} {
+			bodies := requestsFor(requests(), op)
+			if len(bodies) != 1 {
+				t.Fatalf("expected one %s request, got %d", op, len(bodies))
+			}
+			if bodies[0]["ClientId"] != "client-id" || bodies[0]["SecretHash"] != want {
+				t.Errorf("expected %s with client-id and SecretHash %s, got %v", op, want, bodies[0])
+			}
+		}
+	})
+	t.Run("no secret", func(t *testing.T) {
+		c, requests := newRecordingAWSClient(t, nil, WithAppClientID("client-id", ""))
+		if err := c.ConfirmSignUp(context.Background(), "jane", "123456"); err != nil {
+			t.Fatalf("ConfirmSignUp failed: %v", err)
+		}
+		bodies := requestsFor(requests(), "ConfirmSignUp")
+		if _, ok := bodies[0]["SecretHash"]; ok {
+			t.Errorf("expected no SecretHash without a client secret, got %v", bodies[0])
+		}
+	})
+	t.Run("user not found", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusBadRequest,
+				`{"__type":"UserNotFoundException","message":"Username/client id combination not found."}`
+		}, WithAppClientID("client-id", ""), WithMaxAttempts(1))
+		if err := c.ResendConfirmationCode(context.Background(), "jane"); !errors.Is(err, userpool.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound from ResendConfirmationCode, got %v", err)
+		}
+		if err := c.ConfirmSignUp(context.Background(), "jane", "123456"); !errors.Is(err, userpool.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound from ConfirmSignUp, got %v", err)
+		}
+	})
+	t.Run("already confirmed", func(t *testing.T) {
+		// The message Cognito returns when confirming a confirmed user
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusBadRequest,
+				`{"__type":"NotAuthorizedException","message":"User cannot be confirmed. Current status is CONFIRMED"}`
+		}, WithAppClientID("client-id", ""), WithMaxAttempts(1))
+		if err := c.ConfirmSignUp(context.Background(), "jane", "123456"); !errors.Is(err, userpool.ErrUserAlreadyConfirmed) {
+			t.Errorf("expected ErrUserAlreadyConfirmed, got %v", err)
+		}
+	})
+	t.Run("other not authorized", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, func(op string) (int, string) {
+			return http.StatusBadRequest, `{"__type":"NotAuthorizedException","message":"Invalid code provided."}`
+		}, WithAppClientID("client-id", ""), WithMaxAttempts(1))
+		err := c.ConfirmSignUp(context.Background(), "jane", "123456")
+		if err == nil || errors.Is(err, userpool.ErrUserAlreadyConfirmed) {
+			t.Errorf("expected a plain NotAuthorized error, got %v", err)
+		}
+	})
+}
+
+func TestAWSClient_ListUsersModifiedSince(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	c, requests := newTestAWSClient(t, func(op string) (int, string) {
+		return http.StatusOK, `{"Users":[` +
+			`{"Username":"before","UserLastModifiedDate":1699999999},` +
+			`{"Username":"at","UserLastModifiedDate":1700000000},` +
+			`{"Username":"after","UserLastModifiedDate":1700000001},` +
+			`{"Username":"unknown"}]}`
+	})
+	users, err := c.ListUsersModifiedSince(context.Background(), since)
+	if err != nil {
+		t.Fatalf("ListUsersModifiedSince failed: %v", err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	if !reflect.DeepEqual(names, []string{"after"}) {
+		t.Errorf("expected only the user modified after since, got %v", names)
+	}
+	if ops := requests(); !reflect.DeepEqual(ops, []string{"ListUsers"}) {
+		t.Errorf("expected a single ListUsers request, got %v", ops)
+	}
+}
+
+func TestAWSClient_Region(t *testing.T) {
+	tests := []struct {
+		name       string
+		userPoolID string
+		opts       []Option
+		wantRegion string
+		wantFIPS   aws.FIPSEndpointState
+	}{
+		{name: "region from pool ID", userPoolID: "us-gov-west-1_AbC", wantRegion: "us-gov-west-1"},
+		{name: "pool ID without region", userPoolID: "AbC", wantRegion: ""},
+		{name: "explicit region wins", userPoolID: "us-gov-west-1_AbC", opts: []Option{WithRegion("eu-west-1")},
+			wantRegion: "eu-west-1"},
+		{name: "empty region keeps pool ID", userPoolID: "us-gov-west-1_AbC", opts: []Option{WithRegion("")},
+			wantRegion: "us-gov-west-1"},
+		{name: "fips endpoint", userPoolID: "us-gov-west-1_AbC", opts: []Option{WithFIPSEndpoint(true)},
+			wantRegion: "us-gov-west-1", wantFIPS: aws.FIPSEndpointStateEnabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+			t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+			t.Setenv("AWS_REGION", "")
+			t.Setenv("AWS_DEFAULT_REGION", "")
+
+			c, err := NewAWSClient(context.Background(), tt.userPoolID, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewAWSClient failed: %v", err)
+			}
+			options := c.cognito.Options()
+			if options.Region != tt.wantRegion {
+				t.Errorf("expected region %q, got %q", tt.wantRegion, options.Region)
+			}
+			if options.EndpointOptions.UseFIPSEndpoint != tt.wantFIPS {
+				t.Errorf("expected FIPS endpoint state %v, got %v", tt.wantFIPS,
+					options.EndpointOptions.UseFIPSEndpoint)
+			}
+		})
+	}
+}
+
+// fixedBackoff is a retry.BackoffDelayer waiting the same delay before every
+// retry
+type fixedBackoff time.Duration
+
+func (b fixedBackoff) BackoffDelay(attempt int, err error) (time.Duration, error) {
+	return time.Duration(b), nil
+}
+
+func TestAWSClient_Retries(t *testing.T) {
+	internalError := func(op string) (int, string) {
+		return http.StatusInternalServerError, `{"__type":"InternalErrorException","message":"Internal error."}`
+	}
+	t.Run("max attempts", func(t *testing.T) {
+		for _, attempts := range []int{1, 2, 4} {
+			c, requests := newTestAWSClient(t, internalError, WithMaxAttempts(attempts),
+				WithMaxBackoff(time.Millisecond))
+			if _, err := c.GetUser(context.Background(), "jane"); err == nil {
+				t.Fatalf("expected GetUser to fail")
+			}
+			if got := len(requests()); got != attempts {
+				t.Errorf("expected %d attempts, got %d", attempts, got)
+			}
+		}
+	})
+	t.Run("max backoff", func(t *testing.T) {
+		c, _ := newTestAWSClient(t, nil, WithMaxBackoff(10*time.Millisecond))
+		for attempt := 1; attempt <= 10; attempt++ {
+			delay, err := c.cognito.Options().Retryer.RetryDelay(attempt, errors.New("retryable"))
+			if err != nil || delay > 10*time.Millisecond {
+				t.Errorf("attempt %d: expected a delay of at most 10ms, got %v, %v", attempt, delay, err)
+			}
+		}
+	})
+	t.Run("standard retryer", func(t *testing.T) {
+		c, requests := newTestAWSClient(t, internalError, WithStandardRetryer(func(o *retry.StandardOptions) {
+			o.MaxAttempts = 2
+			o.Backoff = fixedBackoff(time.Millisecond)
+		}))
+		delay, err := c.cognito.Options().Retryer.RetryDelay(5, errors.New("retryable"))
+		if err != nil || delay != time.Millisecond {
+			t.Errorf("expected the configured backoff of 1ms, got %v, %v", delay, err)
+		}
+		if _, err := c.GetUser(context.Background(), "jane"); err == nil {
+			t.Fatalf("expected GetUser to fail")
+		}
+		if got := len(requests()); got != 2 {
+			t.Errorf("expected the replaced retryer to make 2 attempts, got %d", got)
+		}
+	})
+}
+
+func TestAWSClient_OperationHooks(t *testing.T) {
+	type call struct {
+		hook string
+		op   string
+		err  bool
+	}
+	var mu sync.Mutex
+	var calls []call
+	hook := func(name string) OperationHook {
+		return OperationHookFunc(func(op string, dur time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, call{hook: name, op: op, err: err != nil})
+		})
+	}
+
+	var gets atomic.Int32
+	c, operations := newTestAWSClient(t, func(op string) (int, string) {
+		switch gets.Add(1) {
+		case 1:
+			return http.StatusBadRequest, `{"__type":"TooManyRequestsException","message":"Too many requests"}`
+		case 2:
+			return http.StatusOK, `{"Username":"jane","Enabled":true,"UserStatus":"CONFIRMED"}`
+		}
+		return http.StatusBadRequest, `{"__type":"UserNotFoundException","message":"User does not exist."}`
+	}, WithOperationHook(hook("first")), WithOperationHook(hook("second")), WithMaxBackoff(time.Millisecond))
+
+	if _, err := c.GetUser(context.Background(), "jane"); err != nil {
+		t.Fatalf("expected the retried call to succeed, got %v", err)
+	}
+	if _, err := c.GetUser(context.Background(), "john"); !errors.Is(err, userpool.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	want := []call{
+		{hook: "first", op: "AdminGetUser"}, {hook: "second", op: "AdminGetUser"},
+		{hook: "first", op: "AdminGetUser", err: true}, {hook: "second", op: "AdminGetUser", err: true},
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("expected hook calls %v, got %v", want, calls)
+	}
+	if got := len(operations()); got != 3 {
+		t.Errorf("expected the retry to reach the server and not the hooks, got %d requests", got)
+	}
+}
+
+func TestAWSClient_UpdateUserIfChanged(t *testing.T) {
+	current := &userpool.User{
+		Username: "jane", Email: "jane@example.com", EmailVerified: aws.Bool(true), Enabled: true,
+		Attributes: map[string]string{"custom:team": "platform"},
+	}
+	tests := []struct {
+		name       string
+		current    *userpool.User
+		update     func(user *userpool.User)
+		wantChange bool
+		wantOps    []string
+	}{
+		{name: "unchanged", current: current, update: func(*userpool.User) {}},
+		{name: "attribute changed", current: current, wantChange: true,
+			update:  func(user *userpool.User) { user.Attributes = map[string]string{"custom:team": "identity"} },
+			wantOps: []string{"AdminUpdateUserAttributes"}},
+		{name: "disabled", current: current, wantChange: true,
+			update:  func(user *userpool.User) { user.Enabled = false },
+			wantOps: []string{"AdminDisableUser"}},
+		{name: "attribute deleted", current: current, wantChange: true,
+			update: func(user *userpool.User) {
+				user.Attributes = nil
+				user.DeleteAttributes = []string{"custom:team"}
+			},
+			wantOps: []string{"AdminDeleteUserAttributes"}},
+		{name: "no current user", wantChange: true, update: func(*userpool.User) {},
+			wantOps: []string{"AdminUpdateUserAttributes", "AdminEnableUser", "AdminDeleteUserAttributes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, operations := newTestAWSClient(t, nil)
+			user := &userpool.User{
+				Username: "jane", Email: "jane@example.com", EmailVerified: aws.Bool(true), Enabled: true,
+				Attributes: map[string]string{"custom:team": "platform"},
+			}
+			tt.update(user)
+
+			changed, err := c.UpdateUserIfChanged(context.Background(), tt.current, user)
+			if err != nil || changed != tt.wantChange {
+				t.Errorf("expected changed %v, got %v, %v", tt.wantChange, changed, err)
+			}
+			if got := operations(); !slices.Equal(got, tt.wantOps) {
+				t.Errorf("expected operations %v, got %v", tt.wantOps, got)
+			}
+		})
+	}
+}
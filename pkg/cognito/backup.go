@@ -0,0 +1,201 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// maxBackupLineSize is the longest backup line ImportFromBackup reads. Cognito
+// limits a user to 50 custom attributes of 2048 characters each, so a user
+// stays well below it.
+const maxBackupLineSize = 1 << 20
+
+// BackupRecord is one line of the newline-delimited JSON written by
+// ExportUsers. Attributes are keyed by their logical name.
+type BackupRecord struct {
+	Username          string            `json:"username"`
+	Email             string            `json:"email,omitempty"`
+	EmailVerified     *bool             `json:"emailVerified,omitempty"`
+	SecondaryEmail    string            `json:"secondaryEmail,omitempty"`
+	PreferredUsername string            `json:"preferredUsername,omitempty"`
+	Locale            string            `json:"locale,omitempty"`
+	Address           *userpool.Address `json:"address,omitempty"`
+	Picture           string            `json:"picture,omitempty"`
+	Profile           string            `json:"profile,omitempty"`
+	Nickname          string            `json:"nickname,omitempty"`
+	Website           string            `json:"website,omitempty"`
+	ZoneInfo          string            `json:"zoneInfo,omitempty"`
+	Birthdate         string            `json:"birthdate,omitempty"`
+	Gender            string            `json:"gender,omitempty"`
+	Enabled           bool              `json:"enabled"`
+	DisableReason     string            `json:"disableReason,omitempty"`
+	Status            userpool.Status   `json:"status,omitempty"`
+	Attributes        map[string]string `json:"attributes,omitempty"`
+	Groups            []string          `json:"groups,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt,omitzero"`
+	LastModified      time.Time         `json:"lastModified,omitzero"`
+}
+
+// ExportUsers writes every user of the pool with its group memberships to w
+// as newline-delimited JSON, one BackupRecord per line. The pool is listed a
+// page at a time, so memory use doesn't grow with the pool. It returns the
+// number of users written.
+func (c *AWSClient) ExportUsers(ctx context.Context, w io.Writer) (int, error) {
+	return exportUsers(ctx, c, c.ListGroupsForUser, w)
+}
+
+// ImportFromBackup restores the users of a backup written by ExportUsers.
+// Missing users are created without an invitation, existing users are
+// updated, and every user is added to its groups, which must exist. Status
+// and timestamps are informational and not restored. It stops at the first
+// failure; the result lists the users restored before it and the failed one.
+func (c *AWSClient) ImportFromBackup(ctx context.Context, r io.Reader) (*userpool.BulkResult, error) {
+	return importFromBackup(ctx, c, r)
+}
+
+// exportUsers implements ExportUsers for client, reading memberships with
+// listGroups
+func exportUsers(ctx context.Context, client userpool.Client,
+	listGroups func(ctx context.Context, username string) ([]string, error), w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	cursor := ""
+	for {
+		page, next, err := client.ListUsersPage(ctx, cursor)
+		if err != nil {
+			return count, fmt.Errorf("failed to export users: %w", err)
+		}
+		for _, user := range page {
+			groups, err := listGroups(ctx, user.Username)
+			if err != nil {
+				return count, fmt.Errorf("failed to export users: %w", err)
+			}
+			slices.Sort(groups)
+			if err := encoder.Encode(newBackupRecord(user, groups)); err != nil {
+				return count, fmt.Errorf("failed to write backup: %w", err)
+			}
+			count++
+		}
+		if next == "" {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+// importFromBackup implements ImportFromBackup for client
+func importFromBackup(ctx context.Context, client userpool.Client, r io.Reader) (*userpool.BulkResult, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBackupLineSize)
+	result := &userpool.BulkResult{}
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record BackupRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return result, fmt.Errorf("failed to read backup line %d: %w", line, err)
+		}
+		if record.Username == "" {
+			return result, fmt.Errorf("failed to read backup line %d: username is empty", line)
+		}
+		if err := restoreRecord(ctx, client, &record); err != nil {
+			err = fmt.Errorf("failed to restore backup line %d: %w", line, err)
+			result.Add(record.Username, userpool.BulkFailed, err)
+			return result, err
+		}
+		result.Add(record.Username, userpool.BulkSucceeded, nil)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return result, nil
+}
+
+// restoreRecord creates or updates the user of record and adds it to its
+// groups
+func restoreRecord(ctx context.Context, client userpool.Client, record *BackupRecord) error {
+	if _, err := client.EnsureUser(ctx, record.user()); err != nil {
+		return err
+	}
+	if len(record.Groups) > 0 {
+		if err := client.UpdateGroups(ctx, record.Username, record.Groups, nil); err != nil {
+			return fmt.Errorf("failed to restore groups: %w", err)
+		}
+	}
+	return nil
+}
+
+// newBackupRecord returns the backup record of user
+func newBackupRecord(user *userpool.User, groups []string) BackupRecord {
+	return BackupRecord{
+		Username:          user.Username,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerified,
+		SecondaryEmail:    user.SecondaryEmail,
+		PreferredUsername: user.PreferredUsername,
+		Locale:            user.Locale,
+		Address:           user.Address,
+		Picture:           user.Picture,
+		Profile:           user.Profile,
+		Nickname:          user.Nickname,
+		Website:           user.Website,
+		ZoneInfo:          user.ZoneInfo,
+		Birthdate:         user.Birthdate,
+		Gender:            user.Gender,
+		Enabled:           user.Enabled,
+		DisableReason:     user.DisableReason,
+		Status:            user.Status,
+		Attributes:        user.Attributes,
+		Groups:            groups,
+		CreatedAt:         user.CreatedAt,
+		LastModified:      user.LastModified,
+	}
+}
+
+// user returns the user to write for the record
+func (r BackupRecord) user() *userpool.User {
+	return &userpool.User{
+		Username:          r.Username,
+		Email:             r.Email,
+		EmailVerified:     r.EmailVerified,
+		SecondaryEmail:    r.SecondaryEmail,
+		PreferredUsername: r.PreferredUsername,
+		Locale:            r.Locale,
+		Address:           r.Address,
+		Picture:           r.Picture,
+		Profile:           r.Profile,
+		Nickname:          r.Nickname,
+		Website:           r.Website,
+		ZoneInfo:          r.ZoneInfo,
+		Birthdate:         r.Birthdate,
+		Gender:            r.Gender,
+		Enabled:           r.Enabled,
+		DisableReason:     r.DisableReason,
+		Attributes:        maps.Clone(r.Attributes),
+	}
+}
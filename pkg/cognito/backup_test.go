@@ -0,0 +1,103 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestExportAndImportUsers(t *testing.T) {
+	ctx := context.Background()
+	source := NewMockClient()
+	source.AddGroup("admins")
+	for i := range 120 {
+		user := &userpool.User{
+			Username:   fmt.Sprintf("user-%03d", i),
+			Email:      fmt.Sprintf("user-%03d@example.com", i),
+			Enabled:    i%2 == 0,
+			Attributes: map[string]string{"custom:tenant": "acme"},
+		}
+		if err := source.CreateUser(ctx, user); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+	if err := source.UpdateGroups(ctx, "user-007", []string{"admins"}, nil); err != nil {
+		t.Fatalf("failed to seed membership: %v", err)
+	}
+
+	var backup bytes.Buffer
+	exported, err := source.ExportUsers(ctx, &backup)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if lines := strings.Count(backup.String(), "\n"); exported != 120 || lines != 120 {
+		t.Fatalf("expected 120 users on 120 lines, got %d users on %d lines", exported, lines)
+	}
+
+	target := NewMockClient()
+	target.AddGroup("admins")
+	imported, err := target.ImportFromBackup(ctx, &backup)
+	if err != nil || imported.Count(userpool.BulkSucceeded) != 120 {
+		t.Fatalf("expected 120 users to be restored, got %v, %v", imported, err)
+	}
+	restored, err := target.GetUser(ctx, "user-007")
+	if err != nil || restored.Email != "user-007@example.com" || restored.Enabled ||
+		restored.Attributes["custom:tenant"] != "acme" {
+		t.Errorf("expected user-007 to be restored, got %+v, %v", restored, err)
+	}
+	if members := target.GroupMembers("admins"); !slices.Equal(members, []string{"user-007"}) {
+		t.Errorf("expected user-007 to be restored into admins, got %v", members)
+	}
+}
+
+func TestImportFromBackup_InvalidLine(t *testing.T) {
+	mock := NewMockClient()
+	backup := strings.NewReader("{\"username\":\"jane\",\"enabled\":true}\n\nnot json\n")
+	imported, err := mock.ImportFromBackup(context.Background(), backup)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected an error for line 3, got %v", err)
+	}
+	if got := imported.Usernames(userpool.BulkSucceeded); !slices.Equal(got, []string{"jane"}) {
+		t.Errorf("expected jane restored before the error, got %v", got)
+	}
+}
+
+func TestImportFromBackup_FailedUser(t *testing.T) {
+	mock := NewMockClient()
+	backup := strings.NewReader("{\"username\":\"jane\",\"enabled\":true}\n" +
+		"{\"username\":\"john\",\"enabled\":true,\"groups\":[\"missing\"]}\n" +
+		"{\"username\":\"mary\",\"enabled\":true}\n")
+	result, err := mock.ImportFromBackup(context.Background(), backup)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+	users := result.Users()
+	if len(users) != 2 || users[0].Username != "jane" || users[0].Outcome != userpool.BulkSucceeded ||
+		users[1].Username != "john" || users[1].Outcome != userpool.BulkFailed || users[1].Err == nil {
+		t.Errorf("expected jane restored and john failed, got %+v", users)
+	}
+	if result.String() != "1 succeeded, 0 skipped, 1 failed" {
+		t.Errorf("expected counts of the restored and failed users, got %q", result)
+	}
+}
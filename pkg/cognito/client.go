@@ -24,6 +24,6 @@ import (
 
 // NewClient creates a new Cognito client with Pod Identity authentication
 // This is a convenience function that returns the AWS implementation
-func NewClient(ctx context.Context, userPoolID string) (userpool.Client, error) {
-	return NewAWSClient(ctx, userPoolID)
+func NewClient(ctx context.Context, userPoolID string, opts ...Option) (userpool.Client, error) {
+	return NewAWSClient(ctx, userPoolID, opts...)
 }
@@ -0,0 +1,59 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"maps"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+)
+
+// WithClusterMetadataKey passes the logical cluster of the reconcile that
+// makes a call, as set by the controllers with
+// sigs.k8s.io/multicluster-runtime/pkg/context.WithCluster, to the user
+// pool's Lambda triggers as client metadata under key, e.g. "kcpCluster". It
+// is added to the calls that accept client metadata: creating users, updating
+// their attributes, resetting passwords and confirming users. Calls without a
+// cluster in their context are unchanged, and client metadata set by the
+// caller under the same key wins. Empty, the default, disables it.
+func WithClusterMetadataKey(key string) Option {
+	return func(c *AWSClient) {
+		c.clusterMetadataKey = key
+	}
+}
+
+// clientMetadata returns metadata with the cluster of ctx added if enabled
+// by WithClusterMetadataKey. metadata itself is never modified.
+func (c *AWSClient) clientMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	if c.clusterMetadataKey == "" {
+		return metadata
+	}
+	cluster, ok := mccontext.ClusterFrom(ctx)
+	if !ok || cluster == "" {
+		return metadata
+	}
+	if _, set := metadata[c.clusterMetadataKey]; set {
+		return metadata
+	}
+	withCluster := maps.Clone(metadata)
+	if withCluster == nil {
+		withCluster = make(map[string]string, 1)
+	}
+	withCluster[c.clusterMetadataKey] = cluster
+	return withCluster
+}
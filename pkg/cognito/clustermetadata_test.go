@@ -0,0 +1,57 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	mccontext "sigs.k8s.io/multicluster-runtime/pkg/context"
+)
+
+func TestAWSClient_ClusterMetadata(t *testing.T) {
+	inCluster := mccontext.WithCluster(context.Background(), "root:org:team")
+	tests := []struct {
+		name     string
+		key      string
+		ctx      context.Context
+		metadata map[string]string
+		want     map[string]string
+	}{
+		{name: "disabled", ctx: inCluster, metadata: map[string]string{"tenant": "acme"},
+			want: map[string]string{"tenant": "acme"}},
+		{name: "outside kcp", key: "kcpCluster", ctx: context.Background()},
+		{name: "added", key: "kcpCluster", ctx: inCluster, want: map[string]string{"kcpCluster": "root:org:team"}},
+		{name: "merged", key: "kcpCluster", ctx: inCluster, metadata: map[string]string{"tenant": "acme"},
+			want: map[string]string{"tenant": "acme", "kcpCluster": "root:org:team"}},
+		{name: "caller wins", key: "kcpCluster", ctx: inCluster, metadata: map[string]string{"kcpCluster": "other"},
+			want: map[string]string{"kcpCluster": "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &AWSClient{clusterMetadataKey: tt.key}
+			original := maps.Clone(tt.metadata)
+			if got := c.clientMetadata(tt.ctx, tt.metadata); !maps.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if !maps.Equal(tt.metadata, original) {
+				t.Errorf("expected the caller's metadata to be unchanged, got %v", tt.metadata)
+			}
+		})
+	}
+}
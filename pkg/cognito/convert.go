@@ -0,0 +1,87 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// userRecord holds the user fields that AdminGetUser and ListUsers both
+// return, so both are converted the same way
+type userRecord struct {
+	username   *string
+	enabled    bool
+	status     types.UserStatusType
+	created    *time.Time
+	modified   *time.Time
+	attributes []types.AttributeType
+}
+
+// UserFromAdminGetUser converts an AdminGetUser response to a user, mapping
+// attributes like GetUser
+func (c *AWSClient) UserFromAdminGetUser(output *cognitoidentityprovider.AdminGetUserOutput) *userpool.User {
+	user := &userpool.User{}
+	c.convertUser(user, userRecord{
+		username:   output.Username,
+		enabled:    output.Enabled,
+		status:     output.UserStatus,
+		created:    output.UserCreateDate,
+		modified:   output.UserLastModifiedDate,
+		attributes: output.UserAttributes,
+	})
+	user.PreferredMFA = aws.ToString(output.PreferredMfaSetting)
+	if user.PreferredMFA == "" {
+		user.PreferredMFA = legacyPreferredMFA(output.MFAOptions)
+	}
+	return user
+}
+
+// UserFromUserType converts a user returned by ListUsers or ListUsersInGroup,
+// mapping attributes like ListUsers
+func (c *AWSClient) UserFromUserType(cognitoUser types.UserType) *userpool.User {
+	user := &userpool.User{}
+	c.convertUser(user, recordOf(cognitoUser))
+	return user
+}
+
+// recordOf returns the fields of a listed user
+func recordOf(cognitoUser types.UserType) userRecord {
+	return userRecord{
+		username:   cognitoUser.Username,
+		enabled:    cognitoUser.Enabled,
+		status:     cognitoUser.UserStatus,
+		created:    cognitoUser.UserCreateDate,
+		modified:   cognitoUser.UserLastModifiedDate,
+		attributes: cognitoUser.Attributes,
+	}
+}
+
+// convertUser populates user from record
+func (c *AWSClient) convertUser(user *userpool.User, record userRecord) {
+	user.Username = aws.ToString(record.username)
+	user.Enabled = record.enabled
+	user.Status = mapUserStatus(record.status)
+	user.RawStatus = string(record.status)
+	user.LastModified = aws.ToTime(record.modified)
+	user.CreatedAt = aws.ToTime(record.created)
+	c.fromCognitoAttributes(user, record.attributes)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestAWSClient_UserConversion(t *testing.T) {
+	c := &AWSClient{}
+	WithAttributeMapping(map[string]string{"tenant": "custom:tenant"})(c)
+
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	modified := created.Add(time.Hour)
+	attributes := []types.AttributeType{
+		{Name: aws.String(AttrSub), Value: aws.String("4f6c1a2e")},
+		{Name: aws.String(AttrEmail), Value: aws.String("jane@example.com")},
+		{Name: aws.String(AttrEmailVerified), Value: aws.String("true")},
+		{Name: aws.String("custom:tenant"), Value: aws.String("acme")},
+		{Name: aws.String("custom:team"), Value: aws.String("a")},
+		{Name: aws.String(DisableReasonAttribute), Value: aws.String("offboarded")},
+		{Name: aws.String(AttrPreferredUsername), Value: aws.String("jane.doe")},
+		{Name: aws.String(AttrLocale), Value: aws.String("de-AT")},
+		{Name: aws.String(AttrAddress), Value: aws.String(`{"locality":"Wien","country":"AT"}`)},
+		{Name: aws.String(AttrPicture), Value: aws.String("https://example.com/jane.png")},
+		{Name: aws.String(AttrProfile), Value: aws.String("https://example.com/jane")},
+		{Name: aws.String(AttrNickname), Value: aws.String("Janie")},
+		{Name: aws.String(AttrWebsite), Value: aws.String("https://jane.example.com")},
+		{Name: aws.String(AttrZoneInfo), Value: aws.String("Europe/Vienna")},
+		{Name: aws.String(AttrBirthdate), Value: aws.String("0000-04-23")},
+		{Name: aws.String(AttrGender), Value: aws.String("female")},
+	}
+	want := &userpool.User{
+		Username:          "jane",
+		Email:             "jane@example.com",
+		EmailVerified:     aws.Bool(true),
+		DisableReason:     "offboarded",
+		PreferredUsername: "jane.doe",
+		Locale:            "de-AT",
+		Address:           &userpool.Address{Locality: "Wien", Country: "AT"},
+		Picture:           "https://example.com/jane.png",
+		Profile:           "https://example.com/jane",
+		Nickname:          "Janie",
+		Website:           "https://jane.example.com",
+		ZoneInfo:          "Europe/Vienna",
+		Birthdate:         "0000-04-23",
+		Gender:            "female",
+		Status:            userpool.StatusConfirmed,
+		RawStatus:         "CONFIRMED",
+		Attributes:        map[string]string{"tenant": "acme", "custom:team": "a"},
+		LastModified:      modified,
+		CreatedAt:         created,
+	}
+
+	listed := c.UserFromUserType(types.UserType{
+		Username:             aws.String("jane"),
+		UserStatus:           types.UserStatusTypeConfirmed,
+		UserCreateDate:       aws.Time(created),
+		UserLastModifiedDate: aws.Time(modified),
+		Attributes:           attributes,
+	})
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("expected listed user %+v, got %+v", want, listed)
+	}
+
+	got := c.UserFromAdminGetUser(&cognitoidentityprovider.AdminGetUserOutput{
+		Username:             aws.String("jane"),
+		UserStatus:           types.UserStatusTypeConfirmed,
+		UserCreateDate:       aws.Time(created),
+		UserLastModifiedDate: aws.Time(modified),
+		UserAttributes:       attributes,
+		PreferredMfaSetting:  aws.String(userpool.MFASoftwareToken),
+	})
+	want.PreferredMFA = userpool.MFASoftwareToken
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected user %+v, got %+v", want, got)
+	}
+
+	legacy := c.UserFromAdminGetUser(&cognitoidentityprovider.AdminGetUserOutput{
+		Username:   aws.String("jane"),
+		MFAOptions: []types.MFAOptionType{{DeliveryMedium: types.DeliveryMediumTypeSms}},
+	})
+	if legacy.PreferredMFA != userpool.MFASMS {
+		t.Errorf("expected legacy SMS MFA option to be read as %s, got %q", userpool.MFASMS, legacy.PreferredMFA)
+	}
+}
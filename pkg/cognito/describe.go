@@ -0,0 +1,66 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"github.com/aws/smithy-go"
+	"github.com/go-logr/logr"
+)
+
+// ErrDescribeDenied is returned when the caller lacks the
+// cognito-idp:DescribeUserPool permission. The schema, username and MFA
+// checks relying on the pool description are skipped then.
+var ErrDescribeDenied = errors.New("cognito-idp:DescribeUserPool is not allowed")
+
+// describeUserPool describes the user pool. Once a call was denied, later
+// calls fail with ErrDescribeDenied without calling Cognito, so least
+// privilege deployments don't pay for a rejected call on every reconcile.
+func (c *AWSClient) describeUserPool(ctx context.Context) (*types.UserPoolType, error) {
+	if c.describeDenied.Load() {
+		return nil, ErrDescribeDenied
+	}
+	output, err := c.cognito.DescribeUserPool(ctx, &cognitoidentityprovider.DescribeUserPoolInput{
+		UserPoolId: aws.String(c.userPoolID),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException" {
+			if c.describeDenied.CompareAndSwap(false, true) {
+				logr.FromContextOrDiscard(ctx).Info("DescribeUserPool is not allowed, skipping user pool checks",
+					"userPoolId", c.userPoolID)
+			}
+			return nil, fmt.Errorf("%w: %w", ErrDescribeDenied, err)
+		}
+		return nil, err
+	}
+	if output.UserPool == nil {
+		return nil, fmt.Errorf("empty DescribeUserPool response")
+	}
+	return output.UserPool, nil
+}
+
+// DescribeDenied reports whether DescribeUserPool was found to be denied
+func (c *AWSClient) DescribeDenied() bool {
+	return c.describeDenied.Load()
+}
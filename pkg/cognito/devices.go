@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// devicePageSize is the most devices AdminListDevices returns per page
+const devicePageSize = 60
+
+// ForgetDevices forgets every device remembered for the user with
+// AdminListDevices and AdminForgetDevice
+func (c *AWSClient) ForgetDevices(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	return c.forgetDevices(ctx, username)
+}
+
+// forgetDevices forgets every device remembered for the user, so none of
+// them can skip MFA after the user is enabled again. All devices are listed
+// before the first is forgotten, so the pagination doesn't shift under it.
+func (c *AWSClient) forgetDevices(ctx context.Context, username string) error {
+	var keys []*string
+	var token *string
+	for {
+		output, err := c.cognito.AdminListDevices(ctx, &cognitoidentityprovider.AdminListDevicesInput{
+			UserPoolId:      aws.String(c.userPoolID),
+			Username:        aws.String(username),
+			Limit:           aws.Int32(devicePageSize),
+			PaginationToken: token,
+		})
+		if err != nil {
+			var notFound *types.UserNotFoundException
+			if errors.As(err, &notFound) {
+				return fmt.Errorf("failed to list devices of user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+			}
+			return fmt.Errorf("failed to list devices of user %s: %w", c.pii(username), err)
+		}
+		for _, device := range output.Devices {
+			keys = append(keys, device.DeviceKey)
+		}
+		if output.PaginationToken == nil || *output.PaginationToken == "" {
+			break
+		}
+		token = output.PaginationToken
+	}
+
+	for _, key := range keys {
+		if _, err := c.cognito.AdminForgetDevice(ctx, &cognitoidentityprovider.AdminForgetDeviceInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+			DeviceKey:  key,
+		}); err != nil {
+			return fmt.Errorf("failed to forget device of user %s: %w", c.pii(username), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+var _ userpool.GroupClient = &AWSClient{}
+
+// groupCacheTTL is how long the list of user pool groups is reused before it
+// is fetched again
+const groupCacheTTL = 5 * time.Minute
+
+// groupCache holds the names of the groups defined in the user pool
+type groupCache struct {
+	mu      sync.Mutex
+	names   map[string]bool
+	fetched time.Time
+}
+
+// UpdateGroups adds the user to the add groups and removes it from the remove
+// groups. The add groups are checked against the pool's groups before any
+// membership is changed, so a missing group leaves the user untouched.
+func (c *AWSClient) UpdateGroups(ctx context.Context, username string, add, remove []string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	if err := c.checkGroupsExist(ctx, add); err != nil {
+		return err
+	}
+
+	for _, group := range add {
+		_, err := c.cognito.AdminAddUserToGroup(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+			GroupName:  aws.String(group),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add user %s to group %s: %w", c.pii(username), group, err)
+		}
+	}
+
+	for _, group := range remove {
+		_, err := c.cognito.AdminRemoveUserFromGroup(ctx, &cognitoidentityprovider.AdminRemoveUserFromGroupInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+			GroupName:  aws.String(group),
+		})
+		var notFound *types.ResourceNotFoundException
+		if err != nil && !errors.As(err, &notFound) {
+			return fmt.Errorf("failed to remove user %s from group %s: %w", c.pii(username), group, err)
+		}
+	}
+
+	return nil
+}
+
+// ListGroupsForUser returns the sorted names of the groups the user belongs
+// to, paging through AdminListGroupsForUser
+func (c *AWSClient) ListGroupsForUser(ctx context.Context, username string) ([]string, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username cannot be empty")
+	}
+
+	var groups []string
+	var nextToken *string
+	for {
+		output, err := c.cognito.AdminListGroupsForUser(ctx, &cognitoidentityprovider.AdminListGroupsForUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(username),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			var notFound *types.UserNotFoundException
+			if errors.As(err, &notFound) {
+				return nil, fmt.Errorf("failed to list groups for user %s: %w", c.pii(username),
+					userpool.ErrUserNotFound)
+			}
+			return nil, fmt.Errorf("failed to list groups for user %s: %w", c.pii(username), err)
+		}
+
+		for _, group := range output.Groups {
+			if group.GroupName != nil {
+				groups = append(groups, *group.GroupName)
+			}
+		}
+
+		nextToken = output.NextToken
+		if nextToken == nil {
+			break
+		}
+	}
+
+	slices.Sort(groups)
+	return groups, nil
+}
+
+// checkGroupsExist returns a *userpool.MissingGroupsError if any of groups is
+// not defined in the user pool. A cached group list is refreshed once before
+// groups are reported missing, so newly created groups are found.
+func (c *AWSClient) checkGroupsExist(ctx context.Context, groups []string) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	c.groups.mu.Lock()
+	defer c.groups.mu.Unlock()
+
+	fresh := false
+	if c.groups.names == nil || userpool.Now(c.clock).Sub(c.groups.fetched) > groupCacheTTL {
+		if err := c.refreshGroups(ctx); err != nil {
+			return err
+		}
+		fresh = true
+	}
+
+	missing := c.missingGroups(groups)
+	if len(missing) > 0 && !fresh {
+		if err := c.refreshGroups(ctx); err != nil {
+			return err
+		}
+		missing = c.missingGroups(groups)
+	}
+	if len(missing) > 0 {
+		return &userpool.MissingGroupsError{Groups: missing}
+	}
+	return nil
+}
+
+// missingGroups returns the sorted groups not in the cache. The caller must
+// hold c.groups.mu.
+func (c *AWSClient) missingGroups(groups []string) []string {
+	var missing []string
+	for _, group := range groups {
+		if !c.groups.names[group] {
+			missing = append(missing, group)
+		}
+	}
+	slices.Sort(missing)
+	return slices.Compact(missing)
+}
+
+// refreshGroups fetches all group names of the user pool into the cache. The
+// caller must hold c.groups.mu.
+func (c *AWSClient) refreshGroups(ctx context.Context) error {
+	names := make(map[string]bool)
+	var nextToken *string
+
+	for {
+		output, err := c.cognito.ListGroups(ctx, &cognitoidentityprovider.ListGroupsInput{
+			UserPoolId: aws.String(c.userPoolID),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list groups: %w", err)
+		}
+
+		for _, group := range output.Groups {
+			if group.GroupName != nil {
+				names[*group.GroupName] = true
+			}
+		}
+
+		nextToken = output.NextToken
+		if nextToken == nil {
+			break
+		}
+	}
+
+	c.groups.names = names
+	c.groups.fetched = userpool.Now(c.clock)
+	return nil
+}
+
+// GetGroup returns a group of the user pool
+func (c *AWSClient) GetGroup(ctx context.Context, name string) (*userpool.Group, error) {
+	if name == "" {
+		return nil, fmt.Errorf("group name cannot be empty")
+	}
+
+	output, err := c.cognito.GetGroup(ctx, &cognitoidentityprovider.GetGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		GroupName:  aws.String(name),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to get group %s: %w", name, userpool.ErrGroupNotFound)
+		}
+		return nil, fmt.Errorf("failed to get group %s: %w", name, err)
+	}
+	if output.Group == nil {
+		return nil, fmt.Errorf("failed to get group %s: empty GetGroup response", name)
+	}
+
+	return &userpool.Group{
+		Name:        aws.ToString(output.Group.GroupName),
+		Description: aws.ToString(output.Group.Description),
+		Precedence:  output.Group.Precedence,
+		RoleARN:     aws.ToString(output.Group.RoleArn),
+	}, nil
+}
+
+// CreateGroup creates a group in the user pool and adds it to the group
+// cache, so users can be added to it right away
+func (c *AWSClient) CreateGroup(ctx context.Context, group *userpool.Group) error {
+	if group == nil || group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+
+	_, err := c.cognito.CreateGroup(ctx, &cognitoidentityprovider.CreateGroupInput{
+		UserPoolId:  aws.String(c.userPoolID),
+		GroupName:   aws.String(group.Name),
+		Description: optionalString(group.Description),
+		Precedence:  group.Precedence,
+		RoleArn:     optionalString(group.RoleARN),
+	})
+	if err != nil {
+		var exists *types.GroupExistsException
+		if errors.As(err, &exists) {
+			return fmt.Errorf("failed to create group %s: %w", group.Name, userpool.ErrGroupExists)
+		}
+		return fmt.Errorf("failed to create group %s: %w", group.Name, err)
+	}
+
+	c.groups.mu.Lock()
+	if c.groups.names != nil {
+		c.groups.names[group.Name] = true
+	}
+	c.groups.mu.Unlock()
+	return nil
+}
+
+// UpdateGroup replaces the description, precedence and role of a group. An
+// empty description or role is left unchanged, since Cognito cannot clear
+// them.
+func (c *AWSClient) UpdateGroup(ctx context.Context, group *userpool.Group) error {
+	if group == nil || group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+
+	_, err := c.cognito.UpdateGroup(ctx, &cognitoidentityprovider.UpdateGroupInput{
+		UserPoolId:  aws.String(c.userPoolID),
+		GroupName:   aws.String(group.Name),
+		Description: optionalString(group.Description),
+		Precedence:  group.Precedence,
+		RoleArn:     optionalString(group.RoleARN),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to update group %s: %w", group.Name, userpool.ErrGroupNotFound)
+		}
+		return fmt.Errorf("failed to update group %s: %w", group.Name, err)
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group from the user pool and the group cache
+func (c *AWSClient) DeleteGroup(ctx context.Context, name string) error {
+	if name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+
+	_, err := c.cognito.DeleteGroup(ctx, &cognitoidentityprovider.DeleteGroupInput{
+		UserPoolId: aws.String(c.userPoolID),
+		GroupName:  aws.String(name),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to delete group %s: %w", name, userpool.ErrGroupNotFound)
+		}
+		return fmt.Errorf("failed to delete group %s: %w", name, err)
+	}
+
+	c.groups.mu.Lock()
+	delete(c.groups.names, name)
+	c.groups.mu.Unlock()
+	return nil
+}
+
+// optionalString returns nil for an empty value, so the field is omitted
+// from the request
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// OperationHook receives the duration and result of every Cognito API call
+// the client makes, e.g. to record custom metrics or traces. op is the API
+// operation name such as "AdminGetUser". The duration includes SDK retries.
+// OnOperation is called synchronously and must return quickly.
+type OperationHook interface {
+	OnOperation(op string, dur time.Duration, err error)
+}
+
+// OperationHookFunc adapts a function to an OperationHook
+type OperationHookFunc func(op string, dur time.Duration, err error)
+
+// OnOperation calls f
+func (f OperationHookFunc) OnOperation(op string, dur time.Duration, err error) {
+	f(op, dur, err)
+}
+
+// WithOperationHook calls hook after every Cognito API call. It can be given
+// several times; the hooks are called in order.
+func WithOperationHook(hook OperationHook) Option {
+	return func(c *AWSClient) {
+		c.hooks = append(c.hooks, hook)
+	}
+}
+
+// withOperationHooks returns the SDK client option installing a middleware
+// that reports every operation to hooks
+func withOperationHooks(hooks []OperationHook) func(*cognitoidentityprovider.Options) {
+	timer := middleware.InitializeMiddlewareFunc("OperationHooks", func(ctx context.Context,
+		in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput,
+		middleware.Metadata, error) {
+		start := time.Now()
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		dur := time.Since(start)
+		op := middleware.GetOperationName(ctx)
+		for _, hook := range hooks {
+			hook.OnOperation(op, dur, err)
+		}
+		return out, metadata, err
+	})
+	return func(o *cognitoidentityprovider.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			// Before all other initialize middleware, so the time includes
+			// retries
+			return stack.Initialize.Add(timer, middleware.Before)
+		})
+	}
+}
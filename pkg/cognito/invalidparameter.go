@@ -0,0 +1,88 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// attributeIndexPattern finds the 1-based position of the rejected attribute
+// in validation messages such as "Value at 'userAttributes.2.member.value'
+// failed to satisfy constraint"
+var attributeIndexPattern = regexp.MustCompile(`[uU]serAttributes\.(\d+)\.member`)
+
+// attributeDescriptions are the phrases Cognito uses for standard attributes
+// in messages like "Invalid phone number format."
+var attributeDescriptions = map[string]string{
+	"email address": AttrEmail,
+	"phone number":  AttrPhoneNumber,
+}
+
+// invalidParameter converts the InvalidParameterException of a request
+// writing attributes into a *userpool.InvalidParameterError naming the
+// rejected attribute if the message allows. It returns nil for other errors.
+func (c *AWSClient) invalidParameter(err error, attributes []types.AttributeType) error {
+	var invalid *types.InvalidParameterException
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+	message := invalid.ErrorMessage()
+	field := rejectedAttribute(message, attributes)
+	if logical, ok := c.logicalAttributeName(field); ok {
+		field = logical
+	}
+	return &userpool.InvalidParameterError{Field: field, Message: message}
+}
+
+// rejectedAttribute infers which of attributes message complains about, or
+// returns "" if it can't tell
+func rejectedAttribute(message string, attributes []types.AttributeType) string {
+	if m := attributeIndexPattern.FindStringSubmatch(message); m != nil {
+		if i, err := strconv.Atoi(m[1]); err == nil && i >= 1 && i <= len(attributes) {
+			return aws.ToString(attributes[i-1].Name)
+		}
+	}
+
+	// The longest name the message mentions, so email_verified wins over
+	// email
+	names := make(map[string]bool, len(attributes))
+	field := ""
+	for _, attr := range attributes {
+		name := aws.ToString(attr.Name)
+		names[name] = true
+		if len(name) > len(field) && strings.Contains(message, name) {
+			field = name
+		}
+	}
+	if field != "" {
+		return field
+	}
+	lower := strings.ToLower(message)
+	for description, name := range attributeDescriptions {
+		if names[name] && strings.Contains(lower, description) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// LegacyMFA selects whether SMS MFA is set with the legacy
+// AdminSetUserSettings API instead of AdminSetUserMFAPreference
+type LegacyMFA string
+
+const (
+	// LegacyMFAAuto uses AdminSetUserMFAPreference and switches to
+	// AdminSetUserSettings for good once the pool rejects the preference
+	LegacyMFAAuto LegacyMFA = "Auto"
+	// LegacyMFAAlways sets SMS MFA with AdminSetUserSettings, for pools
+	// still using the MFA options model
+	LegacyMFAAlways LegacyMFA = "Always"
+	// LegacyMFANever only uses AdminSetUserMFAPreference
+	LegacyMFANever LegacyMFA = "Never"
+)
+
+// ParseLegacyMFA parses a LegacyMFA name
+func ParseLegacyMFA(s string) (LegacyMFA, error) {
+	switch mode := LegacyMFA(s); mode {
+	case LegacyMFAAuto, LegacyMFAAlways, LegacyMFANever:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid legacy MFA mode %q, expected %s, %s or %s", s,
+			LegacyMFAAuto, LegacyMFAAlways, LegacyMFANever)
+	}
+}
+
+// MFAOption is a legacy MFA setting of a user, e.g. SMS codes sent to the
+// phone_number attribute
+type MFAOption struct {
+	// DeliveryMedium is SMS, the only medium the legacy API supports
+	DeliveryMedium types.DeliveryMediumType
+	// AttributeName is the attribute the codes are sent to, phone_number
+	AttributeName string
+}
+
+// smsMFAOption is the legacy MFA option turning SMS MFA on
+var smsMFAOption = MFAOption{DeliveryMedium: types.DeliveryMediumTypeSms, AttributeName: AttrPhoneNumber}
+
+// SetUserSettings replaces the legacy MFA options of a user with
+// AdminSetUserSettings. No options turn SMS MFA off. It is only needed for
+// pools not yet using MFA preferences, SetPreferredMFA picks it as
+// configured with WithLegacyMFA.
+func (c *AWSClient) SetUserSettings(ctx context.Context, username string, mfaOptions []MFAOption) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	options := make([]types.MFAOptionType, 0, len(mfaOptions))
+	for _, option := range mfaOptions {
+		options = append(options, types.MFAOptionType{
+			DeliveryMedium: option.DeliveryMedium,
+			AttributeName:  aws.String(option.AttributeName),
+		})
+	}
+
+	_, err := c.cognito.AdminSetUserSettings(ctx, &cognitoidentityprovider.AdminSetUserSettingsInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		MFAOptions: options,
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to set MFA options of user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to set MFA options of user %s: %w", c.pii(username), err)
+	}
+	return nil
+}
+
+// useLegacySMSMFA reports whether SMS MFA is set with SetUserSettings
+func (c *AWSClient) useLegacySMSMFA() bool {
+	switch c.legacyMFA {
+	case LegacyMFAAlways:
+		return true
+	case LegacyMFANever:
+		return false
+	default:
+		return c.legacyMFADetected.Load()
+	}
+}
+
+// legacyMFAFallback reports whether a failed SMS preference should be retried
+// with SetUserSettings, and remembers the pool as legacy if so
+func (c *AWSClient) legacyMFAFallback(err error) bool {
+	if c.legacyMFA != "" && c.legacyMFA != LegacyMFAAuto {
+		return false
+	}
+	var invalid *types.InvalidParameterException
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	c.legacyMFADetected.Store(true)
+	return true
+}
+
+// legacyPreferredMFA returns the preferred MFA method of a user of a legacy
+// pool, which reports MFA options instead of a preference
+func legacyPreferredMFA(options []types.MFAOptionType) string {
+	for _, option := range options {
+		if option.DeliveryMedium == types.DeliveryMediumTypeSms {
+			return userpool.MFASMS
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/smithy-go/middleware"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// RequestLimits bound the Cognito calls of one AWSClient. A client talks to a
+// single user pool, so with a client per pool every pool's quota is
+// respected independently and a busy pool doesn't slow down the others.
+// Limits apply per operation; SDK retries of an operation don't count again.
+type RequestLimits struct {
+	// MaxInFlight is the most calls in flight at once. Zero means no limit.
+	MaxInFlight int
+
+	// QPS is the sustained number of calls per second, Burst the number of
+	// calls that may be made at once after a quiet period. Zero QPS means no
+	// limit; a Burst below one is raised to one.
+	QPS   float32
+	Burst int
+
+	// OnWait, if set, is called with the time every call waited for the
+	// limits, e.g. to record metrics
+	OnWait func(op string, wait time.Duration)
+}
+
+// WithRequestLimits bounds the calls the client makes to its user pool. The
+// time a call waits is not part of the duration reported to the
+// OperationHooks.
+func WithRequestLimits(limits RequestLimits) Option {
+	return func(c *AWSClient) {
+		if limits.MaxInFlight <= 0 && limits.QPS <= 0 {
+			c.limits = nil
+			return
+		}
+		c.limits = &limits
+	}
+}
+
+// withRequestLimits returns the SDK client option installing a middleware
+// that makes every call wait for limits
+func withRequestLimits(limits RequestLimits) func(*cognitoidentityprovider.Options) {
+	var slots chan struct{}
+	if limits.MaxInFlight > 0 {
+		slots = make(chan struct{}, limits.MaxInFlight)
+	}
+	var bucket flowcontrol.RateLimiter
+	if limits.QPS > 0 {
+		bucket = flowcontrol.NewTokenBucketRateLimiter(limits.QPS, max(limits.Burst, 1))
+	}
+
+	limiter := middleware.InitializeMiddlewareFunc("RequestLimits", func(ctx context.Context,
+		in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput,
+		middleware.Metadata, error) {
+		start := time.Now()
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return middleware.InitializeOutput{}, middleware.Metadata{}, ctx.Err()
+			}
+		}
+		if bucket != nil {
+			if err := bucket.Wait(ctx); err != nil {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, err
+			}
+		}
+		if limits.OnWait != nil {
+			limits.OnWait(middleware.GetOperationName(ctx), time.Since(start))
+		}
+		return next.HandleInitialize(ctx, in)
+	})
+	return func(o *cognitoidentityprovider.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			// Added last and before all other initialize middleware, so the
+			// wait is not measured by the operation hooks
+			return stack.Initialize.Add(limiter, middleware.Before)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// mfaCacheTTL is how long the pool's MFA configuration is reused before it is
+// read again, so turning MFA on in the pool is picked up without a restart
+const mfaCacheTTL = 5 * time.Minute
+
+// mfaCache holds the MFA configuration of the user pool
+type mfaCache struct {
+	mu      sync.Mutex
+	config  types.UserPoolMfaType
+	fetched time.Time
+}
+
+// set stores the configuration read from the pool at now
+func (m *mfaCache) set(config types.UserPoolMfaType, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+	m.fetched = now
+}
+
+// MFAConfiguration returns the pool's MFA configuration: OFF, ON or OPTIONAL.
+// It is loaded by ValidateAttributeMapping at startup and read again after
+// five minutes.
+func (c *AWSClient) MFAConfiguration(ctx context.Context) (types.UserPoolMfaType, error) {
+	c.mfa.mu.Lock()
+	defer c.mfa.mu.Unlock()
+
+	now := userpool.Now(c.clock)
+	if !c.mfa.fetched.IsZero() && now.Sub(c.mfa.fetched) <= mfaCacheTTL {
+		return c.mfa.config, nil
+	}
+
+	pool, err := c.describeUserPool(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA configuration: %w", err)
+	}
+	c.mfa.config = pool.MfaConfiguration
+	c.mfa.fetched = now
+	return c.mfa.config, nil
+}
+
+// SetPreferredMFA makes method the user's preferred MFA method. It fails with
+// userpool.ErrMFADisabled without calling Cognito when the pool has MFA off.
+// If the MFA configuration can't be read because DescribeUserPool is denied,
+// the preference is set anyway and Cognito decides.
+func (c *AWSClient) SetPreferredMFA(ctx context.Context, username, method string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	input := &cognitoidentityprovider.AdminSetUserMFAPreferenceInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+	}
+	switch method {
+	case userpool.MFASoftwareToken:
+		input.SoftwareTokenMfaSettings = &types.SoftwareTokenMfaSettingsType{Enabled: true, PreferredMfa: true}
+	case userpool.MFASMS:
+		input.SMSMfaSettings = &types.SMSMfaSettingsType{Enabled: true, PreferredMfa: true}
+	default:
+		return fmt.Errorf("unsupported MFA method %q, expected %s or %s", method,
+			userpool.MFASoftwareToken, userpool.MFASMS)
+	}
+
+	config, err := c.MFAConfiguration(ctx)
+	if err != nil && !errors.Is(err, ErrDescribeDenied) {
+		return fmt.Errorf("failed to set MFA preference of user %s: %w", c.pii(username), err)
+	}
+	if config == types.UserPoolMfaTypeOff {
+		return fmt.Errorf("failed to set MFA preference of user %s: %w", c.pii(username), userpool.ErrMFADisabled)
+	}
+
+	if method == userpool.MFASMS && c.useLegacySMSMFA() {
+		return c.SetUserSettings(ctx, username, []MFAOption{smsMFAOption})
+	}
+	if _, err := c.cognito.AdminSetUserMFAPreference(ctx, input); err != nil {
+		if method == userpool.MFASMS && c.legacyMFAFallback(err) {
+			return c.SetUserSettings(ctx, username, []MFAOption{smsMFAOption})
+		}
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to set MFA preference of user %s: %w", c.pii(username),
+				userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to set MFA preference of user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+)
+
+// migrateSkippedAttributes are attributes Cognito manages itself and that
+// cannot be supplied to AdminCreateUser
+var migrateSkippedAttributes = map[string]bool{
+	AttrSub:        true,
+	AttrIdentities: true,
+}
+
+// MigrateOption configures a MigrateUser call
+type MigrateOption func(*migrateOptions)
+
+type migrateOptions struct {
+	copyGroups bool
+}
+
+// WithGroupMemberships makes MigrateUser copy the old user's group memberships
+// to the new user
+func WithGroupMemberships() MigrateOption {
+	return func(o *migrateOptions) {
+		o.copyGroups = true
+	}
+}
+
+// MigrateUser moves a user to a new username. Cognito does not support renaming
+// a username, so the user is recreated under newUsername with the same
+// attributes and enabled state, and the old user is deleted afterwards.
+//
+// The new user always receives a new sub: Cognito generates it on creation and
+// it cannot be set or copied. Anything keyed by the old sub (tokens, external
+// references) must be updated by the caller. Passwords, MFA settings and
+// remembered devices are not migrated either.
+//
+// The new user is created while the old one still exists, so on pools using
+// email or phone number as an alias the alias is always moved to the new user.
+// If any step after creating the new user fails, the new user is deleted again
+// and the old user's verified email and phone number are written back so that
+// it holds its aliases again.
+func (c *AWSClient) MigrateUser(ctx context.Context, oldUsername, newUsername string, opts ...MigrateOption) error {
+	if oldUsername == "" || newUsername == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if oldUsername == newUsername {
+		return fmt.Errorf("new username must differ from old username %s", c.pii(oldUsername))
+	}
+
+	var options migrateOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	output, err := c.cognito.AdminGetUser(ctx, &cognitoidentityprovider.AdminGetUserInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(oldUsername),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get user %s: %w", c.pii(oldUsername), err)
+	}
+
+	var groups []string
+	if options.copyGroups {
+		groups, err = c.ListGroupsForUser(ctx, oldUsername)
+		if err != nil {
+			return err
+		}
+	}
+
+	attributes := make([]types.AttributeType, 0, len(output.UserAttributes))
+	for _, attr := range output.UserAttributes {
+		if attr.Name == nil || migrateSkippedAttributes[*attr.Name] {
+			continue
+		}
+		attributes = append(attributes, attr)
+	}
+
+	_, err = c.cognito.AdminCreateUser(ctx, &cognitoidentityprovider.AdminCreateUserInput{
+		UserPoolId:     aws.String(c.userPoolID),
+		Username:       aws.String(newUsername),
+		UserAttributes: attributes,
+		MessageAction:  types.MessageActionTypeSuppress,
+		// The old user still holds the aliases at this point
+		ForceAliasCreation: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user %s: %w", c.pii(newUsername), err)
+	}
+
+	if err := c.completeMigration(ctx, oldUsername, newUsername, output.Enabled, groups); err != nil {
+		if rbErr := c.rollbackMigration(ctx, oldUsername, newUsername, output.UserAttributes); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback failed: %w", rbErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// rollbackMigration deletes the new user and gives the old user back the
+// aliases the new user took over
+func (c *AWSClient) rollbackMigration(ctx context.Context, oldUsername, newUsername string,
+	attributes []types.AttributeType) error {
+	if err := c.DeleteUser(ctx, newUsername); err != nil {
+		return err
+	}
+
+	var verified []types.AttributeType
+	for _, attr := range attributes {
+		if attr.Name == nil || aws.ToString(attr.Value) != "true" {
+			continue
+		}
+		if *attr.Name == AttrEmailVerified || *attr.Name == AttrPhoneNumberVerified {
+			verified = append(verified, attr)
+		}
+	}
+	if len(verified) == 0 {
+		return nil
+	}
+
+	_, err := c.cognito.AdminUpdateUserAttributes(ctx, &cognitoidentityprovider.AdminUpdateUserAttributesInput{
+		UserPoolId:     aws.String(c.userPoolID),
+		Username:       aws.String(oldUsername),
+		UserAttributes: verified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore aliases of user %s: %w", c.pii(oldUsername), err)
+	}
+	return nil
+}
+
+// completeMigration applies the remaining state to the new user and deletes
+// the old one
+func (c *AWSClient) completeMigration(ctx context.Context, oldUsername, newUsername string,
+	enabled bool, groups []string) error {
+	if !enabled {
+		_, err := c.cognito.AdminDisableUser(ctx, &cognitoidentityprovider.AdminDisableUserInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(newUsername),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to disable user %s: %w", c.pii(newUsername), err)
+		}
+	}
+
+	for _, group := range groups {
+		_, err := c.cognito.AdminAddUserToGroup(ctx, &cognitoidentityprovider.AdminAddUserToGroupInput{
+			UserPoolId: aws.String(c.userPoolID),
+			Username:   aws.String(newUsername),
+			GroupName:  aws.String(group),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add user %s to group %s: %w", c.pii(newUsername), group, err)
+		}
+	}
+
+	if err := c.DeleteUser(ctx, oldUsername); err != nil {
+		return err
+	}
+
+	return nil
+}
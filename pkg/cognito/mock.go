@@ -19,6 +19,14 @@ package cognito
 import (
 	"context"
 	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 
 	"piotrjanik.dev/users/pkg/userpool"
 )
@@ -26,13 +34,236 @@ import (
 // MockClient implements the userpool.Client interface for testing
 type MockClient struct {
 	users map[string]*userpool.User
+	// groups maps group names to the usernames of their members
+	groups map[string]map[string]bool
+	// groupDefs holds the definition of every group in groups
+	groupDefs map[string]*userpool.Group
+	// emailAlias makes emails unique like in pools that use email as alias
+	emailAlias bool
+	// preferredUsernameAlias makes preferred usernames unique like in pools
+	// that use preferred_username as alias
+	preferredUsernameAlias bool
+	// mfaDisabled makes the mock behave like a pool with MFA turned off
+	mfaDisabled bool
+	// clock sets LastModified, the system time if nil
+	clock userpool.Clock
+	// signOuts counts the SignOutUser calls per username
+	signOuts map[string]int
+
+	// deviceForgets counts the ForgetDevices calls per username
+	deviceForgets map[string]int
+	// pageSize is the number of users per page of the list calls,
+	// mockPageSize if zero
+	pageSize int
+	// pageHook is called before every page of the list calls is served
+	pageHook func(cursor string)
 }
 
 // NewMockClient creates a new mock client for testing
 func NewMockClient() *MockClient {
 	return &MockClient{
-		users: make(map[string]*userpool.User),
+		users:         make(map[string]*userpool.User),
+		groups:        make(map[string]map[string]bool),
+		groupDefs:     make(map[string]*userpool.Group),
+		signOuts:      make(map[string]int),
+		deviceForgets: make(map[string]int),
+	}
+}
+
+// SetEmailAlias makes the mock behave like a pool that uses email as an
+// alias, where no two users can have the same email and usernames can't be
+// in email format
+func (m *MockClient) SetEmailAlias(enabled bool) {
+	m.emailAlias = enabled
+}
+
+// SetPreferredUsernameAlias makes the mock behave like a pool that uses
+// preferred_username as an alias, where no two users can have the same
+// preferred username
+func (m *MockClient) SetPreferredUsernameAlias(enabled bool) {
+	m.preferredUsernameAlias = enabled
+}
+
+// SetMFADisabled makes the mock behave like a pool with MFA turned off
+func (m *MockClient) SetMFADisabled(disabled bool) {
+	m.mfaDisabled = disabled
+}
+
+// SetClock makes the mock take LastModified timestamps from clock
+func (m *MockClient) SetClock(clock userpool.Clock) {
+	m.clock = clock
+}
+
+// SetPageSize sets the number of users per page. All list calls page through
+// the store like Cognito does, so a small size makes tests cover their
+// pagination. Zero restores the default of 60.
+func (m *MockClient) SetPageSize(size int) {
+	m.pageSize = size
+}
+
+// SetPageHook sets a function called with the cursor of every page before it
+// is served, e.g. to cancel the context in the middle of a listing. The
+// cursor of the first page is empty.
+func (m *MockClient) SetPageHook(hook func(cursor string)) {
+	m.pageHook = hook
+}
+
+// maxAttributeValueLength is the longest attribute value Cognito accepts
+const maxAttributeValueLength = 2048
+
+// checkAttributeValues rejects invalid locales, addresses and URLs and
+// attribute values Cognito rejects for their length, with the errors the AWS
+// client returns for them
+func checkAttributeValues(user *userpool.User) error {
+	if err := validateStandardAttributes(user); err != nil {
+		return fmt.Errorf("failed to write user %s: %w", user.Username, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(user.Attributes)) {
+		if len(user.Attributes[name]) > maxAttributeValueLength {
+			return fmt.Errorf("failed to write user %s: %w", user.Username, &userpool.InvalidParameterError{
+				Field:   name,
+				Message: fmt.Sprintf("Member must have length less than or equal to %d", maxAttributeValueLength),
+			})
+		}
+	}
+	return nil
+}
+
+// checkAlias returns ErrAliasExists if email is used by a user other than
+// username and emails are aliases
+func (m *MockClient) checkAlias(username, email string) error {
+	if !m.emailAlias || email == "" {
+		return nil
+	}
+	for _, other := range m.users {
+		if other.Username != username && other.Email == email {
+			return fmt.Errorf("email %s: %w", email, userpool.ErrAliasExists)
+		}
+	}
+	return nil
+}
+
+// checkPreferredUsername fails with userpool.ErrAliasExists if preferred
+// usernames are aliases and another user has the one of user
+func (m *MockClient) checkPreferredUsername(user *userpool.User) error {
+	if !m.preferredUsernameAlias || user.PreferredUsername == "" {
+		return nil
+	}
+	for _, other := range m.users {
+		if other.Username != user.Username && other.PreferredUsername == user.PreferredUsername {
+			return fmt.Errorf("preferred username %s: %w", user.PreferredUsername, userpool.ErrAliasExists)
+		}
+	}
+	return nil
+}
+
+// AddGroup defines a group in the mock store. Groups must exist before users
+// can be added to them.
+func (m *MockClient) AddGroup(name string) {
+	if _, exists := m.groups[name]; !exists {
+		m.groups[name] = make(map[string]bool)
+		m.groupDefs[name] = &userpool.Group{Name: name}
+	}
+}
+
+var _ userpool.GroupClient = &MockClient{}
+
+// GetGroup returns a group from the mock store
+func (m *MockClient) GetGroup(ctx context.Context, name string) (*userpool.Group, error) {
+	group, exists := m.groupDefs[name]
+	if !exists {
+		return nil, fmt.Errorf("group %s: %w", name, userpool.ErrGroupNotFound)
+	}
+	return copyGroup(group), nil
+}
+
+// CreateGroup defines a group in the mock store
+func (m *MockClient) CreateGroup(ctx context.Context, group *userpool.Group) error {
+	if group == nil || group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+	if _, exists := m.groupDefs[group.Name]; exists {
+		return fmt.Errorf("group %s: %w", group.Name, userpool.ErrGroupExists)
+	}
+	m.groups[group.Name] = make(map[string]bool)
+	m.groupDefs[group.Name] = copyGroup(group)
+	return nil
+}
+
+// UpdateGroup replaces a group definition in the mock store. Like Cognito,
+// an empty description or role keeps the stored one.
+func (m *MockClient) UpdateGroup(ctx context.Context, group *userpool.Group) error {
+	if group == nil || group.Name == "" {
+		return fmt.Errorf("group name cannot be empty")
+	}
+	existing, exists := m.groupDefs[group.Name]
+	if !exists {
+		return fmt.Errorf("group %s: %w", group.Name, userpool.ErrGroupNotFound)
+	}
+	updated := copyGroup(group)
+	if updated.Description == "" {
+		updated.Description = existing.Description
+	}
+	if updated.RoleARN == "" {
+		updated.RoleARN = existing.RoleARN
+	}
+	m.groupDefs[group.Name] = updated
+	return nil
+}
+
+// DeleteGroup removes a group and its memberships from the mock store
+func (m *MockClient) DeleteGroup(ctx context.Context, name string) error {
+	if _, exists := m.groupDefs[name]; !exists {
+		return fmt.Errorf("group %s: %w", name, userpool.ErrGroupNotFound)
+	}
+	delete(m.groups, name)
+	delete(m.groupDefs, name)
+	return nil
+}
+
+// copyGroup returns a deep copy of group
+func copyGroup(group *userpool.Group) *userpool.Group {
+	out := *group
+	if group.Precedence != nil {
+		precedence := *group.Precedence
+		out.Precedence = &precedence
+	}
+	return &out
+}
+
+// GroupMembers returns the sorted usernames of the members of a group
+func (m *MockClient) GroupMembers(name string) []string {
+	return slices.Sorted(maps.Keys(m.groups[name]))
+}
+
+// ExportUsers writes the users of the mock store like AWSClient.ExportUsers
+func (m *MockClient) ExportUsers(ctx context.Context, w io.Writer) (int, error) {
+	return exportUsers(ctx, m, m.ListGroupsForUser, w)
+}
+
+// ImportFromBackup restores users into the mock store like
+// AWSClient.ImportFromBackup
+func (m *MockClient) ImportFromBackup(ctx context.Context, r io.Reader) (*userpool.BulkResult, error) {
+	return importFromBackup(ctx, m, r)
+}
+
+// ListGroupsForUser returns the sorted names of the groups a user belongs to
+func (m *MockClient) ListGroupsForUser(ctx context.Context, username string) ([]string, error) {
+	if username == "" {
+		return nil, fmt.Errorf("username cannot be empty")
 	}
+	if _, exists := m.users[username]; !exists {
+		return nil, fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+
+	var groups []string
+	for name, members := range m.groups {
+		if members[username] {
+			groups = append(groups, name)
+		}
+	}
+	slices.Sort(groups)
+	return groups, nil
 }
 
 // CreateUser creates a new user in the mock store
@@ -41,20 +272,45 @@ func (m *MockClient) CreateUser(ctx context.Context, user *userpool.User) error
 		return fmt.Errorf("user cannot be nil")
 	}
 	if user.Username == "" {
-		return fmt.Errorf("username cannot be empty")
+		if user.Email == "" {
+			return fmt.Errorf("username or email must be set")
+		}
+		user.Username = uuid.NewString()
 	}
 
 	// Check if user already exists
 	if _, exists := m.users[user.Username]; exists {
-		return fmt.Errorf("user %s already exists", user.Username)
+		return fmt.Errorf("user %s: %w", user.Username, userpool.ErrUserExists)
+	}
+	if err := m.checkAlias(user.Username, user.Email); err != nil {
+		return err
+	}
+	usernames := userpool.UsernameConfiguration{EmailAlias: m.emailAlias}
+	if err := usernames.ValidateUsername(user.Username, user.Email); err != nil {
+		return fmt.Errorf("user %s: %w", user.Username, err)
+	}
+	if err := m.checkPreferredUsername(user); err != nil {
+		return err
+	}
+	if err := checkAttributeValues(user); err != nil {
+		return err
 	}
 
 	// Create a copy to avoid reference issues
-	m.users[user.Username] = &userpool.User{
-		Username: user.Username,
-		Email:    user.Email,
-		Enabled:  user.Enabled,
+	created := copyUser(user)
+	created.ClientMetadata = nil
+	created.SendInvitation = false
+	if created.Enabled {
+		created.DisableReason = ""
 	}
+	if created.Status == "" {
+		// Users created by an administrator start with a temporary password
+		created.Status = userpool.StatusForceChangePassword
+		created.RawStatus = "FORCE_CHANGE_PASSWORD"
+	}
+	created.LastModified = userpool.Now(m.clock)
+	created.CreatedAt = created.LastModified
+	m.users[user.Username] = created
 
 	return nil
 }
@@ -67,15 +323,24 @@ func (m *MockClient) GetUser(ctx context.Context, username string) (*userpool.Us
 
 	user, exists := m.users[username]
 	if !exists {
-		return nil, fmt.Errorf("user %s not found", username)
+		return nil, fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
 	}
 
 	// Return a copy to avoid reference issues
-	return &userpool.User{
-		Username: user.Username,
-		Email:    user.Email,
-		Enabled:  user.Enabled,
-	}, nil
+	return copyUser(user), nil
+}
+
+// GetUserStatus returns the status and enabled state from the mock store
+func (m *MockClient) GetUserStatus(ctx context.Context, username string) (userpool.Status, bool, error) {
+	if username == "" {
+		return "", false, fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return "", false, fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	return user.Status, user.Enabled, nil
 }
 
 // UpdateUser updates an existing user in the mock store
@@ -89,19 +354,94 @@ func (m *MockClient) UpdateUser(ctx context.Context, user *userpool.User) error
 
 	// Check if user exists
 	if _, exists := m.users[user.Username]; !exists {
-		return fmt.Errorf("user %s not found", user.Username)
+		return fmt.Errorf("user %s: %w", user.Username, userpool.ErrUserNotFound)
 	}
 
-	// Update the user
-	m.users[user.Username] = &userpool.User{
-		Username: user.Username,
-		Email:    user.Email,
-		Enabled:  user.Enabled,
+	if err := m.checkAlias(user.Username, user.Email); err != nil {
+		return err
+	}
+	if err := m.checkPreferredUsername(user); err != nil {
+		return err
+	}
+	if err := checkAttributeValues(user); err != nil {
+		return err
 	}
 
+	// Update the user, keeping the fields which are not writable
+	existing := m.users[user.Username]
+	updated := copyUser(user)
+	updated.Status = existing.Status
+	updated.RawStatus = existing.RawStatus
+	updated.Identities = existing.Identities
+	updated.PhoneNumberVerified = existing.PhoneNumberVerified
+	updated.PreferredMFA = existing.PreferredMFA
+	updated.CreatedAt = existing.CreatedAt
+	if updated.SecondaryEmail == "" {
+		updated.SecondaryEmail = existing.SecondaryEmail
+	}
+	for _, name := range stringAttributes {
+		if field := stringAttributeField(updated, name); *field == "" {
+			*field = *stringAttributeField(existing, name)
+		}
+	}
+	if updated.Address == nil {
+		updated.Address = existing.Address
+	}
+	updated.ClientMetadata = nil
+	updated.DeleteAttributes = nil
+	if updated.Enabled {
+		updated.DisableReason = ""
+	}
+	updated.LastModified = userpool.Now(m.clock)
+	m.users[user.Username] = updated
+
 	return nil
 }
 
+// UpdateUserDelta updates a user in the mock store. The mock stores whole
+// users, so the result is the same as UpdateUser.
+func (m *MockClient) UpdateUserDelta(ctx context.Context, current, user *userpool.User) error {
+	return m.UpdateUser(ctx, user)
+}
+
+// UpdateUserIfChanged updates a user in the mock store and reports whether
+// the stored user differs afterwards
+func (m *MockClient) UpdateUserIfChanged(ctx context.Context, current, user *userpool.User) (bool, error) {
+	var before *userpool.User
+	if user != nil {
+		if existing, ok := m.users[user.Username]; ok {
+			before = copyUser(existing)
+		}
+	}
+	if err := m.UpdateUser(ctx, user); err != nil {
+		return false, err
+	}
+	after := m.users[user.Username]
+	after.LastModified = before.LastModified
+	if current != nil && reflect.DeepEqual(before, after) {
+		return false, nil
+	}
+	after.LastModified = userpool.Now(m.clock)
+	return true, nil
+}
+
+// EnsureUser creates the user in the mock store or updates it if it exists
+func (m *MockClient) EnsureUser(ctx context.Context, user *userpool.User) (*userpool.User, error) {
+	if user == nil {
+		return nil, fmt.Errorf("user cannot be nil")
+	}
+	var err error
+	if _, exists := m.users[user.Username]; exists {
+		err = m.UpdateUser(ctx, user)
+	} else {
+		err = m.CreateUser(ctx, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.GetUser(ctx, user.Username)
+}
+
 // DeleteUser removes a user from the mock store
 func (m *MockClient) DeleteUser(ctx context.Context, username string) error {
 	if username == "" {
@@ -110,23 +450,435 @@ func (m *MockClient) DeleteUser(ctx context.Context, username string) error {
 
 	// Check if user exists
 	if _, exists := m.users[username]; !exists {
-		return fmt.Errorf("user %s not found", username)
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
 	}
 
 	delete(m.users, username)
+	for _, members := range m.groups {
+		delete(members, username)
+	}
+	return nil
+}
+
+// UpdateGroups changes the group memberships of a user in the mock store
+func (m *MockClient) UpdateGroups(ctx context.Context, username string, add, remove []string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if _, exists := m.users[username]; !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+
+	var missing []string
+	for _, group := range add {
+		if _, exists := m.groups[group]; !exists {
+			missing = append(missing, group)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return &userpool.MissingGroupsError{Groups: slices.Compact(missing)}
+	}
+
+	for _, group := range add {
+		m.groups[group][username] = true
+	}
+	for _, group := range remove {
+		delete(m.groups[group], username)
+	}
+	return nil
+}
+
+// VerifyAttribute marks the email or phone number of a user in the mock store
+// as verified
+func (m *MockClient) VerifyAttribute(ctx context.Context, username, attribute string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if !userpool.IsVerifiable(attribute) {
+		return fmt.Errorf("attribute %s: %w", attribute, userpool.ErrAttributeNotVerifiable)
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	verified := true
+	if attribute == AttrEmail {
+		user.EmailVerified = &verified
+	} else {
+		user.PhoneNumberVerified = &verified
+	}
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// SetPreferredMFA sets the preferred MFA method of a user in the mock store
+func (m *MockClient) SetPreferredMFA(ctx context.Context, username, method string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if method != userpool.MFASoftwareToken && method != userpool.MFASMS {
+		return fmt.Errorf("unsupported MFA method %q", method)
+	}
+	if m.mfaDisabled {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrMFADisabled)
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	user.PreferredMFA = method
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// ConfirmUser marks a user in the mock store as confirmed
+func (m *MockClient) ConfirmUser(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	if user.Status != userpool.StatusUnconfirmed {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserAlreadyConfirmed)
+	}
+
+	user.Status = userpool.StatusConfirmed
+	user.RawStatus = "CONFIRMED"
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// SignOutUser counts the sign-outs of a user in the mock store
+func (m *MockClient) SignOutUser(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if _, exists := m.users[username]; !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	m.signOuts[username]++
+	return nil
+}
+
+// SignOuts returns how often a user was signed out
+func (m *MockClient) SignOuts(username string) int {
+	return m.signOuts[username]
+}
+
+// ResetPassword moves a user in the mock store to RESET_REQUIRED
+func (m *MockClient) ResetPassword(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	user.Status = userpool.StatusResetRequired
+	user.RawStatus = "RESET_REQUIRED"
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// ForcePasswordChange moves a user in the mock store to
+// FORCE_CHANGE_PASSWORD
+func (m *MockClient) ForcePasswordChange(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	user.Status = userpool.StatusForceChangePassword
+	user.RawStatus = "FORCE_CHANGE_PASSWORD"
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// ForgetDevices counts the device forgets of a user in the mock store
+func (m *MockClient) ForgetDevices(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if _, exists := m.users[username]; !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	m.deviceForgets[username]++
+	return nil
+}
+
+// DeviceForgets returns how often the devices of a user were forgotten
+func (m *MockClient) DeviceForgets(username string) int {
+	return m.deviceForgets[username]
+}
+
+// ResendInvitation restarts the temporary password of a user in the mock
+// store that never signed in
+func (m *MockClient) ResendInvitation(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	if user.Status != userpool.StatusForceChangePassword {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserAlreadyConfirmed)
+	}
+
+	user.LastModified = userpool.Now(m.clock)
+	return nil
+}
+
+// LinkProvider adds a linked identity to a user in the mock store
+func (m *MockClient) LinkProvider(ctx context.Context, username, providerName, providerAttributeValue string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	user, exists := m.users[username]
+	if !exists {
+		return fmt.Errorf("user %s: %w", username, userpool.ErrUserNotFound)
+	}
+	identity := userpool.Identity{ProviderName: providerName, UserID: providerAttributeValue}
+	if slices.Contains(user.Identities, identity) {
+		return nil
+	}
+
+	user.Identities = append(user.Identities, identity)
+	user.LastModified = userpool.Now(m.clock)
 	return nil
 }
 
 // ListUsers lists all users in the mock store
 func (m *MockClient) ListUsers(ctx context.Context) ([]*userpool.User, error) {
 	users := make([]*userpool.User, 0, len(m.users))
-	for _, user := range m.users {
+	err := m.eachUser(ctx, func(user *userpool.User) {
 		// Return copies to avoid reference issues
-		users = append(users, &userpool.User{
-			Username: user.Username,
-			Email:    user.Email,
-			Enabled:  user.Enabled,
-		})
+		users = append(users, copyUser(user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListUsersProjected lists all users in the mock store with only the fields of
+// projection
+func (m *MockClient) ListUsersProjected(ctx context.Context, projection userpool.Projection) ([]*userpool.User,
+	error) {
+	users := make([]*userpool.User, 0, len(m.users))
+	err := m.eachUser(ctx, func(user *userpool.User) {
+		users = append(users, projection.Apply(user))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ListUsersFiltered lists the users in the mock store matching a filter of
+// the form attribute = "value" or attribute ^= "value"
+func (m *MockClient) ListUsersFiltered(ctx context.Context, filter string) ([]*userpool.User, error) {
+	name, prefix, value, err := parseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var users []*userpool.User
+	err = m.eachUser(ctx, func(user *userpool.User) {
+		var actual string
+		switch name {
+		case "username":
+			actual = user.Username
+		case AttrEmail:
+			actual = user.Email
+		case "cognito:user_status":
+			actual = user.RawStatus
+		default:
+			actual = user.Attributes[name]
+		}
+		if actual == value || (prefix && strings.HasPrefix(actual, value)) {
+			users = append(users, copyUser(user))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUserByEmail returns the user in the mock store with the given email
+func (m *MockClient) GetUserByEmail(ctx context.Context, email string) (*userpool.User, error) {
+	users, err := m.ListUsersFiltered(ctx, AttrEmail+" = "+userpool.QuoteFilterValue(email))
+	if err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, fmt.Errorf("user with email %s: %w", email, userpool.ErrUserNotFound)
+	case 1:
+		return users[0], nil
+	default:
+		return nil, fmt.Errorf("failed to get user with email %s: %d users share it", email, len(users))
+	}
+}
+
+// parseFilter parses a Cognito filter expression. It reports whether the
+// filter is a prefix match and returns the unquoted value.
+func parseFilter(filter string) (name string, prefix bool, value string, err error) {
+	name, rest, ok := strings.Cut(filter, " ")
+	if !ok {
+		return "", false, "", fmt.Errorf("invalid filter %q", filter)
+	}
+	rest = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(rest, "^="):
+		prefix, rest = true, rest[2:]
+	case strings.HasPrefix(rest, "="):
+		rest = rest[1:]
+	default:
+		return "", false, "", fmt.Errorf("invalid filter %q: expected = or ^=", filter)
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return "", false, "", fmt.Errorf("invalid filter %q: value must be quoted", filter)
+	}
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range rest[1 : len(rest)-1] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+			continue
+		case r == '"':
+			return "", false, "", fmt.Errorf("invalid filter %q: unescaped quote in value", filter)
+		}
+		b.WriteRune(r)
+	}
+	if escaped {
+		return "", false, "", fmt.Errorf("invalid filter %q: value ends with an escape", filter)
+	}
+	return name, prefix, b.String(), nil
+}
+
+// mockPageSize is the default number of users per page, the Cognito maximum
+const mockPageSize = 60
+
+// ListUsersPage lists users in the mock store in username order. The cursor
+// is the username the page starts at.
+func (m *MockClient) ListUsersPage(ctx context.Context, cursor string) ([]*userpool.User, string, error) {
+	page, next, err := m.listPage(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	users := make([]*userpool.User, 0, len(page))
+	for _, user := range page {
+		users = append(users, copyUser(user))
+	}
+	return users, next, nil
+}
+
+// listPage returns the stored users of the page starting at cursor and the
+// cursor of the next page, empty after the last page
+func (m *MockClient) listPage(ctx context.Context, cursor string) ([]*userpool.User, string, error) {
+	if m.pageHook != nil {
+		m.pageHook(cursor)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list users: %w", err)
+	}
+
+	size := m.pageSize
+	if size <= 0 {
+		size = mockPageSize
+	}
+	usernames := slices.Sorted(maps.Keys(m.users))
+	start, _ := slices.BinarySearch(usernames, cursor)
+	end := min(start+size, len(usernames))
+
+	users := make([]*userpool.User, 0, end-start)
+	for _, username := range usernames[start:end] {
+		users = append(users, m.users[username])
+	}
+	next := ""
+	if end < len(usernames) {
+		next = usernames[end]
+	}
+	return users, next, nil
+}
+
+// eachUser calls fn with every stored user in username order, page by page
+func (m *MockClient) eachUser(ctx context.Context, fn func(*userpool.User)) error {
+	cursor := ""
+	for {
+		page, next, err := m.listPage(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, user := range page {
+			fn(user)
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// CountUsers returns the number of users in the mock store
+func (m *MockClient) CountUsers(ctx context.Context) (int, error) {
+	return len(m.users), nil
+}
+
+// ListUsersModifiedSince lists users in the mock store modified after since
+func (m *MockClient) ListUsersModifiedSince(ctx context.Context, since time.Time) ([]*userpool.User, error) {
+	var users []*userpool.User
+	err := m.eachUser(ctx, func(user *userpool.User) {
+		if user.LastModified.After(since) {
+			users = append(users, copyUser(user))
+		}
+	})
+	if err != nil {
+		return nil, err
 	}
 	return users, nil
 }
+
+// copyUser returns a deep copy of user
+func copyUser(user *userpool.User) *userpool.User {
+	out := *user
+	if user.EmailVerified != nil {
+		verified := *user.EmailVerified
+		out.EmailVerified = &verified
+	}
+	if user.PhoneNumberVerified != nil {
+		verified := *user.PhoneNumberVerified
+		out.PhoneNumberVerified = &verified
+	}
+	if user.Address != nil {
+		address := *user.Address
+		out.Address = &address
+	}
+	out.Identities = slices.Clone(user.Identities)
+	if user.Attributes != nil {
+		out.Attributes = make(map[string]string, len(user.Attributes))
+		for k, v := range user.Attributes {
+			out.Attributes[k] = v
+		}
+	}
+	return &out
+}
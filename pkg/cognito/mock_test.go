@@ -0,0 +1,131 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestMockClient_CreateUser_EnabledState(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprint(enabled), func(t *testing.T) {
+			mock := NewMockClient()
+			if err := mock.CreateUser(context.Background(), &userpool.User{Username: "jane", Enabled: enabled}); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			user, err := mock.GetUser(context.Background(), "jane")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if user.Enabled != enabled {
+				t.Errorf("expected enabled %v, got %v", enabled, user.Enabled)
+			}
+		})
+	}
+}
+
+func TestMockClient_Pagination(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockClient()
+	for i := range 25 {
+		user := &userpool.User{Username: fmt.Sprintf("user-%02d", i), Email: fmt.Sprintf("user-%02d@example.com", i)}
+		if err := mock.CreateUser(ctx, user); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+	mock.SetPageSize(10)
+
+	var cursors []string
+	mock.SetPageHook(func(cursor string) { cursors = append(cursors, cursor) })
+
+	tests := []struct {
+		name string
+		list func(ctx context.Context) ([]*userpool.User, error)
+		want int
+	}{
+		{name: "ListUsers", list: mock.ListUsers, want: 25},
+		{name: "ListUsersProjected", list: func(ctx context.Context) ([]*userpool.User, error) {
+			return mock.ListUsersProjected(ctx, userpool.Projection{})
+		}, want: 25},
+		{name: "ListUsersFiltered", list: func(ctx context.Context) ([]*userpool.User, error) {
+			return mock.ListUsersFiltered(ctx, `email ^= "user-1"`)
+		}, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursors = nil
+			users, err := tt.list(ctx)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(users) != tt.want {
+				t.Errorf("expected %d users, got %d", tt.want, len(users))
+			}
+			if want := []string{"", "user-10", "user-20"}; !slices.Equal(cursors, want) {
+				t.Errorf("expected pages starting at %q, got %q", want, cursors)
+			}
+		})
+
+		t.Run(tt.name+" canceled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			cursors = nil
+			mock.SetPageHook(func(cursor string) {
+				if cursors = append(cursors, cursor); len(cursors) == 2 {
+					cancel()
+				}
+			})
+			defer mock.SetPageHook(func(cursor string) { cursors = append(cursors, cursor) })
+
+			if _, err := tt.list(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if len(cursors) != 2 {
+				t.Errorf("expected listing to stop at the second page, got %q", cursors)
+			}
+		})
+	}
+}
+
+func TestMockClient_UpdateUserIfChanged(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockClient()
+	user := &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true}
+	if err := m.CreateUser(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	current, _ := m.GetUser(ctx, "jane")
+
+	if changed, err := m.UpdateUserIfChanged(ctx, current, current); err != nil || changed {
+		t.Errorf("expected no change, got %v, %v", changed, err)
+	}
+	if after, _ := m.GetUser(ctx, "jane"); !after.LastModified.Equal(current.LastModified) {
+		t.Errorf("expected the modification time to be kept, got %v", after.LastModified)
+	}
+
+	updated := *current
+	updated.Email = "jane@example.org"
+	if changed, err := m.UpdateUserIfChanged(ctx, current, &updated); err != nil || !changed {
+		t.Errorf("expected a change, got %v, %v", changed, err)
+	}
+}
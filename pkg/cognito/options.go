@@ -0,0 +1,301 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// Option configures an AWSClient
+type Option func(*AWSClient)
+
+// WithAttributeMapping sets the mapping from logical attribute names used in
+// userpool.User.Attributes to the attribute names defined in the user pool,
+// e.g. {"org": "custom:tenant"}. Attributes without a mapping are passed
+// through unchanged.
+func WithAttributeMapping(mapping map[string]string) Option {
+	return func(c *AWSClient) {
+		c.attributeMapping = make(map[string]string, len(mapping))
+		c.reverseAttributeMapping = make(map[string]string, len(mapping))
+		for logical, name := range mapping {
+			c.attributeMapping[logical] = name
+			c.reverseAttributeMapping[name] = logical
+		}
+	}
+}
+
+// WithCustomAttributePrefix stores the unmapped custom attributes of
+// userpool.User.Attributes under prefix instead of "custom:", e.g.
+// "custom:kcp_" stores custom:tenant as custom:kcp_tenant, and reads only the
+// custom attributes under prefix back, with it stripped. This keeps the
+// attributes of other writers of the pool out of drift detection and
+// deletion. Mapped attributes, User.SecondaryEmail and User.DisableReason are
+// unaffected. NewAWSClient rejects a prefix ValidateCustomAttributePrefix
+// doesn't accept.
+func WithCustomAttributePrefix(prefix string) Option {
+	return func(c *AWSClient) {
+		c.customAttributePrefix = prefix
+	}
+}
+
+// WithEmailVerifiedDefault sets the email_verified value written for users that
+// don't set User.EmailVerified. It defaults to true, which is convenient for
+// development pools; production pools that require real verification should
+// set it to false. An explicitly set User.EmailVerified always wins.
+func WithEmailVerifiedDefault(verified bool) Option {
+	return func(c *AWSClient) {
+		c.emailVerifiedDefault = verified
+	}
+}
+
+// WithEmailVerifiedUnmanaged leaves the email_verified attribute out of every
+// write, for pools where an external verification flow owns it. User.EmailVerified
+// is still read but ignored on writes, and VerifyAttribute refuses to verify
+// emails. Cognito resets email_verified when the email changes, so changed
+// emails have to be verified again by that flow. It defaults to false.
+func WithEmailVerifiedUnmanaged(enabled bool) Option {
+	return func(c *AWSClient) {
+		c.emailVerifiedUnmanaged = enabled
+	}
+}
+
+// WithAppClientID sets the app client used by methods that call non-admin
+// Cognito APIs, such as ResendConfirmationCode and ConfirmSignUp. The admin
+// methods of userpool.Client don't need it. secret must be set if the app
+// client has a client secret and may be empty otherwise.
+func WithAppClientID(id, secret string) Option {
+	return func(c *AWSClient) {
+		c.appClientID = id
+		c.appClientSecret = secret
+	}
+}
+
+// WithForceAliasCreation makes CreateUser and MigrateUser take over an email
+// alias held by another user instead of failing with userpool.ErrAliasExists.
+// The other user keeps its email attribute but can no longer sign in with it,
+// so only enable it where that takeover is intended, e.g. while migrating
+// users. It defaults to false.
+func WithForceAliasCreation(enabled bool) Option {
+	return func(c *AWSClient) {
+		c.forceAliasCreation = enabled
+	}
+}
+
+// WithLegacyMFA selects whether SetPreferredMFA sets SMS MFA with the legacy
+// AdminSetUserSettings API, for pools still using the MFA options model. It
+// defaults to LegacyMFAAuto.
+func WithLegacyMFA(mode LegacyMFA) Option {
+	return func(c *AWSClient) {
+		c.legacyMFA = mode
+	}
+}
+
+// WithForgetDevicesOnDisable makes disabling a user also forget all of the
+// user's remembered devices with AdminForgetDevice, so no trusted device can
+// skip MFA once the user is enabled again. It needs the
+// cognito-idp:AdminListDevices and cognito-idp:AdminForgetDevice permissions
+// and is only useful in pools that remember devices. It defaults to false.
+func WithForgetDevicesOnDisable(enabled bool) Option {
+	return func(c *AWSClient) {
+		c.forgetDevicesOnDisable = enabled
+	}
+}
+
+// WithDefaultAttributes sets attributes written for every created or updated
+// user that doesn't set them itself, keyed by logical attribute name, e.g.
+// {"custom:managedBy": "kcp-users-controller"} to mark users managed by the
+// controller. ValidateAttributeMapping checks that they exist in the pool.
+func WithDefaultAttributes(attrs map[string]string) Option {
+	return func(c *AWSClient) {
+		c.defaultAttributes = attrs
+	}
+}
+
+// WithClock sets the clock used to expire the cached pool configuration,
+// e.g. a fake clock in tests. It defaults to userpool.RealClock.
+func WithClock(clock userpool.Clock) Option {
+	return func(c *AWSClient) {
+		c.clock = clock
+	}
+}
+
+// WithRedactPII replaces usernames and emails in returned errors and log
+// lines with a stable hash, see userpool.Redact
+func WithRedactPII(enabled bool) Option {
+	return func(c *AWSClient) {
+		c.redactPII = enabled
+	}
+}
+
+// SchemaPolicy decides how CreateUser and UpdateUser treat attributes that are
+// not defined in the user pool schema
+type SchemaPolicy string
+
+const (
+	// SchemaPolicyFailClosed rejects the write with ErrUnknownAttribute
+	SchemaPolicyFailClosed SchemaPolicy = "FailClosed"
+	// SchemaPolicyDropUnknown drops unknown attributes, logs them and writes
+	// the rest
+	SchemaPolicyDropUnknown SchemaPolicy = "DropUnknown"
+)
+
+// WithSchemaPolicy sets how attributes missing from the user pool schema are
+// handled. It defaults to SchemaPolicyFailClosed. Dropping unknown attributes
+// helps during schema migrations, when Users may reference attributes that
+// are not added to the pool yet.
+func WithSchemaPolicy(policy SchemaPolicy) Option {
+	return func(c *AWSClient) {
+		c.schemaPolicy = policy
+	}
+}
+
+// ParseSchemaPolicy parses a SchemaPolicy name
+func ParseSchemaPolicy(s string) (SchemaPolicy, error) {
+	switch policy := SchemaPolicy(s); policy {
+	case SchemaPolicyFailClosed, SchemaPolicyDropUnknown:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid schema policy %q, expected %s or %s", s,
+			SchemaPolicyFailClosed, SchemaPolicyDropUnknown)
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts the SDK makes for each
+// Cognito call, including the first one. Zero keeps the SDK default of 3.
+func WithMaxAttempts(attempts int) Option {
+	return func(c *AWSClient) {
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.RetryMaxAttempts = attempts
+		})
+	}
+}
+
+// WithMaxBackoff caps the delay between SDK retries. Zero keeps the SDK
+// default of 20 seconds.
+func WithMaxBackoff(delay time.Duration) Option {
+	return func(c *AWSClient) {
+		if delay <= 0 {
+			return
+		}
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			if o.Retryer == nil {
+				o.Retryer = retry.NewStandard()
+			}
+			o.Retryer = retry.AddWithMaxBackoffDelay(o.Retryer, delay)
+		})
+	}
+}
+
+// WithStandardRetryer replaces the SDK retryer with a retry.Standard
+// configured by fns, e.g. to set a custom Backoff delayer or RateLimiter.
+// WithMaxBackoff wraps the retryer it finds, so it must come after
+// WithStandardRetryer to take effect.
+func WithStandardRetryer(fns ...func(*retry.StandardOptions)) Option {
+	return func(c *AWSClient) {
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.Retryer = retry.NewStandard(fns...)
+		})
+	}
+}
+
+// WithRegion sets the region of the Cognito endpoint, overriding the region
+// of the AWS configuration. The region also selects the partition, so
+// "us-gov-west-1" resolves GovCloud endpoints and "cn-north-1" China ones.
+// An empty region keeps the configured one.
+func WithRegion(region string) Option {
+	return func(c *AWSClient) {
+		if region == "" {
+			return
+		}
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.Region = region
+		})
+	}
+}
+
+// WithFIPSEndpoint makes the client use the region's FIPS endpoint, which
+// GovCloud deployments usually require
+func WithFIPSEndpoint(enabled bool) Option {
+	return func(c *AWSClient) {
+		if !enabled {
+			return
+		}
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		})
+	}
+}
+
+// WithBaseEndpoint sends all Cognito calls to url instead of the endpoint
+// resolved from the region, e.g. a VPC endpoint or a local emulator. An
+// empty url keeps the resolved endpoint.
+func WithBaseEndpoint(url string) Option {
+	return func(c *AWSClient) {
+		if url == "" {
+			return
+		}
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.BaseEndpoint = aws.String(url)
+		})
+	}
+}
+
+// WithEndpointResolver replaces the SDK's endpoint resolution, for partitions
+// or endpoint layouts the SDK doesn't know about
+func WithEndpointResolver(resolver cognitoidentityprovider.EndpointResolverV2) Option {
+	return func(c *AWSClient) {
+		c.clientOptions = append(c.clientOptions, func(o *cognitoidentityprovider.Options) {
+			o.EndpointResolverV2 = resolver
+		})
+	}
+}
+
+// ParseAttributeMapping parses a comma-separated list of logical=attribute
+// pairs, e.g. "org=custom:tenant,team=custom:team"
+func ParseAttributeMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	reverse := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		logical, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		logical = strings.TrimSpace(logical)
+		name = strings.TrimSpace(name)
+		if !ok || logical == "" || name == "" {
+			return nil, fmt.Errorf("invalid attribute mapping %q, expected logical=attribute", pair)
+		}
+		if _, exists := mapping[logical]; exists {
+			return nil, fmt.Errorf("duplicate attribute mapping for %s", logical)
+		}
+		if other, exists := reverse[name]; exists {
+			return nil, fmt.Errorf("attribute %s is mapped from both %s and %s", name, other, logical)
+		}
+		mapping[logical] = name
+		reverse[name] = logical
+	}
+
+	return mapping, nil
+}
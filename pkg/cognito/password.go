@@ -0,0 +1,116 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// temporaryPasswordLength is the length of the passwords ForcePasswordChange
+// sets. It exceeds the longest minimum length a password policy can require.
+const temporaryPasswordLength = 128
+
+// passwordCharacterClasses are the characters of temporary passwords. Each
+// class is used at least once, so the passwords meet any password policy.
+var passwordCharacterClasses = []string{
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"!#%*+-=?@^_",
+}
+
+// ForcePasswordChange replaces the password of a user with a random temporary
+// one with AdminSetUserPassword, which moves the user to
+// FORCE_CHANGE_PASSWORD, and sends the invitation message. The invitation
+// carries a new temporary password generated by Cognito, so the random one
+// is never revealed.
+func (c *AWSClient) ForcePasswordChange(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+
+	password, err := temporaryPassword()
+	if err != nil {
+		return fmt.Errorf("failed to generate temporary password: %w", err)
+	}
+	_, err = c.cognito.AdminSetUserPassword(ctx, &cognitoidentityprovider.AdminSetUserPasswordInput{
+		UserPoolId: aws.String(c.userPoolID),
+		Username:   aws.String(username),
+		Password:   aws.String(password),
+		Permanent:  false,
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to force password change of user %s: %w", c.pii(username),
+				userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to force password change of user %s: %w", c.pii(username), err)
+	}
+
+	return c.ResendInvitation(ctx, username)
+}
+
+// temporaryPassword returns a random password of temporaryPasswordLength
+// characters with every character class
+func temporaryPassword() (string, error) {
+	var all string
+	password := make([]byte, 0, temporaryPasswordLength)
+	for _, class := range passwordCharacterClasses {
+		all += class
+		b, err := randomCharacter(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, b)
+	}
+	for len(password) < temporaryPasswordLength {
+		b, err := randomCharacter(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, b)
+	}
+
+	// Move the guaranteed characters to random positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+// randomCharacter returns a random character of chars
+func randomCharacter(chars string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i.Int64()], nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// ErrAppClientIDRequired is returned by methods that call non-admin Cognito
+// APIs when the client was created without WithAppClientID
+var ErrAppClientIDRequired = errors.New("app client ID required, configure it with WithAppClientID")
+
+var _ userpool.SignUpClient = &AWSClient{}
+
+// ResendConfirmationCode sends a new sign-up confirmation code to an
+// unconfirmed user through the configured app client
+func (c *AWSClient) ResendConfirmationCode(ctx context.Context, username string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if c.appClientID == "" {
+		return fmt.Errorf("failed to resend confirmation code to user %s: %w", c.pii(username), ErrAppClientIDRequired)
+	}
+
+	_, err := c.cognito.ResendConfirmationCode(ctx, &cognitoidentityprovider.ResendConfirmationCodeInput{
+		ClientId:   aws.String(c.appClientID),
+		Username:   aws.String(username),
+		SecretHash: c.secretHash(username),
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to resend confirmation code to user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		return fmt.Errorf("failed to resend confirmation code to user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// ConfirmSignUp confirms a user with the confirmation code the user received,
+// as opposed to ConfirmUser which confirms without a code
+func (c *AWSClient) ConfirmSignUp(ctx context.Context, username, code string) error {
+	if username == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if code == "" {
+		return fmt.Errorf("confirmation code cannot be empty")
+	}
+	if c.appClientID == "" {
+		return fmt.Errorf("failed to confirm sign-up of user %s: %w", c.pii(username), ErrAppClientIDRequired)
+	}
+
+	_, err := c.cognito.ConfirmSignUp(ctx, &cognitoidentityprovider.ConfirmSignUpInput{
+		ClientId:         aws.String(c.appClientID),
+		Username:         aws.String(username),
+		ConfirmationCode: aws.String(code),
+		SecretHash:       c.secretHash(username),
+	})
+	if err != nil {
+		var notFound *types.UserNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("failed to confirm sign-up of user %s: %w", c.pii(username), userpool.ErrUserNotFound)
+		}
+		var notAuthorized *types.NotAuthorizedException
+		if errors.As(err, &notAuthorized) && strings.Contains(notAuthorized.ErrorMessage(), "status is CONFIRMED") {
+			return fmt.Errorf("failed to confirm sign-up of user %s: %w", c.pii(username), userpool.ErrUserAlreadyConfirmed)
+		}
+		return fmt.Errorf("failed to confirm sign-up of user %s: %w", c.pii(username), err)
+	}
+
+	return nil
+}
+
+// secretHash computes the SECRET_HASH Cognito requires from app clients with
+// a client secret, or returns nil when no secret is configured
+func (c *AWSClient) secretHash(username string) *string {
+	if c.appClientSecret == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, []byte(c.appClientSecret))
+	mac.Write([]byte(username + c.appClientID))
+	return aws.String(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
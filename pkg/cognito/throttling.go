@@ -0,0 +1,51 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cognito
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/aws/smithy-go/middleware"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// throttles recognizes the errors Cognito returns for exceeding a request
+// quota, e.g. TooManyRequestsException
+var throttles = retry.IsErrorThrottles(retry.DefaultThrottles)
+
+// withThrottlingErrors returns the SDK client option installing a middleware
+// that marks throttling errors with userpool.ErrThrottled. It runs outside the
+// retries, so only calls that stayed throttled after all attempts are marked.
+func withThrottlingErrors() func(*cognitoidentityprovider.Options) {
+	marker := middleware.InitializeMiddlewareFunc("ThrottlingErrors", func(ctx context.Context,
+		in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput,
+		middleware.Metadata, error) {
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if err != nil && throttles.IsErrorThrottle(err).Bool() {
+			err = fmt.Errorf("%w: %w", userpool.ErrThrottled, err)
+		}
+		return out, metadata, err
+	})
+	return func(o *cognitoidentityprovider.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(marker, middleware.Before)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxValueLength is the longest attribute value Cognito accepts
+const maxValueLength = 2048
+
+// Address is a postal address, stored in the address attribute as the JSON
+// object defined by OpenID Connect
+type Address struct {
+	// Formatted is the full address for display, lines separated by "\n"
+	Formatted     string `json:"formatted,omitempty"`
+	StreetAddress string `json:"street_address,omitempty"`
+	Locality      string `json:"locality,omitempty"`
+	Region        string `json:"region,omitempty"`
+	PostalCode    string `json:"postal_code,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// EncodeAddress returns the address attribute value of address
+func EncodeAddress(address *Address) string {
+	// Marshaling a struct of strings cannot fail
+	value, _ := json.Marshal(address)
+	return string(value)
+}
+
+// DecodeAddress parses an address attribute value. Values that are not a
+// JSON object, e.g. written by other tools as plain text, are kept as the
+// formatted address rather than failing the read.
+func DecodeAddress(value string) *Address {
+	var address Address
+	if err := json.Unmarshal([]byte(value), &address); err != nil {
+		return &Address{Formatted: value}
+	}
+	return &address
+}
+
+// ValidateAddress returns an *InvalidParameterError for the address attribute
+// if address is empty or its encoding is longer than Cognito accepts. A nil
+// address is valid.
+func ValidateAddress(address *Address) error {
+	if address == nil {
+		return nil
+	}
+	if *address == (Address{}) {
+		return &InvalidParameterError{Field: "address", Message: "address has no fields set"}
+	}
+	if length := len(EncodeAddress(address)); length > maxValueLength {
+		return &InvalidParameterError{
+			Field:   "address",
+			Message: fmt.Sprintf("encoded address is %d characters long, more than %d", length, maxValueLength),
+		}
+	}
+	return nil
+}
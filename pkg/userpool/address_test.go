@@ -0,0 +1,73 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestAddress(t *testing.T) {
+	address := &userpool.Address{
+		Formatted:     "Ringstraße 1\n1010 Wien",
+		StreetAddress: "Ringstraße 1",
+		Locality:      "Wien",
+		PostalCode:    "1010",
+		Country:       "AT",
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		encoded := userpool.EncodeAddress(address)
+		want := `{"formatted":"Ringstraße 1\n1010 Wien","street_address":"Ringstraße 1","locality":"Wien",` +
+			`"postal_code":"1010","country":"AT"}`
+		if encoded != want {
+			t.Errorf("expected %s, got %s", want, encoded)
+		}
+		if decoded := userpool.DecodeAddress(encoded); *decoded != *address {
+			t.Errorf("expected %+v, got %+v", address, decoded)
+		}
+	})
+
+	t.Run("malformed stored value", func(t *testing.T) {
+		decoded := userpool.DecodeAddress("Ringstraße 1, Wien")
+		if *decoded != (userpool.Address{Formatted: "Ringstraße 1, Wien"}) {
+			t.Errorf("expected the value as formatted address, got %+v", decoded)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		address *userpool.Address
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "valid", address: address},
+		{name: "empty", address: &userpool.Address{}, wantErr: true},
+		{name: "too long", address: &userpool.Address{Formatted: strings.Repeat("a", 2048)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := userpool.ValidateAddress(tt.address)
+			if tt.wantErr != errors.Is(err, userpool.ErrInvalidParameter) {
+				t.Errorf("expected invalid parameter error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultBulkConcurrency is the number of users BulkUpdateAttribute updates
+// at once when no concurrency is given
+const DefaultBulkConcurrency = 4
+
+// BulkOutcome is what a bulk operation did with one user
+type BulkOutcome string
+
+const (
+	// BulkSucceeded means the operation was applied to the user
+	BulkSucceeded BulkOutcome = "Succeeded"
+	// BulkSkipped means the user needed no change
+	BulkSkipped BulkOutcome = "Skipped"
+	// BulkFailed means the operation failed for the user
+	BulkFailed BulkOutcome = "Failed"
+)
+
+// BulkUserResult is the outcome of a bulk operation for one user
+type BulkUserResult struct {
+	Username string
+	Outcome  BulkOutcome
+	// Err is the cause of a BulkFailed outcome
+	Err error
+}
+
+// BulkResult reports the outcome of a bulk operation per user. It is safe
+// for concurrent use while the operation runs.
+type BulkResult struct {
+	mu    sync.Mutex
+	users []BulkUserResult
+}
+
+// Add records the outcome for username. It is called by the bulk operations
+// and by clients implementing their own.
+func (r *BulkResult) Add(username string, outcome BulkOutcome, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users = append(r.users, BulkUserResult{Username: username, Outcome: outcome, Err: err})
+}
+
+// Users returns the outcomes sorted by username
+func (r *BulkResult) Users() []BulkUserResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := slices.Clone(r.users)
+	slices.SortStableFunc(users, func(a, b BulkUserResult) int { return strings.Compare(a.Username, b.Username) })
+	return users
+}
+
+// Usernames returns the sorted usernames with outcome
+func (r *BulkResult) Usernames(outcome BulkOutcome) []string {
+	var names []string
+	for _, user := range r.Users() {
+		if user.Outcome == outcome {
+			names = append(names, user.Username)
+		}
+	}
+	return names
+}
+
+// Count returns the number of users with outcome
+func (r *BulkResult) Count(outcome BulkOutcome) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, user := range r.users {
+		if user.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Err joins the errors of all failed users, naming them, or returns nil if
+// none failed
+func (r *BulkResult) Err() error {
+	var names []string
+	var errs []error
+	for _, user := range r.Users() {
+		if user.Outcome == BulkFailed {
+			names = append(names, user.Username)
+			errs = append(errs, user.Err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed users %s: %w", strings.Join(names, ", "), errors.Join(errs...))
+}
+
+// String returns the number of users per outcome, e.g.
+// "3 succeeded, 10 skipped, 1 failed"
+func (r *BulkResult) String() string {
+	return fmt.Sprintf("%d succeeded, %d skipped, %d failed",
+		r.Count(BulkSucceeded), r.Count(BulkSkipped), r.Count(BulkFailed))
+}
+
+// AttributeFix computes the attribute changes for user, keyed by logical
+// attribute name. An empty value deletes the attribute. Returning false skips
+// the user. It must not modify user.
+type AttributeFix func(user *User) (map[string]string, bool)
+
+// BulkUpdateAttribute lists the whole user pool a page at a time and updates
+// every user fix returns changes for, up to concurrency users at once. Only
+// the changed attributes are written. A failing user doesn't stop the others.
+// The result has an outcome for every user listed, including the skipped
+// ones, and the returned error joins result.Err with a failed listing. A
+// concurrency of zero or less uses DefaultBulkConcurrency.
+func BulkUpdateAttribute(ctx context.Context, client Client, fix AttributeFix, concurrency int) (*BulkResult,
+	error) {
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+
+	var (
+		wg     sync.WaitGroup
+		result BulkResult
+	)
+	slots := make(chan struct{}, concurrency)
+	update := func(current *User, changes map[string]string) {
+		defer wg.Done()
+		defer func() { <-slots }()
+		if err := client.UpdateUserDelta(ctx, current, applyAttributeChanges(current, changes)); err != nil {
+			result.Add(current.Username, BulkFailed, err)
+			return
+		}
+		result.Add(current.Username, BulkSucceeded, nil)
+	}
+
+	var listErr error
+	cursor := ""
+pages:
+	for {
+		page, next, err := client.ListUsersPage(ctx, cursor)
+		if err != nil {
+			listErr = fmt.Errorf("failed to list users in user pool: %w", err)
+			break
+		}
+		for _, user := range page {
+			changes, ok := fix(user)
+			if !ok || len(changes) == 0 {
+				result.Add(user.Username, BulkSkipped, nil)
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				listErr = ctx.Err()
+				break pages
+			}
+			wg.Add(1)
+			go update(user, changes)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	wg.Wait()
+
+	return &result, errors.Join(listErr, result.Err())
+}
+
+// applyAttributeChanges returns a copy of user with changes applied, empty
+// values deleting their attribute
+func applyAttributeChanges(user *User, changes map[string]string) *User {
+	updated := *user
+	updated.Attributes = maps.Clone(user.Attributes)
+	if updated.Attributes == nil {
+		updated.Attributes = make(map[string]string, len(changes))
+	}
+	updated.DeleteAttributes = nil
+	for _, name := range slices.Sorted(maps.Keys(changes)) {
+		if value := changes[name]; value != "" {
+			updated.Attributes[name] = value
+			continue
+		}
+		delete(updated.Attributes, name)
+		updated.DeleteAttributes = append(updated.DeleteAttributes, name)
+	}
+	return &updated
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// serialClient passes calls to the wrapped client one at a time, since the
+// mock isn't safe for concurrent use, and records how many updates were in
+// flight at once
+type serialClient struct {
+	userpool.Client
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *serialClient) ListUsersPage(ctx context.Context, cursor string) ([]*userpool.User, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Client.ListUsersPage(ctx, cursor)
+}
+
+func (c *serialClient) UpdateUserDelta(ctx context.Context, current, user *userpool.User) error {
+	c.mu.Lock()
+	c.inFlight++
+	c.maxInFlight = max(c.maxInFlight, c.inFlight)
+	c.mu.Unlock()
+	time.Sleep(time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	if user.Username == "broken" {
+		return errors.New("rejected")
+	}
+	return c.Client.UpdateUserDelta(ctx, current, user)
+}
+
+func TestBulkResult_Err(t *testing.T) {
+	var result userpool.BulkResult
+	result.Add("jane", userpool.BulkSucceeded, nil)
+	result.Add("bob", userpool.BulkSkipped, nil)
+	if err := result.Err(); err != nil {
+		t.Errorf("expected no error without failures, got %v", err)
+	}
+
+	errNotFound := fmt.Errorf("user mary: %w", userpool.ErrUserNotFound)
+	result.Add("mary", userpool.BulkFailed, errNotFound)
+	result.Add("john", userpool.BulkFailed, errors.New("rejected"))
+	err := result.Err()
+	if err == nil || !strings.HasPrefix(err.Error(), "failed users john, mary: ") {
+		t.Errorf("expected the failed users named in order, got %v", err)
+	}
+	if !errors.Is(err, userpool.ErrUserNotFound) {
+		t.Errorf("expected the failures to be wrapped, got %v", err)
+	}
+}
+
+func TestBulkUpdateAttribute(t *testing.T) {
+	ctx := context.Background()
+	mock := cognito.NewMockClient()
+	mock.SetPageSize(2)
+	tenants := map[string]string{
+		"jane": "Acme", "john": "ACME", "mary": "acme", "bob": "", "broken": "Acme", "old": "retired",
+	}
+	for username, tenant := range tenants {
+		user := &userpool.User{Username: username, Enabled: true}
+		if tenant != "" {
+			user.Attributes = map[string]string{"custom:tenant": tenant}
+		}
+		if err := mock.CreateUser(ctx, user); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+	client := &serialClient{Client: mock}
+
+	result, err := userpool.BulkUpdateAttribute(ctx, client, func(user *userpool.User) (map[string]string, bool) {
+		tenant, ok := user.Attributes["custom:tenant"]
+		switch {
+		case tenant == "retired":
+			return map[string]string{"custom:tenant": ""}, true
+		case !ok || tenant == strings.ToLower(tenant):
+			return nil, false
+		}
+		return map[string]string{"custom:tenant": strings.ToLower(tenant)}, true
+	}, 2)
+	if err == nil || err.Error() != "failed users broken: rejected" {
+		t.Errorf("expected the failure of broken to be reported, got %v", err)
+	}
+	if resultErr := result.Err(); resultErr == nil || resultErr.Error() != err.Error() {
+		t.Errorf("expected the result to report the same failures, got %v", resultErr)
+	}
+	if got := result.Usernames(userpool.BulkSucceeded); !slices.Equal(got, []string{"jane", "john", "old"}) {
+		t.Errorf("expected jane, john and old updated, got %v", got)
+	}
+	if got := result.Usernames(userpool.BulkSkipped); !slices.Equal(got, []string{"bob", "mary"}) {
+		t.Errorf("expected bob and mary skipped, got %v", got)
+	}
+	failed := result.Usernames(userpool.BulkFailed)
+	if !slices.Equal(failed, []string{"broken"}) || result.Users()[0].Username != "bob" {
+		t.Errorf("expected broken failed and the outcomes sorted, got %+v", result.Users())
+	}
+	if result.String() != "3 succeeded, 2 skipped, 1 failed" {
+		t.Errorf("expected the outcome counts, got %q", result)
+	}
+	if client.maxInFlight > 2 {
+		t.Errorf("expected at most 2 updates at once, got %d", client.maxInFlight)
+	}
+
+	// An empty tenant means the attribute is unset
+	want := map[string]string{"jane": "acme", "john": "acme", "mary": "acme", "bob": "", "broken": "Acme", "old": ""}
+	for username, tenant := range want {
+		user, err := mock.GetUser(ctx, username)
+		if err != nil {
+			t.Fatalf("failed to get user %s: %v", username, err)
+		}
+		if got, ok := user.Attributes["custom:tenant"]; got != tenant || (tenant == "") == ok {
+			t.Errorf("expected tenant %q for %s, got %q", tenant, username, got)
+		}
+	}
+}
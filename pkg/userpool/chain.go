@@ -0,0 +1,50 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+// Middleware wraps a Client to add a cross-cutting concern, e.g. caching,
+// rate limiting or metrics, around the calls it delegates
+type Middleware func(Client) Client
+
+// Chain wraps base with each middleware in turn, so the last middleware is
+// the outermost one and sees every call first. For example
+//
+//	Chain(aws, withMetrics, withRateLimit, withCache)
+//
+// answers cached reads without spending rate limit tokens and only measures
+// calls that reach aws. Middleware that short-circuits calls, such as a
+// cache, belongs after middleware that protects the user pool, such as a
+// rate limiter; middleware that observes the user pool belongs before it.
+// Nil middleware is skipped.
+func Chain(base Client, middleware ...Middleware) Client {
+	client := base
+	for _, m := range middleware {
+		if m != nil {
+			client = m(client)
+		}
+	}
+	return client
+}
+
+// Recording returns a Middleware wrapping the client in a RecordingClient
+// and stores that recorder in rec, e.g. to assert the calls in tests
+func Recording(rec **RecordingClient) Middleware {
+	return func(client Client) Client {
+		*rec = NewRecordingClient(client)
+		return *rec
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// tracingClient appends its name to calls before delegating GetUser
+type tracingClient struct {
+	userpool.Client
+	name  string
+	calls *[]string
+}
+
+func (c *tracingClient) GetUser(ctx context.Context, username string) (*userpool.User, error) {
+	*c.calls = append(*c.calls, c.name)
+	return c.Client.GetUser(ctx, username)
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	tracing := func(name string) userpool.Middleware {
+		return func(client userpool.Client) userpool.Client {
+			return &tracingClient{Client: client, name: name, calls: &calls}
+		}
+	}
+
+	var rec *userpool.RecordingClient
+	client := userpool.Chain(cognito.NewMockClient(), userpool.Recording(&rec), tracing("inner"), nil,
+		tracing("outer"))
+	if _, err := client.GetUser(context.Background(), "missing"); err == nil {
+		t.Fatalf("expected GetUser of a missing user to fail")
+	}
+
+	if want := []string{"outer", "inner"}; !slices.Equal(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+	if ops := rec.Operations(); len(ops) != 1 || ops[0].Name != "GetUser" {
+		t.Errorf("expected the innermost recorder to see GetUser, got %v", ops)
+	}
+}
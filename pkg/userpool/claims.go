@@ -0,0 +1,61 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidateBirthdate returns an *InvalidParameterError for the birthdate
+// attribute if birthdate is not a YYYY-MM-DD date as OpenID Connect defines
+// it. The year may be 0000 when it is omitted. An empty birthdate is valid.
+func ValidateBirthdate(birthdate string) error {
+	if birthdate == "" {
+		return nil
+	}
+	// 2000 is a leap year, so February 29 is accepted without a year
+	value, withoutYear := strings.CutPrefix(birthdate, "0000-")
+	if withoutYear {
+		value = "2000-" + value
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return &InvalidParameterError{
+			Field:   "birthdate",
+			Message: fmt.Sprintf("%q is not a YYYY-MM-DD date", birthdate),
+		}
+	}
+	return nil
+}
+
+// ValidateZoneInfo returns an *InvalidParameterError for the zoneinfo
+// attribute if zoneinfo is not an IANA time zone such as "Europe/Paris". An
+// empty zoneinfo is valid.
+func ValidateZoneInfo(zoneinfo string) error {
+	if zoneinfo == "" {
+		return nil
+	}
+	// LoadLocation treats "Local" as the zone of the machine it runs on
+	if _, err := time.LoadLocation(zoneinfo); err != nil || zoneinfo == "Local" {
+		return &InvalidParameterError{
+			Field:   "zoneinfo",
+			Message: fmt.Sprintf("%q is not an IANA time zone", zoneinfo),
+		}
+	}
+	return nil
+}
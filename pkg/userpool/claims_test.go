@@ -0,0 +1,50 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateBirthdate(t *testing.T) {
+	for _, birthdate := range []string{"", "1990-04-23", "0000-02-29"} {
+		if err := ValidateBirthdate(birthdate); err != nil {
+			t.Errorf("expected %q to be valid, got %v", birthdate, err)
+		}
+	}
+	for _, birthdate := range []string{"23.04.1990", "1990-4-23", "1990-02-30", "1990-04-23T00:00:00Z"} {
+		var invalid *InvalidParameterError
+		if err := ValidateBirthdate(birthdate); !errors.As(err, &invalid) || invalid.Field != "birthdate" {
+			t.Errorf("expected %q to be rejected for birthdate, got %v", birthdate, err)
+		}
+	}
+}
+
+func TestValidateZoneInfo(t *testing.T) {
+	for _, zoneinfo := range []string{"", "UTC", "Europe/Paris", "America/Argentina/Buenos_Aires"} {
+		if err := ValidateZoneInfo(zoneinfo); err != nil {
+			t.Errorf("expected %q to be valid, got %v", zoneinfo, err)
+		}
+	}
+	for _, zoneinfo := range []string{"Local", "Europe/Nowhere", "+02:00"} {
+		var invalid *InvalidParameterError
+		if err := ValidateZoneInfo(zoneinfo); !errors.As(err, &invalid) || invalid.Field != "zoneinfo" {
+			t.Errorf("expected %q to be rejected for zoneinfo, got %v", zoneinfo, err)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// PrunePolicy decides what Converge does with pool users that are not
+// desired
+type PrunePolicy string
+
+const (
+	// PruneNone leaves users that are not desired alone
+	PruneNone PrunePolicy = "None"
+	// PruneDisable disables users that are not desired
+	PruneDisable PrunePolicy = "Disable"
+	// PruneDelete deletes users that are not desired
+	PruneDelete PrunePolicy = "Delete"
+)
+
+// ParsePrunePolicy parses a PrunePolicy name
+func ParsePrunePolicy(s string) (PrunePolicy, error) {
+	switch policy := PrunePolicy(s); policy {
+	case PruneNone, PruneDisable, PruneDelete:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid prune policy %q, expected %s, %s or %s", s,
+			PruneNone, PruneDisable, PruneDelete)
+	}
+}
+
+// ConvergeOptions configures Converge
+type ConvergeOptions struct {
+	// Prune is applied to pool users that are not desired. Empty means
+	// PruneNone.
+	Prune PrunePolicy
+
+	// MaxPrune is the most users a run prunes. A run that would prune more
+	// prunes none of them, since that usually means the desired users are
+	// incomplete. Zero means no limit.
+	MaxPrune int
+
+	// DryRun reports the actions without performing them
+	DryRun bool
+}
+
+// ConvergeSummary lists the usernames Converge acted on
+type ConvergeSummary struct {
+	Created   []string
+	Updated   []string
+	Unchanged []string
+	Pruned    []string
+	// Unmanaged are the pool users that are not desired and were left alone
+	Unmanaged []string
+	// Failed maps usernames to the error that stopped their action
+	Failed map[string]error
+}
+
+// String returns the number of users per action, e.g.
+// "2 created, 1 updated, 10 unchanged, 0 pruned, 0 unmanaged, 0 failed"
+func (s *ConvergeSummary) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged, %d pruned, %d unmanaged, %d failed",
+		len(s.Created), len(s.Updated), len(s.Unchanged), len(s.Pruned), len(s.Unmanaged), len(s.Failed))
+}
+
+// Converge makes the user pool match desired: missing users are created,
+// differing users updated and users that are not desired pruned according to
+// opts.Prune. Users are compared like DiffUser, so attributes desired doesn't
+// set are left alone. A failing user doesn't stop the others; the returned
+// error joins all failures.
+func Converge(ctx context.Context, client Client, desired []*User, opts ConvergeOptions) (*ConvergeSummary,
+	error) {
+	existing, err := client.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users in user pool: %w", err)
+	}
+	byName := make(map[string]*User, len(existing))
+	for _, user := range existing {
+		byName[user.Username] = user
+	}
+
+	summary := &ConvergeSummary{Failed: make(map[string]error)}
+	wanted := make(map[string]bool, len(desired))
+	for _, user := range desired {
+		if user.Username == "" {
+			return nil, fmt.Errorf("desired users must have a username")
+		}
+		if wanted[user.Username] {
+			return nil, fmt.Errorf("user %s is desired more than once", user.Username)
+		}
+		wanted[user.Username] = true
+
+		current, ok := byName[user.Username]
+		switch {
+		case !ok:
+			summary.record(&summary.Created, user.Username, opts.DryRun, func() error {
+				return client.CreateUser(ctx, user)
+			})
+		case len(DiffUser(user, current)) > 0:
+			summary.record(&summary.Updated, user.Username, opts.DryRun, func() error {
+				return client.UpdateUserDelta(ctx, current, user)
+			})
+		default:
+			summary.Unchanged = append(summary.Unchanged, user.Username)
+		}
+	}
+
+	var prune []*User
+	for _, user := range existing {
+		if wanted[user.Username] {
+			continue
+		}
+		if opts.Prune == PruneDisable && !user.Enabled {
+			summary.Unchanged = append(summary.Unchanged, user.Username)
+			continue
+		}
+		prune = append(prune, user)
+	}
+	if opts.Prune == "" || opts.Prune == PruneNone || len(prune) == 0 {
+		for _, user := range prune {
+			summary.Unmanaged = append(summary.Unmanaged, user.Username)
+		}
+		return summary, summary.err()
+	}
+	if opts.MaxPrune > 0 && len(prune) > opts.MaxPrune {
+		for _, user := range prune {
+			summary.Unmanaged = append(summary.Unmanaged, user.Username)
+		}
+		return summary, errors.Join(summary.err(), fmt.Errorf("refusing to prune %d users, more than the limit of %d",
+			len(prune), opts.MaxPrune))
+	}
+
+	for _, user := range prune {
+		summary.record(&summary.Pruned, user.Username, opts.DryRun, func() error {
+			if opts.Prune == PruneDelete {
+				err := client.DeleteUser(ctx, user.Username)
+				if errors.Is(err, ErrUserNotFound) {
+					return nil
+				}
+				return err
+			}
+			disabled := *user
+			disabled.Enabled = false
+			return client.UpdateUserDelta(ctx, user, &disabled)
+		})
+	}
+	return summary, summary.err()
+}
+
+// record performs action, unless dryRun is set, and adds username to done or,
+// if the action fails, to the failures
+func (s *ConvergeSummary) record(done *[]string, username string, dryRun bool, action func() error) {
+	if !dryRun {
+		if err := action(); err != nil {
+			s.Failed[username] = err
+			return
+		}
+	}
+	*done = append(*done, username)
+}
+
+// err joins the failures, nil if there are none
+func (s *ConvergeSummary) err() error {
+	if len(s.Failed) == 0 {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(s.Failed))
+	errs := make([]error, 0, len(names))
+	for _, username := range names {
+		errs = append(errs, s.Failed[username])
+	}
+	return fmt.Errorf("failed to converge users %s: %w", strings.Join(names, ", "), errors.Join(errs...))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestConverge(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) *cognito.MockClient {
+		mock := cognito.NewMockClient()
+		for _, user := range []*userpool.User{
+			{Username: "jane", Email: "jane@example.com", Enabled: true},
+			{Username: "john", Email: "old@example.com", Enabled: true},
+			{Username: "stale", Email: "stale@example.com", Enabled: true},
+		} {
+			if err := mock.CreateUser(ctx, user); err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+		}
+		return mock
+	}
+	desired := func() []*userpool.User {
+		return []*userpool.User{
+			{Username: "jane", Email: "jane@example.com", Enabled: true},
+			{Username: "john", Email: "john@example.com", Enabled: true},
+			{Username: "new", Email: "new@example.com", Enabled: true},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		opts        userpool.ConvergeOptions
+		wantPruned  []string
+		wantStale   bool
+		wantEnabled bool
+	}{
+		{name: "without pruning", wantStale: true, wantEnabled: true},
+		{name: "prune disable", opts: userpool.ConvergeOptions{Prune: userpool.PruneDisable},
+			wantPruned: []string{"stale"}, wantStale: true},
+		{name: "prune delete", opts: userpool.ConvergeOptions{Prune: userpool.PruneDelete},
+			wantPruned: []string{"stale"}},
+		{name: "prune over the limit", opts: userpool.ConvergeOptions{Prune: userpool.PruneDelete, MaxPrune: 1},
+			wantPruned: []string{"stale"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := setup(t)
+			summary, err := userpool.Converge(ctx, mock, desired(), tt.opts)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !slices.Equal(summary.Created, []string{"new"}) || !slices.Equal(summary.Updated, []string{"john"}) ||
+				!slices.Equal(summary.Unchanged, []string{"jane"}) || !slices.Equal(summary.Pruned, tt.wantPruned) {
+				t.Errorf("unexpected summary %+v", summary)
+			}
+			if user, err := mock.GetUser(ctx, "john"); err != nil || user.Email != "john@example.com" {
+				t.Errorf("expected john to be updated, got %+v, %v", user, err)
+			}
+			stale, err := mock.GetUser(ctx, "stale")
+			if (err == nil) != tt.wantStale {
+				t.Fatalf("expected stale to exist: %v, got %v", tt.wantStale, err)
+			}
+			if stale != nil && stale.Enabled != tt.wantEnabled {
+				t.Errorf("expected stale to be enabled: %v", tt.wantEnabled)
+			}
+		})
+	}
+
+	t.Run("refuses to prune more than the limit", func(t *testing.T) {
+		mock := setup(t)
+		summary, err := userpool.Converge(ctx, mock, desired()[:1],
+			userpool.ConvergeOptions{Prune: userpool.PruneDelete, MaxPrune: 1})
+		if err == nil {
+			t.Fatalf("expected an error when the limit is exceeded")
+		}
+		if len(summary.Pruned) != 0 || len(summary.Unmanaged) != 2 {
+			t.Errorf("expected nothing to be pruned, got %+v", summary)
+		}
+		if _, err := mock.GetUser(ctx, "stale"); err != nil {
+			t.Errorf("expected stale to be kept, got %v", err)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		mock := setup(t)
+		summary, err := userpool.Converge(ctx, mock, desired(),
+			userpool.ConvergeOptions{Prune: userpool.PruneDelete, DryRun: true})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if got := summary.String(); got != "1 created, 1 updated, 1 unchanged, 1 pruned, 0 unmanaged, 0 failed" {
+			t.Errorf("unexpected summary %q", got)
+		}
+		if _, err := mock.GetUser(ctx, "new"); err == nil {
+			t.Errorf("expected no user to be created")
+		}
+		if _, err := mock.GetUser(ctx, "stale"); err != nil {
+			t.Errorf("expected stale to be kept, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import "slices"
+
+// DiffUser returns the fields in which actual differs from desired, e.g.
+// "email" or "attributes.custom:team". Only attributes set on desired are
+// compared, so attributes written by other means are ignored, and
+// EmailVerified only when desired sets it explicitly.
+func DiffUser(desired, actual *User) []string {
+	var fields []string
+	if desired.Email != actual.Email {
+		fields = append(fields, "email")
+	}
+	if desired.EmailVerified != nil && (actual.EmailVerified == nil || *desired.EmailVerified != *actual.EmailVerified) {
+		fields = append(fields, "emailVerified")
+	}
+	if desired.SecondaryEmail != "" && desired.SecondaryEmail != actual.SecondaryEmail {
+		fields = append(fields, "secondaryEmail")
+	}
+	if desired.PreferredUsername != "" && desired.PreferredUsername != actual.PreferredUsername {
+		fields = append(fields, "preferredUsername")
+	}
+	if desired.Locale != "" && desired.Locale != actual.Locale {
+		fields = append(fields, "locale")
+	}
+	if desired.Address != nil && (actual.Address == nil || *desired.Address != *actual.Address) {
+		fields = append(fields, "address")
+	}
+	if desired.Picture != "" && desired.Picture != actual.Picture {
+		fields = append(fields, "picture")
+	}
+	if desired.Profile != "" && desired.Profile != actual.Profile {
+		fields = append(fields, "profile")
+	}
+	if desired.Nickname != "" && desired.Nickname != actual.Nickname {
+		fields = append(fields, "nickname")
+	}
+	if desired.Website != "" && desired.Website != actual.Website {
+		fields = append(fields, "website")
+	}
+	if desired.ZoneInfo != "" && desired.ZoneInfo != actual.ZoneInfo {
+		fields = append(fields, "zoneInfo")
+	}
+	if desired.Birthdate != "" && desired.Birthdate != actual.Birthdate {
+		fields = append(fields, "birthdate")
+	}
+	if desired.Gender != "" && desired.Gender != actual.Gender {
+		fields = append(fields, "gender")
+	}
+	if desired.Enabled != actual.Enabled {
+		fields = append(fields, "enabled")
+	}
+	if !desired.Enabled && desired.DisableReason != actual.DisableReason {
+		fields = append(fields, "disableReason")
+	}
+
+	var attributes []string
+	for name, value := range desired.Attributes {
+		if current, ok := actual.Attributes[name]; !ok || current != value {
+			attributes = append(attributes, "attributes."+name)
+		}
+	}
+	slices.Sort(attributes)
+	return append(fields, attributes...)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffUser(t *testing.T) {
+	verified := true
+	desired := &User{
+		Username:      "jane",
+		Email:         "jane@example.com",
+		EmailVerified: &verified,
+		Enabled:       true,
+		Attributes:    map[string]string{"custom:team": "platform", "givenName": "Jane"},
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		actual := &User{
+			Username:      "jane",
+			Email:         "jane@example.com",
+			EmailVerified: &verified,
+			Enabled:       true,
+			Attributes:    map[string]string{"custom:team": "platform", "givenName": "Jane", "other": "x"},
+		}
+		if fields := DiffUser(desired, actual); len(fields) != 0 {
+			t.Errorf("expected no differences, got %v", fields)
+		}
+	})
+
+	t.Run("drifted", func(t *testing.T) {
+		actual := &User{
+			Username:   "jane",
+			Email:      "old@example.com",
+			Attributes: map[string]string{"custom:team": "sales"},
+		}
+		want := []string{"email", "emailVerified", "enabled", "attributes.custom:team", "attributes.givenName"}
+		if fields := DiffUser(desired, actual); !slices.Equal(fields, want) {
+			t.Errorf("expected %v, got %v", want, fields)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"fmt"
+)
+
+// DisableUser disables the pool user username, recording reason as its
+// DisableReason unless reason is empty, which keeps the recorded one. Only
+// what changes is written, so disabling a disabled user with the same reason
+// makes no call besides the read. It reports whether anything was written.
+func DisableUser(ctx context.Context, client Client, username, reason string) (bool, error) {
+	current, err := client.GetUser(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user %s: %w", username, err)
+	}
+	disabled := *current
+	disabled.Enabled = false
+	if reason != "" {
+		disabled.DisableReason = reason
+	}
+	written, err := client.UpdateUserIfChanged(ctx, current, &disabled)
+	if err != nil {
+		return false, fmt.Errorf("failed to disable user %s: %w", username, err)
+	}
+	return written, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestDisableUser(t *testing.T) {
+	ctx := context.Background()
+	client := cognito.NewMockClient()
+	if err := client.CreateUser(ctx, &userpool.User{Username: "jane", Email: "jane@example.com", Enabled: true,
+		Attributes: map[string]string{"custom:team": "a"}}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	written, err := userpool.DisableUser(ctx, client, "jane", "offboarded")
+	if err != nil || !written {
+		t.Fatalf("expected the user to be disabled, got %v, %v", written, err)
+	}
+	user, _ := client.GetUser(ctx, "jane")
+	if user.Enabled || user.DisableReason != "offboarded" || user.Attributes["custom:team"] != "a" {
+		t.Errorf("expected only the user to be disabled with the reason, got %+v", user)
+	}
+
+	written, err = userpool.DisableUser(ctx, client, "jane", "")
+	if err != nil || written {
+		t.Errorf("expected disabling again to write nothing, got %v, %v", written, err)
+	}
+	if user, _ := client.GetUser(ctx, "jane"); user.DisableReason != "offboarded" {
+		t.Errorf("expected an empty reason to keep the recorded one, got %q", user.DisableReason)
+	}
+
+	if _, err := userpool.DisableUser(ctx, client, "john", ""); !errors.Is(err, userpool.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
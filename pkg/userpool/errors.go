@@ -0,0 +1,105 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrUserNotFound is returned when a user does not exist in the user pool
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserExists is returned when creating a user whose username is taken
+	ErrUserExists = errors.New("user already exists")
+
+	// ErrUserAlreadyConfirmed is returned when confirming a user that is
+	// already confirmed
+	ErrUserAlreadyConfirmed = errors.New("user already confirmed")
+
+	// ErrAliasExists is returned when the email is used as an alias and
+	// another user already has it
+	ErrAliasExists = errors.New("email already used by another user")
+
+	// ErrAttributeNotVerifiable is returned when verifying an attribute other
+	// than email or phone_number
+	ErrAttributeNotVerifiable = errors.New("attribute cannot be verified")
+
+	// ErrMFADisabled is returned when setting an MFA preference while MFA is
+	// turned off for the user pool. The pool's MFA configuration has to be
+	// changed, retrying won't help.
+	ErrMFADisabled = errors.New("MFA is turned off for the user pool")
+
+	// ErrGroupNotFound is returned when a group does not exist in the user
+	// pool
+	ErrGroupNotFound = errors.New("group not found")
+
+	// ErrGroupExists is returned when creating a group whose name is taken
+	ErrGroupExists = errors.New("group already exists")
+
+	// ErrThrottled is returned when the user pool kept rejecting a call for
+	// exceeding its request quota after all retries. Reducing the request
+	// rate or raising the quota helps, retrying right away doesn't.
+	ErrThrottled = errors.New("throttled by the user pool")
+
+	// ErrNoUserPool is returned by a Router when no user pool is configured
+	// for the context of a call
+	ErrNoUserPool = errors.New("no user pool configured")
+
+	// ErrInvalidUsername is returned when a username doesn't fit how users
+	// sign in to the user pool, e.g. an email-like username in a pool using
+	// email as an alias. The username has to change, retrying won't help.
+	ErrInvalidUsername = errors.New("invalid username for user pool")
+
+	// ErrInvalidParameter matches every *InvalidParameterError
+	ErrInvalidParameter = errors.New("invalid parameter")
+)
+
+// InvalidParameterError is returned when the user pool rejects a value of the
+// request, e.g. an attribute value that is too long or malformed. Retrying
+// won't help until the value changes.
+type InvalidParameterError struct {
+	// Field is the logical name of the rejected attribute, or empty if the
+	// message doesn't tell
+	Field string
+	// Message is the user pool's explanation
+	Message string
+}
+
+func (e *InvalidParameterError) Error() string {
+	if e.Field == "" {
+		return "invalid parameter: " + e.Message
+	}
+	return "invalid parameter " + e.Field + ": " + e.Message
+}
+
+// Is makes errors.Is(err, ErrInvalidParameter) match
+func (e *InvalidParameterError) Is(target error) bool {
+	return target == ErrInvalidParameter
+}
+
+// MissingGroupsError is returned when a user is added to groups that don't
+// exist in the user pool
+type MissingGroupsError struct {
+	// Groups lists the missing group names in sorted order
+	Groups []string
+}
+
+func (e *MissingGroupsError) Error() string {
+	return "groups not found in user pool: " + strings.Join(e.Groups, ", ")
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import "strings"
+
+// QuoteFilterValue quotes s for use as the value of a Cognito ListUsers
+// filter. A filter has the form
+//
+//	attribute = "value"   (exact match)
+//	attribute ^= "value"  (prefix match)
+//
+// where the value is a double-quoted string in which double quotes and
+// backslashes are escaped with a backslash. Quoting every value this way
+// keeps a value containing quotes from changing the meaning of the filter.
+// Only standard attributes such as email, username or phone_number can be
+// filtered on; custom attributes cannot.
+func QuoteFilterValue(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
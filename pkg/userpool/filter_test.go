@@ -0,0 +1,60 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestQuoteFilterValue(t *testing.T) {
+	tests := map[string]string{
+		"jane@example.com": `"jane@example.com"`,
+		`o"brien`:          `"o\"brien"`,
+		`back\slash`:       `"back\\slash"`,
+		"":                 `""`,
+	}
+	for in, want := range tests {
+		if got := userpool.QuoteFilterValue(in); got != want {
+			t.Errorf("QuoteFilterValue(%q) = %s, want %s", in, got, want)
+		}
+	}
+
+	ctx := context.Background()
+	mock := cognito.NewMockClient()
+	for _, user := range []*userpool.User{
+		{Username: "jane", Email: `jane"@example.com`},
+		{Username: "john", Email: "john@example.com"},
+	} {
+		if err := mock.CreateUser(ctx, user); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+
+	user, err := mock.GetUserByEmail(ctx, `jane"@example.com`)
+	if err != nil || user.Username != "jane" {
+		t.Fatalf("expected jane, got %v, %v", user, err)
+	}
+	// A value trying to turn the exact match into a prefix match finds nobody
+	if _, err := mock.GetUserByEmail(ctx, `" ^= "`); !errors.Is(err, userpool.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
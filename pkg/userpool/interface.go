@@ -18,29 +18,315 @@ package userpool
 
 import (
 	"context"
+	"time"
 )
 
+// Status is the account status of a user in a user pool
+type Status string
+
+const (
+	// StatusUnconfirmed means the user signed up but has not been confirmed
+	StatusUnconfirmed Status = "Unconfirmed"
+	// StatusConfirmed means the user has been confirmed
+	StatusConfirmed Status = "Confirmed"
+	// StatusForceChangePassword means the user must change the temporary
+	// password on first sign-in
+	StatusForceChangePassword Status = "ForceChangePassword"
+	// StatusResetRequired means the user must reset the password
+	StatusResetRequired Status = "ResetRequired"
+	// StatusExternalProvider means the user signed in through a federated
+	// identity provider
+	StatusExternalProvider Status = "ExternalProvider"
+	// StatusArchived means the user has been archived
+	StatusArchived Status = "Archived"
+	// StatusCompromised means the user has been flagged as compromised
+	StatusCompromised Status = "Compromised"
+	// StatusUnknown is used for status values the client does not recognize.
+	// The original value is kept in User.RawStatus.
+	StatusUnknown Status = "Unknown"
+)
+
+// MFA methods a user can prefer
+const (
+	MFASoftwareToken = "SOFTWARE_TOKEN_MFA"
+	MFASMS           = "SMS_MFA"
+)
+
+// Identity is an external identity provider account linked to a user
+type Identity struct {
+	// ProviderName is the name of the identity provider in the user pool
+	ProviderName string
+	// UserID is the user's subject at the identity provider
+	UserID string
+}
+
 // User represents a user in a user pool
 type User struct {
 	Username string
 	Email    string
 	Enabled  bool
+
+	// EmailVerified marks the email as verified. When nil, the client decides
+	// based on its configured default.
+	EmailVerified *bool
+
+	// SecondaryEmail is an additional contact email. Unlike Email it is not
+	// an alias and has no verified flag. Writes leave the stored value
+	// unchanged when it is empty.
+	SecondaryEmail string
+
+	// PreferredUsername is the name applications display, stored in the
+	// preferred_username attribute and separate from Username. Writes leave
+	// the stored value unchanged when it is empty.
+	PreferredUsername string
+
+	// Locale is the user's BCP 47 language tag, e.g. "en-US", stored in the
+	// locale attribute and used to pick localized messages. Writes leave the
+	// stored value unchanged when it is empty and reject invalid tags with an
+	// *InvalidParameterError.
+	Locale string
+
+	// Address is the user's postal address, stored as JSON in the address
+	// attribute. Writes leave the stored value unchanged when it is nil.
+	Address *Address
+
+	// Picture and Profile are URLs of the user's avatar and profile page,
+	// stored in the picture and profile attributes. Writes leave the stored
+	// values unchanged when they are empty and reject anything but absolute
+	// http and https URLs with an *InvalidParameterError.
+	Picture string
+	Profile string
+
+	// Nickname, Website, ZoneInfo, Birthdate and Gender hold the OIDC
+	// standard claims of the same names. Writes leave the stored values
+	// unchanged when they are empty and reject a Website that isn't an
+	// absolute http or https URL, a ZoneInfo that isn't an IANA time zone such
+	// as "Europe/Paris" and a Birthdate that isn't a YYYY-MM-DD date, or
+	// 0000-MM-DD without the year, with an *InvalidParameterError.
+	Nickname  string
+	Website   string
+	ZoneInfo  string
+	Birthdate string
+	Gender    string
+
+	// DisableReason records why a disabled user was disabled, e.g.
+	// "offboarded". It is only written while Enabled is false and cleared
+	// when the user is enabled.
+	DisableReason string
+
+	// PhoneNumberVerified reports whether the phone number is verified. It is
+	// set by the client and ignored on writes.
+	PhoneNumberVerified *bool
+
+	// PreferredMFA is the user's preferred MFA method, e.g.
+	// "SOFTWARE_TOKEN_MFA", or empty when MFA is not set up. It is set by
+	// GetUser and ignored on writes.
+	PreferredMFA string
+
+	// Status is the account status of the user and RawStatus the unmapped
+	// value reported by the user pool. Both are set by the client and ignored
+	// on writes.
+	Status    Status
+	RawStatus string
+
+	// Attributes holds additional user attributes keyed by their logical name.
+	// Clients translate logical names to pool-specific attribute names.
+	Attributes map[string]string
+
+	// DeleteAttributes lists logical attribute names removed from the user by
+	// UpdateUser and UpdateUserDelta. Names also set in Attributes, or not set
+	// on the user, are skipped. It is never returned by reads.
+	DeleteAttributes []string
+
+	// Identities lists the external identities linked to the user. It is set
+	// by the client and ignored on writes; use LinkProvider to add links.
+	Identities []Identity
+
+	// SendInvitation makes CreateUser send the invitation message with the
+	// temporary password. It is ignored for disabled users and by other
+	// writes, and never returned by reads.
+	SendInvitation bool
+
+	// ClientMetadata is passed to the Lambda triggers invoked by UpdateUser,
+	// e.g. the custom message trigger that sends the verification message
+	// after an email change. It is never returned by reads.
+	ClientMetadata map[string]string
+
+	// LastModified is the time the user was last modified in the user pool.
+	// It is set by the client and ignored on writes.
+	LastModified time.Time
+
+	// CreatedAt is the time the user was created in the user pool. It is set
+	// by the client and ignored on writes.
+	CreatedAt time.Time
+}
+
+// IsVerifiable reports whether attribute has a verified flag that
+// VerifyAttribute can set
+func IsVerifiable(attribute string) bool {
+	return attribute == "email" || attribute == "phone_number"
 }
 
 // Client defines the interface for managing users in a user pool
 type Client interface {
-	// CreateUser creates a new user in the user pool
+	// CreateUser creates a new user in the user pool, enabled or disabled
+	// as user.Enabled says. If user.Username is empty and user.Email is set, a
+	// username is generated and written back to user.Username.
 	CreateUser(ctx context.Context, user *User) error
 
 	// GetUser retrieves a user from the user pool by username
 	GetUser(ctx context.Context, username string) (*User, error)
 
+	// GetUserStatus returns only the account status and enabled state of a
+	// user. It is cheaper than GetUser for callers that don't need the
+	// attributes.
+	GetUserStatus(ctx context.Context, username string) (Status, bool, error)
+
 	// UpdateUser updates an existing user in the user pool
 	UpdateUser(ctx context.Context, user *User) error
 
+	// UpdateUserDelta updates an existing user like UpdateUser, but only
+	// writes the attributes that differ from current, the user as last read
+	// with GetUser. A nil current writes all attributes.
+	UpdateUserDelta(ctx context.Context, current, user *User) error
+
+	// UpdateUserIfChanged updates the user like UpdateUserDelta and reports
+	// whether anything was written, so callers can tell an update from a
+	// no-op. A nil current always writes.
+	UpdateUserIfChanged(ctx context.Context, current, user *User) (bool, error)
+
+	// EnsureUser creates the user if it doesn't exist and updates it
+	// otherwise. It returns the user as stored in the user pool afterwards.
+	EnsureUser(ctx context.Context, user *User) (*User, error)
+
 	// DeleteUser removes a user from the user pool
 	DeleteUser(ctx context.Context, username string) error
 
+	// VerifyAttribute marks the user's email or phone_number attribute as
+	// verified after an out-of-band check. Other attributes are rejected with
+	// ErrAttributeNotVerifiable.
+	VerifyAttribute(ctx context.Context, username, attribute string) error
+
+	// SetPreferredMFA makes method, MFASoftwareToken or MFASMS, the user's
+	// preferred MFA method. It returns ErrMFADisabled when the user pool has
+	// MFA turned off.
+	SetPreferredMFA(ctx context.Context, username, method string) error
+
+	// ConfirmUser confirms an unconfirmed user. It returns ErrUserNotFound or
+	// ErrUserAlreadyConfirmed when the user cannot be confirmed.
+	ConfirmUser(ctx context.Context, username string) error
+
+	// ResendInvitation sends the invitation message with a new temporary
+	// password to a user that never signed in, restarting the password's
+	// validity. It returns ErrUserAlreadyConfirmed when the user has already
+	// changed the temporary password.
+	ResendInvitation(ctx context.Context, username string) error
+
+	// SignOutUser signs the user out of all sessions by revoking the user's
+	// refresh tokens. Access and ID tokens stay valid until they expire. It
+	// returns ErrUserNotFound if there is no such user.
+	SignOutUser(ctx context.Context, username string) error
+
+	// ResetPassword invalidates the user's password, so the user has to set
+	// a new one with a code sent to the verified email or phone number. It
+	// returns ErrUserNotFound if there is no such user.
+	ResetPassword(ctx context.Context, username string) error
+
+	// ForcePasswordChange replaces the user's password with a temporary one
+	// and sends it in the invitation message, so the user has to choose a new
+	// password at the next sign-in. The user's status becomes
+	// StatusForceChangePassword. It returns ErrUserNotFound if there is no
+	// such user.
+	ForcePasswordChange(ctx context.Context, username string) error
+
+	// ForgetDevices forgets all devices remembered for the user, so none of
+	// them can skip MFA. It returns ErrUserNotFound if there is no such user.
+	ForgetDevices(ctx context.Context, username string) error
+
+	// LinkProvider links an external identity provider account to the user.
+	// Linking an already linked identity succeeds without changes.
+	LinkProvider(ctx context.Context, username, providerName, providerAttributeValue string) error
+
+	// UpdateGroups adds the user to the add groups and removes it from the
+	// remove groups. All add groups must exist, otherwise no membership is
+	// changed and a *MissingGroupsError is returned.
+	UpdateGroups(ctx context.Context, username string, add, remove []string) error
+
+	// ListGroupsForUser returns the sorted names of the groups the user
+	// belongs to. It is cheaper than GetUser for callers that only need the
+	// memberships and returns ErrUserNotFound if there is no such user.
+	ListGroupsForUser(ctx context.Context, username string) ([]string, error)
+
 	// ListUsers lists all users in the user pool
 	ListUsers(ctx context.Context) ([]*User, error)
+
+	// ListUsersProjected lists all users like ListUsers, but only populates
+	// the fields selected by projection. It is cheaper for callers that need
+	// a few fields of every user of a large pool.
+	ListUsersProjected(ctx context.Context, projection Projection) ([]*User, error)
+
+	// ListUsersFiltered lists the users matching a Cognito filter expression
+	// such as `email ^= "jane"`. Quote values with QuoteFilterValue.
+	ListUsersFiltered(ctx context.Context, filter string) ([]*User, error)
+
+	// GetUserByEmail returns the user with the given email, or
+	// ErrUserNotFound if there is none
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+
+	// ListUsersPage lists one page of users starting at cursor, where an empty
+	// cursor starts at the first page. It returns the cursor of the next page,
+	// which is empty after the last page.
+	ListUsersPage(ctx context.Context, cursor string) ([]*User, string, error)
+
+	// ListUsersModifiedSince lists users modified after the given time
+	ListUsersModifiedSince(ctx context.Context, since time.Time) ([]*User, error)
+
+	// CountUsers returns the number of users in the user pool. Clients may
+	// return an estimate that lags behind recent changes.
+	CountUsers(ctx context.Context) (int, error)
+}
+
+// SignUpClient is implemented by clients that support the self-service
+// sign-up flow. These operations act on behalf of the user through an app
+// client rather than with admin credentials.
+type SignUpClient interface {
+	// ResendConfirmationCode sends a new confirmation code to an unconfirmed
+	// user
+	ResendConfirmationCode(ctx context.Context, username string) error
+
+	// ConfirmSignUp confirms a user with the code the user received. It
+	// returns ErrUserNotFound or ErrUserAlreadyConfirmed when the user
+	// cannot be confirmed.
+	ConfirmSignUp(ctx context.Context, username, code string) error
+}
+
+// Group is a group of the user pool
+type Group struct {
+	Name        string
+	Description string
+
+	// Precedence decides which group's role a user in several groups
+	// receives. Lower values take precedence; nil means none.
+	Precedence *int32
+
+	// RoleARN is the IAM role of the group's users, empty for none
+	RoleARN string
+}
+
+// GroupClient is implemented by clients that manage the groups of the user
+// pool themselves. Memberships are changed with Client.UpdateGroups.
+type GroupClient interface {
+	// GetGroup returns a group, or ErrGroupNotFound if there is none
+	GetGroup(ctx context.Context, name string) (*Group, error)
+
+	// CreateGroup creates a group. It returns ErrGroupExists when the name
+	// is taken.
+	CreateGroup(ctx context.Context, group *Group) error
+
+	// UpdateGroup replaces the description, precedence and role of a group
+	UpdateGroup(ctx context.Context, group *Group) error
+
+	// DeleteGroup deletes a group and all of its memberships. It returns
+	// ErrGroupNotFound if there is no such group.
+	DeleteGroup(ctx context.Context, name string) error
 }
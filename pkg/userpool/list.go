@@ -0,0 +1,118 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"time"
+)
+
+// ListUsersWithin pages through the user pool from cursor until the last page
+// or until budget has elapsed, whichever comes first. It returns the users
+// collected and the cursor to resume from; an empty cursor means the listing
+// is complete, any other value means the result is partial and a later call
+// with that cursor continues where this one stopped.
+//
+// The budget is checked between pages, so a call takes up to one page longer
+// than budget. The first page is always fetched so every call makes progress.
+func ListUsersWithin(ctx context.Context, client Client, cursor string, budget time.Duration) ([]*User, string,
+	error) {
+	deadline := time.Now().Add(budget)
+
+	var users []*User
+	for {
+		page, next, err := client.ListUsersPage(ctx, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		users = append(users, page...)
+		cursor = next
+
+		if cursor == "" || !time.Now().Before(deadline) {
+			return users, cursor, nil
+		}
+	}
+}
+
+// DefaultChurnThreshold is the relative change of the user count during a
+// listing above which Snapshot.Churned reports it
+const DefaultChurnThreshold = 0.05
+
+// Snapshot is a full listing of the user pool made by ListUsersSnapshot
+type Snapshot struct {
+	Users []*User
+	// Pages is the number of pages fetched
+	Pages int
+	// CountBefore and CountAfter are the user counts reported by CountUsers
+	// before the first and after the last page
+	CountBefore int
+	CountAfter  int
+}
+
+// Churned reports whether the user count changed by more than threshold,
+// relative to the count before the listing, while the pool was listed. Users
+// created or deleted during a listing may or may not be part of it, so a
+// churned snapshot is not a reliable basis for reports. A threshold of zero
+// uses DefaultChurnThreshold.
+//
+// Cognito's count is an estimate updated every few minutes, so churn is only
+// noticed in listings that take a while; a snapshot that didn't churn can
+// still miss concurrent changes.
+func (s *Snapshot) Churned(threshold float64) bool {
+	if threshold <= 0 {
+		threshold = DefaultChurnThreshold
+	}
+	change := s.CountAfter - s.CountBefore
+	if change < 0 {
+		change = -change
+	}
+	if s.CountBefore == 0 {
+		return change > 0
+	}
+	return float64(change)/float64(s.CountBefore) > threshold
+}
+
+// ListUsersSnapshot pages through the whole user pool like ListUsers and
+// records the number of pages and the user count before and after, so
+// callers can tell with Snapshot.Churned whether the pool changed
+// significantly during the listing
+func ListUsersSnapshot(ctx context.Context, client Client) (*Snapshot, error) {
+	before, err := client.CountUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{CountBefore: before}
+	cursor := ""
+	for {
+		page, next, err := client.ListUsersPage(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Users = append(snapshot.Users, page...)
+		snapshot.Pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if snapshot.CountAfter, err = client.CountUsers(ctx); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
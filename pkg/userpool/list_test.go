@@ -0,0 +1,160 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestListUsersWithin(t *testing.T) {
+	ctx := context.Background()
+	mock := cognito.NewMockClient()
+	for i := range 150 {
+		if err := mock.CreateUser(ctx, &userpool.User{Username: fmt.Sprintf("user-%03d", i)}); err != nil {
+			t.Fatalf("failed to seed user: %v", err)
+		}
+	}
+
+	t.Run("large budget lists everything", func(t *testing.T) {
+		users, cursor, err := userpool.ListUsersWithin(ctx, mock, "", time.Minute)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cursor != "" || len(users) != 150 {
+			t.Errorf("expected 150 users and no cursor, got %d users and cursor %q", len(users), cursor)
+		}
+	})
+
+	t.Run("exhausted budget returns a page and resumes", func(t *testing.T) {
+		seen := make(map[string]bool)
+		cursor, calls := "", 0
+		for {
+			users, next, err := userpool.ListUsersWithin(ctx, mock, cursor, 0)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			calls++
+			for _, user := range users {
+				if seen[user.Username] {
+					t.Fatalf("user %s listed twice", user.Username)
+				}
+				seen[user.Username] = true
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if len(seen) != 150 || calls != 3 {
+			t.Errorf("expected 150 users in 3 calls, got %d users in %d calls", len(seen), calls)
+		}
+	})
+
+	t.Run("small pages", func(t *testing.T) {
+		mock.SetPageSize(7)
+		t.Cleanup(func() { mock.SetPageSize(0) })
+		users, cursor, err := userpool.ListUsersWithin(ctx, mock, "", time.Minute)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if cursor != "" || len(users) != 150 {
+			t.Errorf("expected 150 users and no cursor, got %d users and cursor %q", len(users), cursor)
+		}
+	})
+
+	t.Run("canceled between pages", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		pages := 0
+		mock.SetPageHook(func(string) {
+			if pages++; pages == 2 {
+				cancel()
+			}
+		})
+		t.Cleanup(func() { mock.SetPageHook(nil) })
+
+		if _, _, err := userpool.ListUsersWithin(ctx, mock, "", time.Minute); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if pages != 2 {
+			t.Errorf("expected listing to stop at the second page, got %d pages", pages)
+		}
+	})
+}
+
+func TestListUsersSnapshot(t *testing.T) {
+	ctx := context.Background()
+	seed := func(t *testing.T) *cognito.MockClient {
+		mock := cognito.NewMockClient()
+		mock.SetPageSize(10)
+		for i := range 40 {
+			if err := mock.CreateUser(ctx, &userpool.User{Username: fmt.Sprintf("user-%03d", i)}); err != nil {
+				t.Fatalf("failed to seed user: %v", err)
+			}
+		}
+		return mock
+	}
+
+	t.Run("stable pool", func(t *testing.T) {
+		snapshot, err := userpool.ListUsersSnapshot(ctx, seed(t))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(snapshot.Users) != 40 || snapshot.Pages != 4 {
+			t.Errorf("expected 40 users in 4 pages, got %d users in %d pages", len(snapshot.Users), snapshot.Pages)
+		}
+		if snapshot.Churned(0) {
+			t.Errorf("expected no churn, got counts %d and %d", snapshot.CountBefore, snapshot.CountAfter)
+		}
+	})
+
+	t.Run("users created during the listing", func(t *testing.T) {
+		mock := seed(t)
+		created := 0
+		mock.SetPageHook(func(cursor string) {
+			if cursor == "" {
+				return
+			}
+			for range 5 {
+				created++
+				if err := mock.CreateUser(ctx, &userpool.User{Username: fmt.Sprintf("new-%03d", created)}); err != nil {
+					t.Errorf("failed to create user: %v", err)
+				}
+			}
+		})
+		snapshot, err := userpool.ListUsersSnapshot(ctx, mock)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if snapshot.CountBefore != 40 || snapshot.CountAfter != 40+created {
+			t.Errorf("expected counts 40 and %d, got %d and %d", 40+created, snapshot.CountBefore, snapshot.CountAfter)
+		}
+		if !snapshot.Churned(0) {
+			t.Errorf("expected churn to be detected")
+		}
+		if snapshot.Churned(1) {
+			t.Errorf("expected no churn above a threshold of 100%%")
+		}
+	})
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// ValidateLocale returns an *InvalidParameterError for the locale attribute if
+// locale is not a valid BCP 47 language tag such as "en" or "pt-BR". Unknown
+// languages and POSIX-style tags like "en_US" are rejected as well. An empty
+// locale is valid.
+func ValidateLocale(locale string) error {
+	if locale == "" {
+		return nil
+	}
+	if strings.Contains(locale, "_") {
+		return &InvalidParameterError{
+			Field:   "locale",
+			Message: fmt.Sprintf("%q is not a BCP 47 language tag: subtags are separated by hyphens", locale),
+		}
+	}
+	if _, err := language.Parse(locale); err != nil {
+		return &InvalidParameterError{
+			Field:   "locale",
+			Message: fmt.Sprintf("%q is not a BCP 47 language tag: %v", locale, err),
+		}
+	}
+	return nil
+}
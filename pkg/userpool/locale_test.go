@@ -0,0 +1,54 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		wantErr bool
+	}{
+		{locale: ""},
+		{locale: "en"},
+		{locale: "en-US"},
+		{locale: "pt-BR"},
+		{locale: "zh-Hant-TW"},
+		{locale: "en_US", wantErr: true},
+		{locale: "english", wantErr: true},
+		{locale: "xx", wantErr: true},
+		{locale: "en-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			err := ValidateLocale(tt.locale)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var invalid *InvalidParameterError
+			if !errors.As(err, &invalid) || invalid.Field != "locale" {
+				t.Fatalf("expected an InvalidParameterError for locale, got %v", err)
+			}
+		})
+	}
+}
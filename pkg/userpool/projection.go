@@ -0,0 +1,67 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+// Projection selects the fields of the users returned by ListUsersProjected.
+// Username is always set; unselected fields keep their zero value.
+type Projection struct {
+	// Enabled populates User.Enabled
+	Enabled bool
+	// Status populates User.Status and User.RawStatus
+	Status bool
+	// Timestamps populates User.LastModified and User.CreatedAt
+	Timestamps bool
+	// Email populates User.Email and User.EmailVerified
+	Email bool
+	// Attributes lists the logical names of the attributes to populate in
+	// User.Attributes. Nil populates none.
+	Attributes []string
+}
+
+// Apply returns a copy of user with only the fields of the projection
+func (p Projection) Apply(user *User) *User {
+	out := &User{Username: user.Username}
+	if p.Enabled {
+		out.Enabled = user.Enabled
+	}
+	if p.Status {
+		out.Status = user.Status
+		out.RawStatus = user.RawStatus
+	}
+	if p.Timestamps {
+		out.LastModified = user.LastModified
+		out.CreatedAt = user.CreatedAt
+	}
+	if p.Email {
+		out.Email = user.Email
+		if user.EmailVerified != nil {
+			verified := *user.EmailVerified
+			out.EmailVerified = &verified
+		}
+	}
+	for _, name := range p.Attributes {
+		value, ok := user.Attributes[name]
+		if !ok {
+			continue
+		}
+		if out.Attributes == nil {
+			out.Attributes = make(map[string]string, len(p.Attributes))
+		}
+		out.Attributes[name] = value
+	}
+	return out
+}
@@ -0,0 +1,273 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Operation describes a single call made through a RecordingClient
+type Operation struct {
+	// Name is the name of the Client method, e.g. "CreateUser"
+	Name string
+	// Username is the user the call targeted, empty for list calls
+	Username string
+	// Args holds the remaining call arguments
+	Args []any
+	// Err is the error returned by the wrapped client
+	Err error
+}
+
+// RecordingClient wraps a Client and records every call in order. It is meant
+// for tests that assert which user pool operations were performed. It is safe
+// for concurrent use.
+type RecordingClient struct {
+	client Client
+
+	mu         sync.Mutex
+	operations []Operation
+}
+
+var _ Client = &RecordingClient{}
+
+// NewRecordingClient returns a RecordingClient delegating to client
+func NewRecordingClient(client Client) *RecordingClient {
+	return &RecordingClient{client: client}
+}
+
+// Operations returns the recorded operations in call order
+func (r *RecordingClient) Operations() []Operation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.operations...)
+}
+
+// Reset clears the recorded operations
+func (r *RecordingClient) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = nil
+}
+
+func (r *RecordingClient) record(name, username string, err error, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, Operation{Name: name, Username: username, Args: args, Err: err})
+}
+
+// CreateUser records the call and delegates to the wrapped client
+func (r *RecordingClient) CreateUser(ctx context.Context, user *User) error {
+	err := r.client.CreateUser(ctx, user)
+	r.record("CreateUser", usernameOf(user), err, copyOf(user))
+	return err
+}
+
+// GetUser records the call and delegates to the wrapped client
+func (r *RecordingClient) GetUser(ctx context.Context, username string) (*User, error) {
+	user, err := r.client.GetUser(ctx, username)
+	r.record("GetUser", username, err)
+	return user, err
+}
+
+// GetUserStatus records the call and delegates to the wrapped client
+func (r *RecordingClient) GetUserStatus(ctx context.Context, username string) (Status, bool, error) {
+	status, enabled, err := r.client.GetUserStatus(ctx, username)
+	r.record("GetUserStatus", username, err)
+	return status, enabled, err
+}
+
+// UpdateUser records the call and delegates to the wrapped client
+func (r *RecordingClient) UpdateUser(ctx context.Context, user *User) error {
+	err := r.client.UpdateUser(ctx, user)
+	r.record("UpdateUser", usernameOf(user), err, copyOf(user))
+	return err
+}
+
+// UpdateUserDelta records the call and delegates to the wrapped client
+func (r *RecordingClient) UpdateUserDelta(ctx context.Context, current, user *User) error {
+	err := r.client.UpdateUserDelta(ctx, current, user)
+	r.record("UpdateUserDelta", usernameOf(user), err, copyOf(current), copyOf(user))
+	return err
+}
+
+// UpdateUserIfChanged records the call and delegates to the wrapped client
+func (r *RecordingClient) UpdateUserIfChanged(ctx context.Context, current, user *User) (bool, error) {
+	changed, err := r.client.UpdateUserIfChanged(ctx, current, user)
+	r.record("UpdateUserIfChanged", usernameOf(user), err, copyOf(current), copyOf(user))
+	return changed, err
+}
+
+// EnsureUser records the call and delegates to the wrapped client
+func (r *RecordingClient) EnsureUser(ctx context.Context, user *User) (*User, error) {
+	result, err := r.client.EnsureUser(ctx, user)
+	r.record("EnsureUser", usernameOf(user), err, copyOf(user))
+	return result, err
+}
+
+// DeleteUser records the call and delegates to the wrapped client
+func (r *RecordingClient) DeleteUser(ctx context.Context, username string) error {
+	err := r.client.DeleteUser(ctx, username)
+	r.record("DeleteUser", username, err)
+	return err
+}
+
+// VerifyAttribute records the call and delegates to the wrapped client
+func (r *RecordingClient) VerifyAttribute(ctx context.Context, username, attribute string) error {
+	err := r.client.VerifyAttribute(ctx, username, attribute)
+	r.record("VerifyAttribute", username, err, attribute)
+	return err
+}
+
+// SetPreferredMFA records the call and delegates to the wrapped client
+func (r *RecordingClient) SetPreferredMFA(ctx context.Context, username, method string) error {
+	err := r.client.SetPreferredMFA(ctx, username, method)
+	r.record("SetPreferredMFA", username, err, method)
+	return err
+}
+
+// ConfirmUser records the call and delegates to the wrapped client
+func (r *RecordingClient) ConfirmUser(ctx context.Context, username string) error {
+	err := r.client.ConfirmUser(ctx, username)
+	r.record("ConfirmUser", username, err)
+	return err
+}
+
+// SignOutUser records and forwards the call
+func (r *RecordingClient) SignOutUser(ctx context.Context, username string) error {
+	err := r.client.SignOutUser(ctx, username)
+	r.record("SignOutUser", username, err)
+	return err
+}
+
+// ResetPassword records the call and delegates to the wrapped client
+func (r *RecordingClient) ResetPassword(ctx context.Context, username string) error {
+	err := r.client.ResetPassword(ctx, username)
+	r.record("ResetPassword", username, err)
+	return err
+}
+
+// ForcePasswordChange records the call and delegates to the wrapped client
+func (r *RecordingClient) ForcePasswordChange(ctx context.Context, username string) error {
+	err := r.client.ForcePasswordChange(ctx, username)
+	r.record("ForcePasswordChange", username, err)
+	return err
+}
+
+// ForgetDevices records the call and delegates to the wrapped client
+func (r *RecordingClient) ForgetDevices(ctx context.Context, username string) error {
+	err := r.client.ForgetDevices(ctx, username)
+	r.record("ForgetDevices", username, err)
+	return err
+}
+
+// ResendInvitation records the call and delegates to the wrapped client
+func (r *RecordingClient) ResendInvitation(ctx context.Context, username string) error {
+	err := r.client.ResendInvitation(ctx, username)
+	r.record("ResendInvitation", username, err)
+	return err
+}
+
+// LinkProvider records the call and delegates to the wrapped client
+func (r *RecordingClient) LinkProvider(ctx context.Context, username, providerName,
+	providerAttributeValue string) error {
+	err := r.client.LinkProvider(ctx, username, providerName, providerAttributeValue)
+	r.record("LinkProvider", username, err, providerName, providerAttributeValue)
+	return err
+}
+
+// UpdateGroups records the call and delegates to the wrapped client
+func (r *RecordingClient) UpdateGroups(ctx context.Context, username string, add, remove []string) error {
+	err := r.client.UpdateGroups(ctx, username, add, remove)
+	r.record("UpdateGroups", username, err, slices.Clone(add), slices.Clone(remove))
+	return err
+}
+
+// ListGroupsForUser records the call and delegates to the wrapped client
+func (r *RecordingClient) ListGroupsForUser(ctx context.Context, username string) ([]string, error) {
+	groups, err := r.client.ListGroupsForUser(ctx, username)
+	r.record("ListGroupsForUser", username, err)
+	return groups, err
+}
+
+// ListUsers records the call and delegates to the wrapped client
+func (r *RecordingClient) ListUsers(ctx context.Context) ([]*User, error) {
+	users, err := r.client.ListUsers(ctx)
+	r.record("ListUsers", "", err)
+	return users, err
+}
+
+// ListUsersProjected records and forwards the call
+func (r *RecordingClient) ListUsersProjected(ctx context.Context, projection Projection) ([]*User, error) {
+	users, err := r.client.ListUsersProjected(ctx, projection)
+	r.record("ListUsersProjected", "", err)
+	return users, err
+}
+
+// ListUsersFiltered records the call and delegates to the wrapped client
+func (r *RecordingClient) ListUsersFiltered(ctx context.Context, filter string) ([]*User, error) {
+	users, err := r.client.ListUsersFiltered(ctx, filter)
+	r.record("ListUsersFiltered", "", err, filter)
+	return users, err
+}
+
+// GetUserByEmail records the call and delegates to the wrapped client
+func (r *RecordingClient) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	user, err := r.client.GetUserByEmail(ctx, email)
+	r.record("GetUserByEmail", "", err, email)
+	return user, err
+}
+
+// ListUsersPage records the call and delegates to the wrapped client
+func (r *RecordingClient) ListUsersPage(ctx context.Context, cursor string) ([]*User, string, error) {
+	users, next, err := r.client.ListUsersPage(ctx, cursor)
+	r.record("ListUsersPage", "", err, cursor)
+	return users, next, err
+}
+
+// ListUsersModifiedSince records the call and delegates to the wrapped client
+func (r *RecordingClient) ListUsersModifiedSince(ctx context.Context, since time.Time) ([]*User, error) {
+	users, err := r.client.ListUsersModifiedSince(ctx, since)
+	r.record("ListUsersModifiedSince", "", err, since)
+	return users, err
+}
+
+// CountUsers records the call and delegates to the wrapped client
+func (r *RecordingClient) CountUsers(ctx context.Context) (int, error) {
+	count, err := r.client.CountUsers(ctx)
+	r.record("CountUsers", "", err)
+	return count, err
+}
+
+func usernameOf(user *User) string {
+	if user == nil {
+		return ""
+	}
+	return user.Username
+}
+
+// copyOf returns a shallow copy so later changes by the caller don't alter
+// the recorded arguments
+func copyOf(user *User) *User {
+	if user == nil {
+		return nil
+	}
+	out := *user
+	return &out
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestRecordingClient(t *testing.T) {
+	ctx := context.Background()
+	rec := userpool.NewRecordingClient(cognito.NewMockClient())
+
+	user := &userpool.User{Username: "jane", Email: "jane@example.com"}
+	if err := rec.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	user.Email = "changed@example.com"
+	if _, err := rec.GetUser(ctx, "missing"); err == nil {
+		t.Fatalf("expected GetUser of a missing user to fail")
+	}
+	if err := rec.DeleteUser(ctx, "jane"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+
+	ops := rec.Operations()
+	want := []struct{ name, username string }{
+		{"CreateUser", "jane"},
+		{"GetUser", "missing"},
+		{"DeleteUser", "jane"},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("expected %d operations, got %d: %v", len(want), len(ops), ops)
+	}
+	for i, w := range want {
+		if ops[i].Name != w.name || ops[i].Username != w.username {
+			t.Errorf("operation %d: expected %s(%s), got %s(%s)", i, w.name, w.username, ops[i].Name, ops[i].Username)
+		}
+	}
+	if ops[1].Err == nil {
+		t.Errorf("expected GetUser error to be recorded")
+	}
+	if recorded := ops[0].Args[0].(*userpool.User); recorded.Email != "jane@example.com" {
+		t.Errorf("expected recorded arguments to be unaffected by later changes, got %s", recorded.Email)
+	}
+
+	rec.Reset()
+	if len(rec.Operations()) != 0 {
+		t.Errorf("expected no operations after Reset")
+	}
+}
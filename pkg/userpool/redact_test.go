@@ -0,0 +1,35 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestRedact(t *testing.T) {
+	if got := userpool.Redact("test"); got != "sha256:9f86d081884c" {
+		t.Errorf("expected sha256:9f86d081884c, got %s", got)
+	}
+	if userpool.Redact("jane@example.com") != userpool.Redact("jane@example.com") {
+		t.Errorf("expected the same value to yield the same hash")
+	}
+	if got := userpool.Redact(""); got != "" {
+		t.Errorf("expected empty value to stay empty, got %s", got)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Router is a Client that sends every call to the client resolved for its
+// context, e.g. the user pool of the kcp workspace a reconcile belongs to.
+// Calls fail with the error of the resolver, which should wrap ErrNoUserPool
+// when no user pool is configured for the context. The group methods require
+// the resolved client to be a GroupClient.
+type Router struct {
+	resolve func(ctx context.Context) (Client, error)
+}
+
+var (
+	_ Client      = &Router{}
+	_ GroupClient = &Router{}
+)
+
+// NewRouter returns a Router resolving clients with resolve
+func NewRouter(resolve func(ctx context.Context) (Client, error)) *Router {
+	return &Router{resolve: resolve}
+}
+
+// CreateUser delegates to the client resolved for ctx
+func (r *Router) CreateUser(ctx context.Context, user *User) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.CreateUser(ctx, user)
+}
+
+// GetUser delegates to the client resolved for ctx
+func (r *Router) GetUser(ctx context.Context, username string) (*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetUser(ctx, username)
+}
+
+// GetUserStatus delegates to the client resolved for ctx
+func (r *Router) GetUserStatus(ctx context.Context, username string) (Status, bool, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	return client.GetUserStatus(ctx, username)
+}
+
+// UpdateUser delegates to the client resolved for ctx
+func (r *Router) UpdateUser(ctx context.Context, user *User) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.UpdateUser(ctx, user)
+}
+
+// UpdateUserDelta delegates to the client resolved for ctx
+func (r *Router) UpdateUserDelta(ctx context.Context, current, user *User) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.UpdateUserDelta(ctx, current, user)
+}
+
+// UpdateUserIfChanged delegates to the client resolved for ctx
+func (r *Router) UpdateUserIfChanged(ctx context.Context, current, user *User) (bool, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return false, err
+	}
+	return client.UpdateUserIfChanged(ctx, current, user)
+}
+
+// EnsureUser delegates to the client resolved for ctx
+func (r *Router) EnsureUser(ctx context.Context, user *User) (*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.EnsureUser(ctx, user)
+}
+
+// DeleteUser delegates to the client resolved for ctx
+func (r *Router) DeleteUser(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.DeleteUser(ctx, username)
+}
+
+// VerifyAttribute delegates to the client resolved for ctx
+func (r *Router) VerifyAttribute(ctx context.Context, username, attribute string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.VerifyAttribute(ctx, username, attribute)
+}
+
+// SetPreferredMFA delegates to the client resolved for ctx
+func (r *Router) SetPreferredMFA(ctx context.Context, username, method string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.SetPreferredMFA(ctx, username, method)
+}
+
+// ConfirmUser delegates to the client resolved for ctx
+func (r *Router) ConfirmUser(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ConfirmUser(ctx, username)
+}
+
+// ResendInvitation delegates to the client resolved for ctx
+func (r *Router) ResendInvitation(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ResendInvitation(ctx, username)
+}
+
+// SignOutUser delegates to the client resolved for ctx
+func (r *Router) SignOutUser(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.SignOutUser(ctx, username)
+}
+
+// ResetPassword delegates to the client resolved for ctx
+func (r *Router) ResetPassword(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ResetPassword(ctx, username)
+}
+
+// ForcePasswordChange delegates to the client resolved for ctx
+func (r *Router) ForcePasswordChange(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ForcePasswordChange(ctx, username)
+}
+
+// ForgetDevices delegates to the client resolved for ctx
+func (r *Router) ForgetDevices(ctx context.Context, username string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.ForgetDevices(ctx, username)
+}
+
+// LinkProvider delegates to the client resolved for ctx
+func (r *Router) LinkProvider(ctx context.Context, username, providerName, providerAttributeValue string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.LinkProvider(ctx, username, providerName, providerAttributeValue)
+}
+
+// UpdateGroups delegates to the client resolved for ctx
+func (r *Router) UpdateGroups(ctx context.Context, username string, add, remove []string) error {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return client.UpdateGroups(ctx, username, add, remove)
+}
+
+// ListGroupsForUser delegates to the client resolved for ctx
+func (r *Router) ListGroupsForUser(ctx context.Context, username string) ([]string, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListGroupsForUser(ctx, username)
+}
+
+// ListUsers delegates to the client resolved for ctx
+func (r *Router) ListUsers(ctx context.Context) ([]*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListUsers(ctx)
+}
+
+// ListUsersProjected delegates to the client resolved for ctx
+func (r *Router) ListUsersProjected(ctx context.Context, projection Projection) ([]*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListUsersProjected(ctx, projection)
+}
+
+// ListUsersFiltered delegates to the client resolved for ctx
+func (r *Router) ListUsersFiltered(ctx context.Context, filter string) ([]*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListUsersFiltered(ctx, filter)
+}
+
+// GetUserByEmail delegates to the client resolved for ctx
+func (r *Router) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetUserByEmail(ctx, email)
+}
+
+// ListUsersPage delegates to the client resolved for ctx
+func (r *Router) ListUsersPage(ctx context.Context, cursor string) ([]*User, string, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return client.ListUsersPage(ctx, cursor)
+}
+
+// ListUsersModifiedSince delegates to the client resolved for ctx
+func (r *Router) ListUsersModifiedSince(ctx context.Context, since time.Time) ([]*User, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListUsersModifiedSince(ctx, since)
+}
+
+// CountUsers delegates to the client resolved for ctx
+func (r *Router) CountUsers(ctx context.Context) (int, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return client.CountUsers(ctx)
+}
+
+// GetGroup delegates to the client resolved for ctx
+func (r *Router) GetGroup(ctx context.Context, name string) (*Group, error) {
+	client, err := r.groupClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetGroup(ctx, name)
+}
+
+// CreateGroup delegates to the client resolved for ctx
+func (r *Router) CreateGroup(ctx context.Context, group *Group) error {
+	client, err := r.groupClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.CreateGroup(ctx, group)
+}
+
+// UpdateGroup delegates to the client resolved for ctx
+func (r *Router) UpdateGroup(ctx context.Context, group *Group) error {
+	client, err := r.groupClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.UpdateGroup(ctx, group)
+}
+
+// DeleteGroup delegates to the client resolved for ctx
+func (r *Router) DeleteGroup(ctx context.Context, name string) error {
+	client, err := r.groupClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.DeleteGroup(ctx, name)
+}
+
+// groupClient resolves the client for ctx as a GroupClient
+func (r *Router) groupClient(ctx context.Context) (GroupClient, error) {
+	client, err := r.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	groups, ok := client.(GroupClient)
+	if !ok {
+		return nil, fmt.Errorf("user pool client %T does not manage groups", client)
+	}
+	return groups, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+type poolKey struct{}
+
+func TestRouter(t *testing.T) {
+	pools := map[string]userpool.Client{
+		"a": cognito.NewMockClient(),
+		"b": userpool.NewRecordingClient(cognito.NewMockClient()),
+	}
+	router := userpool.NewRouter(func(ctx context.Context) (userpool.Client, error) {
+		client, ok := pools[ctx.Value(poolKey{}).(string)]
+		if !ok {
+			return nil, userpool.ErrNoUserPool
+		}
+		return client, nil
+	})
+	ctxA := context.WithValue(context.Background(), poolKey{}, "a")
+	ctxB := context.WithValue(context.Background(), poolKey{}, "b")
+
+	if err := router.CreateUser(ctxA, &userpool.User{Username: "jane"}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	if _, err := pools["a"].GetUser(context.Background(), "jane"); err != nil {
+		t.Errorf("expected user in the resolved pool, got %v", err)
+	}
+	if _, err := router.GetUser(ctxB, "jane"); !errors.Is(err, userpool.ErrUserNotFound) {
+		t.Errorf("expected user to be missing from the other pool, got %v", err)
+	}
+
+	ctxNone := context.WithValue(context.Background(), poolKey{}, "none")
+	if err := router.DeleteUser(ctxNone, "jane"); !errors.Is(err, userpool.ErrNoUserPool) {
+		t.Errorf("expected ErrNoUserPool, got %v", err)
+	}
+
+	if err := router.CreateGroup(ctxA, &userpool.Group{Name: "admins"}); err != nil {
+		t.Errorf("CreateGroup failed: %v", err)
+	}
+	if err := router.CreateGroup(ctxB, &userpool.Group{Name: "admins"}); err == nil {
+		t.Errorf("expected CreateGroup to fail for a client without group support")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// UserSealer encrypts users with AES-GCM, so a cache of users held in memory
+// doesn't contain their personal data in plaintext. It is meant for caching
+// decorators of Client in environments that require it and is safe for
+// concurrent use.
+type UserSealer struct {
+	aead cipher.AEAD
+}
+
+// NewUserSealer returns a UserSealer using key, which must be 16, 24 or 32
+// bytes long to select AES-128, AES-192 or AES-256
+func NewUserSealer(key []byte) (*UserSealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user sealer: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user sealer: %w", err)
+	}
+	return &UserSealer{aead: aead}, nil
+}
+
+// Seal returns user encrypted under a fresh nonce. The username is
+// authenticated but not encrypted, as callers key their cache by it, so a
+// sealed user only opens under the username it was sealed for.
+func (s *UserSealer) Seal(user *User) ([]byte, error) {
+	plaintext, err := json.Marshal(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal user: %w", err)
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to seal user: %w", err)
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, []byte(user.Username)), nil
+}
+
+// Open decrypts a user sealed by Seal for username. It fails if sealed was
+// modified, sealed with another key or sealed for another username.
+func (s *UserSealer) Open(username string, sealed []byte) (*User, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("failed to open sealed user: too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(username))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sealed user: %w", err)
+	}
+	var user User
+	if err := json.Unmarshal(plaintext, &user); err != nil {
+		return nil, fmt.Errorf("failed to open sealed user: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestUserSealer(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	sealer, err := userpool.NewUserSealer(key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	verified := true
+	user := &userpool.User{
+		Username:      "jane",
+		Email:         "jane@example.com",
+		EmailVerified: &verified,
+		Address:       &userpool.Address{Locality: "Wien"},
+		Enabled:       true,
+		Attributes:    map[string]string{"tenant": "acme"},
+	}
+
+	sealed, err := sealer.Seal(user)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if bytes.Contains(sealed, []byte("jane@example.com")) {
+		t.Errorf("expected the email not to be stored in plaintext")
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		opened, err := sealer.Open("jane", sealed)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(opened, user) {
+			t.Errorf("expected %+v, got %+v", user, opened)
+		}
+	})
+
+	t.Run("fresh nonce", func(t *testing.T) {
+		again, err := sealer.Seal(user)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if bytes.Equal(again, sealed) {
+			t.Errorf("expected sealing twice to differ")
+		}
+	})
+
+	t.Run("other username", func(t *testing.T) {
+		if _, err := sealer.Open("john", sealed); err == nil {
+			t.Errorf("expected opening under another username to fail")
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other, err := userpool.NewUserSealer(bytes.Repeat([]byte{8}, 32))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := other.Open("jane", sealed); err == nil {
+			t.Errorf("expected opening with another key to fail")
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(sealed)
+		tampered[len(tampered)-1] ^= 1
+		if _, err := sealer.Open("jane", tampered); err == nil {
+			t.Errorf("expected opening a modified user to fail")
+		}
+		if _, err := sealer.Open("jane", sealed[:4]); err == nil {
+			t.Errorf("expected opening a truncated user to fail")
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := userpool.NewUserSealer([]byte("short")); err == nil {
+			t.Errorf("expected a 5 byte key to be rejected")
+		}
+	})
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ValidateURL returns an *InvalidParameterError for the attribute field if
+// value is not an absolute http or https URL, as the picture and profile
+// attributes hold. An empty value is valid.
+func ValidateURL(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > maxValueLength {
+		return &InvalidParameterError{
+			Field:   field,
+			Message: fmt.Sprintf("URL is %d characters long, more than %d", len(value), maxValueLength),
+		}
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return &InvalidParameterError{Field: field, Message: fmt.Sprintf("%q is not a URL: %v", value, err)}
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &InvalidParameterError{
+			Field:   field,
+			Message: fmt.Sprintf("%q is not an absolute http or https URL", value),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "https", value: "https://example.com/avatars/jane.png"},
+		{name: "http with port", value: "http://intranet:8080/people/jane"},
+		{name: "relative", value: "/avatars/jane.png", wantErr: true},
+		{name: "no scheme", value: "example.com/jane", wantErr: true},
+		{name: "other scheme", value: "ftp://example.com/jane.png", wantErr: true},
+		{name: "data URL", value: "data:image/png;base64,iVBORw0KGgo=", wantErr: true},
+		{name: "malformed", value: "https://exa mple.com/%zz", wantErr: true},
+		{name: "too long", value: "https://example.com/" + strings.Repeat("a", 2048), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := userpool.ValidateURL("picture", tt.value)
+			if tt.wantErr != errors.Is(err, userpool.ErrInvalidParameter) {
+				t.Errorf("expected invalid parameter error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
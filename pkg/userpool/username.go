@@ -0,0 +1,60 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// UsernameConfiguration describes how users sign in to a user pool, as set by
+// its username and alias attributes
+type UsernameConfiguration struct {
+	// EmailUsername is set when users sign in with their email instead of a
+	// username
+	EmailUsername bool
+	// EmailAlias is set when users can sign in with their email as well as
+	// their username
+	EmailAlias bool
+}
+
+// ValidateUsername returns an error wrapping ErrInvalidUsername if a user with
+// username and email can't be created in a pool configured like c. Cognito
+// would reject these users too, or later fail with errors that don't point at
+// the username. The error doesn't contain the username or email.
+func (c UsernameConfiguration) ValidateUsername(username, email string) error {
+	if !looksLikeEmail(username) {
+		return nil
+	}
+	if c.EmailAlias {
+		return fmt.Errorf("%w: email format is not allowed when email is an alias", ErrInvalidUsername)
+	}
+	if c.EmailUsername && email != "" && !strings.EqualFold(username, email) {
+		return fmt.Errorf("%w: username must be the email users sign in with", ErrInvalidUsername)
+	}
+	return nil
+}
+
+// looksLikeEmail reports whether s is a bare email address
+func looksLikeEmail(s string) bool {
+	if !strings.Contains(s, "@") {
+		return false
+	}
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
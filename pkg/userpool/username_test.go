@@ -0,0 +1,54 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUsernameConfiguration_ValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   UsernameConfiguration
+		username string
+		email    string
+		wantErr  bool
+	}{
+		{name: "plain pool", username: "jane@example.com", email: "john@example.com"},
+		{name: "email alias", config: UsernameConfiguration{EmailAlias: true}, username: "jane",
+			email: "jane@example.com"},
+		{name: "email alias with email username", config: UsernameConfiguration{EmailAlias: true},
+			username: "jane@example.com", email: "jane@example.com", wantErr: true},
+		{name: "email username", config: UsernameConfiguration{EmailUsername: true},
+			username: "Jane@example.com", email: "jane@example.com"},
+		{name: "email username with other email", config: UsernameConfiguration{EmailUsername: true},
+			username: "john@example.com", email: "jane@example.com", wantErr: true},
+		{name: "email username with generated username", config: UsernameConfiguration{EmailUsername: true},
+			username: "5f0c6a8e-7d1b-4c1e-9b7a-2f4b3c6d8e9f", email: "jane@example.com"},
+		{name: "at sign but not an email", config: UsernameConfiguration{EmailAlias: true},
+			username: "jane@", email: "jane@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ValidateUsername(tt.username, tt.email)
+			if tt.wantErr != errors.Is(err, ErrInvalidUsername) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
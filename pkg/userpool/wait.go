@@ -0,0 +1,90 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Defaults of WaitOptions
+const (
+	DefaultWaitTimeout         = 10 * time.Second
+	DefaultWaitInitialInterval = 100 * time.Millisecond
+	DefaultWaitMaxInterval     = 2 * time.Second
+)
+
+// WaitOptions bounds the polling of WaitForUser. Zero fields use the defaults.
+type WaitOptions struct {
+	// Timeout is how long to poll before giving up
+	Timeout time.Duration
+	// InitialInterval is the delay after the first attempt. It doubles after
+	// every further attempt up to MaxInterval.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between attempts
+	MaxInterval time.Duration
+}
+
+// WaitForUser polls GetUser until the user exists, so a verification right
+// after a write doesn't trip over Cognito's eventual consistency. It returns
+// the user, or an error wrapping ErrUserNotFound if the user didn't appear
+// within the timeout. Errors other than ErrUserNotFound are returned at once.
+func WaitForUser(ctx context.Context, client Client, username string, opts WaitOptions) (*User, error) {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWaitTimeout
+	}
+	interval := opts.InitialInterval
+	if interval <= 0 {
+		interval = DefaultWaitInitialInterval
+	}
+	maxInterval := opts.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultWaitMaxInterval
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		user, err := client.GetUser(waitCtx, username)
+		switch {
+		case err == nil:
+			return user, nil
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case waitCtx.Err() != nil:
+			return nil, fmt.Errorf("user did not appear within %s: %w", timeout, ErrUserNotFound)
+		case !errors.Is(err, ErrUserNotFound):
+			return nil, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("user did not appear within %s: %w", timeout, ErrUserNotFound)
+		case <-timer.C:
+		}
+		interval = min(interval*2, maxInterval)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 Piotr Janik.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package userpool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"piotrjanik.dev/users/pkg/cognito"
+	"piotrjanik.dev/users/pkg/userpool"
+)
+
+// laggingClient reports users as missing for the first lag GetUser calls
+type laggingClient struct {
+	userpool.Client
+	lag   int
+	calls int
+}
+
+func (c *laggingClient) GetUser(ctx context.Context, username string) (*userpool.User, error) {
+	c.calls++
+	if c.calls <= c.lag {
+		return nil, userpool.ErrUserNotFound
+	}
+	return c.Client.GetUser(ctx, username)
+}
+
+func TestWaitForUser(t *testing.T) {
+	ctx := context.Background()
+	mock := cognito.NewMockClient()
+	if err := mock.CreateUser(ctx, &userpool.User{Username: "jane"}); err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	opts := userpool.WaitOptions{Timeout: time.Second, InitialInterval: time.Millisecond,
+		MaxInterval: 5 * time.Millisecond}
+
+	t.Run("returns the user once it appears", func(t *testing.T) {
+		client := &laggingClient{Client: mock, lag: 3}
+		user, err := userpool.WaitForUser(ctx, client, "jane", opts)
+		if err != nil || user.Username != "jane" {
+			t.Fatalf("expected jane, got %+v, %v", user, err)
+		}
+		if client.calls != 4 {
+			t.Errorf("expected 4 GetUser calls, got %d", client.calls)
+		}
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		opts := opts
+		opts.Timeout = 20 * time.Millisecond
+		_, err := userpool.WaitForUser(ctx, mock, "missing", opts)
+		if !errors.Is(err, userpool.ErrUserNotFound) {
+			t.Errorf("expected ErrUserNotFound, got %v", err)
+		}
+	})
+
+	t.Run("returns when the context is canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := userpool.WaitForUser(canceled, mock, "missing", opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	})
+}